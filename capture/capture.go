@@ -0,0 +1,643 @@
+// Package capture reconstructs multipath TCP connections from captured TCP
+// segments, such as those read from a pcap file.
+//
+// ReadSegments decodes the TCP segments of a capture, and Analyze groups
+// them into subflows, and subflows into multipath TCP connections, using the
+// keys exchanged in MP_CAPABLE options and the tokens carried by MP_JOIN
+// options.
+package capture
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/crypto"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// A Capture is the result of Analyze.
+type Capture struct {
+	// Connections are the multipath TCP connections, in the order their
+	// initial subflows began.
+	Connections []*Connection
+
+	// Orphans are subflows carrying multipath TCP options which could
+	// not be associated with a connection, such as subflows whose
+	// handshake was not captured, or which joined a connection whose
+	// initial subflow was not captured.
+	Orphans []*Subflow
+}
+
+// A Connection is a multipath TCP connection.  Its client is the endpoint
+// which sent the SYN of the initial subflow.
+type Connection struct {
+	// Version is the multipath TCP version negotiated by the handshake.
+	Version uint8
+
+	// ClientKey and ServerKey are the keys of each endpoint, or zero if
+	// they were not captured.
+	ClientKey, ServerKey uint64
+
+	// ClientToken and ServerToken are the tokens of each endpoint,
+	// derived from their keys, or zero if the key was not captured.
+	ClientToken, ServerToken mptcp.Token
+
+	// Checksum indicates that DSS checksums were negotiated.
+	Checksum bool
+
+	// Fallback indicates that the connection fell back to regular TCP.
+	Fallback bool
+
+	// Subflows are the subflows of the connection, beginning with the
+	// initial subflow, in the order they began.
+	Subflows []*Subflow
+
+	// Events are the notable events of the connection, in time order.
+	Events []Event
+}
+
+// An Auth is the result of verifying the HMAC of an MP_JOIN handshake or an
+// ADD_ADDR option.
+type Auth int
+
+// Possible Auth values.
+const (
+	// AuthUnknown indicates that the HMAC could not be verified, because
+	// the keys or the handshake were not captured.
+	AuthUnknown Auth = iota
+
+	// AuthValid indicates that the HMAC matches the connection's keys.
+	AuthValid
+
+	// AuthInvalid indicates that the HMAC does not match the
+	// connection's keys.
+	AuthInvalid
+)
+
+// String returns the string representation of an Auth.
+func (a Auth) String() string {
+	switch a {
+	case AuthUnknown:
+		return "unknown"
+	case AuthValid:
+		return "valid"
+	case AuthInvalid:
+		return "invalid"
+	default:
+		return fmt.Sprintf("Auth(%d)", int(a))
+	}
+}
+
+// A Subflow is a TCP connection which belongs to a multipath TCP connection.
+// Its client is the endpoint which sent its SYN.
+type Subflow struct {
+	// Client and Server are the endpoints of the subflow.  If the SYN
+	// was not captured, the client is the sender of the first segment.
+	Client, Server netip.AddrPort
+
+	// Initial indicates that the subflow is the initial subflow of its
+	// connection, which began with MP_CAPABLE.  Otherwise, it began with
+	// MP_JOIN.
+	Initial bool
+
+	// Token, ClientID, ServerID, and Backup are carried by the MP_JOIN
+	// handshake: the token of the connection, the address IDs of each
+	// endpoint, and whether the client requested a backup subflow.
+	Token              mptcp.Token
+	ClientID, ServerID uint8
+	Backup             bool
+
+	// Auth is the result of verifying the MP_JOIN handshake.
+	Auth Auth
+
+	// Start and End are the times of the first and last segments.
+	Start, End time.Time
+
+	// SYN and SYNACK are the segments of the handshake, or nil if they
+	// were not captured.
+	SYN, SYNACK *Segment
+
+	// Segments are all of the segments of the subflow, in time order.
+	Segments []*Segment
+
+	// forward indicates that the client of the subflow is also the
+	// client of its connection.
+	forward bool
+}
+
+// FromClient reports whether s was sent by the client of the subflow.
+func (sf *Subflow) FromClient(s *Segment) bool {
+	return s.Src == sf.Client
+}
+
+// An EventType is the type of an Event.
+type EventType int
+
+// Possible EventType values.
+const (
+	EventSubflow EventType = iota + 1
+	EventAddAddr
+	EventRemoveAddr
+	EventPriority
+	EventFallback
+	EventFastclose
+	EventReset
+)
+
+// String returns the string representation of an EventType.
+func (t EventType) String() string {
+	switch t {
+	case EventSubflow:
+		return "subflow"
+	case EventAddAddr:
+		return "add_addr"
+	case EventRemoveAddr:
+		return "remove_addr"
+	case EventPriority:
+		return "priority"
+	case EventFallback:
+		return "fallback"
+	case EventFastclose:
+		return "fastclose"
+	case EventReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// An Event is a notable event of a Connection, such as a new subflow, an
+// address announcement, or a reset.
+type Event struct {
+	// Time is the time of the segment which caused the event.
+	Time time.Time
+
+	// Type is the type of the event.
+	Type EventType
+
+	// Subflow is the subflow which carried the segment.
+	Subflow *Subflow
+
+	// From is the sender of the segment.
+	From netip.AddrPort
+
+	// Option is the multipath TCP option which caused the event, if any,
+	// such as *option.AddAddr for EventAddAddr, or *option.MPTCPRst for
+	// EventReset.
+	Option option.Option
+
+	// Auth is the result of verifying the HMAC of an ADD_ADDR.
+	Auth Auth
+
+	// Detail describes the event, such as the reason for a fallback.
+	Detail string
+}
+
+// Analyze groups segments into subflows and multipath TCP connections.
+// Segments which belong to TCP connections without multipath TCP options are
+// ignored.
+func Analyze(segs []*Segment) *Capture {
+	segs = append([]*Segment(nil), segs...)
+	sort.SliceStable(segs, func(i, j int) bool {
+		return segs[i].Time.Before(segs[j].Time)
+	})
+
+	var (
+		c     Capture
+		joins []*Subflow
+	)
+
+	for _, sf := range subflows(segs) {
+		switch synOption(sf).(type) {
+		case *option.MPCapable:
+			sf.Initial = true
+			sf.forward = true
+			c.Connections = append(c.Connections, newConnection(sf))
+		case *option.MPJoin:
+			joins = append(joins, sf)
+		default:
+			if hasOptions(sf) {
+				c.Orphans = append(c.Orphans, sf)
+			}
+		}
+	}
+
+	// Each endpoint's token identifies the connection in MP_JOIN SYNs
+	// sent to that endpoint.
+	tokens := make(map[mptcp.Token]*Connection)
+	for _, conn := range c.Connections {
+		if conn.ClientKey != 0 {
+			tokens[conn.ClientToken] = conn
+		}
+		if conn.ServerKey != 0 {
+			tokens[conn.ServerToken] = conn
+		}
+	}
+
+	for _, sf := range joins {
+		join(sf)
+
+		conn, ok := tokens[sf.Token]
+		if !ok {
+			c.Orphans = append(c.Orphans, sf)
+			continue
+		}
+
+		sf.forward = conn.ServerKey != 0 && sf.Token == conn.ServerToken
+		verifyJoin(conn, sf)
+		conn.Subflows = append(conn.Subflows, sf)
+	}
+
+	for _, conn := range c.Connections {
+		sort.SliceStable(conn.Subflows, func(i, j int) bool {
+			return conn.Subflows[i].Start.Before(conn.Subflows[j].Start)
+		})
+
+		conn.Events = events(conn)
+	}
+
+	return &c
+}
+
+// A flowKey identifies a TCP connection regardless of direction.
+type flowKey struct {
+	a, b netip.AddrPort
+}
+
+// newFlowKey creates a flowKey for a segment.
+func newFlowKey(s *Segment) flowKey {
+	a, b := s.Src, s.Dst
+	if c := a.Addr().Compare(b.Addr()); c > 0 || (c == 0 && a.Port() > b.Port()) {
+		a, b = b, a
+	}
+
+	return flowKey{a: a, b: b}
+}
+
+// subflows groups segments into TCP connections, in the order they began.
+// A new SYN on the same addresses and ports begins a new connection.
+func subflows(segs []*Segment) []*Subflow {
+	var (
+		sfs   []*Subflow
+		flows = make(map[flowKey]*Subflow)
+	)
+
+	for _, s := range segs {
+		k := newFlowKey(s)
+		syn := s.Has(FlagSYN) && !s.Has(FlagACK)
+
+		sf, ok := flows[k]
+		if !ok || (syn && (sf.SYN == nil || sf.SYN.Seq != s.Seq)) {
+			sf = &Subflow{
+				Client: s.Src,
+				Server: s.Dst,
+				Start:  s.Time,
+			}
+			if s.Has(FlagSYN | FlagACK) {
+				sf.Client, sf.Server = s.Dst, s.Src
+			}
+
+			flows[k] = sf
+			sfs = append(sfs, sf)
+		}
+
+		switch {
+		case syn && sf.SYN == nil:
+			sf.SYN = s
+		case s.Has(FlagSYN|FlagACK) && sf.SYNACK == nil && !sf.FromClient(s):
+			sf.SYNACK = s
+		}
+
+		sf.End = s.Time
+		sf.Segments = append(sf.Segments, s)
+	}
+
+	return sfs
+}
+
+// synOption returns the MP_CAPABLE or MP_JOIN option of the SYN of a
+// subflow, if any.
+func synOption(sf *Subflow) option.Option {
+	if sf.SYN == nil {
+		return nil
+	}
+
+	for _, o := range sf.SYN.Options {
+		switch o := o.(type) {
+		case *option.MPCapable:
+			return o
+		case *option.MPJoin:
+			if o.Form == option.JoinSYN {
+				return o
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasOptions reports whether any segment of a subflow carries multipath TCP
+// options.
+func hasOptions(sf *Subflow) bool {
+	for _, s := range sf.Segments {
+		if len(s.Options) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newConnection creates a Connection from its initial subflow, using the
+// MP_CAPABLE options of its segments.
+func newConnection(sf *Subflow) *Connection {
+	conn := &Connection{Subflows: []*Subflow{sf}}
+
+	for _, s := range sf.Segments {
+		for _, o := range s.Options {
+			c, ok := o.(*option.MPCapable)
+			if !ok {
+				continue
+			}
+
+			if s == sf.SYN || s == sf.SYNACK {
+				// The version of the SYN/ACK is the negotiated
+				// version.
+				conn.Version = c.Version
+				conn.Checksum = conn.Checksum || c.Flags&option.CapableChecksum != 0
+			}
+
+			switch {
+			case !sf.FromClient(s):
+				if c.HasSenderKey {
+					conn.ServerKey = c.SenderKey
+				}
+			case c.HasSenderKey:
+				conn.ClientKey = c.SenderKey
+				if c.HasReceiverKey && conn.ServerKey == 0 {
+					conn.ServerKey = c.ReceiverKey
+				}
+			}
+		}
+	}
+
+	if conn.ClientKey != 0 {
+		conn.ClientToken = crypto.Token(conn.Version, conn.ClientKey)
+	}
+	if conn.ServerKey != 0 {
+		conn.ServerToken = crypto.Token(conn.Version, conn.ServerKey)
+	}
+
+	return conn
+}
+
+// join sets the fields of a subflow from its MP_JOIN handshake.
+func join(sf *Subflow) {
+	if o, ok := synOption(sf).(*option.MPJoin); ok {
+		sf.Token = o.Token
+		sf.ClientID = o.AddressID
+		sf.Backup = o.Backup
+	}
+
+	if o := joinOption(sf.SYNACK, option.JoinSYNACK); o != nil {
+		sf.ServerID = o.AddressID
+	}
+}
+
+// joinOption returns the MP_JOIN option with form f carried by s, or nil if
+// none exists.
+func joinOption(s *Segment, f option.JoinForm) *option.MPJoin {
+	if s == nil {
+		return nil
+	}
+
+	for _, o := range s.Options {
+		if j, ok := o.(*option.MPJoin); ok && j.Form == f {
+			return j
+		}
+	}
+
+	return nil
+}
+
+// verifyJoin verifies the HMACs of the MP_JOIN handshake of sf.
+func verifyJoin(conn *Connection, sf *Subflow) {
+	keyA, keyB := conn.keys(sf.forward)
+	if keyA == 0 || keyB == 0 {
+		return
+	}
+
+	syn := joinOption(sf.SYN, option.JoinSYN)
+	synack := joinOption(sf.SYNACK, option.JoinSYNACK)
+	if syn == nil || synack == nil {
+		return
+	}
+
+	if !crypto.VerifyJoinSYNACK(conn.Version, keyA, keyB, syn.Nonce, synack.Nonce, synack.HMAC) {
+		sf.Auth = AuthInvalid
+		return
+	}
+
+	for _, s := range sf.Segments {
+		if !sf.FromClient(s) {
+			continue
+		}
+
+		if ack := joinOption(s, option.JoinACK); ack != nil {
+			sf.Auth = AuthInvalid
+			if crypto.VerifyJoinACK(conn.Version, keyA, keyB, syn.Nonce, synack.Nonce, ack.HMAC) {
+				sf.Auth = AuthValid
+			}
+
+			return
+		}
+	}
+}
+
+// keys returns the key of the connection's client followed by the key of its
+// server if forward is set, or the reverse otherwise.
+func (conn *Connection) keys(forward bool) (uint64, uint64) {
+	if forward {
+		return conn.ClientKey, conn.ServerKey
+	}
+
+	return conn.ServerKey, conn.ClientKey
+}
+
+// events finds the notable events of a connection.
+func events(conn *Connection) []Event {
+	var es []Event
+	add := func(e Event) { es = append(es, e) }
+
+	initial := conn.Subflows[0]
+	if s := initial.SYNACK; s != nil && synackFallback(s) {
+		conn.Fallback = true
+		add(Event{
+			Time:    s.Time,
+			Type:    EventFallback,
+			Subflow: initial,
+			From:    s.Src,
+			Detail:  "SYN/ACK without MP_CAPABLE",
+		})
+	}
+
+	for _, sf := range conn.Subflows {
+		detail := "join"
+		if sf.Initial {
+			detail = "initial"
+		}
+
+		add(Event{
+			Time:    sf.Start,
+			Type:    EventSubflow,
+			Subflow: sf,
+			From:    sf.Client,
+			Detail:  detail,
+		})
+
+		var (
+			infinite bool
+
+			// mapped contains the subflow sequence numbers mapped
+			// by each endpoint of the subflow, indexed by whether
+			// the client sent the mapping.
+			mapped = map[bool]*spans{true: new(spans), false: new(spans)}
+		)
+		for _, s := range sf.Segments {
+			var (
+				reset bool
+				m     = mapped[sf.FromClient(s)]
+			)
+			for _, o := range s.Options {
+				e := Event{
+					Time:    s.Time,
+					Subflow: sf,
+					From:    s.Src,
+					Option:  o,
+				}
+
+				switch o := o.(type) {
+				case *option.MPCapable:
+					if o.HasDataLength && o.DataLength > 0 {
+						m.add(1, 1+uint64(o.DataLength))
+					}
+					continue
+				case *option.DSS:
+					if o.HasMapping && o.DataLength > 0 {
+						ssn := uint64(o.SubflowSeq)
+						m.add(ssn, ssn+uint64(o.DataLength))
+					}
+					if !o.HasMapping || o.DataLength != 0 || infinite {
+						continue
+					}
+
+					infinite = true
+					e.Type = EventFallback
+					e.Detail = "infinite mapping"
+				case *option.MPFail:
+					e.Type = EventFallback
+					e.Detail = "MP_FAIL"
+				case *option.AddAddr:
+					e.Type = EventAddAddr
+					e.Auth = conn.verifyAddAddr(sf, s, o)
+				case *option.RemoveAddr:
+					e.Type = EventRemoveAddr
+				case *option.MPPrio:
+					e.Type = EventPriority
+				case *option.MPFastclose:
+					e.Type = EventFastclose
+				case *option.MPTCPRst:
+					reset = true
+					e.Type = EventReset
+					e.Detail = o.Reason.String()
+				default:
+					continue
+				}
+
+				if e.Type == EventFallback {
+					conn.Fallback = true
+				}
+				add(e)
+			}
+
+			if s.Has(FlagRST) && !reset {
+				add(Event{
+					Time:    s.Time,
+					Type:    EventReset,
+					Subflow: sf,
+					From:    s.Src,
+				})
+			}
+
+			// Data on the initial subflow must be mapped by a DSS
+			// option, or by MP_CAPABLE in version 1, unless the
+			// connection has fallen back to regular TCP.  One
+			// mapping may cover many segments, so only data outside
+			// every mapping seen so far is a fallback.
+			if sf.Initial && s.Len > 0 && !conn.Fallback && !sf.mapped(s, *m) {
+				conn.Fallback = true
+				add(Event{
+					Time:    s.Time,
+					Type:    EventFallback,
+					Subflow: sf,
+					From:    s.Src,
+					Detail:  "data without mapping",
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(es, func(i, j int) bool {
+		return es[i].Time.Before(es[j].Time)
+	})
+
+	return es
+}
+
+// mapped reports whether the data of segment s of sf lies within the mapped
+// subflow sequence numbers m of its sender.  If the sender's initial sequence
+// number was not captured, any mapping is assumed to cover the data.
+func (sf *Subflow) mapped(s *Segment, m spans) bool {
+	isn := sf.isn(sf.FromClient(s))
+	if isn == nil {
+		return len(m) > 0
+	}
+
+	start := relSeq(isn, s)
+	return len(m.covered(start, start+uint64(s.Len))) > 0
+}
+
+// synackFallback reports whether the SYN/ACK of the initial subflow declined
+// multipath TCP.
+func synackFallback(s *Segment) bool {
+	for _, o := range s.Options {
+		if _, ok := o.(*option.MPCapable); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// verifyAddAddr verifies the HMAC of an ADD_ADDR option carried by segment s
+// of subflow sf.
+func (conn *Connection) verifyAddAddr(sf *Subflow, s *Segment, o *option.AddAddr) Auth {
+	if o.Version == 0 || o.Echo {
+		return AuthUnknown
+	}
+
+	// The sender's key is first.
+	keyA, keyB := conn.keys(sf.forward == sf.FromClient(s))
+	if keyA == 0 || keyB == 0 {
+		return AuthUnknown
+	}
+
+	if crypto.VerifyAddAddr(keyA, keyB, o.AddressID, o.Address, o.Port, o.HMAC) {
+		return AuthValid
+	}
+
+	return AuthInvalid
+}
@@ -0,0 +1,271 @@
+package capture
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp/crypto"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// Keys and addresses of a test connection.
+const (
+	clientKey = 0x62fdcce14201dfbc
+	serverKey = 0x6d0cb517e2512744
+)
+
+var (
+	t0 = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	client  = netip.MustParseAddrPort("192.0.2.1:50000")
+	client2 = netip.MustParseAddrPort("192.0.2.2:50001")
+	server  = netip.MustParseAddrPort("198.51.100.1:443")
+)
+
+// seg creates a segment sent at t0 plus ms milliseconds.
+func seg(ms int, src, dst netip.AddrPort, seq uint32, flags Flags, n int, opts ...option.Option) *Segment {
+	return &Segment{
+		Time:    t0.Add(time.Duration(ms) * time.Millisecond),
+		Src:     src,
+		Dst:     dst,
+		Seq:     seq,
+		Flags:   flags,
+		Options: opts,
+		Len:     n,
+	}
+}
+
+// testSegments returns the segments of a connection with a join, a
+// connection which falls back, an orphaned subflow, and a regular TCP
+// connection.
+func testSegments() []*Segment {
+	const (
+		nonceA = 0x93f468a4
+		nonceB = 0x92e65dbf
+	)
+
+	var (
+		other    = netip.MustParseAddrPort("192.0.2.1:50002")
+		plain    = netip.MustParseAddrPort("192.0.2.1:50003")
+		orphan   = netip.MustParseAddrPort("192.0.2.1:50004")
+		stranger = netip.MustParseAddrPort("192.0.2.2:50005")
+		addr     = netip.MustParseAddr("198.51.100.2")
+	)
+
+	return []*Segment{
+		// Initial subflow handshake.
+		seg(0, client, server, 100, FlagSYN, 0,
+			&option.MPCapable{Version: 1, Flags: option.CapableHMACSHA}),
+		seg(1, server, client, 500, FlagSYN|FlagACK, 0,
+			&option.MPCapable{Version: 1, Flags: option.CapableHMACSHA, SenderKey: serverKey, HasSenderKey: true}),
+		seg(2, client, server, 101, FlagACK, 0,
+			&option.MPCapable{
+				Version:        1,
+				Flags:          option.CapableHMACSHA,
+				SenderKey:      clientKey,
+				HasSenderKey:   true,
+				ReceiverKey:    serverKey,
+				HasReceiverKey: true,
+			}),
+		// The server announces an address.
+		seg(3, server, client, 501, FlagACK, 0,
+			&option.AddAddr{
+				Version:   1,
+				AddressID: 1,
+				Address:   addr,
+				HMAC:      crypto.AddAddrHMAC(serverKey, clientKey, 1, addr, 0),
+			}),
+		// Data on the initial subflow.
+		seg(4, client, server, 101, FlagACK|FlagPSH, 100,
+			&option.DSS{HasMapping: true, DSN: 1, SubflowSeq: 1, DataLength: 100}),
+		// A join from another client address.
+		seg(5, client2, server, 200, FlagSYN, 0,
+			&option.MPJoin{
+				Form:      option.JoinSYN,
+				Backup:    true,
+				AddressID: 2,
+				Token:     crypto.Token(1, serverKey),
+				Nonce:     nonceA,
+			}),
+		seg(6, server, client2, 600, FlagSYN|FlagACK, 0,
+			&option.MPJoin{
+				Form:  option.JoinSYNACK,
+				Nonce: nonceB,
+				HMAC:  crypto.JoinSYNACKHMAC(1, clientKey, serverKey, nonceA, nonceB),
+			}),
+		seg(7, client2, server, 201, FlagACK, 0,
+			&option.MPJoin{
+				Form: option.JoinACK,
+				HMAC: crypto.JoinACKHMAC(1, clientKey, serverKey, nonceA, nonceB),
+			}),
+		seg(8, client2, server, 201, FlagACK, 0, &option.MPPrio{}),
+		// A join with an unknown token.
+		seg(9, stranger, server, 300, FlagSYN, 0,
+			&option.MPJoin{Form: option.JoinSYN, Token: 1}),
+		// A connection which falls back to regular TCP.
+		seg(10, other, server, 400, FlagSYN, 0,
+			&option.MPCapable{Version: 1}),
+		seg(11, server, other, 700, FlagSYN|FlagACK, 0),
+		// A subflow whose handshake was not captured.
+		seg(12, orphan, server, 900, FlagACK, 10,
+			&option.DSS{HasDataACK: true, DataACK: 1}),
+		// Regular TCP.
+		seg(13, plain, server, 800, FlagSYN, 0),
+		// The joined subflow is reset.
+		seg(14, server, client2, 601, FlagRST, 0,
+			&option.MPTCPRst{Reason: option.ResetAdminProhibited}),
+	}
+}
+
+// TestAnalyze verifies that Analyze groups subflows into connections and
+// finds their events.
+func TestAnalyze(t *testing.T) {
+	c := Analyze(testSegments())
+
+	if want, got := 2, len(c.Connections); want != got {
+		t.Fatalf("unexpected number of connections:\n- want: %d\n-  got: %d", want, got)
+	}
+
+	conn := c.Connections[0]
+	if conn.Version != 1 || conn.ClientKey != clientKey || conn.ServerKey != serverKey ||
+		conn.ClientToken != crypto.Token(1, clientKey) || conn.ServerToken != crypto.Token(1, serverKey) ||
+		conn.Fallback {
+		t.Fatalf("unexpected connection: %+v", conn)
+	}
+
+	if want, got := 2, len(conn.Subflows); want != got {
+		t.Fatalf("unexpected number of subflows:\n- want: %d\n-  got: %d", want, got)
+	}
+
+	initial, joined := conn.Subflows[0], conn.Subflows[1]
+	if !initial.Initial || initial.Client != client || initial.Server != server || len(initial.Segments) != 5 {
+		t.Fatalf("unexpected initial subflow: %+v", initial)
+	}
+	if joined.Initial || joined.Client != client2 || joined.Token != conn.ServerToken ||
+		joined.ClientID != 2 || !joined.Backup || joined.Auth != AuthValid || len(joined.Segments) != 5 {
+		t.Fatalf("unexpected joined subflow: %+v", joined)
+	}
+
+	type event struct {
+		Type    EventType
+		Subflow *Subflow
+		Auth    Auth
+		Detail  string
+	}
+
+	var got []event
+	for _, e := range conn.Events {
+		got = append(got, event{e.Type, e.Subflow, e.Auth, e.Detail})
+	}
+
+	want := []event{
+		{EventSubflow, initial, AuthUnknown, "initial"},
+		{EventAddAddr, initial, AuthValid, ""},
+		{EventSubflow, joined, AuthUnknown, "join"},
+		{EventPriority, joined, AuthUnknown, ""},
+		{EventReset, joined, AuthUnknown, "administratively prohibited"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected events:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	fallback := c.Connections[1]
+	if !fallback.Fallback || len(fallback.Events) != 2 || fallback.Events[0].Type != EventSubflow ||
+		fallback.Events[1].Detail != "SYN/ACK without MP_CAPABLE" {
+		t.Fatalf("unexpected fallback connection: %+v", fallback)
+	}
+
+	if want, got := 2, len(c.Orphans); want != got {
+		t.Fatalf("unexpected number of orphans:\n- want: %d\n-  got: %d", want, got)
+	}
+}
+
+// TestAnalyzeInvalidJoin verifies that Analyze reports MP_JOIN handshakes
+// whose HMACs do not match the connection's keys.
+func TestAnalyzeInvalidJoin(t *testing.T) {
+	segs := testSegments()
+	for _, s := range segs {
+		for _, o := range s.Options {
+			if j, ok := o.(*option.MPJoin); ok && j.Form == option.JoinACK {
+				j.HMAC = make([]byte, len(j.HMAC))
+			}
+		}
+	}
+
+	if got := Analyze(segs).Connections[0].Subflows[1].Auth; got != AuthInvalid {
+		t.Fatalf("unexpected join authentication: %v", got)
+	}
+}
+
+// TestAnalyzeDataWithoutMapping verifies that Analyze reports a fallback when
+// data on the initial subflow is outside every mapping, but not when one
+// mapping covers several segments.
+func TestAnalyzeDataWithoutMapping(t *testing.T) {
+	mapping := func(ssn uint32, n uint16) *option.DSS {
+		return &option.DSS{HasMapping: true, DSN: 1, SubflowSeq: ssn, DataLength: n}
+	}
+
+	var tests = []struct {
+		desc     string
+		segs     []*Segment
+		fallback bool
+	}{
+		{
+			desc: "no mapping",
+			segs: []*Segment{
+				seg(4, client, server, 101, FlagACK, 100),
+			},
+			fallback: true,
+		},
+		{
+			desc: "mapping across segments",
+			segs: []*Segment{
+				seg(4, client, server, 101, FlagACK, 100, mapping(1, 200)),
+				seg(5, client, server, 201, FlagACK, 100),
+			},
+		},
+		{
+			desc: "MP_CAPABLE mapping across segments",
+			segs: []*Segment{
+				seg(4, client, server, 101, FlagACK, 100,
+					&option.MPCapable{Version: 1, DataLength: 200, HasDataLength: true}),
+				seg(5, client, server, 201, FlagACK, 100),
+			},
+		},
+		{
+			desc: "data after mapping",
+			segs: []*Segment{
+				seg(4, client, server, 101, FlagACK, 100, mapping(1, 200)),
+				seg(5, client, server, 301, FlagACK, 100),
+			},
+			fallback: true,
+		},
+		{
+			desc: "mapping from the other endpoint",
+			segs: []*Segment{
+				seg(4, server, client, 501, FlagACK, 100, mapping(1, 200)),
+				seg(5, client, server, 101, FlagACK, 100),
+			},
+			fallback: true,
+		},
+	}
+
+	for i, tt := range tests {
+		segs := append(testSegments()[:4:4], tt.segs...)
+		conn := Analyze(segs).Connections[0]
+
+		if conn.Fallback != tt.fallback {
+			t.Fatalf("[%02d] test %q, unexpected fallback: %v", i, tt.desc, conn.Fallback)
+		}
+		if !tt.fallback {
+			continue
+		}
+
+		last := conn.Events[len(conn.Events)-1]
+		if last.Type != EventFallback || last.Detail != "data without mapping" {
+			t.Fatalf("[%02d] test %q, unexpected event: %+v", i, tt.desc, last)
+		}
+	}
+}
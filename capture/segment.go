@@ -0,0 +1,353 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/mptcp/option"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// Flags are the control flags of a TCP segment.
+type Flags uint8
+
+// Possible Flags values.
+const (
+	FlagFIN Flags = 1 << iota
+	FlagSYN
+	FlagRST
+	FlagPSH
+	FlagACK
+	FlagURG
+	FlagECE
+	FlagCWR
+)
+
+// String returns the flags of a segment in the form used by tcpdump, such as
+// "S." for a SYN/ACK.
+func (f Flags) String() string {
+	var b strings.Builder
+	for _, fl := range []struct {
+		f Flags
+		c byte
+	}{
+		{FlagFIN, 'F'},
+		{FlagSYN, 'S'},
+		{FlagRST, 'R'},
+		{FlagPSH, 'P'},
+		{FlagURG, 'U'},
+		{FlagECE, 'E'},
+		{FlagCWR, 'W'},
+		{FlagACK, '.'},
+	} {
+		if f&fl.f != 0 {
+			b.WriteByte(fl.c)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "none"
+	}
+
+	return b.String()
+}
+
+// A Segment is a TCP segment decoded from a captured packet.
+type Segment struct {
+	// Time is the time the packet was captured.
+	Time time.Time
+
+	// Src and Dst are the source and destination of the segment.
+	Src, Dst netip.AddrPort
+
+	// Seq, Ack, Flags, and Window are fields of the TCP header.
+	Seq, Ack uint32
+	Flags    Flags
+	Window   uint16
+
+	// Options are the multipath TCP options of the segment.  If they are
+	// malformed, Options is empty and OptionErr is set.
+	Options   []option.Option
+	OptionErr error
+
+	// Payload is the captured payload of the segment, which is shorter
+	// than Len if the capture was truncated.
+	Payload []byte
+
+	// Len is the length of the payload of the segment.
+	Len int
+}
+
+// Has reports whether all of flags are set on the segment.
+func (s *Segment) Has(flags Flags) bool {
+	return s.Flags&flags == flags
+}
+
+// ErrNotTCP is returned by Decode for packets which do not contain a TCP
+// segment, or contain only a fragment of one.
+var ErrNotTCP = errors.New("capture: not a TCP segment")
+
+// errTruncated is returned when a packet is too short for its headers.
+var errTruncated = errors.New("capture: truncated packet")
+
+// Ethernet types and IP protocol numbers used by Decode.
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+	protoTCP      = 6
+	protoHopByHop = 0
+	protoRouting  = 43
+	protoFragment = 44
+	protoAuth     = 51
+	protoDestOpts = 60
+	tcpHeaderLen  = 20
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	ethernetLen   = 14
+	linuxSLLLen   = 16
+	linuxSLL2Len  = 20
+	nullHeaderLen = 4
+)
+
+// Decode decodes a TCP segment from a packet with link type lt.  Packets
+// which do not contain a TCP segment return ErrNotTCP.
+func Decode(lt pcap.LinkType, b []byte) (*Segment, error) {
+	ip, err := network(lt, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(ip) == 0 {
+		return nil, ErrNotTCP
+	}
+
+	var (
+		src, dst netip.Addr
+		tcp      []byte
+		n        int
+	)
+
+	switch ip[0] >> 4 {
+	case 4:
+		src, dst, tcp, n, err = ipv4(ip)
+	case 6:
+		src, dst, tcp, n, err = ipv6(ip)
+	default:
+		return nil, ErrNotTCP
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTCP(src, dst, tcp, n)
+}
+
+// network returns the network layer of a packet with link type lt, or an
+// empty slice if it is not IPv4 or IPv6.
+func network(lt pcap.LinkType, b []byte) ([]byte, error) {
+	switch lt {
+	case pcap.LinkTypeEthernet:
+		if len(b) < ethernetLen {
+			return nil, errTruncated
+		}
+
+		et := binary.BigEndian.Uint16(b[12:14])
+		b = b[ethernetLen:]
+		for et == etherTypeVLAN || et == etherTypeQinQ {
+			if len(b) < 4 {
+				return nil, errTruncated
+			}
+
+			et = binary.BigEndian.Uint16(b[2:4])
+			b = b[4:]
+		}
+
+		return ipEtherType(et, b), nil
+	case pcap.LinkTypeLinuxSLL:
+		if len(b) < linuxSLLLen {
+			return nil, errTruncated
+		}
+
+		return ipEtherType(binary.BigEndian.Uint16(b[14:16]), b[linuxSLLLen:]), nil
+	case pcap.LinkTypeLinuxSLL2:
+		if len(b) < linuxSLL2Len {
+			return nil, errTruncated
+		}
+
+		return ipEtherType(binary.BigEndian.Uint16(b[0:2]), b[linuxSLL2Len:]), nil
+	case pcap.LinkTypeNull, pcap.LinkTypeLoop:
+		if len(b) < nullHeaderLen {
+			return nil, errTruncated
+		}
+
+		// The address family is in the byte order of the capturing
+		// host for NULL, and network byte order for LOOP.  All
+		// families of interest are less than 256, so accept either.
+		fam := binary.BigEndian.Uint32(b[0:4])
+		if fam > 0xff {
+			fam = binary.LittleEndian.Uint32(b[0:4])
+		}
+		if fam > 0xff {
+			return nil, nil
+		}
+
+		return b[nullHeaderLen:], nil
+	case pcap.LinkTypeRaw, pcap.LinkTypeIPv4, pcap.LinkTypeIPv6:
+		return b, nil
+	default:
+		return nil, fmt.Errorf("capture: unsupported link type %v", lt)
+	}
+}
+
+// ipEtherType returns b if et is the Ethernet type of IPv4 or IPv6, or nil
+// otherwise.
+func ipEtherType(et uint16, b []byte) []byte {
+	if et != etherTypeIPv4 && et != etherTypeIPv6 {
+		return nil
+	}
+
+	return b
+}
+
+// ipv4 decodes an IPv4 header, and returns the addresses, the captured
+// payload, and the length of the payload.
+func ipv4(b []byte) (netip.Addr, netip.Addr, []byte, int, error) {
+	var zero netip.Addr
+	if len(b) < ipv4HeaderLen {
+		return zero, zero, nil, 0, errTruncated
+	}
+
+	hl := int(b[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(b[2:4]))
+	if hl < ipv4HeaderLen || len(b) < hl || total < hl {
+		return zero, zero, nil, 0, errTruncated
+	}
+
+	// Only the first fragment carries the TCP header, and even then the
+	// segment is incomplete.
+	if b[9] != protoTCP || binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 {
+		return zero, zero, nil, 0, ErrNotTCP
+	}
+
+	src, _ := netip.AddrFromSlice(b[12:16])
+	dst, _ := netip.AddrFromSlice(b[16:20])
+
+	// Trim any link-layer padding.
+	if len(b) > total {
+		b = b[:total]
+	}
+
+	return src, dst, b[hl:], total - hl, nil
+}
+
+// ipv6 decodes an IPv6 header and any extension headers, and returns the
+// addresses, the captured payload, and the length of the payload.
+func ipv6(b []byte) (netip.Addr, netip.Addr, []byte, int, error) {
+	var zero netip.Addr
+	if len(b) < ipv6HeaderLen {
+		return zero, zero, nil, 0, errTruncated
+	}
+
+	n := int(binary.BigEndian.Uint16(b[4:6]))
+	next := b[6]
+	src, _ := netip.AddrFromSlice(b[8:24])
+	dst, _ := netip.AddrFromSlice(b[24:40])
+
+	b = b[ipv6HeaderLen:]
+	if len(b) > n {
+		b = b[:n]
+	}
+
+	for next != protoTCP {
+		if len(b) < 8 {
+			return zero, zero, nil, 0, ErrNotTCP
+		}
+
+		var hl int
+		switch next {
+		case protoHopByHop, protoRouting, protoDestOpts:
+			hl = (int(b[1]) + 1) * 8
+		case protoAuth:
+			hl = (int(b[1]) + 2) * 4
+		case protoFragment:
+			if binary.BigEndian.Uint16(b[2:4])&0xfff8 != 0 {
+				return zero, zero, nil, 0, ErrNotTCP
+			}
+			hl = 8
+		default:
+			return zero, zero, nil, 0, ErrNotTCP
+		}
+		if len(b) < hl {
+			return zero, zero, nil, 0, errTruncated
+		}
+
+		next = b[0]
+		b = b[hl:]
+		n -= hl
+	}
+
+	return src, dst, b, n, nil
+}
+
+// decodeTCP decodes a TCP header, given the captured IP payload b and the
+// length of the payload n.
+func decodeTCP(src, dst netip.Addr, b []byte, n int) (*Segment, error) {
+	if len(b) < tcpHeaderLen {
+		return nil, errTruncated
+	}
+
+	off := int(b[12]>>4) * 4
+	if off < tcpHeaderLen || len(b) < off || n < off {
+		return nil, errTruncated
+	}
+
+	s := &Segment{
+		Src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(b[0:2])),
+		Dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(b[2:4])),
+		Seq:     binary.BigEndian.Uint32(b[4:8]),
+		Ack:     binary.BigEndian.Uint32(b[8:12]),
+		Flags:   Flags(b[13]),
+		Window:  binary.BigEndian.Uint16(b[14:16]),
+		Payload: b[off:],
+		Len:     n - off,
+	}
+
+	s.Options, s.OptionErr = option.Options(b[tcpHeaderLen:off])
+	return s, nil
+}
+
+// ReadSegments reads all TCP segments from r.  Packets which do not contain
+// a TCP segment are skipped, and the number of malformed packets which were
+// skipped is returned.
+func ReadSegments(r *pcap.Reader) ([]*Segment, int, error) {
+	var (
+		segs []*Segment
+		bad  int
+	)
+
+	for {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			return segs, bad, nil
+		}
+		if err != nil {
+			return nil, bad, err
+		}
+
+		s, err := Decode(p.LinkType, p.Data)
+		switch {
+		case err == nil:
+			s.Time = p.Time
+			segs = append(segs, s)
+		case errors.Is(err, ErrNotTCP):
+		default:
+			bad++
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/mptcp/option"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// testPacket builds an IP packet containing a TCP segment from src to dst
+// with flags, TCP options opts, and payload.
+func testPacket(src, dst netip.AddrPort, flags Flags, opts, payload []byte) []byte {
+	for len(opts)%4 != 0 {
+		opts = append(opts, 1)
+	}
+
+	tcp := make([]byte, tcpHeaderLen, tcpHeaderLen+len(opts)+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:2], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], 1000)
+	binary.BigEndian.PutUint32(tcp[8:12], 2000)
+	tcp[12] = byte((tcpHeaderLen+len(opts))/4) << 4
+	tcp[13] = byte(flags)
+	binary.BigEndian.PutUint16(tcp[14:16], 65535)
+	tcp = append(append(tcp, opts...), payload...)
+
+	if src.Addr().Is4() {
+		ip := make([]byte, ipv4HeaderLen)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(ipv4HeaderLen+len(tcp)))
+		ip[8] = 64
+		ip[9] = protoTCP
+		copy(ip[12:16], src.Addr().AsSlice())
+		copy(ip[16:20], dst.Addr().AsSlice())
+
+		return append(ip, tcp...)
+	}
+
+	ip := make([]byte, ipv6HeaderLen)
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+	ip[6] = protoTCP
+	ip[7] = 64
+	copy(ip[8:24], src.Addr().AsSlice())
+	copy(ip[24:40], dst.Addr().AsSlice())
+
+	return append(ip, tcp...)
+}
+
+// TestDecode verifies that Decode decodes TCP segments from each supported
+// link type.
+func TestDecode(t *testing.T) {
+	var (
+		src4 = netip.MustParseAddrPort("192.0.2.1:50000")
+		dst4 = netip.MustParseAddrPort("198.51.100.1:443")
+		src6 = netip.MustParseAddrPort("[2001:db8::1]:50000")
+		dst6 = netip.MustParseAddrPort("[2001:db8::2]:443")
+
+		// MSS followed by an MP_CAPABLE v1 SYN.
+		opts    = []byte{2, 4, 0x05, 0xb4, 30, 4, 0x01, 0x01}
+		capable = &option.MPCapable{Version: 1, Flags: option.CapableHMACSHA}
+		payload = []byte("hello")
+
+		ip4 = testPacket(src4, dst4, FlagSYN, opts, payload)
+		ip6 = testPacket(src6, dst6, FlagSYN, opts, payload)
+	)
+
+	// An IPv6 packet with a destination options extension header.
+	ext6 := append([]byte(nil), ip6[:ipv6HeaderLen]...)
+	ext6[6] = protoDestOpts
+	binary.BigEndian.PutUint16(ext6[4:6], uint16(len(ip6)-ipv6HeaderLen+8))
+	ext6 = append(ext6, protoTCP, 0, 1, 4, 0, 0, 0, 0)
+	ext6 = append(ext6, ip6[ipv6HeaderLen:]...)
+
+	ether := func(et uint16, b []byte) []byte {
+		h := make([]byte, 12)
+		return append(binary.BigEndian.AppendUint16(h, et), b...)
+	}
+
+	var tests = []struct {
+		desc string
+		lt   pcap.LinkType
+		b    []byte
+		v6   bool
+	}{
+		{
+			desc: "raw IPv4",
+			lt:   pcap.LinkTypeRaw,
+			b:    ip4,
+		},
+		{
+			desc: "Ethernet IPv4 with padding",
+			lt:   pcap.LinkTypeEthernet,
+			b:    append(ether(etherTypeIPv4, ip4), 0, 0, 0, 0),
+		},
+		{
+			desc: "Ethernet VLAN IPv6",
+			lt:   pcap.LinkTypeEthernet,
+			b:    ether(etherTypeVLAN, append([]byte{0x00, 0x01, 0x86, 0xdd}, ip6...)),
+			v6:   true,
+		},
+		{
+			desc: "Linux SLL IPv4",
+			lt:   pcap.LinkTypeLinuxSLL,
+			b:    append(append(make([]byte, 14), 0x08, 0x00), ip4...),
+		},
+		{
+			desc: "Linux SLL2 IPv6",
+			lt:   pcap.LinkTypeLinuxSLL2,
+			b:    append(append([]byte{0x86, 0xdd}, make([]byte, 18)...), ip6...),
+			v6:   true,
+		},
+		{
+			desc: "NULL little endian IPv4",
+			lt:   pcap.LinkTypeNull,
+			b:    append([]byte{2, 0, 0, 0}, ip4...),
+		},
+		{
+			desc: "LOOP IPv6",
+			lt:   pcap.LinkTypeLoop,
+			b:    append([]byte{0, 0, 0, 30}, ip6...),
+			v6:   true,
+		},
+		{
+			desc: "IPv6 extension header",
+			lt:   pcap.LinkTypeIPv6,
+			b:    ext6,
+			v6:   true,
+		},
+	}
+
+	for i, tt := range tests {
+		s, err := Decode(tt.lt, tt.b)
+		if err != nil {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.desc, err)
+		}
+
+		want := &Segment{
+			Src:     src4,
+			Dst:     dst4,
+			Seq:     1000,
+			Ack:     2000,
+			Flags:   FlagSYN,
+			Window:  65535,
+			Options: []option.Option{capable},
+			Payload: payload,
+			Len:     len(payload),
+		}
+		if tt.v6 {
+			want.Src, want.Dst = src6, dst6
+		}
+
+		if !reflect.DeepEqual(want, s) {
+			t.Fatalf("[%02d] test %q, unexpected segment:\n- want: %+v\n-  got: %+v",
+				i, tt.desc, want, s)
+		}
+	}
+}
+
+// TestDecodeErrors verifies that Decode reports packets which are not TCP
+// segments, or are malformed.
+func TestDecodeErrors(t *testing.T) {
+	var (
+		src = netip.MustParseAddrPort("192.0.2.1:50000")
+		dst = netip.MustParseAddrPort("198.51.100.1:443")
+		ip  = testPacket(src, dst, FlagACK, nil, []byte("data"))
+	)
+
+	udp := append([]byte(nil), ip...)
+	udp[9] = 17
+
+	frag := append([]byte(nil), ip...)
+	binary.BigEndian.PutUint16(frag[6:8], 100)
+
+	badOpts := testPacket(src, dst, FlagACK, []byte{30, 3, 0x20, 0}, nil)
+
+	var tests = []struct {
+		desc   string
+		lt     pcap.LinkType
+		b      []byte
+		notTCP bool
+	}{
+		{
+			desc:   "ARP",
+			lt:     pcap.LinkTypeEthernet,
+			b:      append(make([]byte, 12), 0x08, 0x06, 0, 0),
+			notTCP: true,
+		},
+		{
+			desc:   "UDP",
+			lt:     pcap.LinkTypeRaw,
+			b:      udp,
+			notTCP: true,
+		},
+		{
+			desc:   "fragment",
+			lt:     pcap.LinkTypeRaw,
+			b:      frag,
+			notTCP: true,
+		},
+		{
+			desc: "truncated Ethernet",
+			lt:   pcap.LinkTypeEthernet,
+			b:    []byte{0, 1, 2},
+		},
+		{
+			desc: "truncated TCP header",
+			lt:   pcap.LinkTypeRaw,
+			b:    ip[:30],
+		},
+		{
+			desc: "unsupported link type",
+			lt:   pcap.LinkType(147),
+			b:    ip,
+		},
+	}
+
+	for i, tt := range tests {
+		_, err := Decode(tt.lt, tt.b)
+		if err == nil {
+			t.Fatalf("[%02d] test %q, expected an error, but none occurred", i, tt.desc)
+		}
+
+		if want, got := tt.notTCP, errors.Is(err, ErrNotTCP); want != got {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.desc, err)
+		}
+	}
+
+	// Malformed multipath TCP options do not prevent decoding.
+	s, err := Decode(pcap.LinkTypeRaw, badOpts)
+	if err != nil {
+		t.Fatalf("failed to decode segment with malformed options: %v", err)
+	}
+	if s.OptionErr == nil || len(s.Options) != 0 {
+		t.Fatalf("expected option error, but got options %v and error %v",
+			s.Options, s.OptionErr)
+	}
+}
+
+// TestFlagsString verifies the tcpdump style of Flags.String.
+func TestFlagsString(t *testing.T) {
+	var tests = []struct {
+		f Flags
+		s string
+	}{
+		{f: 0, s: "none"},
+		{f: FlagSYN, s: "S"},
+		{f: FlagSYN | FlagACK, s: "S."},
+		{f: FlagPSH | FlagACK, s: "P."},
+		{f: FlagFIN | FlagACK, s: "F."},
+		{f: FlagRST, s: "R"},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.s, tt.f.String(); want != got {
+			t.Fatalf("[%02d] unexpected string:\n- want: %q\n-  got: %q", i, want, got)
+		}
+	}
+}
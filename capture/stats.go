@@ -0,0 +1,446 @@
+package capture
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats are performance statistics of a Connection, computed from its
+// captured segments, for tuning multipath TCP schedulers.
+type Stats struct {
+	// Interval is the length of each Interval of the subflows.
+	Interval time.Duration
+
+	// Subflows are the statistics of each subflow, in the same order as
+	// the subflows of the connection.
+	Subflows []SubflowStats
+
+	// Client and Server are the statistics of the data sent by the
+	// client and server of the connection.
+	Client, Server StreamStats
+
+	// JoinDelay is the time from the end of the handshake of the initial
+	// subflow until the SYN of the first joined subflow, or zero if no
+	// subflow joined the connection.
+	JoinDelay time.Duration
+
+	// RTTDifference is the difference between the largest and smallest
+	// round-trip times of the subflows, or zero if fewer than two
+	// subflows have a round-trip time.
+	RTTDifference time.Duration
+}
+
+// SubflowStats are the performance statistics of a Subflow.
+type SubflowStats struct {
+	// Subflow is the subflow.
+	Subflow *Subflow
+
+	// FromClient and FromServer are the bytes of data sent by the client
+	// and server of the connection on the subflow, including
+	// retransmissions.
+	FromClient, FromServer int
+
+	// Intervals are the bytes of data sent on the subflow over time, in
+	// consecutive intervals beginning with the start of the connection.
+	Intervals []Interval
+
+	// HandshakeRTT is the round-trip time of the subflow's handshake,
+	// from its SYN until the acknowledgement of its SYN/ACK, or zero if
+	// the handshake was not captured.
+	HandshakeRTT time.Duration
+
+	// ClientRTT and ServerRTT are the round-trip times observed at the
+	// capture point from segments sent by the client and server of the
+	// subflow until their acknowledgement.
+	ClientRTT, ServerRTT RTTStats
+
+	// RTT is the round-trip time of the subflow's path: the sum of the
+	// minimum round-trip times in each direction, which does not depend on
+	// the capture point, or HandshakeRTT if either direction has no
+	// samples.
+	RTT time.Duration
+}
+
+// An Interval is the bytes of data sent on a subflow during an interval of
+// time.
+type Interval struct {
+	// Start is the beginning of the interval.
+	Start time.Time
+
+	// FromClient and FromServer are the bytes of data sent by the client
+	// and server of the connection.
+	FromClient, FromServer int
+}
+
+// RTTStats are statistics of round-trip time samples.  Segments which were
+// retransmitted are not sampled.
+type RTTStats struct {
+	Samples   int
+	Min, Mean time.Duration
+}
+
+// StreamStats are the performance statistics of one direction of the
+// data-level byte stream of a Connection.
+type StreamStats struct {
+	// Bytes is the length of the data-level byte stream.
+	Bytes int
+
+	// Reinjections is the number of mappings whose data was previously
+	// sent on another subflow, and ReinjectedBytes is the number of bytes
+	// sent again.
+	Reinjections    int
+	ReinjectedBytes int
+
+	// OutOfOrder is the number of segments whose data arrived before
+	// data which precedes it in the data-level byte stream.
+	OutOfOrder int
+
+	// HeadOfLine is the total time during which data could not be
+	// delivered because preceding data had not yet arrived,
+	// HeadOfLineMax is the longest such period, and HeadOfLineCount is
+	// the number of periods.  A period which has not ended by the end of
+	// the capture ends with the last segment.
+	HeadOfLine      time.Duration
+	HeadOfLineMax   time.Duration
+	HeadOfLineCount int
+}
+
+// Stats computes the performance statistics of conn.  The bytes sent on each
+// subflow are counted in intervals of length interval, which must be
+// positive.
+func (conn *Connection) Stats(interval time.Duration) *Stats {
+	st := &Stats{
+		Interval: interval,
+		Subflows: make([]SubflowStats, 0, len(conn.Subflows)),
+	}
+
+	var (
+		start = conn.Subflows[0].Start
+		end   = start
+	)
+	for _, sf := range conn.Subflows {
+		if sf.End.After(end) {
+			end = sf.End
+		}
+	}
+	n := int(end.Sub(start)/interval) + 1
+
+	var (
+		lo, hi time.Duration
+		paths  int
+	)
+	for _, sf := range conn.Subflows {
+		ss := newSubflowStats(sf, start, interval, n)
+		st.Subflows = append(st.Subflows, ss)
+
+		if ss.RTT == 0 {
+			continue
+		}
+		if paths == 0 || ss.RTT < lo {
+			lo = ss.RTT
+		}
+		if ss.RTT > hi {
+			hi = ss.RTT
+		}
+		paths++
+	}
+	if paths > 1 {
+		st.RTTDifference = hi - lo
+	}
+
+	if len(conn.Subflows) > 1 {
+		initial := conn.Subflows[0]
+		est := initial.Start
+		if s := established(initial); s != nil {
+			est = s.Time
+		}
+
+		st.JoinDelay = conn.Subflows[1].Start.Sub(est)
+	}
+
+	client, server := conn.Streams()
+	st.Client = newStreamStats(client)
+	st.Server = newStreamStats(server)
+
+	return st
+}
+
+// established returns the segment which completed the handshake of sf: the
+// client's acknowledgement of the SYN/ACK, or the SYN/ACK itself if it was
+// not captured.  It returns nil if the SYN/ACK was not captured.
+func established(sf *Subflow) *Segment {
+	if sf.SYNACK == nil {
+		return nil
+	}
+
+	for _, s := range sf.Segments {
+		if s.Time.Before(sf.SYNACK.Time) || !sf.FromClient(s) || !s.Has(FlagACK) {
+			continue
+		}
+		if s.Ack == sf.SYNACK.Seq+1 {
+			return s
+		}
+	}
+
+	return sf.SYNACK
+}
+
+// newSubflowStats computes the statistics of sf, counting bytes in n
+// intervals of length interval beginning at start.
+func newSubflowStats(sf *Subflow, start time.Time, interval time.Duration, n int) SubflowStats {
+	ss := SubflowStats{
+		Subflow:   sf,
+		Intervals: make([]Interval, n),
+	}
+
+	for i := range ss.Intervals {
+		ss.Intervals[i].Start = start.Add(time.Duration(i) * interval)
+	}
+
+	for _, s := range sf.Segments {
+		i := int(s.Time.Sub(start) / interval)
+		if i < 0 || i >= n {
+			continue
+		}
+
+		if sf.forward == sf.FromClient(s) {
+			ss.FromClient += s.Len
+			ss.Intervals[i].FromClient += s.Len
+		} else {
+			ss.FromServer += s.Len
+			ss.Intervals[i].FromServer += s.Len
+		}
+	}
+
+	if sf.SYN != nil {
+		if s := established(sf); s != nil && s != sf.SYNACK {
+			ss.HandshakeRTT = s.Time.Sub(sf.SYN.Time)
+		}
+	}
+
+	ss.ClientRTT = rtt(sf, true)
+	ss.ServerRTT = rtt(sf, false)
+
+	ss.RTT = ss.HandshakeRTT
+	if ss.ClientRTT.Samples > 0 && ss.ServerRTT.Samples > 0 {
+		ss.RTT = ss.ClientRTT.Min + ss.ServerRTT.Min
+	}
+
+	return ss
+}
+
+// rtt samples the round-trip times of segments sent by the client of sf if
+// fromClient is set, or by its server otherwise, until their
+// acknowledgement.
+func rtt(sf *Subflow, fromClient bool) RTTStats {
+	type pending struct {
+		end   uint32
+		t     time.Time
+		valid bool
+	}
+
+	var (
+		st    RTTStats
+		sum   time.Duration
+		ps    []pending
+		next  uint32
+		first = true
+	)
+
+	for _, s := range sf.Segments {
+		if sf.FromClient(s) != fromClient {
+			if !s.Has(FlagACK) {
+				continue
+			}
+
+			// Sample the segments acknowledged by s.
+			i := 0
+			for ; i < len(ps) && int32(s.Ack-ps[i].end) >= 0; i++ {
+				if !ps[i].valid {
+					continue
+				}
+
+				d := s.Time.Sub(ps[i].t)
+				if st.Samples == 0 || d < st.Min {
+					st.Min = d
+				}
+				sum += d
+				st.Samples++
+			}
+			ps = ps[i:]
+			continue
+		}
+
+		n := uint32(s.Len)
+		if s.Has(FlagSYN) {
+			n++
+		}
+		if s.Has(FlagFIN) {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+
+		end := s.Seq + n
+		if !first && int32(end-next) <= 0 {
+			// A retransmission, so segments it covers may not be
+			// sampled.
+			for i := range ps {
+				if int32(ps[i].end-s.Seq) > 0 {
+					ps[i].valid = false
+				}
+			}
+			continue
+		}
+
+		first = false
+		next = end
+		ps = append(ps, pending{end: end, t: s.Time, valid: true})
+	}
+
+	if st.Samples > 0 {
+		st.Mean = sum / time.Duration(st.Samples)
+	}
+
+	return st
+}
+
+// newStreamStats computes the statistics of st.
+func newStreamStats(st *Stream) StreamStats {
+	ss := StreamStats{Bytes: len(st.Data)}
+
+	for _, m := range st.Mappings {
+		if m.Reinjected {
+			ss.Reinjections++
+		}
+	}
+	for _, is := range st.Issues {
+		if is.Type == IssueReinjection {
+			ss.ReinjectedBytes += is.Length
+		}
+	}
+
+	// Deliver the data of each segment in the order it arrived, using the
+	// mappings of its subflow to find its data sequence numbers.
+	var (
+		next     uint64
+		ahead    spans
+		blocked  bool
+		since    time.Time
+		last     time.Time
+		arrivals = st.arrivals()
+	)
+
+	for _, a := range arrivals {
+		last = a.t
+		if a.end <= next {
+			continue
+		}
+
+		if a.start > next {
+			ss.OutOfOrder++
+			ahead.add(a.start, a.end)
+			if !blocked {
+				blocked = true
+				since = a.t
+				ss.HeadOfLineCount++
+			}
+			continue
+		}
+
+		next = a.end
+		for len(ahead) > 0 && ahead[0].start <= next {
+			if ahead[0].end > next {
+				next = ahead[0].end
+			}
+			ahead = ahead[1:]
+		}
+
+		if blocked && len(ahead) == 0 {
+			blocked = false
+			ss.block(a.t.Sub(since))
+		}
+	}
+
+	if blocked {
+		ss.block(last.Sub(since))
+	}
+
+	return ss
+}
+
+// block records a period of head-of-line blocking of length d.
+func (ss *StreamStats) block(d time.Duration) {
+	ss.HeadOfLine += d
+	if d > ss.HeadOfLineMax {
+		ss.HeadOfLineMax = d
+	}
+}
+
+// An arrival is data which arrived at the time t, at offsets [start, end) of
+// a Stream.
+type arrival struct {
+	t          time.Time
+	start, end uint64
+}
+
+// arrivals returns the data of the segments of st which are mapped to the
+// data-level byte stream, in the order it arrived.
+func (st *Stream) arrivals() []arrival {
+	// Index the mappings of each subflow by subflow sequence number.
+	var (
+		sfs  []*Subflow
+		maps = make(map[*Subflow][]Mapping)
+	)
+	for _, m := range st.Mappings {
+		if m.Length == 0 {
+			continue
+		}
+		if _, ok := maps[m.Subflow]; !ok {
+			sfs = append(sfs, m.Subflow)
+		}
+		maps[m.Subflow] = append(maps[m.Subflow], m)
+	}
+
+	var as []arrival
+	for _, sf := range sfs {
+		ms := maps[sf]
+		sort.SliceStable(ms, func(i, j int) bool {
+			return ms[i].SubflowSeq < ms[j].SubflowSeq
+		})
+
+		fromClient := sf.forward == st.FromClient
+		isn := sf.isn(fromClient)
+		if isn == nil {
+			continue
+		}
+
+		for _, s := range sf.Segments {
+			if sf.FromClient(s) != fromClient || s.Len == 0 {
+				continue
+			}
+
+			start := relSeq(isn, s)
+			end := start + uint64(s.Len)
+
+			i := sort.Search(len(ms), func(i int) bool {
+				return uint64(ms[i].SubflowSeq)+uint64(ms[i].Length) > start
+			})
+			for ; i < len(ms) && uint64(ms[i].SubflowSeq) < end; i++ {
+				m := ms[i]
+				ssn := uint64(m.SubflowSeq)
+				lo, hi := max(start, ssn), min(end, ssn+uint64(m.Length))
+
+				off := m.DSN - st.Start + (lo - ssn)
+				as = append(as, arrival{t: s.Time, start: off, end: off + (hi - lo)})
+			}
+		}
+	}
+
+	sort.SliceStable(as, func(i, j int) bool {
+		return as[i].t.Before(as[j].t)
+	})
+
+	return as
+}
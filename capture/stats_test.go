@@ -0,0 +1,127 @@
+package capture
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestStats verifies the performance statistics of a connection whose joined
+// subflow has a longer round-trip time, causing head-of-line blocking and a
+// reinjection.
+func TestStats(t *testing.T) {
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+
+	// The handshakes of the initial and joined subflows, with round-trip
+	// times of 20ms and 100ms.
+	hs := testSegments()
+	hs = append(hs[0:3:3], hs[5:8]...)
+	for i, tt := range []struct {
+		ms  int
+		ack uint32
+	}{
+		{0, 0}, {10, 101}, {20, 501},
+		{30, 0}, {80, 201}, {130, 601},
+	} {
+		hs[i].Time = ms(tt.ms)
+		hs[i].Ack = tt.ack
+	}
+
+	ack := func(s *Segment, n uint32) *Segment {
+		s.Flags |= FlagACK
+		s.Ack = n
+		return s
+	}
+
+	segs := append(hs,
+		// The second part of the data arrives first on the joined
+		// subflow, and is reinjected on the initial subflow.
+		dataSeg(140, client2, server, 201, "world", dss(7, 1, 5)),
+		dataSeg(150, client, server, 101, "hello ", dss(1, 1, 6)),
+		ack(seg(170, server, client, 501, 0, 0), 107),
+		dataSeg(200, client, server, 107, "world", dss(7, 7, 5)),
+		ack(seg(240, server, client2, 601, 0, 0), 206),
+	)
+
+	conn := Analyze(segs).Connections[0]
+	st := conn.Stats(100 * time.Millisecond)
+
+	initial, joined := st.Subflows[0], st.Subflows[1]
+
+	if want, got := []Interval{
+		{Start: ms(0)},
+		{Start: ms(100), FromClient: 6},
+		{Start: ms(200), FromClient: 5},
+	}, initial.Intervals; !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected initial subflow intervals:\n- want: %+v\n-  got: %+v", want, got)
+	}
+	if want, got := []Interval{
+		{Start: ms(0)},
+		{Start: ms(100), FromClient: 5},
+		{Start: ms(200)},
+	}, joined.Intervals; !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected joined subflow intervals:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	if initial.FromClient != 11 || initial.FromServer != 0 || joined.FromClient != 5 {
+		t.Fatalf("unexpected subflow bytes: %d/%d, %d",
+			initial.FromClient, initial.FromServer, joined.FromClient)
+	}
+
+	type rtts struct {
+		Handshake, Path time.Duration
+		Client, Server  RTTStats
+	}
+
+	if want, got := (rtts{
+		Handshake: 20 * time.Millisecond,
+		Path:      20 * time.Millisecond,
+		Client:    RTTStats{Samples: 2, Min: 10 * time.Millisecond, Mean: 15 * time.Millisecond},
+		Server:    RTTStats{Samples: 1, Min: 10 * time.Millisecond, Mean: 10 * time.Millisecond},
+	}), (rtts{initial.HandshakeRTT, initial.RTT, initial.ClientRTT, initial.ServerRTT}); want != got {
+		t.Fatalf("unexpected initial subflow RTTs:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	if want, got := (rtts{
+		Handshake: 100 * time.Millisecond,
+		Path:      100 * time.Millisecond,
+		Client:    RTTStats{Samples: 2, Min: 50 * time.Millisecond, Mean: 75 * time.Millisecond},
+		Server:    RTTStats{Samples: 1, Min: 50 * time.Millisecond, Mean: 50 * time.Millisecond},
+	}), (rtts{joined.HandshakeRTT, joined.RTT, joined.ClientRTT, joined.ServerRTT}); want != got {
+		t.Fatalf("unexpected joined subflow RTTs:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	if want, got := 80*time.Millisecond, st.RTTDifference; want != got {
+		t.Fatalf("unexpected RTT difference:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 10*time.Millisecond, st.JoinDelay; want != got {
+		t.Fatalf("unexpected join delay:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	want := StreamStats{
+		Bytes:           11,
+		Reinjections:    1,
+		ReinjectedBytes: 5,
+		OutOfOrder:      1,
+		HeadOfLine:      10 * time.Millisecond,
+		HeadOfLineMax:   10 * time.Millisecond,
+		HeadOfLineCount: 1,
+	}
+	if got := st.Client; want != got {
+		t.Fatalf("unexpected client stream statistics:\n- want: %+v\n-  got: %+v", want, got)
+	}
+	if got := st.Server; got != (StreamStats{}) {
+		t.Fatalf("unexpected server stream statistics: %+v", got)
+	}
+
+	// Without payloads, as in a capture of headers only, reinjections are
+	// found from the mappings alone.
+	for _, s := range segs {
+		s.Payload = nil
+	}
+
+	want.Bytes = 0
+	if got := Analyze(segs).Connections[0].Stats(100 * time.Millisecond).Client; want != got {
+		t.Fatalf("unexpected headers only client stream statistics:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
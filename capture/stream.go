@@ -0,0 +1,673 @@
+package capture
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mdlayher/mptcp/crypto"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// maxStream is the maximum length of a reassembled subflow or data-level
+// stream.  Mappings and segments beyond it are ignored.
+const maxStream = 1 << 30
+
+// A Stream is one direction of the data-level byte stream of a Connection,
+// reassembled from the data of all of its subflows using their DSS mappings.
+type Stream struct {
+	// FromClient indicates that the stream is sent by the client of the
+	// connection.  Otherwise, it is sent by the server.
+	FromClient bool
+
+	// Start is the data sequence number of the first byte of Data.  It
+	// follows the initial data sequence number if the sender's key was
+	// captured, or is the lowest mapped data sequence number otherwise.
+	Start uint64
+
+	// Data is the reassembled data.  Bytes which were not captured are
+	// zero, and are reported by Issues with type IssueGap.
+	Data []byte
+
+	// FIN indicates that a DATA_FIN was captured, and Data ends at the
+	// end of the stream.
+	FIN bool
+
+	// Mappings are the DSS mappings of the stream, in the order they
+	// were first captured.  Repeated mappings are omitted.
+	Mappings []Mapping
+
+	// Issues are the problems found while reassembling the stream, in
+	// data sequence order.
+	Issues []Issue
+}
+
+// A Mapping maps data sent on a subflow to the data sequence space of a
+// connection.
+type Mapping struct {
+	// Time is the time of the first segment which carried the mapping.
+	Time time.Time
+
+	// Subflow is the subflow which carried the mapping.
+	Subflow *Subflow
+
+	// DSN is the full 64-bit data sequence number of the mapping, and
+	// SubflowSeq is the subflow sequence number relative to the initial
+	// sequence number of the sender.
+	DSN        uint64
+	SubflowSeq uint32
+
+	// Length is the number of data bytes mapped, which excludes the
+	// DATA_FIN if DataFIN is set.
+	Length int
+
+	// DataFIN indicates that the mapping ends with a DATA_FIN.
+	DataFIN bool
+
+	// Reinjected indicates that data in the mapping was previously sent
+	// on another subflow.
+	Reinjected bool
+}
+
+// An IssueType is the type of an Issue.
+type IssueType int
+
+// Possible IssueType values.
+const (
+	// IssueGap indicates data which was not captured, or was not mapped.
+	IssueGap IssueType = iota + 1
+
+	// IssueOverlap indicates that a mapping overlaps a different mapping
+	// on the same subflow, or that it carries different data than was
+	// previously sent for the same data sequence numbers.
+	IssueOverlap
+
+	// IssueReinjection indicates that data previously sent on one
+	// subflow was sent again on another subflow.
+	IssueReinjection
+
+	// IssueChecksum indicates that the DSS checksum of a mapping does not
+	// match its data.
+	IssueChecksum
+
+	// IssueInvalid indicates that a mapping could not be placed in the
+	// stream, because it precedes its start or is too far beyond it.
+	IssueInvalid
+)
+
+// String returns the string representation of an IssueType.
+func (t IssueType) String() string {
+	switch t {
+	case IssueGap:
+		return "gap"
+	case IssueOverlap:
+		return "overlap"
+	case IssueReinjection:
+		return "reinjection"
+	case IssueChecksum:
+		return "checksum"
+	case IssueInvalid:
+		return "invalid"
+	default:
+		return fmt.Sprintf("IssueType(%d)", int(t))
+	}
+}
+
+// An Issue is a problem found while reassembling a Stream.
+type Issue struct {
+	// Type is the type of the issue.
+	Type IssueType
+
+	// Time is the time of the mapping which caused the issue.  It is zero
+	// for gaps.
+	Time time.Time
+
+	// Subflow is the subflow which carried the mapping, or nil for gaps.
+	Subflow *Subflow
+
+	// DSN and Length are the range of data sequence numbers affected.
+	DSN    uint64
+	Length int
+
+	// Detail describes the issue.
+	Detail string
+}
+
+// Streams reassembles both directions of the data-level byte stream of conn:
+// the data sent by its client, and the data sent by its server.
+func (conn *Connection) Streams() (client, server *Stream) {
+	return conn.stream(true), conn.stream(false)
+}
+
+// stream reassembles the data sent by the client of conn if fromClient is
+// set, or by its server otherwise.
+func (conn *Connection) stream(fromClient bool) *Stream {
+	key := conn.ServerKey
+	if fromClient {
+		key = conn.ClientKey
+	}
+
+	var (
+		ms    []mapping
+		flows = make(map[*Subflow]*flow)
+	)
+
+	for _, sf := range conn.Subflows {
+		f := newFlow(sf, sf.forward == fromClient)
+		flows[sf] = f
+		ms = append(ms, f.mappings(conn, key)...)
+	}
+
+	sort.SliceStable(ms, func(i, j int) bool {
+		return ms[i].Time.Before(ms[j].Time)
+	})
+
+	st := &Stream{FromClient: fromClient}
+
+	var ref uint64
+	switch {
+	case key != 0:
+		st.Start = crypto.IDSN(conn.Version, key) + 1
+		ref = st.Start
+	case len(ms) > 0:
+		ref = ms[0].DSN
+	}
+
+	// Data sent after a fallback without any mappings is implicitly
+	// mapped to the data sequence space by the initial subflow.
+	if f := flows[conn.Subflows[0]]; len(ms) == 0 && conn.Fallback && len(f.data) > 1 {
+		ms = append(ms, mapping{
+			Mapping: Mapping{
+				Time:       conn.Subflows[0].Start,
+				Subflow:    conn.Subflows[0],
+				DSN:        ref,
+				SubflowSeq: 1,
+				Length:     len(f.data) - 1,
+			},
+			dsn64: true,
+		})
+	}
+
+	// Expand the data sequence numbers of mappings to 64 bits, in the
+	// order they were sent, and omit repeated mappings.
+	type mappingKey struct {
+		sf     *Subflow
+		dsn    uint64
+		ssn    uint32
+		length int
+	}
+
+	seen := make(map[mappingKey]bool)
+	unique := ms[:0]
+	for _, m := range ms {
+		if !m.dsn64 {
+			m.DSN = expand(ref, uint32(m.DSN))
+		}
+		if end := m.DSN + uint64(m.Length); end > ref {
+			ref = end
+		}
+
+		k := mappingKey{sf: m.Subflow, dsn: m.DSN, ssn: m.SubflowSeq, length: m.Length}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, m)
+	}
+	ms = unique
+
+	if key == 0 && len(ms) > 0 {
+		st.Start = ms[0].DSN
+		for _, m := range ms {
+			if m.DSN < st.Start {
+				st.Start = m.DSN
+			}
+		}
+	}
+
+	a := &assembler{
+		st:    st,
+		flows: flows,
+		cov:   make(map[*Subflow]*spans),
+	}
+	for _, m := range ms {
+		a.place(m)
+	}
+
+	end := a.total.end()
+	if a.fin && a.finOff >= end {
+		end = a.finOff
+	}
+
+	for _, g := range a.total.missing(0, end) {
+		st.Issues = append(st.Issues, Issue{
+			Type:   IssueGap,
+			DSN:    st.Start + g.start,
+			Length: int(g.end - g.start),
+		})
+	}
+
+	sort.SliceStable(st.Issues, func(i, j int) bool {
+		return st.Issues[i].DSN < st.Issues[j].DSN
+	})
+
+	if uint64(len(a.data)) < end {
+		a.data = append(a.data, make([]byte, end-uint64(len(a.data)))...)
+	}
+
+	st.FIN = a.fin
+	st.Data = a.data[:end]
+
+	return st
+}
+
+// A mapping is a Mapping as it is carried by a DSS or MP_CAPABLE option.
+type mapping struct {
+	Mapping
+
+	// length is the data-level length of the option, which includes the
+	// DATA_FIN.
+	length uint16
+
+	// dsn64 indicates that the data sequence number is a full 64-bit
+	// value.
+	dsn64 bool
+
+	checksum    uint16
+	hasChecksum bool
+}
+
+// expand returns the 64-bit sequence number nearest to ref whose lower 32
+// bits are low.
+func expand(ref uint64, low uint32) uint64 {
+	v := ref&^0xffffffff | uint64(low)
+
+	switch {
+	case v > ref && v-ref > 1<<31 && v >= 1<<32:
+		v -= 1 << 32
+	case v < ref && ref-v > 1<<31:
+		v += 1 << 32
+	}
+
+	return v
+}
+
+// A flow is the data sent in one direction of a subflow, indexed by
+// sequence number relative to the sender's initial sequence number.
+type flow struct {
+	sf     *Subflow
+	sender bool
+	segs   []*Segment
+	data   []byte
+	have   spans
+}
+
+// newFlow reassembles the data sent by the client of sf if fromClient is
+// set, or by its server otherwise.  The data cannot be located if the
+// sender's SYN or SYN/ACK was not captured.
+func newFlow(sf *Subflow, fromClient bool) *flow {
+	f := &flow{sf: sf}
+
+	isn := sf.isn(fromClient)
+	for _, s := range sf.Segments {
+		if sf.FromClient(s) != fromClient {
+			continue
+		}
+		f.segs = append(f.segs, s)
+
+		if isn == nil || len(s.Payload) == 0 {
+			continue
+		}
+
+		f.write(relSeq(isn, s), s.Payload)
+	}
+
+	return f
+}
+
+// isn returns the segment carrying the initial sequence number of the client
+// of sf if fromClient is set, or of its server otherwise, or nil if it was
+// not captured.
+func (sf *Subflow) isn(fromClient bool) *Segment {
+	if fromClient {
+		return sf.SYN
+	}
+
+	return sf.SYNACK
+}
+
+// relSeq returns the sequence number of the first byte of data in s, relative
+// to the initial sequence number carried by isn.
+func relSeq(isn, s *Segment) uint64 {
+	// A SYN consumes the initial sequence number.
+	rel := uint64(s.Seq - isn.Seq)
+	if s.Has(FlagSYN) {
+		rel++
+	}
+
+	return rel
+}
+
+// write writes b at offset off, unless the data at off was already
+// captured.
+func (f *flow) write(off uint64, b []byte) {
+	end := off + uint64(len(b))
+	if end > maxStream {
+		return
+	}
+
+	if uint64(len(f.data)) < end {
+		f.data = append(f.data, make([]byte, end-uint64(len(f.data)))...)
+	}
+
+	for _, p := range f.have.add(off, end) {
+		copy(f.data[p.start:p.end], b[p.start-off:])
+	}
+}
+
+// mappings returns the mappings carried by the segments of f.  MP_CAPABLE
+// options with data map the first data of the initial subflow, which
+// requires the sender's key.
+func (f *flow) mappings(conn *Connection, key uint64) []mapping {
+	var ms []mapping
+	for _, s := range f.segs {
+		for _, o := range s.Options {
+			m := mapping{
+				Mapping: Mapping{
+					Time:    s.Time,
+					Subflow: f.sf,
+				},
+			}
+
+			switch o := o.(type) {
+			case *option.DSS:
+				if !o.HasMapping {
+					continue
+				}
+
+				m.DSN = o.DSN
+				m.dsn64 = o.DSN64
+				m.SubflowSeq = o.SubflowSeq
+				m.length = o.DataLength
+				m.DataFIN = o.DataFIN
+				m.checksum, m.hasChecksum = o.Checksum, o.HasChecksum
+			case *option.MPCapable:
+				if !o.HasDataLength || key == 0 {
+					continue
+				}
+
+				m.DSN = crypto.IDSN(conn.Version, key) + 1
+				m.dsn64 = true
+				m.SubflowSeq = 1
+				m.length = o.DataLength
+				m.checksum, m.hasChecksum = o.Checksum, o.HasChecksum
+			default:
+				continue
+			}
+
+			m.Length = int(m.length)
+			switch {
+			case m.length == 0:
+				// An infinite mapping maps all of the following
+				// data of the subflow.
+				if end := len(f.data); end > int(m.SubflowSeq) {
+					m.Length = end - int(m.SubflowSeq)
+				}
+			case m.DataFIN:
+				m.Length--
+			}
+
+			ms = append(ms, m)
+		}
+	}
+
+	return ms
+}
+
+// read returns n bytes at offset off, and the spans relative to off which
+// were not captured.
+func (f *flow) read(off uint64, n int) ([]byte, []span) {
+	end := off + uint64(n)
+
+	b := make([]byte, n)
+	if off < uint64(len(f.data)) {
+		copy(b, f.data[off:])
+	}
+
+	missing := f.have.missing(off, end)
+	for i := range missing {
+		missing[i].start -= off
+		missing[i].end -= off
+	}
+
+	return b, missing
+}
+
+// An assembler places mappings in a Stream.
+type assembler struct {
+	st    *Stream
+	flows map[*Subflow]*flow
+
+	data  []byte
+	total spans
+
+	// cov contains the offsets mapped by each subflow, whether or not
+	// their data was captured.
+	cov    map[*Subflow]*spans
+	fin    bool
+	finOff uint64
+}
+
+// place places the data of m in the stream, and reports any issues.
+func (a *assembler) place(m mapping) {
+	issue := func(t IssueType, dsn uint64, n int, detail string) {
+		a.st.Issues = append(a.st.Issues, Issue{
+			Type:    t,
+			Time:    m.Time,
+			Subflow: m.Subflow,
+			DSN:     dsn,
+			Length:  n,
+			Detail:  detail,
+		})
+	}
+
+	off := m.DSN - a.st.Start
+	end := off + uint64(m.Length)
+	if m.DSN < a.st.Start || end > maxStream {
+		issue(IssueInvalid, m.DSN, m.Length, "mapping outside of the stream")
+		return
+	}
+
+	if m.DataFIN {
+		a.fin = true
+		a.finOff = end
+	}
+
+	if m.Length == 0 {
+		// A DATA_FIN without data.
+		a.st.Mappings = append(a.st.Mappings, m.Mapping)
+		return
+	}
+
+	b, missing := a.flows[m.Subflow].read(uint64(m.SubflowSeq), m.Length)
+
+	if m.hasChecksum && len(missing) == 0 {
+		if sum := crypto.DSSChecksum(m.DSN, m.SubflowSeq, m.length, b); sum != m.checksum {
+			issue(IssueChecksum, m.DSN, m.Length,
+				fmt.Sprintf("checksum %#04x, want %#04x", m.checksum, sum))
+		}
+	}
+
+	// Only the captured portions of the mapping are placed.
+	var captured spans
+	captured.add(0, uint64(m.Length))
+	for _, p := range missing {
+		captured.remove(p.start, p.end)
+	}
+
+	own := a.cov[m.Subflow]
+	if own == nil {
+		own = new(spans)
+		a.cov[m.Subflow] = own
+	}
+
+	// Reinjections and overlaps are found from the ranges of the
+	// mappings, so they are reported even if the payload was not
+	// captured.
+	var overlap, reinjected, differs spans
+	for sf, cov := range a.cov {
+		for _, q := range cov.covered(off, end) {
+			if sf == m.Subflow {
+				overlap.add(q.start, q.end)
+			} else {
+				reinjected.add(q.start, q.end)
+			}
+		}
+	}
+	own.add(off, end)
+
+	for _, p := range captured {
+		start, end := off+p.start, off+p.end
+
+		// Data already placed must match.
+		for _, q := range a.total.covered(start, end) {
+			if string(a.data[q.start:q.end]) != string(b[q.start-off:q.end-off]) {
+				differs.add(q.start, q.end)
+			}
+		}
+
+		if uint64(len(a.data)) < end {
+			a.data = append(a.data, make([]byte, end-uint64(len(a.data)))...)
+		}
+		for _, q := range a.total.add(start, end) {
+			copy(a.data[q.start:q.end], b[q.start-off:])
+		}
+	}
+
+	if len(reinjected) > 0 {
+		m.Reinjected = true
+		for _, q := range reinjected {
+			issue(IssueReinjection, a.st.Start+q.start, int(q.end-q.start), "")
+		}
+	}
+	for _, q := range overlap {
+		issue(IssueOverlap, a.st.Start+q.start, int(q.end-q.start), "mapping overlaps another on the same subflow")
+	}
+	for _, q := range differs {
+		issue(IssueOverlap, a.st.Start+q.start, int(q.end-q.start), "data differs")
+	}
+
+	a.st.Mappings = append(a.st.Mappings, m.Mapping)
+}
+
+// A span is the half-open range of offsets [start, end).
+type span struct {
+	start, end uint64
+}
+
+// spans is a sorted set of non-overlapping, non-adjacent spans.
+type spans []span
+
+// add adds [start, end) to the set, and returns the portions which were not
+// already present.
+func (ss *spans) add(start, end uint64) []span {
+	missing := ss.missing(start, end)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	*ss = append(*ss, span{start: start, end: end})
+	ss.normalize()
+
+	return missing
+}
+
+// remove removes [start, end) from the set.
+func (ss *spans) remove(start, end uint64) {
+	var out spans
+	for _, s := range *ss {
+		if s.end <= start || s.start >= end {
+			out = append(out, s)
+			continue
+		}
+
+		if s.start < start {
+			out = append(out, span{start: s.start, end: start})
+		}
+		if s.end > end {
+			out = append(out, span{start: end, end: s.end})
+		}
+	}
+
+	*ss = out
+}
+
+// missing returns the portions of [start, end) which are not in the set.
+func (ss spans) missing(start, end uint64) []span {
+	var out []span
+
+	cur := start
+	for _, s := range ss {
+		if s.end <= cur {
+			continue
+		}
+		if s.start >= end {
+			break
+		}
+
+		if s.start > cur {
+			out = append(out, span{start: cur, end: s.start})
+		}
+		cur = s.end
+	}
+
+	if cur < end {
+		out = append(out, span{start: cur, end: end})
+	}
+
+	return out
+}
+
+// covered returns the portions of [start, end) which are in the set.
+func (ss spans) covered(start, end uint64) []span {
+	var out []span
+	for _, s := range ss {
+		if s.end <= start || s.start >= end {
+			continue
+		}
+
+		out = append(out, span{start: max(s.start, start), end: min(s.end, end)})
+	}
+
+	return out
+}
+
+// end returns the end of the last span, or zero if the set is empty.
+func (ss spans) end() uint64 {
+	if len(ss) == 0 {
+		return 0
+	}
+
+	return ss[len(ss)-1].end
+}
+
+// normalize sorts the set and merges overlapping and adjacent spans.
+func (ss *spans) normalize() {
+	s := *ss
+	sort.Slice(s, func(i, j int) bool { return s[i].start < s[j].start })
+
+	out := s[:1]
+	for _, x := range s[1:] {
+		last := &out[len(out)-1]
+		if x.start <= last.end {
+			if x.end > last.end {
+				last.end = x.end
+			}
+			continue
+		}
+
+		out = append(out, x)
+	}
+
+	*ss = out
+}
@@ -0,0 +1,217 @@
+package capture
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/mptcp/crypto"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// dataSeg creates a segment carrying payload, sent at t0 plus ms
+// milliseconds.
+func dataSeg(ms int, src, dst netip.AddrPort, seq uint32, payload string, opts ...option.Option) *Segment {
+	s := seg(ms, src, dst, seq, FlagACK|FlagPSH, len(payload), opts...)
+	s.Payload = []byte(payload)
+	return s
+}
+
+// dss creates a DSS option which maps n bytes at subflow sequence number ssn
+// to data sequence number dsn, relative to the client's initial data
+// sequence number.
+func dss(dsn uint64, ssn uint32, n uint16) *option.DSS {
+	return &option.DSS{
+		HasMapping: true,
+		DSN:        crypto.IDSN(1, clientKey) + dsn,
+		DSN64:      true,
+		SubflowSeq: ssn,
+		DataLength: n,
+	}
+}
+
+// checksummed sets the DSS checksum of d for data.
+func checksummed(d *option.DSS, data string) *option.DSS {
+	d.Checksum = crypto.DSSChecksum(d.DSN, d.SubflowSeq, d.DataLength, []byte(data))
+	d.HasChecksum = true
+	return d
+}
+
+// TestStreams verifies that Streams reassembles the data sent by the client
+// of a connection across its subflows, and reports issues.
+func TestStreams(t *testing.T) {
+	// The handshakes of the initial and joined subflows, whose initial
+	// sequence numbers are 100 and 200.
+	hs := testSegments()
+	hs = append(hs[0:3:3], hs[5:8]...)
+
+	type issue struct {
+		Type   IssueType
+		DSN    uint64
+		Length int
+	}
+
+	var tests = []struct {
+		desc   string
+		segs   []*Segment
+		data   string
+		fin    bool
+		issues []issue
+	}{
+		{
+			desc: "in order",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello ", checksummed(dss(1, 1, 6), "hello ")),
+				dataSeg(11, client2, server, 201, "world", dss(7, 1, 5)),
+				seg(12, client, server, 107, FlagACK, 0,
+					&option.DSS{HasMapping: true, DataFIN: true, DSN: crypto.IDSN(1, clientKey) + 12, DSN64: true, DataLength: 1}),
+			},
+			data: "hello world",
+			fin:  true,
+		},
+		{
+			desc: "MP_CAPABLE and 32-bit DSN",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello ",
+					&option.MPCapable{Version: 1, DataLength: 6, HasDataLength: true}),
+				dataSeg(11, client2, server, 201, "world",
+					&option.DSS{HasMapping: true, DSN: uint64(uint32(crypto.IDSN(1, clientKey) + 7)), SubflowSeq: 1, DataLength: 5}),
+			},
+			data: "hello world",
+		},
+		{
+			desc: "mapping across segments with retransmission",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello", dss(1, 1, 11)),
+				dataSeg(11, client, server, 106, " world"),
+				dataSeg(12, client, server, 101, "hello", dss(1, 1, 11)),
+			},
+			data: "hello world",
+		},
+		{
+			desc: "reinjection",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello ", dss(1, 1, 6)),
+				dataSeg(11, client2, server, 201, "world", dss(7, 1, 5)),
+				dataSeg(12, client, server, 107, "world", dss(7, 7, 5)),
+			},
+			data:   "hello world",
+			issues: []issue{{IssueReinjection, 7, 5}},
+		},
+		{
+			desc: "reinjection without payload",
+			segs: []*Segment{
+				seg(10, client, server, 101, FlagACK, 6, dss(1, 1, 6)),
+				seg(11, client2, server, 201, FlagACK, 5, dss(7, 1, 5)),
+				seg(12, client, server, 107, FlagACK, 5, dss(7, 7, 5)),
+			},
+			issues: []issue{{IssueReinjection, 7, 5}},
+		},
+		{
+			desc: "gap",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello ", dss(1, 1, 6)),
+				dataSeg(11, client, server, 110, "ld", dss(7, 7, 5)),
+			},
+			data:   "hello \x00\x00\x00ld",
+			issues: []issue{{IssueGap, 7, 3}},
+		},
+		{
+			desc: "checksum",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello ", checksummed(dss(1, 1, 6), "HELLO ")),
+			},
+			data:   "hello ",
+			issues: []issue{{IssueChecksum, 1, 6}},
+		},
+		{
+			desc: "overlap",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello ", dss(1, 1, 6)),
+				dataSeg(11, client, server, 107, "world", dss(5, 7, 5)),
+			},
+			data: "hello rld",
+			issues: []issue{
+				{IssueOverlap, 5, 2},
+				{IssueOverlap, 5, 2},
+			},
+		},
+		{
+			desc: "invalid",
+			segs: []*Segment{
+				dataSeg(10, client, server, 101, "hello ", dss(0, 1, 6)),
+			},
+			issues: []issue{{IssueInvalid, 0, 6}},
+		},
+	}
+
+	for i, tt := range tests {
+		segs := append(append([]*Segment(nil), hs...), tt.segs...)
+		conn := Analyze(segs).Connections[0]
+
+		st, _ := conn.Streams()
+		if want, got := tt.data, string(st.Data); want != got {
+			t.Fatalf("[%02d] test %q, unexpected data:\n- want: %q\n-  got: %q",
+				i, tt.desc, want, got)
+		}
+		if want, got := tt.fin, st.FIN; want != got {
+			t.Fatalf("[%02d] test %q, unexpected DATA_FIN:\n- want: %v\n-  got: %v",
+				i, tt.desc, want, got)
+		}
+
+		var issues []issue
+		for _, is := range st.Issues {
+			issues = append(issues, issue{is.Type, is.DSN - st.Start + 1, is.Length})
+		}
+		if !reflect.DeepEqual(tt.issues, issues) {
+			t.Fatalf("[%02d] test %q, unexpected issues:\n- want: %v\n-  got: %v",
+				i, tt.desc, tt.issues, issues)
+		}
+	}
+}
+
+// TestStreamsMappings verifies the mappings of a reassembled stream.
+func TestStreamsMappings(t *testing.T) {
+	segs := testSegments()
+	segs = append(segs[0:3:3], segs[5:8]...)
+	segs = append(segs,
+		dataSeg(10, client, server, 101, "hello ", dss(1, 1, 6)),
+		dataSeg(11, client2, server, 201, "hello ", dss(1, 1, 6)),
+		dataSeg(12, server, client, 501, "ok",
+			&option.DSS{HasMapping: true, DSN: crypto.IDSN(1, serverKey) + 1, DSN64: true, SubflowSeq: 1, DataLength: 2}),
+	)
+
+	conn := Analyze(segs).Connections[0]
+	cs, ss := conn.Streams()
+
+	initial, joined := conn.Subflows[0], conn.Subflows[1]
+	want := []Mapping{
+		{Time: segs[6].Time, Subflow: initial, DSN: cs.Start, SubflowSeq: 1, Length: 6},
+		{Time: segs[7].Time, Subflow: joined, DSN: cs.Start, SubflowSeq: 1, Length: 6, Reinjected: true},
+	}
+	if !reflect.DeepEqual(want, cs.Mappings) {
+		t.Fatalf("unexpected client mappings:\n- want: %+v\n-  got: %+v", want, cs.Mappings)
+	}
+
+	if ss.FromClient || string(ss.Data) != "ok" || ss.Start != crypto.IDSN(1, serverKey)+1 {
+		t.Fatalf("unexpected server stream: %+v", ss)
+	}
+}
+
+// TestStreamsFallback verifies that data is reassembled from the initial
+// subflow of a connection which falls back to regular TCP.
+func TestStreamsFallback(t *testing.T) {
+	other := netip.MustParseAddrPort("192.0.2.1:50002")
+
+	segs := []*Segment{
+		seg(0, other, server, 400, FlagSYN, 0, &option.MPCapable{Version: 1}),
+		seg(1, server, other, 700, FlagSYN|FlagACK, 0),
+		dataSeg(2, other, server, 401, "hello"),
+		dataSeg(3, server, other, 701, "world"),
+	}
+
+	cs, ss := Analyze(segs).Connections[0].Streams()
+	if string(cs.Data) != "hello" || string(ss.Data) != "world" || len(cs.Issues) > 0 || len(ss.Issues) > 0 {
+		t.Fatalf("unexpected streams:\n%+v\n%+v", cs, ss)
+	}
+}
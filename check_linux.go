@@ -42,6 +42,21 @@ var (
 // checkMPTCP checks if an input host string and uint16 port are present
 // in this Linux machine's MPTCP active connections.
 var checkMPTCP = func(host string, port uint16) (bool, error) {
+	ok, err := mptcpTableExists()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		// Mainline kernels have no connections table, so check the
+		// connections reported by sock_diag instead
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false, ErrInvalidIPAddress
+		}
+
+		return lookupDiagLinux(ip, port)
+	}
+
 	// Get hex representation of host
 	hexHost, err := hostToHex(host)
 	if err != nil {
@@ -55,6 +70,22 @@ var checkMPTCP = func(host string, port uint16) (bool, error) {
 	return lookupMPTCPLinux(hexHostPort)
 }
 
+// mptcpTableExists determines if the Linux MPTCP connections table exists,
+// as it does on kernels with out-of-tree MPTCP support.
+//
+// This implementation is swappable for testing.
+var mptcpTableExists = func() (bool, error) {
+	_, err := os.Stat(procMPTCP)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return false, err
+}
+
 // mptcpEnabled uses the Linux /proc filesystem to determine if
 // the current host supports MPTCP.
 var mptcpEnabled = func() (bool, error) {
@@ -65,14 +96,30 @@ var mptcpEnabled = func() (bool, error) {
 		return true, nil
 	}
 
-	// If table does not exist, return false, but do not return
-	// the accompanying error
-	if os.IsNotExist(err) {
+	// Return any error other than a missing table
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	// Mainline kernels have no connections table, so check sysctls
+	return sysctlEnabled(NewSysctl())
+}
+
+// sysctlEnabled uses sysctls to determine if the current host supports
+// MPTCP on a mainline kernel.
+func sysctlEnabled(s *Sysctl) (bool, error) {
+	f, err := s.Flavor()
+	if err != nil {
+		return false, err
+	}
+
+	// If sysctls do not exist, return false, but do not return
+	// an error
+	if f != FlavorMainline {
 		return false, nil
 	}
 
-	// Return any other error
-	return false, err
+	return s.Enabled()
 }
 
 // hostToHex converts an input host IP address into its equivalent hex form,
@@ -124,6 +171,39 @@ var lookupMPTCPLinux = func(hexHostPort string) (bool, error) {
 	return mptcpTableReaderLinux(mptcpFile, hexHostPort)
 }
 
+// lookupDiagLinux uses sock_diag to attempt to detect active MPTCP
+// connections from the input IP address and port on a mainline kernel.
+//
+// This implementation is swappable for testing with a mock data source.
+var lookupDiagLinux = func(ip net.IP, port uint16) (bool, error) {
+	entries, err := diagEntries()
+	if err != nil {
+		return false, err
+	}
+
+	return entriesContainLinux(entries, ip, port), nil
+}
+
+// entriesContainLinux checks if any of the input entries is a connection
+// from the input IP address and port which has not fallen back to TCP.
+func entriesContainLinux(entries []Entry, ip net.IP, port uint16) bool {
+	for _, e := range entries {
+		if e.Remote == nil || !e.Remote.IP.Equal(ip) || e.Remote.Port != int(port) {
+			continue
+		}
+
+		// Mainline kernels report connections which have fallen back
+		// to TCP, which are not using MPTCP
+		if e.Info != nil && e.Info.Fallback {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
 // mptcpTableReaderLinux reads a MPTCP connections table from an input stream.
 // This function allows easier testability with table parsing.
 func mptcpTableReaderLinux(r io.Reader, hexHostPort string) (bool, error) {
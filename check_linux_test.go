@@ -17,9 +17,13 @@ var (
 	testIPv6MPTCPEntry = []byte(" 0: F6635734 353F1E98  1 80A80426100000080000000001C07400:1F90 80A80426100000080000000001208902:93A5 01 01 00000000:00000000 39893")
 )
 
-// Swap in mock MPTCP lookup function for tests
+// Swap in mock MPTCP lookup function for tests, and use it even on
+// mainline kernels
 func init() {
 	lookupMPTCPLinux = generateMockLookupMPTCPLinux()
+	mptcpTableExists = func() (bool, error) {
+		return true, nil
+	}
 }
 
 // TestLinux_mptcpEnabled verifies that mptcpEnabled properly detects
@@ -35,8 +39,15 @@ func TestLinux_mptcpEnabled(t *testing.T) {
 	// connections table
 	_, err = os.Stat(procMPTCP)
 	if os.IsNotExist(err) {
-		if enabled {
-			t.Fatalf("could not find %s, but mptcpEnabled returned true", procMPTCP)
+		// Without a connections table, check mainline sysctls
+		sysctl, err := sysctlEnabled(NewSysctl())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if enabled != sysctl {
+			t.Fatalf("could not find %s, and sysctls reported %v, but mptcpEnabled returned %v",
+				procMPTCP, sysctl, enabled)
 		}
 
 		return
@@ -197,3 +208,107 @@ func generateMockLookupMPTCPLinux() func(string) (bool, error) {
 		return ok, nil
 	}
 }
+
+// TestLinux_sysctlEnabled verifies that sysctlEnabled only reports multipath
+// TCP as enabled on mainline kernels with the enabled sysctl set.
+func TestLinux_sysctlEnabled(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		files   map[string]string
+		enabled bool
+	}{
+		{"no sysctls", nil, false},
+		{"out-of-tree", map[string]string{"mptcp_enabled": "1"}, false},
+		{"mainline disabled", map[string]string{"enabled": "0"}, false},
+		{"mainline enabled", map[string]string{"enabled": "1"}, true},
+	}
+
+	for i, test := range tests {
+		enabled, err := sysctlEnabled(testSysctl(t, test.files))
+		if err != nil {
+			t.Fatalf("[%02d] %s: unexpected err: %v", i, test.desc, err)
+		}
+
+		if enabled != test.enabled {
+			t.Fatalf("[%02d] %s: unexpected enabled: %v != %v", i, test.desc, enabled, test.enabled)
+		}
+	}
+}
+
+// TestLinux_checkMPTCPDiag verifies that checkMPTCP uses sock_diag to detect
+// connections when the MPTCP connections table does not exist.
+func TestLinux_checkMPTCPDiag(t *testing.T) {
+	exists, lookup := mptcpTableExists, lookupDiagLinux
+	defer func() {
+		mptcpTableExists, lookupDiagLinux = exists, lookup
+	}()
+
+	mptcpTableExists = func() (bool, error) {
+		return false, nil
+	}
+	lookupDiagLinux = func(ip net.IP, port uint16) (bool, error) {
+		return entriesContainLinux([]Entry{
+			{Remote: &net.TCPAddr{IP: net.ParseIP(ipv4HostOne), Port: int(hostPorts[ipv4HostOne])}},
+			{Remote: &net.TCPAddr{IP: net.ParseIP(ipv6HostOne), Port: int(hostPorts[ipv6HostOne])}},
+		}, ip, port), nil
+	}
+
+	var tests = []struct {
+		host string
+		port uint16
+		ok   bool
+		err  error
+	}{
+		{badIPHostOne, 0, false, ErrInvalidIPAddress},
+		{ipv4HostOne, hostPorts[ipv4HostOne], true, nil},
+		{ipv4HostTwo, hostPorts[ipv4HostTwo], false, nil},
+		{ipv6HostOne, hostPorts[ipv6HostOne], true, nil},
+		{ipv6HostOne, 1, false, nil},
+	}
+
+	for i, test := range tests {
+		ok, err := checkMPTCP(test.host, test.port)
+		if err != test.err {
+			t.Fatalf("[%02d] unexpected err: %v != %v [test: %v]", i, err, test.err, test)
+		}
+
+		if ok != test.ok {
+			t.Fatalf("[%02d] unexpected ok: %v != %v [test: %v]", i, ok, test.ok, test)
+		}
+	}
+}
+
+// TestLinux_entriesContainLinux verifies that entriesContainLinux matches
+// entries using their remote address, and ignores connections which have
+// fallen back to TCP.
+func TestLinux_entriesContainLinux(t *testing.T) {
+	var (
+		ip4 = net.ParseIP(ipv4HostOne)
+		ip6 = net.ParseIP(ipv6HostOne)
+	)
+
+	entries := []Entry{
+		{Remote: &net.TCPAddr{IP: ip4.To4(), Port: 2020}},
+		{Remote: &net.TCPAddr{IP: ip6, Port: 2020}, Info: &Info{}},
+		{Remote: &net.TCPAddr{IP: ip6, Port: 4040}, Info: &Info{Fallback: true}},
+		{},
+	}
+
+	var tests = []struct {
+		ip   net.IP
+		port uint16
+		ok   bool
+	}{
+		{ip4, 2020, true},
+		{ip4, 4040, false},
+		{net.ParseIP(ipv4HostTwo), 2020, false},
+		{ip6, 2020, true},
+		{ip6, 4040, false},
+	}
+
+	for i, test := range tests {
+		if ok := entriesContainLinux(entries, test.ip, test.port); ok != test.ok {
+			t.Fatalf("[%02d] unexpected ok: %v != %v [test: %v]", i, ok, test.ok, test)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Command mptcpcap reconstructs multipath TCP connections and their subflows
+// from pcap and pcapng capture files.
+//
+// Subflows are grouped into connections using the keys exchanged in
+// MP_CAPABLE options and the tokens carried by MP_JOIN options.  Segments
+// from all files are analyzed together, so captures taken on each network
+// interface of a host may be combined.
+//
+// The data-level byte stream of each direction of each connection is
+// reassembled from the data of all of its subflows using their DSS mappings,
+// and may be written to files using the -w flag.
+//
+// With the -stats flag, a performance report is written instead, including
+// the bytes sent on each subflow over time, data-level reinjections and
+// head-of-line blocking, the delay until the first subflow joined each
+// connection, and the round-trip times of each subflow's path.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+func main() {
+	var (
+		format = flag.String("o", "text", "output format: text or json")
+		dir    = flag.String("w", "", "optional: directory to which the data-level byte streams of each connection are written")
+		stats  = flag.Bool("stats", false, "write a performance report instead of the connections report")
+		ival   = flag.Duration("i", 1*time.Second, "interval in which bytes sent on each subflow are counted, with -stats")
+	)
+
+	log.SetPrefix("mptcpcap: ")
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mptcpcap [flags] file.pcap [file.pcap ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	write, ok := writers[*format]
+	writeStats, sok := statsWriters[*format]
+	if !ok || !sok {
+		log.Fatalf("unknown output format %q", *format)
+	}
+	if *ival <= 0 {
+		log.Fatalf("interval must be positive: %s", *ival)
+	}
+
+	var segs []*capture.Segment
+	for _, path := range flag.Args() {
+		ss, bad, err := readFile(path)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", path, err)
+		}
+		if bad > 0 {
+			log.Printf("%s: skipped %d malformed packets", path, bad)
+		}
+
+		segs = append(segs, ss...)
+	}
+
+	c := capture.Analyze(segs)
+	if *dir != "" {
+		if err := writeStreams(*dir, c); err != nil {
+			log.Fatalf("failed to write streams: %v", err)
+		}
+	}
+
+	var err error
+	if *stats {
+		err = writeStats(os.Stdout, newStatsReport(c, *ival))
+	} else {
+		err = write(os.Stdout, newReport(c))
+	}
+	if err != nil {
+		log.Fatalf("failed to write output: %v", err)
+	}
+}
+
+// writeStreams writes the data-level byte streams of each connection in c to
+// files in dir, named by the index of the connection and the sender of the
+// stream, such as "0-client" and "0-server".
+func writeStreams(dir string, c *capture.Capture) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, conn := range c.Connections {
+		client, server := conn.Streams()
+		for _, st := range []*capture.Stream{client, server} {
+			name := filepath.Join(dir, fmt.Sprintf("%d-%s", i, direction(st.FromClient)))
+			if err := os.WriteFile(name, st.Data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readFile reads the TCP segments of the capture file at path.
+func readFile(path string) ([]*capture.Segment, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return capture.ReadSegments(r)
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// A report is the output for a capture.
+type report struct {
+	Connections []connection `json:"connections"`
+	Orphans     []subflow    `json:"orphans"`
+}
+
+// A connection is the output for a single connection.
+type connection struct {
+	Version     int       `json:"version"`
+	ClientKey   string    `json:"client_key,omitempty"`
+	ServerKey   string    `json:"server_key,omitempty"`
+	ClientToken string    `json:"client_token,omitempty"`
+	ServerToken string    `json:"server_token,omitempty"`
+	Checksum    bool      `json:"checksum"`
+	Fallback    bool      `json:"fallback"`
+	Subflows    []subflow `json:"subflows"`
+	Events      []event   `json:"events"`
+	Streams     []stream  `json:"streams"`
+}
+
+// A subflow is the output for a single subflow.
+type subflow struct {
+	Client   string    `json:"client"`
+	Server   string    `json:"server"`
+	Initial  bool      `json:"initial"`
+	Token    string    `json:"token,omitempty"`
+	ClientID int       `json:"client_id"`
+	ServerID int       `json:"server_id"`
+	Backup   bool      `json:"backup"`
+	Auth     string    `json:"auth,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Segments int       `json:"segments"`
+	Bytes    int       `json:"bytes"`
+}
+
+// An event is the output for a single connection event.
+type event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Subflow int       `json:"subflow"`
+	From    string    `json:"from"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// A stream is the output for one direction of a connection's data-level
+// byte stream.
+type stream struct {
+	From     string  `json:"from"`
+	Start    uint64  `json:"start"`
+	Bytes    int     `json:"bytes"`
+	FIN      bool    `json:"fin"`
+	Mappings int     `json:"mappings"`
+	Issues   []issue `json:"issues"`
+}
+
+// An issue is the output for a single stream reassembly issue.
+type issue struct {
+	Type    string     `json:"type"`
+	Time    *time.Time `json:"time,omitempty"`
+	Subflow *int       `json:"subflow,omitempty"`
+	DSN     uint64     `json:"dsn"`
+	Length  int        `json:"length"`
+	Detail  string     `json:"detail,omitempty"`
+}
+
+// newReport creates a report for c.
+func newReport(c *capture.Capture) report {
+	r := report{
+		Connections: make([]connection, 0, len(c.Connections)),
+		Orphans:     make([]subflow, 0, len(c.Orphans)),
+	}
+
+	for _, conn := range c.Connections {
+		r.Connections = append(r.Connections, newConnection(conn))
+	}
+	for _, sf := range c.Orphans {
+		r.Orphans = append(r.Orphans, newSubflow(sf))
+	}
+
+	return r
+}
+
+// newConnection creates the output for conn.
+func newConnection(conn *capture.Connection) connection {
+	c := connection{
+		Version:  int(conn.Version),
+		Checksum: conn.Checksum,
+		Fallback: conn.Fallback,
+		Subflows: make([]subflow, 0, len(conn.Subflows)),
+		Events:   make([]event, 0, len(conn.Events)),
+	}
+
+	if conn.ClientKey != 0 {
+		c.ClientKey = fmt.Sprintf("%016x", conn.ClientKey)
+		c.ClientToken = conn.ClientToken.String()
+	}
+	if conn.ServerKey != 0 {
+		c.ServerKey = fmt.Sprintf("%016x", conn.ServerKey)
+		c.ServerToken = conn.ServerToken.String()
+	}
+
+	index := make(map[*capture.Subflow]int)
+	for i, sf := range conn.Subflows {
+		index[sf] = i
+		c.Subflows = append(c.Subflows, newSubflow(sf))
+	}
+
+	for _, e := range conn.Events {
+		c.Events = append(c.Events, event{
+			Time:    e.Time,
+			Type:    e.Type.String(),
+			Subflow: index[e.Subflow],
+			From:    e.From.String(),
+			Detail:  describe(e),
+		})
+	}
+
+	client, server := conn.Streams()
+	c.Streams = []stream{
+		newStream(client, index),
+		newStream(server, index),
+	}
+
+	return c
+}
+
+// newStream creates the output for st, using index to find the index of
+// each subflow.
+func newStream(st *capture.Stream, index map[*capture.Subflow]int) stream {
+	s := stream{
+		From:     direction(st.FromClient),
+		Start:    st.Start,
+		Bytes:    len(st.Data),
+		FIN:      st.FIN,
+		Mappings: len(st.Mappings),
+		Issues:   make([]issue, 0, len(st.Issues)),
+	}
+
+	for _, is := range st.Issues {
+		i := issue{
+			Type:   is.Type.String(),
+			DSN:    is.DSN,
+			Length: is.Length,
+			Detail: is.Detail,
+		}
+		if is.Subflow != nil {
+			t, sf := is.Time, index[is.Subflow]
+			i.Time, i.Subflow = &t, &sf
+		}
+
+		s.Issues = append(s.Issues, i)
+	}
+
+	return s
+}
+
+// direction returns the name of the sender of a stream.
+func direction(fromClient bool) string {
+	if fromClient {
+		return "client"
+	}
+
+	return "server"
+}
+
+// newSubflow creates the output for sf.
+func newSubflow(sf *capture.Subflow) subflow {
+	s := subflow{
+		Client:   sf.Client.String(),
+		Server:   sf.Server.String(),
+		Initial:  sf.Initial,
+		ClientID: int(sf.ClientID),
+		ServerID: int(sf.ServerID),
+		Backup:   sf.Backup,
+		Start:    sf.Start,
+		End:      sf.End,
+		Segments: len(sf.Segments),
+	}
+
+	if !sf.Initial {
+		s.Token = sf.Token.String()
+		s.Auth = sf.Auth.String()
+	}
+
+	for _, seg := range sf.Segments {
+		s.Bytes += seg.Len
+	}
+
+	return s
+}
+
+// describe describes the details of an event.
+func describe(e capture.Event) string {
+	var ss []string
+	if e.Detail != "" && e.Type != capture.EventReset {
+		ss = append(ss, e.Detail)
+	}
+
+	switch o := e.Option.(type) {
+	case *option.AddAddr:
+		if o.Echo {
+			ss = append(ss, "echo")
+		}
+		ss = append(ss, fmt.Sprintf("id=%d addr=%s", o.AddressID, o.Address))
+		if o.Port != 0 {
+			ss = append(ss, fmt.Sprintf("port=%d", o.Port))
+		}
+		if !o.Echo && o.Version > 0 {
+			ss = append(ss, "hmac="+e.Auth.String())
+		}
+	case *option.RemoveAddr:
+		ids := make([]string, 0, len(o.AddressIDs))
+		for _, id := range o.AddressIDs {
+			ids = append(ids, fmt.Sprint(id))
+		}
+		ss = append(ss, "ids="+strings.Join(ids, ","))
+	case *option.MPPrio:
+		ss = append(ss, fmt.Sprintf("backup=%t", o.Backup))
+		if o.HasAddressID {
+			ss = append(ss, fmt.Sprintf("id=%d", o.AddressID))
+		}
+	case *option.MPFastclose:
+		ss = append(ss, fmt.Sprintf("key=%016x", o.ReceiverKey))
+	case *option.MPFail:
+		ss = append(ss, fmt.Sprintf("dsn=%d", o.DSN))
+	case *option.MPTCPRst:
+		ss = append(ss, fmt.Sprintf("reason=%q transient=%t", o.Reason.String(), o.Transient))
+	}
+
+	if e.Type == capture.EventReset && e.Option == nil {
+		ss = append(ss, "without MP_TCPRST")
+	}
+
+	return strings.Join(ss, " ")
+}
+
+// writers are the functions which write a report in each output format.
+var writers = map[string]func(w io.Writer, r report) error{
+	"text": writeText,
+	"json": writeJSON,
+}
+
+// writeJSON writes a report as JSON.
+func writeJSON(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// timeFormat is the format of times in text output.
+const timeFormat = "15:04:05.000000"
+
+// writeText writes a report as text, with a section for each connection.
+func writeText(w io.Writer, r report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for i, c := range r.Connections {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		var flags string
+		if c.Checksum {
+			flags += ", checksum"
+		}
+		if c.Fallback {
+			flags += ", fallback"
+		}
+		fmt.Fprintf(tw, "connection %d: version %d, %d subflows%s\n",
+			i, c.Version, len(c.Subflows), flags)
+		fmt.Fprintf(tw, "  client key %s token %s\n", dash(c.ClientKey), dash(c.ClientToken))
+		fmt.Fprintf(tw, "  server key %s token %s\n", dash(c.ServerKey), dash(c.ServerToken))
+
+		fmt.Fprintln(tw, "  subflows:")
+		for j, sf := range c.Subflows {
+			writeSubflow(tw, j, sf)
+		}
+
+		fmt.Fprintln(tw, "  events:")
+		for _, e := range c.Events {
+			fmt.Fprintf(tw, "    %s\t%s\t[%d]\tfrom %s\t%s\n",
+				e.Time.Format(timeFormat), e.Type, e.Subflow, e.From, e.Detail)
+		}
+
+		fmt.Fprintln(tw, "  streams:")
+		for _, st := range c.Streams {
+			writeStream(tw, st)
+		}
+	}
+
+	if len(r.Orphans) > 0 {
+		if len(r.Connections) > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		fmt.Fprintf(tw, "orphaned subflows: %d\n", len(r.Orphans))
+		for j, sf := range r.Orphans {
+			writeSubflow(tw, j, sf)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// writeSubflow writes a single subflow as text.
+func writeSubflow(w io.Writer, i int, sf subflow) {
+	kind := "initial"
+	if !sf.Initial {
+		kind = fmt.Sprintf("join token %s ids %d/%d backup=%t hmac=%s",
+			sf.Token, sf.ClientID, sf.ServerID, sf.Backup, sf.Auth)
+	}
+
+	fmt.Fprintf(w, "    [%d]\t%s -> %s\t%s - %s\t%d segments\t%d bytes\t%s\n",
+		i, sf.Client, sf.Server, sf.Start.Format(timeFormat), sf.End.Format(timeFormat),
+		sf.Segments, sf.Bytes, kind)
+}
+
+// writeStream writes a single stream and its issues as text.
+func writeStream(w io.Writer, st stream) {
+	var fin string
+	if st.FIN {
+		fin = "\tfin"
+	}
+
+	fmt.Fprintf(w, "    %s\t%d bytes\t%d mappings\t%d issues%s\n",
+		st.From, st.Bytes, st.Mappings, len(st.Issues), fin)
+
+	for _, is := range st.Issues {
+		sf := "-"
+		if is.Subflow != nil {
+			sf = fmt.Sprintf("[%d]", *is.Subflow)
+		}
+
+		fmt.Fprintf(w, "      %s\t%s\tdsn %d\tlength %d\t%s\n",
+			is.Type, sf, is.DSN, is.Length, is.Detail)
+	}
+}
+
+// dash returns s, or "-" if s is empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
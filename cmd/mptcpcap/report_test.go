@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// TestDescribe verifies the details written for each type of event.
+func TestDescribe(t *testing.T) {
+	var tests = []struct {
+		desc string
+		e    capture.Event
+		s    string
+	}{
+		{
+			desc: "subflow",
+			e:    capture.Event{Type: capture.EventSubflow, Detail: "join"},
+			s:    "join",
+		},
+		{
+			desc: "add_addr",
+			e: capture.Event{
+				Type: capture.EventAddAddr,
+				Option: &option.AddAddr{
+					Version:   1,
+					AddressID: 2,
+					Address:   netip.MustParseAddr("2001:db8::1"),
+					Port:      443,
+				},
+				Auth: capture.AuthValid,
+			},
+			s: "id=2 addr=2001:db8::1 port=443 hmac=valid",
+		},
+		{
+			desc: "add_addr echo",
+			e: capture.Event{
+				Type: capture.EventAddAddr,
+				Option: &option.AddAddr{
+					Version:   1,
+					Echo:      true,
+					AddressID: 1,
+					Address:   netip.MustParseAddr("192.0.2.1"),
+				},
+			},
+			s: "echo id=1 addr=192.0.2.1",
+		},
+		{
+			desc: "remove_addr",
+			e: capture.Event{
+				Type:   capture.EventRemoveAddr,
+				Option: &option.RemoveAddr{AddressIDs: []uint8{1, 3}},
+			},
+			s: "ids=1,3",
+		},
+		{
+			desc: "priority",
+			e: capture.Event{
+				Type:   capture.EventPriority,
+				Option: &option.MPPrio{Backup: true},
+			},
+			s: "backup=true",
+		},
+		{
+			desc: "fallback",
+			e: capture.Event{
+				Type:   capture.EventFallback,
+				Option: &option.MPFail{DSN: 10},
+				Detail: "MP_FAIL",
+			},
+			s: "MP_FAIL dsn=10",
+		},
+		{
+			desc: "reset",
+			e: capture.Event{
+				Type:   capture.EventReset,
+				Option: &option.MPTCPRst{Transient: true, Reason: option.ResetLackOfResources},
+				Detail: "lack of resources",
+			},
+			s: `reason="lack of resources" transient=true`,
+		},
+		{
+			desc: "reset without reason",
+			e:    capture.Event{Type: capture.EventReset},
+			s:    "without MP_TCPRST",
+		},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.s, describe(tt.e); want != got {
+			t.Fatalf("[%02d] test %q, unexpected details:\n- want: %q\n-  got: %q",
+				i, tt.desc, want, got)
+		}
+	}
+}
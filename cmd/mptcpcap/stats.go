@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mdlayher/mptcp/capture"
+)
+
+// A statsReport is the performance report for a capture.
+type statsReport struct {
+	Interval    time.Duration     `json:"-"`
+	IntervalUS  int64             `json:"interval_us"`
+	Connections []connectionStats `json:"connections"`
+}
+
+// A connectionStats is the performance report for a single connection.
+type connectionStats struct {
+	Subflows        []subflowStats `json:"subflows"`
+	Client          streamStats    `json:"client"`
+	Server          streamStats    `json:"server"`
+	JoinDelayUS     int64          `json:"join_delay_us"`
+	RTTDifferenceUS int64          `json:"rtt_difference_us"`
+}
+
+// A subflowStats is the performance report for a single subflow.
+type subflowStats struct {
+	Client         string     `json:"client"`
+	Server         string     `json:"server"`
+	FromClient     int        `json:"from_client"`
+	FromServer     int        `json:"from_server"`
+	HandshakeRTTUS int64      `json:"handshake_rtt_us"`
+	RTTUS          int64      `json:"rtt_us"`
+	ClientRTT      rttStats   `json:"client_rtt"`
+	ServerRTT      rttStats   `json:"server_rtt"`
+	Intervals      []interval `json:"intervals"`
+}
+
+// An rttStats is the output for round-trip time samples.
+type rttStats struct {
+	Samples int   `json:"samples"`
+	MinUS   int64 `json:"min_us"`
+	MeanUS  int64 `json:"mean_us"`
+}
+
+// An interval is the output for the bytes sent on a subflow during an
+// interval.
+type interval struct {
+	Start      time.Time `json:"start"`
+	FromClient int       `json:"from_client"`
+	FromServer int       `json:"from_server"`
+}
+
+// A streamStats is the performance report for one direction of a
+// connection's data-level byte stream.
+type streamStats struct {
+	Bytes           int   `json:"bytes"`
+	Reinjections    int   `json:"reinjections"`
+	ReinjectedBytes int   `json:"reinjected_bytes"`
+	OutOfOrder      int   `json:"out_of_order"`
+	HeadOfLineUS    int64 `json:"head_of_line_us"`
+	HeadOfLineMaxUS int64 `json:"head_of_line_max_us"`
+	HeadOfLineCount int   `json:"head_of_line_count"`
+}
+
+// newStatsReport creates a performance report for c, counting bytes sent on
+// each subflow in intervals of length d.
+func newStatsReport(c *capture.Capture, d time.Duration) statsReport {
+	r := statsReport{
+		Interval:    d,
+		IntervalUS:  d.Microseconds(),
+		Connections: make([]connectionStats, 0, len(c.Connections)),
+	}
+
+	for _, conn := range c.Connections {
+		st := conn.Stats(d)
+
+		cs := connectionStats{
+			Subflows:        make([]subflowStats, 0, len(st.Subflows)),
+			Client:          newStreamStats(st.Client),
+			Server:          newStreamStats(st.Server),
+			JoinDelayUS:     st.JoinDelay.Microseconds(),
+			RTTDifferenceUS: st.RTTDifference.Microseconds(),
+		}
+
+		for _, ss := range st.Subflows {
+			s := subflowStats{
+				Client:         ss.Subflow.Client.String(),
+				Server:         ss.Subflow.Server.String(),
+				FromClient:     ss.FromClient,
+				FromServer:     ss.FromServer,
+				HandshakeRTTUS: ss.HandshakeRTT.Microseconds(),
+				RTTUS:          ss.RTT.Microseconds(),
+				ClientRTT:      newRTTStats(ss.ClientRTT),
+				ServerRTT:      newRTTStats(ss.ServerRTT),
+				Intervals:      make([]interval, 0, len(ss.Intervals)),
+			}
+
+			for _, i := range ss.Intervals {
+				s.Intervals = append(s.Intervals, interval{
+					Start:      i.Start,
+					FromClient: i.FromClient,
+					FromServer: i.FromServer,
+				})
+			}
+
+			cs.Subflows = append(cs.Subflows, s)
+		}
+
+		r.Connections = append(r.Connections, cs)
+	}
+
+	return r
+}
+
+// newRTTStats creates the output for st.
+func newRTTStats(st capture.RTTStats) rttStats {
+	return rttStats{
+		Samples: st.Samples,
+		MinUS:   st.Min.Microseconds(),
+		MeanUS:  st.Mean.Microseconds(),
+	}
+}
+
+// newStreamStats creates the output for st.
+func newStreamStats(st capture.StreamStats) streamStats {
+	return streamStats{
+		Bytes:           st.Bytes,
+		Reinjections:    st.Reinjections,
+		ReinjectedBytes: st.ReinjectedBytes,
+		OutOfOrder:      st.OutOfOrder,
+		HeadOfLineUS:    st.HeadOfLine.Microseconds(),
+		HeadOfLineMaxUS: st.HeadOfLineMax.Microseconds(),
+		HeadOfLineCount: st.HeadOfLineCount,
+	}
+}
+
+// statsWriters are the functions which write a performance report in each
+// output format.
+var statsWriters = map[string]func(w io.Writer, r statsReport) error{
+	"text": writeStatsText,
+	"json": writeStatsJSON,
+}
+
+// writeStatsJSON writes a performance report as JSON.
+func writeStatsJSON(w io.Writer, r statsReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeStatsText writes a performance report as a text summary, with a
+// section for each connection.
+func writeStatsText(w io.Writer, r statsReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for i, c := range r.Connections {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		fmt.Fprintf(tw, "connection %d: %d subflows, join delay %s, rtt difference %s\n",
+			i, len(c.Subflows), us(c.JoinDelayUS), us(c.RTTDifferenceUS))
+
+		fmt.Fprintln(tw, "  subflows:")
+		for j, sf := range c.Subflows {
+			fmt.Fprintf(tw, "    [%d]\t%s -> %s\trtt %s\thandshake %s\tclient min %s n=%d\tserver min %s n=%d\t%d/%d bytes\n",
+				j, sf.Client, sf.Server, us(sf.RTTUS), us(sf.HandshakeRTTUS),
+				us(sf.ClientRTT.MinUS), sf.ClientRTT.Samples,
+				us(sf.ServerRTT.MinUS), sf.ServerRTT.Samples,
+				sf.FromClient, sf.FromServer)
+		}
+
+		fmt.Fprintln(tw, "  streams:")
+		for _, s := range []struct {
+			from string
+			st   streamStats
+		}{
+			{"client", c.Client},
+			{"server", c.Server},
+		} {
+			fmt.Fprintf(tw, "    %s\t%d bytes\t%d reinjections (%d bytes)\t%d out of order\thead-of-line %s total, %s max, %d periods\n",
+				s.from, s.st.Bytes, s.st.Reinjections, s.st.ReinjectedBytes, s.st.OutOfOrder,
+				us(s.st.HeadOfLineUS), us(s.st.HeadOfLineMaxUS), s.st.HeadOfLineCount)
+		}
+
+		if len(c.Subflows) == 0 || len(c.Subflows[0].Intervals) == 0 {
+			continue
+		}
+
+		// Bytes sent by the client and server of the connection on each
+		// subflow, in each interval.
+		fmt.Fprintf(tw, "  bytes per %s:\n", r.Interval)
+		fmt.Fprint(tw, "    time")
+		for j := range c.Subflows {
+			fmt.Fprintf(tw, "\t[%d]", j)
+		}
+		fmt.Fprintln(tw)
+
+		start := c.Subflows[0].Intervals[0].Start
+		for k, iv := range c.Subflows[0].Intervals {
+			fmt.Fprintf(tw, "    +%s", iv.Start.Sub(start))
+			for _, sf := range c.Subflows {
+				iv := sf.Intervals[k]
+				fmt.Fprintf(tw, "\t%d/%d", iv.FromClient, iv.FromServer)
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// us formats a duration in microseconds.
+func us(n int64) string {
+	return (time.Duration(n) * time.Microsecond).String()
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/mptcp/pm"
+	"github.com/mdlayher/netlink"
+)
+
+// A config is the desired state of the path manager in one or more network
+// namespaces, as read by apply.
+type config struct {
+	Namespaces []namespaceConfig `json:"namespaces"`
+}
+
+// A namespaceConfig is the desired state of the path manager in a single
+// network namespace.  An empty Name selects the current namespace.
+type namespaceConfig struct {
+	Name      string           `json:"name"`
+	Limits    *limitsConfig    `json:"limits"`
+	Endpoints []endpointConfig `json:"endpoints"`
+}
+
+// A limitsConfig is the configuration for pm.Limits.
+type limitsConfig struct {
+	Subflows        int `json:"subflows"`
+	AddAddrAccepted int `json:"add_addr_accepted"`
+}
+
+// An endpointConfig is the configuration for a pm.Endpoint.  Interface may
+// be the name or index of a network interface.
+type endpointConfig struct {
+	ID        uint8    `json:"id"`
+	Address   string   `json:"address"`
+	Port      int      `json:"port"`
+	Interface string   `json:"interface"`
+	Flags     pm.Flags `json:"flags"`
+}
+
+// apply implements the apply subcommand.
+func apply(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	var (
+		file   = fs.String("f", "-", "JSON file containing the desired state, or - for stdin")
+		dryRun = fs.Bool("dry-run", false, "print the changes which would be made, without making them")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mptcpctl apply [-dry-run] [-f file]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	cfg, err := readConfig(*file)
+	if err != nil {
+		log.Fatalf("failed to read configuration: %v", err)
+	}
+
+	for _, ns := range cfg.Namespaces {
+		if err := applyNamespace(os.Stdout, ns, *dryRun); err != nil {
+			log.Fatalf("%s: %v", namespaceLabel(ns.Name), err)
+		}
+	}
+}
+
+// readConfig reads and validates a config from a file, or from stdin if file
+// is "-".
+func readConfig(file string) (*config, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return parseConfig(r)
+}
+
+// parseConfig parses and validates a config from r.
+func parseConfig(r io.Reader) (*config, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+
+	var cfg config
+	if err := d.Decode(&cfg); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, ns := range cfg.Namespaces {
+		if ns.Name != "" {
+			// Check names up front, rather than after other namespaces
+			// have been changed.
+			if _, err := pm.NamespacePath(ns.Name); err != nil {
+				return nil, err
+			}
+		}
+		if seen[ns.Name] {
+			return nil, fmt.Errorf("duplicate configuration for %s", namespaceLabel(ns.Name))
+		}
+		seen[ns.Name] = true
+	}
+
+	return &cfg, nil
+}
+
+// applyNamespace plans the changes needed to reach the desired state of a
+// single namespace, prints them to w, and applies them unless dryRun is set.
+func applyNamespace(w io.Writer, ns namespaceConfig, dryRun bool) error {
+	desired, err := desiredState(ns)
+	if err != nil {
+		return err
+	}
+
+	c, err := pm.DialNamespace(ns.Name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	changes, err := c.Plan(*desired)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "%s: up to date\n", namespaceLabel(ns.Name))
+		return nil
+	}
+
+	verb := "applying"
+	if dryRun {
+		verb = "would apply"
+	}
+
+	fmt.Fprintf(w, "%s: %s %d change(s):\n", namespaceLabel(ns.Name), verb, len(changes))
+	for _, ch := range changes {
+		fmt.Fprintf(w, "\t%s\n", ch)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	return c.Apply(changes)
+}
+
+// desiredState converts a namespaceConfig into a pm.State, resolving
+// interface names within the namespace.
+func desiredState(ns namespaceConfig) (*pm.State, error) {
+	var s pm.State
+	if ns.Limits != nil {
+		s.Limits = &pm.Limits{
+			Subflows:        ns.Limits.Subflows,
+			AddAddrAccepted: ns.Limits.AddAddrAccepted,
+		}
+	}
+
+	// Interface names are only resolved if needed
+	var ifis map[string]int
+
+	s.Endpoints = make([]pm.Endpoint, 0, len(ns.Endpoints))
+	for _, ec := range ns.Endpoints {
+		ip := net.ParseIP(ec.Address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid endpoint address %q", ec.Address)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		e := pm.Endpoint{
+			ID:      ec.ID,
+			Address: ip,
+			Port:    ec.Port,
+			Flags:   ec.Flags,
+		}
+
+		if ec.Interface != "" {
+			if idx, err := strconv.Atoi(ec.Interface); err == nil {
+				e.Interface = idx
+			} else {
+				if ifis == nil {
+					if ifis, err = interfaceIndexes(ns.Name); err != nil {
+						return nil, fmt.Errorf("failed to list interfaces: %v", err)
+					}
+				}
+
+				idx, ok := ifis[ec.Interface]
+				if !ok {
+					return nil, fmt.Errorf("endpoint %s: unknown interface %q", ec.Address, ec.Interface)
+				}
+				e.Interface = idx
+			}
+		}
+
+		s.Endpoints = append(s.Endpoints, e)
+	}
+
+	return &s, nil
+}
+
+// interfaceIndexes returns the indexes of all network interfaces in the
+// named network namespace, keyed by name.
+func interfaceIndexes(netns string) (map[string]int, error) {
+	var cfg *netlink.Config
+	if netns != "" {
+		path, err := pm.NamespacePath(netns)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		cfg = &netlink.Config{NetNS: int(f.Fd())}
+	}
+
+	c, err := rtnetlink.Dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	links, err := c.Link.List()
+	if err != nil {
+		return nil, err
+	}
+
+	ifis := make(map[string]int, len(links))
+	for _, l := range links {
+		if l.Attributes != nil {
+			ifis[l.Attributes.Name] = int(l.Index)
+		}
+	}
+
+	return ifis, nil
+}
+
+// namespaceLabel describes a network namespace in output.
+func namespaceLabel(name string) string {
+	if name == "" {
+		return "current namespace"
+	}
+
+	return fmt.Sprintf("namespace %q", name)
+}
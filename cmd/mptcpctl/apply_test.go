@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// TestParseConfig verifies that parseConfig parses namespaces and rejects
+// invalid configurations.
+func TestParseConfig(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		s     string
+		names []string
+		ok    bool
+	}{
+		{
+			desc: "OK",
+			s: `{"namespaces": [
+				{"limits": {"subflows": 2, "add_addr_accepted": 2}},
+				{"name": "blue", "endpoints": [
+					{"id": 1, "address": "192.0.2.1", "interface": "eth0", "flags": "signal"}
+				]}
+			]}`,
+			names: []string{"", "blue"},
+			ok:    true,
+		},
+		{
+			desc: "empty",
+			s:    `{}`,
+			ok:   true,
+		},
+		{
+			desc: "malformed",
+			s:    `{"namespaces": [`,
+		},
+		{
+			desc: "unknown field",
+			s:    `{"namespaces": [{"name": "blue", "foo": 1}]}`,
+		},
+		{
+			desc: "bad flags",
+			s:    `{"namespaces": [{"endpoints": [{"address": "192.0.2.1", "flags": "foo"}]}]}`,
+		},
+		{
+			desc: "duplicate current namespace",
+			s:    `{"namespaces": [{}, {"name": ""}]}`,
+		},
+		{
+			desc: "duplicate namespace",
+			s:    `{"namespaces": [{"name": "blue"}, {"name": "blue"}]}`,
+		},
+		{
+			desc: "path",
+			s:    `{"namespaces": [{"name": "a/b"}]}`,
+		},
+		{
+			desc: "absolute path",
+			s:    `{"namespaces": [{"name": "/proc/1/ns/net"}]}`,
+		},
+		{
+			desc: "parent directory",
+			s:    `{"namespaces": [{"name": ".."}]}`,
+		},
+	}
+
+	for i, tt := range tests {
+		cfg, err := parseConfig(strings.NewReader(tt.s))
+		if err != nil && tt.ok {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.desc, err)
+		}
+		if err == nil && !tt.ok {
+			t.Fatalf("[%02d] test %q, expected an error, but none occurred", i, tt.desc)
+		}
+		if err != nil {
+			continue
+		}
+
+		var names []string
+		for _, ns := range cfg.Namespaces {
+			names = append(names, ns.Name)
+		}
+
+		if want, got := tt.names, names; !reflect.DeepEqual(want, got) {
+			t.Fatalf("[%02d] test %q, unexpected namespaces:\n- want: %q\n-  got: %q",
+				i, tt.desc, want, got)
+		}
+	}
+}
+
+// TestDesiredState verifies that desiredState converts a namespaceConfig into
+// a pm.State.
+func TestDesiredState(t *testing.T) {
+	var tests = []struct {
+		desc string
+		ns   namespaceConfig
+		s    *pm.State
+		ok   bool
+	}{
+		{
+			desc: "empty",
+			s: &pm.State{
+				Endpoints: []pm.Endpoint{},
+			},
+			ok: true,
+		},
+		{
+			desc: "limits",
+			ns: namespaceConfig{
+				Limits: &limitsConfig{Subflows: 4, AddAddrAccepted: 2},
+			},
+			s: &pm.State{
+				Limits:    &pm.Limits{Subflows: 4, AddAddrAccepted: 2},
+				Endpoints: []pm.Endpoint{},
+			},
+			ok: true,
+		},
+		{
+			desc: "endpoints",
+			ns: namespaceConfig{
+				Endpoints: []endpointConfig{
+					{
+						ID:        1,
+						Address:   "192.0.2.1",
+						Interface: "2",
+						Flags:     pm.FlagSignal,
+					},
+					{
+						ID:      2,
+						Address: "2001:db8::1",
+						Port:    8080,
+						Flags:   pm.FlagSubflow | pm.FlagBackup,
+					},
+				},
+			},
+			s: &pm.State{
+				Endpoints: []pm.Endpoint{
+					{
+						ID:        1,
+						Address:   net.IPv4(192, 0, 2, 1).To4(),
+						Interface: 2,
+						Flags:     pm.FlagSignal,
+					},
+					{
+						ID:      2,
+						Address: net.ParseIP("2001:db8::1"),
+						Port:    8080,
+						Flags:   pm.FlagSubflow | pm.FlagBackup,
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "bad address",
+			ns: namespaceConfig{
+				Endpoints: []endpointConfig{{Address: "192.0.2"}},
+			},
+		},
+		{
+			desc: "no address",
+			ns: namespaceConfig{
+				Endpoints: []endpointConfig{{ID: 1}},
+			},
+		},
+		{
+			desc: "bad namespace",
+			ns: namespaceConfig{
+				Name:      "a/b",
+				Endpoints: []endpointConfig{{Address: "192.0.2.1", Interface: "eth0"}},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		s, err := desiredState(tt.ns)
+		if err != nil && tt.ok {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.desc, err)
+		}
+		if err == nil && !tt.ok {
+			t.Fatalf("[%02d] test %q, expected an error, but none occurred", i, tt.desc)
+		}
+
+		if want, got := tt.s, s; !reflect.DeepEqual(want, got) {
+			t.Fatalf("[%02d] test %q, unexpected state:\n- want: %+v\n-  got: %+v",
+				i, tt.desc, want, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Command mptcpctl manages the Linux kernel's multipath TCP path manager.
+//
+// The apply subcommand reconciles the path manager's endpoints and limits in
+// one or more network namespaces with a desired state read from a JSON file,
+// performing only the changes needed.  Running it again once the desired
+// state is reached makes no changes, so it is safe to run repeatedly from
+// configuration management.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// usage is printed when mptcpctl is run without a valid subcommand.
+const usage = `usage: mptcpctl <command> [flags]
+
+commands:
+  apply    reconcile path manager endpoints and limits with a JSON file`
+
+func main() {
+	log.SetPrefix("mptcpctl: ")
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "apply":
+		apply(args)
+	default:
+		log.Printf("unknown command %q", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
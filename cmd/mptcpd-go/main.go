@@ -0,0 +1,134 @@
+// Command mptcpd-go is a daemon which manages multipath TCP path manager
+// endpoints as network interfaces and addresses come and go.
+//
+// mptcpd-go watches rtnetlink for link and address changes, and uses a policy
+// read from a JSON file to decide which addresses are used as endpoints and
+// with which flags.  mptcpd-go manages all endpoints in the current network
+// namespace: endpoints which are not produced by its policy are removed.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mdlayher/mptcp/pm"
+)
+
+var (
+	// config is the path to the policy file
+	config string
+
+	// dryRun logs changes without making them
+	dryRun bool
+
+	// resync is the interval at which endpoints are reconciled, even if
+	// no changes are reported
+	resync time.Duration
+)
+
+// settle is how long mptcpd-go waits after a change is reported before
+// reconciling endpoints, so that bursts of changes are handled together.
+const settle = 250 * time.Millisecond
+
+func init() {
+	// Set up flags
+	flag.StringVar(&config, "c", "/etc/mptcpd-go.json", "path to JSON policy file")
+	flag.BoolVar(&dryRun, "dry-run", false, "log endpoint changes without making them")
+	flag.DurationVar(&resync, "resync", time.Minute, "interval at which endpoints are reconciled, even without changes")
+}
+
+func main() {
+	log.SetPrefix("mptcpd-go: ")
+
+	// Parse flags
+	flag.Parse()
+
+	p, err := readPolicy(config)
+	if err != nil {
+		log.Fatalf("failed to read policy: %v", err)
+	}
+
+	c, err := pm.Dial()
+	if err != nil {
+		log.Fatalf("failed to open path manager: %v", err)
+	}
+	defer c.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	changes, err := watch(ctx)
+	if err != nil {
+		log.Fatalf("failed to watch network interfaces: %v", err)
+	}
+
+	// Reconcile immediately, and then whenever changes settle or resync
+	// elapses
+	reconcile(c, p)
+
+	timer := time.NewTimer(settle)
+	timer.Stop()
+
+	tick := time.NewTicker(resync)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("exiting")
+			return
+		case err, ok := <-changes:
+			if !ok {
+				// Only closed after ctx is canceled
+				changes = nil
+				continue
+			}
+			if err != nil {
+				log.Fatalf("failed to watch network interfaces: %v", err)
+			}
+
+			timer.Reset(settle)
+		case <-timer.C:
+			reconcile(c, p)
+		case <-tick.C:
+			reconcile(c, p)
+		}
+	}
+}
+
+// reconcile applies the changes needed to make the path manager's endpoints
+// match the policy for the current interfaces and addresses.  Errors are
+// logged, and retried on the next reconciliation.
+func reconcile(c *pm.Client, p *policy) {
+	ifis, addrs, err := snapshot()
+	if err != nil {
+		log.Printf("failed to list network interfaces: %v", err)
+		return
+	}
+
+	changes, err := c.Plan(p.state(ifis, addrs))
+	if err != nil {
+		log.Printf("failed to plan endpoint changes: %v", err)
+		return
+	}
+
+	for _, ch := range changes {
+		if dryRun {
+			log.Printf("would apply: %s", ch)
+		} else {
+			log.Printf("applying: %s", ch)
+		}
+	}
+	if dryRun {
+		return
+	}
+
+	if err := c.Apply(changes); err != nil {
+		log.Printf("failed to apply endpoint changes: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// +build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// snapshot returns the network interfaces and addresses currently present on
+// the host.
+func snapshot() ([]iface, []address, error) {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+
+	links, err := c.Link.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ifis := make([]iface, 0, len(links))
+	for _, l := range links {
+		if l.Attributes == nil {
+			continue
+		}
+
+		ifis = append(ifis, iface{
+			Index:    int(l.Index),
+			Name:     l.Attributes.Name,
+			Up:       l.Flags&unix.IFF_UP != 0 && l.Flags&unix.IFF_RUNNING != 0,
+			Loopback: l.Flags&unix.IFF_LOOPBACK != 0,
+		})
+	}
+
+	msgs, err := c.Address.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addrs := make([]address, 0, len(msgs))
+	for _, m := range msgs {
+		if m.Attributes == nil {
+			continue
+		}
+
+		// IFA_LOCAL is the local address of point-to-point interfaces,
+		// where IFA_ADDRESS is the peer's address
+		ip := m.Attributes.Local
+		if ip == nil {
+			ip = m.Attributes.Address
+		}
+		if ip == nil {
+			continue
+		}
+
+		flags := m.Attributes.Flags
+		if flags == 0 {
+			flags = uint32(m.Flags)
+		}
+
+		addrs = append(addrs, address{
+			Index:  int(m.Index),
+			IP:     ip,
+			Usable: flags&(unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) == 0,
+		})
+	}
+
+	return ifis, addrs, nil
+}
+
+// watch sends nil on the returned channel whenever network interfaces or their
+// addresses change, until ctx is canceled, and then closes the channel.
+// Notifications are coalesced, so one notification may represent many
+// changes.  If receiving changes fails, the error is sent before the channel
+// is closed.
+func watch(ctx context.Context) (<-chan error, error) {
+	c, err := rtnetlink.Dial(&netlink.Config{
+		Groups: unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Interrupt a blocked Receive when ctx is canceled.
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.SetReadDeadline(time.Unix(1, 0))
+	})
+
+	ch := make(chan error, 1)
+	notify := func(err error) {
+		select {
+		case ch <- err:
+		default:
+		}
+	}
+
+	go func() {
+		defer func() {
+			stop()
+			_ = c.Close()
+			close(ch)
+		}()
+
+		for {
+			_, _, err := c.Receive()
+			switch {
+			case ctx.Err() != nil:
+				return
+			case errors.Is(err, unix.ENOBUFS):
+				// Changes were dropped, so a full resync is needed
+				// anyway
+				notify(nil)
+			case err != nil:
+				// Replace any pending notification with the error
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- err
+				return
+			default:
+				notify(nil)
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,21 @@
+// +build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errUnimplemented is returned on platforms without rtnetlink.
+var errUnimplemented = errors.New("not implemented on this platform")
+
+// snapshot always returns an error.
+func snapshot() ([]iface, []address, error) {
+	return nil, nil, errUnimplemented
+}
+
+// watch always returns an error.
+func watch(_ context.Context) (<-chan error, error) {
+	return nil, errUnimplemented
+}
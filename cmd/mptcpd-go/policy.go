@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// A policy decides which local addresses are used as path manager endpoints,
+// and with which flags.
+type policy struct {
+	// Rules are evaluated in order for each address, and the first
+	// matching rule decides how the address is used.  Addresses which
+	// match no rule are not used.
+	Rules []rule `json:"rules"`
+
+	// Limits are the optional path manager limits to apply.
+	Limits *limits `json:"limits"`
+}
+
+// A rule matches addresses by interface name and prefix, and either excludes
+// them or creates endpoints with Flags.
+type rule struct {
+	// Interface is an optional shell pattern, as used by path.Match,
+	// which matches interface names.
+	Interface string `json:"interface"`
+
+	// Prefix is an optional CIDR prefix which matches addresses.
+	Prefix string `json:"prefix"`
+
+	// Exclude prevents matching addresses from being used.
+	Exclude bool `json:"exclude"`
+
+	// Flags are the endpoint flags used for matching addresses.
+	Flags pm.Flags `json:"flags"`
+
+	prefix *net.IPNet
+}
+
+// limits is the configuration for pm.Limits.
+type limits struct {
+	Subflows        int `json:"subflows"`
+	AddAddrAccepted int `json:"add_addr_accepted"`
+}
+
+// An iface is a network interface which may carry endpoints.
+type iface struct {
+	Index    int
+	Name     string
+	Up       bool
+	Loopback bool
+}
+
+// An address is an address assigned to a network interface.
+type address struct {
+	Index int
+	IP    net.IP
+
+	// Usable is false if the address is not yet or no longer usable, such
+	// as a tentative IPv6 address.
+	Usable bool
+}
+
+// errNoRules is returned when a policy contains no rules.
+var errNoRules = errors.New("policy contains no rules")
+
+// readPolicy reads and validates a policy from a JSON file.
+func readPolicy(file string) (*policy, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parsePolicy(f)
+}
+
+// parsePolicy parses and validates a policy from JSON.
+func parsePolicy(r io.Reader) (*policy, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+
+	var p policy
+	if err := d.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	if len(p.Rules) == 0 {
+		return nil, errNoRules
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+
+		if _, err := path.Match(r.Interface, ""); err != nil {
+			return nil, fmt.Errorf("rule %d: invalid interface pattern %q: %v", i, r.Interface, err)
+		}
+
+		if r.Prefix != "" {
+			_, ipn, err := net.ParseCIDR(r.Prefix)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i, err)
+			}
+			r.prefix = ipn
+		}
+
+		switch {
+		case r.Exclude && r.Flags != 0:
+			return nil, fmt.Errorf("rule %d: excluding rules may not set flags", i)
+		case !r.Exclude && r.Flags&(pm.FlagSignal|pm.FlagSubflow) == 0:
+			return nil, fmt.Errorf("rule %d: flags must include signal or subflow: %s", i, r.Flags)
+		case r.Flags&pm.FlagImplicit != 0:
+			return nil, fmt.Errorf("rule %d: the implicit flag is reserved for the kernel", i)
+		}
+	}
+
+	return &p, nil
+}
+
+// state returns the desired path manager state for the interfaces and
+// addresses present on the host.
+func (p *policy) state(ifis []iface, addrs []address) pm.State {
+	byIndex := make(map[int]iface, len(ifis))
+	for _, ifi := range ifis {
+		byIndex[ifi.Index] = ifi
+	}
+
+	var s pm.State
+	if p.Limits != nil {
+		s.Limits = &pm.Limits{
+			Subflows:        p.Limits.Subflows,
+			AddAddrAccepted: p.Limits.AddAddrAccepted,
+		}
+	}
+
+	// The same address may be assigned to more than one interface, but
+	// may only be used by one endpoint
+	seen := make(map[string]bool)
+
+	for _, a := range addrs {
+		ifi, ok := byIndex[a.Index]
+		if !ok || !ifi.Up || ifi.Loopback || !a.Usable {
+			continue
+		}
+
+		// Loopback and link-local addresses cannot reach peers
+		if a.IP.IsLoopback() || a.IP.IsLinkLocalUnicast() {
+			continue
+		}
+
+		r := p.match(ifi, a.IP)
+		if r == nil || r.Exclude {
+			continue
+		}
+
+		ip := a.IP
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		if seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+
+		s.Endpoints = append(s.Endpoints, pm.Endpoint{
+			Address:   ip,
+			Interface: ifi.Index,
+			Flags:     r.Flags,
+		})
+	}
+
+	return s
+}
+
+// match returns the first rule which matches an address on an interface, or
+// nil if none match.
+func (p *policy) match(ifi iface, ip net.IP) *rule {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+
+		if r.Interface != "" {
+			// Patterns were validated by parsePolicy
+			if ok, _ := path.Match(r.Interface, ifi.Name); !ok {
+				continue
+			}
+		}
+
+		if r.prefix != nil && !r.prefix.Contains(ip) {
+			continue
+		}
+
+		return r
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// TestParsePolicy verifies that parsePolicy rejects invalid policies.
+func TestParsePolicy(t *testing.T) {
+	var tests = []struct {
+		desc string
+		s    string
+		ok   bool
+	}{
+		{
+			desc: "OK",
+			s: `{"rules": [
+				{"interface": "docker*", "exclude": true},
+				{"prefix": "10.0.0.0/8", "flags": "subflow|backup"},
+				{"flags": "signal"}
+			], "limits": {"subflows": 2, "add_addr_accepted": 2}}`,
+			ok: true,
+		},
+		{
+			desc: "no rules",
+			s:    `{}`,
+		},
+		{
+			desc: "unknown field",
+			s:    `{"rules": [{"flags": "subflow", "foo": 1}]}`,
+		},
+		{
+			desc: "bad pattern",
+			s:    `{"rules": [{"interface": "eth[", "flags": "subflow"}]}`,
+		},
+		{
+			desc: "bad prefix",
+			s:    `{"rules": [{"prefix": "10.0.0.0", "flags": "subflow"}]}`,
+		},
+		{
+			desc: "exclude with flags",
+			s:    `{"rules": [{"exclude": true, "flags": "subflow"}]}`,
+		},
+		{
+			desc: "backup only",
+			s:    `{"rules": [{"flags": "backup"}]}`,
+		},
+		{
+			desc: "implicit",
+			s:    `{"rules": [{"flags": "subflow|implicit"}]}`,
+		},
+	}
+
+	for i, test := range tests {
+		_, err := parsePolicy(strings.NewReader(test.s))
+		if err != nil && test.ok {
+			t.Fatalf("[%02d] %s: unexpected error: %v", i, test.desc, err)
+		}
+		if err == nil && !test.ok {
+			t.Fatalf("[%02d] %s: expected an error, but none occurred", i, test.desc)
+		}
+	}
+}
+
+// TestPolicyState verifies that a policy produces endpoints for usable
+// addresses using the first matching rule.
+func TestPolicyState(t *testing.T) {
+	p, err := parsePolicy(strings.NewReader(`{"rules": [
+		{"interface": "docker*", "exclude": true},
+		{"prefix": "192.168.0.0/16", "exclude": true},
+		{"interface": "eth*", "flags": "signal"},
+		{"interface": "wlan*", "flags": "subflow|backup"},
+		{"prefix": "2001:db8::/32", "flags": "subflow"}
+	], "limits": {"subflows": 4, "add_addr_accepted": 2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ifis := []iface{
+		{Index: 1, Name: "lo", Up: true, Loopback: true},
+		{Index: 2, Name: "eth0", Up: true},
+		{Index: 3, Name: "wlan0", Up: true},
+		{Index: 4, Name: "docker0", Up: true},
+		{Index: 5, Name: "eth1"},
+		{Index: 6, Name: "wwan0", Up: true},
+	}
+
+	addrs := []address{
+		{Index: 1, IP: net.IPv4(127, 0, 0, 1), Usable: true},
+		{Index: 2, IP: net.IPv4(192, 0, 2, 1), Usable: true},
+		{Index: 2, IP: net.ParseIP("fe80::1"), Usable: true},
+		{Index: 2, IP: net.ParseIP("2001:db8::2"), Usable: false},
+		{Index: 3, IP: net.IPv4(198, 51, 100, 1), Usable: true},
+		{Index: 3, IP: net.IPv4(192, 168, 1, 10), Usable: true},
+		{Index: 4, IP: net.IPv4(172, 17, 0, 1), Usable: true},
+		{Index: 5, IP: net.IPv4(203, 0, 113, 1), Usable: true},
+		{Index: 6, IP: net.ParseIP("2001:db8::1"), Usable: true},
+		{Index: 6, IP: net.IPv4(100, 64, 0, 1), Usable: true},
+		// Duplicate of an address on eth0
+		{Index: 3, IP: net.IPv4(192, 0, 2, 1), Usable: true},
+	}
+
+	want := pm.State{
+		Limits: &pm.Limits{Subflows: 4, AddAddrAccepted: 2},
+		Endpoints: []pm.Endpoint{
+			{Address: net.IPv4(192, 0, 2, 1).To4(), Interface: 2, Flags: pm.FlagSignal},
+			{Address: net.IPv4(198, 51, 100, 1).To4(), Interface: 3, Flags: pm.FlagSubflow | pm.FlagBackup},
+			{Address: net.ParseIP("2001:db8::1"), Interface: 6, Flags: pm.FlagSubflow},
+		},
+	}
+
+	if got := p.state(ifis, addrs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected state:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	// With no usable addresses, all endpoints must be removed
+	if got := p.state(ifis, nil); len(got.Endpoints) != 0 {
+		t.Fatalf("expected empty endpoints, but got: %v", got.Endpoints)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import "golang.org/x/net/bpf"
+
+const (
+	// snapLen is the number of bytes of each packet received by a live
+	// capture, which is enough for the headers of a TCP segment.
+	snapLen = 512
+
+	// maxOptions is the number of TCP options which the filter examines.
+	// Each option is at least one byte, so every option in the 40 bytes of
+	// TCP options is examined.
+	maxOptions = 40
+)
+
+// filter returns a BPF program which accepts Ethernet frames containing an
+// IPv4 or IPv6 TCP segment whose options include multipath TCP option kind
+// 30, truncated to snapLen.  IPv4 fragments other than the first, and IPv6
+// extension headers, are not supported.
+//
+// Classic BPF cannot loop, so the program examines each of up to maxOptions
+// options in turn, keeping the offset of each option from the network header in the
+// X register.  Each check returns directly, because conditional jumps may
+// only skip 255 instructions.
+func filter() []bpf.Instruction {
+	const (
+		// Offsets from the beginning of the Ethernet frame.
+		etherType = 12
+		network   = 14
+
+		// Scratch memory holding the offset of the end of the TCP
+		// options from the network header.
+		memEnd = 0
+	)
+
+	reject := bpf.RetConstant{Val: 0}
+	accept := bpf.RetConstant{Val: snapLen}
+
+	prog := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: etherType, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x0800, SkipFalse: 7},
+
+		// IPv4: TCP, not a fragment other than the first, and X holds the
+		// header length.
+		bpf.LoadAbsolute{Off: network + 9, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6, SkipFalse: 4},
+		bpf.LoadAbsolute{Off: network + 6, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x1fff, SkipTrue: 2},
+		bpf.LoadMemShift{Off: network},
+		bpf.Jump{Skip: 7},
+		reject,
+
+		// IPv6: TCP, and X holds the fixed header length.
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x86dd, SkipFalse: 4},
+		bpf.LoadAbsolute{Off: network + 6, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6, SkipFalse: 2},
+		bpf.LoadConstant{Dst: bpf.RegX, Val: 40},
+		bpf.Jump{Skip: 1},
+		reject,
+
+		// The end of the TCP options is the offset of the TCP header
+		// plus its length, from the data offset field.
+		bpf.LoadIndirect{Off: network + 12, Size: 1},
+		bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xf0},
+		bpf.ALUOpConstant{Op: bpf.ALUOpShiftRight, Val: 2},
+		bpf.ALUOpX{Op: bpf.ALUOpAdd},
+		bpf.StoreScratch{Src: bpf.RegA, N: memEnd},
+
+		// The options follow the fixed 20 byte TCP header.
+		bpf.TXA{},
+		bpf.ALUOpConstant{Op: bpf.ALUOpAdd, Val: 20},
+		bpf.TAX{},
+	}
+
+	for i := 0; i < maxOptions; i++ {
+		prog = append(prog,
+			// Reject segments whose options end before kind 30.
+			bpf.LoadScratch{Dst: bpf.RegA, N: memEnd},
+			bpf.JumpIfX{Cond: bpf.JumpGreaterThan, SkipTrue: 1},
+			reject,
+
+			// Check the kind of the option, stopping at the end of
+			// the option list.
+			bpf.LoadIndirect{Off: network, Size: 1},
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 30, SkipFalse: 1},
+			accept,
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0, SkipFalse: 1},
+			reject,
+
+			// No-operation is a single byte.
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 1, SkipFalse: 4},
+			bpf.TXA{},
+			bpf.ALUOpConstant{Op: bpf.ALUOpAdd, Val: 1},
+			bpf.TAX{},
+			bpf.Jump{Skip: 5},
+
+			// Other options are skipped using their length, which
+			// must be at least 2.
+			bpf.LoadIndirect{Off: network + 1, Size: 1},
+			bpf.JumpIf{Cond: bpf.JumpGreaterOrEqual, Val: 2, SkipTrue: 1},
+			reject,
+			bpf.ALUOpX{Op: bpf.ALUOpAdd},
+			bpf.TAX{},
+		)
+	}
+
+	return append(prog, reject)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/net/bpf"
+)
+
+// TestFilter verifies the packets accepted by the live capture filter.
+func TestFilter(t *testing.T) {
+	var (
+		mss      = []byte{2, 4, 0x05, 0xb4}
+		nops     = []byte{1, 1}
+		sack     = []byte{4, 2}
+		capable  = []byte{30, 4, 0x01, 0x01}
+		eol      = []byte{0, 0, 0, 0}
+		ts       = []byte{8, 10, 0, 0, 0, 1, 0, 0, 0, 2}
+		zeroLen  = []byte{8, 0, 0, 0}
+		tcpProto = byte(6)
+	)
+
+	cat := func(bs ...[]byte) []byte {
+		var out []byte
+		for _, b := range bs {
+			out = append(out, b...)
+		}
+		return out
+	}
+
+	nopN := make([]byte, 36)
+	for i := range nopN {
+		nopN[i] = 1
+	}
+
+	var tests = []struct {
+		desc string
+		b    []byte
+		ok   bool
+	}{
+		{
+			desc: "IPv4 MP_CAPABLE",
+			b:    ipv4Packet(tcpProto, 0, cat(mss, nops, sack, capable)),
+			ok:   true,
+		},
+		{
+			desc: "IPv4 first option",
+			b:    ipv4Packet(tcpProto, 0, capable),
+			ok:   true,
+		},
+		{
+			desc: "IPv4 after timestamps",
+			b:    ipv4Packet(tcpProto, 0, cat(nops, ts, capable)),
+			ok:   true,
+		},
+		{
+			desc: "IPv4 after many no-operations",
+			b:    ipv4Packet(tcpProto, 0, cat(nopN[:20], capable)),
+			ok:   true,
+		},
+		{
+			desc: "IPv4 no options",
+			b:    ipv4Packet(tcpProto, 0, nil),
+		},
+		{
+			desc: "IPv4 other options",
+			b:    ipv4Packet(tcpProto, 0, cat(mss, nops, ts)),
+		},
+		{
+			desc: "IPv4 after end of options",
+			b:    ipv4Packet(tcpProto, 0, cat(eol, capable)),
+		},
+		{
+			desc: "IPv4 zero length option",
+			b:    ipv4Packet(tcpProto, 0, cat(zeroLen, capable)),
+		},
+		{
+			desc: "IPv4 last option",
+			b:    ipv4Packet(tcpProto, 0, cat(nopN, capable)),
+			ok:   true,
+		},
+		{
+			desc: "IPv4 UDP",
+			b:    ipv4Packet(17, 0, capable),
+		},
+		{
+			desc: "IPv4 fragment",
+			b:    ipv4Packet(tcpProto, 185, capable),
+		},
+		{
+			desc: "IPv6 MP_CAPABLE",
+			b:    ipv6Packet(tcpProto, cat(mss, nops, sack, capable)),
+			ok:   true,
+		},
+		{
+			desc: "IPv6 other options",
+			b:    ipv6Packet(tcpProto, cat(mss, nops, sack)),
+		},
+		{
+			desc: "IPv6 extension header",
+			b:    ipv6Packet(0, capable),
+		},
+		{
+			desc: "ARP",
+			b:    append(make([]byte, 12), 0x08, 0x06, 0, 1),
+		},
+	}
+
+	vm, err := bpf.NewVM(filter())
+	if err != nil {
+		t.Fatalf("failed to create VM: %v", err)
+	}
+
+	for i, tt := range tests {
+		n, err := vm.Run(tt.b)
+		if err != nil {
+			t.Fatalf("[%02d] test %q, failed to run filter: %v", i, tt.desc, err)
+		}
+
+		if want, got := tt.ok, n > 0; want != got {
+			t.Fatalf("[%02d] test %q, unexpected result:\n- want: %v\n-  got: %v (%d bytes)",
+				i, tt.desc, want, got, n)
+		}
+	}
+
+	if _, err := bpf.Assemble(filter()); err != nil {
+		t.Fatalf("failed to assemble filter: %v", err)
+	}
+}
+
+// tcpHeader returns a TCP header with options opts, which are padded to a
+// multiple of 4 bytes.
+func tcpHeader(opts []byte) []byte {
+	for len(opts)%4 != 0 {
+		opts = append(opts, 0)
+	}
+
+	b := make([]byte, 20+len(opts))
+	binary.BigEndian.PutUint16(b[0:2], 50000)
+	binary.BigEndian.PutUint16(b[2:4], 443)
+	b[12] = byte((20+len(opts))/4) << 4
+	b[13] = 0x02
+	copy(b[20:], opts)
+
+	return b
+}
+
+// ipv4Packet returns an Ethernet frame with an IPv4 packet carrying protocol
+// proto, with fragment offset frag, and a TCP header with options opts.
+func ipv4Packet(proto byte, frag uint16, opts []byte) []byte {
+	tcp := tcpHeader(opts)
+
+	b := make([]byte, 14+20)
+	binary.BigEndian.PutUint16(b[12:14], 0x0800)
+
+	ip := b[14:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	binary.BigEndian.PutUint16(ip[6:8], frag)
+	ip[8] = 64
+	ip[9] = proto
+
+	return append(b, tcp...)
+}
+
+// ipv6Packet returns an Ethernet frame with an IPv6 packet whose next header
+// is next, and a TCP header with options opts.
+func ipv6Packet(next byte, opts []byte) []byte {
+	tcp := tcpHeader(opts)
+
+	b := make([]byte, 14+40)
+	binary.BigEndian.PutUint16(b[12:14], 0x86dd)
+
+	ip := b[14:]
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+	ip[6] = next
+	ip[7] = 64
+
+	return append(b, tcp...)
+}
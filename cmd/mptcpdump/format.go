@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mdlayher/mptcp/capture"
+)
+
+// timeFormat is the format of the time of each segment.
+const timeFormat = "15:04:05.000000"
+
+// format formats a segment as a single line, in a form similar to tcpdump,
+// followed by its multipath TCP options.
+func format(s *capture.Segment) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s > %s: Flags [%s], seq %d",
+		s.Time.Format(timeFormat), s.Src, s.Dst, s.Flags, s.Seq)
+	if s.Has(capture.FlagACK) {
+		fmt.Fprintf(&b, ", ack %d", s.Ack)
+	}
+	fmt.Fprintf(&b, ", win %d, length %d", s.Window, s.Len)
+
+	opts := make([]string, 0, len(s.Options))
+	for _, o := range s.Options {
+		opts = append(opts, fmt.Sprint(o))
+	}
+	if s.OptionErr != nil {
+		opts = append(opts, fmt.Sprintf("invalid: %v", s.OptionErr))
+	}
+	fmt.Fprintf(&b, ", mptcp [%s]", strings.Join(opts, ", "))
+
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// TestFormat verifies the line printed for each segment.
+func TestFormat(t *testing.T) {
+	var (
+		tm  = time.Date(2026, 10, 18, 12, 0, 0, 123456000, time.UTC)
+		src = netip.MustParseAddrPort("192.0.2.1:50000")
+		dst = netip.MustParseAddrPort("[2001:db8::1]:443")
+	)
+
+	var tests = []struct {
+		desc string
+		s    *capture.Segment
+		out  string
+	}{
+		{
+			desc: "SYN",
+			s: &capture.Segment{
+				Time:   tm,
+				Src:    src,
+				Dst:    dst,
+				Seq:    100,
+				Flags:  capture.FlagSYN,
+				Window: 64240,
+				Options: []option.Option{&option.MPJoin{
+					Form:      option.JoinSYN,
+					AddressID: 2,
+					Token:     0x9c290bf6,
+					Nonce:     0x01020304,
+				}},
+			},
+			out: "12:00:00.123456 192.0.2.1:50000 > [2001:db8::1]:443: Flags [S], seq 100, win 64240, length 0, " +
+				"mptcp [MP_JOIN syn token=0x9C290BF6 nonce=0x01020304 backup=0 addrid=2]",
+		},
+		{
+			desc: "data",
+			s: &capture.Segment{
+				Time:   tm,
+				Src:    dst,
+				Dst:    src,
+				Seq:    200,
+				Ack:    101,
+				Flags:  capture.FlagACK | capture.FlagPSH,
+				Window: 512,
+				Len:    10,
+				Options: []option.Option{
+					&option.DSS{DataACK: 1, HasDataACK: true, DSN: 2, SubflowSeq: 1, DataLength: 10, HasMapping: true},
+					&option.MPPrio{Backup: true},
+				},
+			},
+			out: "12:00:00.123456 [2001:db8::1]:443 > 192.0.2.1:50000: Flags [P.], seq 200, ack 101, win 512, length 10, " +
+				"mptcp [DSS ack=1 dsn=2 ssn=1 len=10, MP_PRIO backup=1]",
+		},
+		{
+			desc: "invalid",
+			s: &capture.Segment{
+				Time:      tm,
+				Src:       src,
+				Dst:       dst,
+				Flags:     capture.FlagACK,
+				OptionErr: errors.New("invalid TCP options"),
+			},
+			out: "12:00:00.123456 192.0.2.1:50000 > [2001:db8::1]:443: Flags [.], seq 0, ack 0, win 0, length 0, " +
+				"mptcp [invalid: invalid TCP options]",
+		},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.out, format(tt.s); want != got {
+			t.Fatalf("[%02d] test %q, unexpected output:\n- want: %s\n-  got: %s",
+				i, tt.desc, want, got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// +build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"time"
+
+	"github.com/mdlayher/mptcp/pcap"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// A listener captures packets from a network interface using an AF_PACKET
+// socket.
+type listener struct {
+	fd       int
+	loopback bool
+	b        []byte
+}
+
+// listen opens an AF_PACKET socket which captures TCP segments carrying
+// multipath TCP options from the Ethernet or loopback interface ifi.
+func listen(ifi *net.Interface) (*listener, error) {
+	prog, err := bpf.Assemble(filter())
+	if err != nil {
+		return nil, err
+	}
+
+	proto := htons(unix.ETH_P_ALL)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, int(proto))
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	// Attach the filter before binding, so that no other packets are
+	// received.
+	filter := make([]unix.SockFilter, 0, len(prog))
+	for _, ins := range prog {
+		filter = append(filter, unix.SockFilter{
+			Code: ins.Op,
+			Jt:   ins.Jt,
+			Jf:   ins.Jf,
+			K:    ins.K,
+		})
+	}
+
+	fprog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &fprog); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+
+	sa := &unix.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  ifi.Index,
+	}
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	return &listener{
+		fd:       fd,
+		loopback: ifi.Flags&net.FlagLoopback != 0,
+		b:        make([]byte, snapLen),
+	}, nil
+}
+
+// ReadPacket reads the next captured packet.
+func (l *listener) ReadPacket() (*pcap.Packet, error) {
+	for {
+		n, from, err := unix.Recvfrom(l.fd, l.b, unix.MSG_TRUNC)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+
+		// Packets sent on the loopback interface are received twice, so
+		// only the incoming copy is kept.
+		if sa, ok := from.(*unix.SockaddrLinklayer); ok && l.loopback && sa.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+
+		return &pcap.Packet{
+			Time:     time.Now(),
+			LinkType: pcap.LinkTypeEthernet,
+			Data:     append([]byte(nil), l.b[:min(n, len(l.b))]...),
+			Length:   n,
+		}, nil
+	}
+}
+
+// Close closes the socket.
+func (l *listener) Close() error {
+	return unix.Close(l.fd)
+}
+
+// htons converts v to network byte order.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
@@ -0,0 +1,28 @@
+// +build !linux
+
+package main
+
+import (
+	"net"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// A listener is not currently implemented on non-Linux platforms.
+type listener struct{}
+
+// listen is not currently implemented on non-Linux platforms.
+func listen(_ *net.Interface) (*listener, error) {
+	return nil, mptcp.ErrNotImplemented
+}
+
+// ReadPacket is not currently implemented on non-Linux platforms.
+func (*listener) ReadPacket() (*pcap.Packet, error) {
+	return nil, mptcp.ErrNotImplemented
+}
+
+// Close is not currently implemented on non-Linux platforms.
+func (*listener) Close() error {
+	return mptcp.ErrNotImplemented
+}
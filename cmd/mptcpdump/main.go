@@ -0,0 +1,116 @@
+// Command mptcpdump prints each captured TCP segment which carries multipath
+// TCP options as a single line, with its options decoded.
+//
+// Segments are read from a pcap or pcapng file, or captured live from a
+// network interface using an AF_PACKET socket on Linux, which requires the
+// CAP_NET_RAW capability.  Live captures use a BPF filter so that only TCP
+// segments carrying option kind 30 are received.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// A packetReader reads packets, such as a *pcap.Reader or a live capture.
+type packetReader interface {
+	ReadPacket() (*pcap.Packet, error)
+}
+
+func main() {
+	var (
+		file  = flag.String("r", "", "pcap or pcapng file to read")
+		iface = flag.String("i", "", "network interface to capture from")
+		count = flag.Int("c", 0, "optional: exit after printing this many segments")
+	)
+
+	log.SetPrefix("mptcpdump: ")
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mptcpdump [flags] -r file.pcap | -i interface")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if (*file == "") == (*iface == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var r packetReader
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("failed to open file: %v", err)
+		}
+		defer f.Close()
+
+		pr, err := pcap.NewReader(bufio.NewReader(f))
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", *file, err)
+		}
+		r = pr
+	} else {
+		ifi, err := net.InterfaceByName(*iface)
+		if err != nil {
+			log.Fatalf("failed to get interface: %v", err)
+		}
+
+		l, err := listen(ifi)
+		if err != nil {
+			log.Fatalf("failed to capture from %s: %v", ifi.Name, err)
+		}
+		defer l.Close()
+		r = l
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	// Flush each line during live captures.
+	flush := func() error { return nil }
+	if *iface != "" {
+		flush = w.Flush
+	}
+
+	var n int
+	for *count == 0 || n < *count {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			w.Flush()
+			log.Fatalf("failed to read packet: %v", err)
+		}
+
+		s, err := capture.Decode(p.LinkType, p.Data)
+		if err != nil {
+			if !errors.Is(err, capture.ErrNotTCP) {
+				fmt.Fprintf(w, "%s malformed packet: %v\n", p.Time.Format(timeFormat), err)
+			}
+			continue
+		}
+		s.Time = p.Time
+
+		if len(s.Options) == 0 && s.OptionErr == nil {
+			continue
+		}
+
+		fmt.Fprintln(w, format(s))
+		if err := flush(); err != nil {
+			log.Fatalf("failed to write output: %v", err)
+		}
+		n++
+	}
+}
@@ -3,12 +3,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 
 	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
 )
 
 var (
@@ -33,6 +36,10 @@ func main() {
 		log.Fatal("multipath TCP is not enabled, exiting now")
 	}
 
+	// Warn about path manager configuration which prevents clients
+	// from using more than one subflow
+	warnPathManager()
+
 	// Parse flags
 	flag.Parse()
 
@@ -55,7 +62,37 @@ func main() {
 		}
 	})
 
-	// Bind HTTP server to host
+	// Bind HTTP server to host, using multipath TCP on mainline kernels
+	// even where it is not the default
+	var lc net.ListenConfig
+	lc.SetMultipathTCP(true)
+
+	l, err := lc.Listen(context.Background(), "tcp", host)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println("binding to:", host)
-	http.ListenAndServe(host, nil)
+	log.Fatal(http.Serve(l, nil))
+}
+
+// warnPathManager logs any problems found in the configuration of the
+// path manager.  The path manager is unavailable on out-of-tree kernels,
+// so errors are not fatal.
+func warnPathManager() {
+	c, err := pm.Dial()
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	d, err := c.Diagnose()
+	if err != nil {
+		log.Println("failed to check path manager:", err)
+		return
+	}
+
+	for _, p := range d.Problems {
+		log.Println("warning:", p)
+	}
 }
@@ -0,0 +1,235 @@
+// Command mptcpprobe reports whether remote servers support multipath TCP.
+//
+// Each target is probed concurrently by connecting with multipath TCP and
+// inspecting the connection once the handshake completes.  One JSON object
+// is written per target, in the order targets were given.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// A result is the JSON output for a single target.
+type result struct {
+	Target    string `json:"target"`
+	Remote    string `json:"remote,omitempty"`
+	Capable   bool   `json:"capable"`
+	Version   int    `json:"version,omitempty"`
+	Announced int    `json:"announced"`
+	Subflows  int    `json:"subflows,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	// err is an error which prevents any target from being probed.
+	err error
+}
+
+func main() {
+	var (
+		file        = flag.String("f", "", "file of targets, one per line, or - for stdin")
+		port        = flag.String("port", "443", "port used for targets without one")
+		concurrency = flag.Int("c", 16, "number of targets to probe concurrently")
+		timeout     = flag.Duration("timeout", 5*time.Second, "timeout for each probe")
+		wait        = flag.Duration("wait", mptcp.DefaultProbeWait, "time to wait for peers to announce addresses")
+	)
+
+	log.SetPrefix("mptcpprobe: ")
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mptcpprobe [flags] [host:port ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	targets := flag.Args()
+	if *file != "" {
+		ts, err := readTargetsFile(*file)
+		if err != nil {
+			log.Fatalf("failed to read targets: %v", err)
+		}
+		targets = append(targets, ts...)
+	}
+	if len(targets) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *concurrency < 1 {
+		log.Fatal("concurrency must be at least 1")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := &mptcp.Prober{Wait: *wait}
+	count, err := announcements(ctx)
+	if err != nil {
+		log.Printf("counting only announced addresses accepted by the local path manager: %v", err)
+	} else {
+		p.Announced = count
+	}
+
+	ok, err := probeAll(ctx, p, targets, *port, *concurrency, *timeout, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// announcements counts the addresses announced by peers using path manager
+// events until ctx is canceled.  Receiving events requires CAP_NET_ADMIN on
+// recent kernels.
+func announcements(ctx context.Context) (func(mptcp.Token) int, error) {
+	c, err := pm.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := c.WatchAnnouncements(ctx)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	context.AfterFunc(ctx, func() { _ = c.Close() })
+
+	return a.Count, nil
+}
+
+// probeAll probes targets using up to n goroutines, and writes results to w
+// in the order of targets.  It reports whether every probe succeeded.  If an
+// error prevents any target from being probed, the results which precede it
+// are written, the remaining probes are canceled, and the error is returned.
+func probeAll(ctx context.Context, p *mptcp.Prober, targets []string, port string, n int, timeout time.Duration, w io.Writer) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan result, len(targets))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	work := make(chan int)
+	go func() {
+		defer close(work)
+		for i := range targets {
+			select {
+			case work <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < n && i < len(targets); i++ {
+		go func() {
+			for i := range work {
+				results[i] <- probe(ctx, p, targets[i], port, timeout)
+			}
+		}()
+	}
+
+	ok := true
+	enc := json.NewEncoder(w)
+	for _, ch := range results {
+		r := <-ch
+		if r.err != nil {
+			return false, r.err
+		}
+		if r.Error != "" {
+			ok = false
+		}
+
+		if err := enc.Encode(r); err != nil {
+			return false, fmt.Errorf("failed to write result: %v", err)
+		}
+	}
+
+	return ok, nil
+}
+
+// probe probes a single target.
+func probe(ctx context.Context, p *mptcp.Prober, target, port string, timeout time.Duration) result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r := result{Target: target}
+
+	pr, err := p.Probe(ctx, withPort(target, port))
+	if err != nil {
+		// Without local support, no target can be probed.
+		if errors.Is(err, mptcp.ErrLocalUnsupported) {
+			r.err = err
+		}
+
+		r.Error = err.Error()
+		return r
+	}
+
+	r.Remote = pr.Remote.String()
+	r.Capable = pr.Capable
+	r.Version = pr.Version
+	r.Announced = pr.Announced
+	if pr.Info != nil {
+		r.Subflows = pr.Info.Subflows + 1
+	}
+
+	return r
+}
+
+// withPort adds port to target, if target does not already specify one.
+func withPort(target, port string) string {
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+
+	return net.JoinHostPort(strings.Trim(target, "[]"), port)
+}
+
+// readTargetsFile reads targets from the file at path, or from stdin if path
+// is "-".
+func readTargetsFile(path string) ([]string, error) {
+	if path == "-" {
+		return readTargets(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readTargets(f)
+}
+
+// readTargets reads one target per line from r.  Blank lines and lines
+// beginning with '#' are ignored.
+func readTargets(r io.Reader) ([]string, error) {
+	var targets []string
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		targets = append(targets, line)
+	}
+
+	return targets, s.Err()
+}
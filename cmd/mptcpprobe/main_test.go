@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestReadTargets verifies that readTargets skips blank lines and comments.
+func TestReadTargets(t *testing.T) {
+	in := `
+# partners
+example.com:443
+
+  192.0.2.1:8080
+[2001:db8::1]:443
+`
+
+	targets, err := readTargets(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com:443", "192.0.2.1:8080", "[2001:db8::1]:443"}
+	if !reflect.DeepEqual(want, targets) {
+		t.Fatalf("unexpected targets:\n- want: %v\n-  got: %v", want, targets)
+	}
+}
+
+// TestWithPort verifies that withPort only adds a port to targets which do
+// not specify one.
+func TestWithPort(t *testing.T) {
+	var tests = []struct {
+		target string
+		want   string
+	}{
+		{"example.com", "example.com:443"},
+		{"example.com:80", "example.com:80"},
+		{"192.0.2.1", "192.0.2.1:443"},
+		{"2001:db8::1", "[2001:db8::1]:443"},
+		{"[2001:db8::1]", "[2001:db8::1]:443"},
+		{"[2001:db8::1]:80", "[2001:db8::1]:80"},
+	}
+
+	for i, test := range tests {
+		if got := withPort(test.target, "443"); got != test.want {
+			t.Fatalf("[%02d] unexpected target for %q: %q != %q",
+				i, test.target, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// A filter reports whether a connection should be listed.
+type filter func(e *mptcp.Entry) bool
+
+// stateGroups are the state names and groups accepted by the state and
+// exclude keywords, as used by ss.
+var stateGroups = map[string][]mptcp.State{
+	"established": {mptcp.StateEstablished},
+	"syn-sent":    {mptcp.StateSynSent},
+	"syn-recv":    {mptcp.StateSynRecv},
+	"fin-wait-1":  {mptcp.StateFinWait1},
+	"fin-wait-2":  {mptcp.StateFinWait2},
+	"time-wait":   {mptcp.StateTimeWait},
+	"closed":      {mptcp.StateClose},
+	"close-wait":  {mptcp.StateCloseWait},
+	"last-ack":    {mptcp.StateLastAck},
+	"closing":     {mptcp.StateClosing},
+	"all": {
+		mptcp.StateEstablished, mptcp.StateSynSent, mptcp.StateSynRecv,
+		mptcp.StateFinWait1, mptcp.StateFinWait2, mptcp.StateTimeWait,
+		mptcp.StateClose, mptcp.StateCloseWait, mptcp.StateLastAck,
+		mptcp.StateClosing,
+	},
+	"connected": {
+		mptcp.StateEstablished, mptcp.StateSynSent, mptcp.StateSynRecv,
+		mptcp.StateFinWait1, mptcp.StateFinWait2, mptcp.StateCloseWait,
+		mptcp.StateLastAck, mptcp.StateClosing,
+	},
+	"synchronized": {
+		mptcp.StateEstablished, mptcp.StateSynRecv, mptcp.StateFinWait1,
+		mptcp.StateFinWait2, mptcp.StateCloseWait, mptcp.StateLastAck,
+		mptcp.StateClosing,
+	},
+	"bucket": {mptcp.StateSynRecv, mptcp.StateTimeWait},
+	"big": {
+		mptcp.StateEstablished, mptcp.StateSynSent, mptcp.StateFinWait1,
+		mptcp.StateFinWait2, mptcp.StateClose, mptcp.StateCloseWait,
+		mptcp.StateLastAck, mptcp.StateClosing,
+	},
+}
+
+// parseFilter parses a filter from command line arguments, in the style of
+// ss.  Leading "state" and "exclude" keywords select connection states, and
+// are followed by an optional expression of address and port conditions:
+//
+//	state established dport = :443 and not dst 10.0.0.0/8
+//	sport :8000-8080 or ( src 192.0.2.1 dport >= :1024 )
+//
+// Adjacent conditions are joined by "and".
+func parseFilter(args []string) (filter, error) {
+	toks := tokenize(args)
+
+	// Consume leading state selections.  Exclusions apply to all states
+	// if no states are selected.
+	var (
+		states   map[mptcp.State]bool
+		excluded = make(map[mptcp.State]bool)
+	)
+	for len(toks) >= 2 && (toks[0] == "state" || toks[0] == "exclude") {
+		group, ok := stateGroups[toks[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown state %q", toks[1])
+		}
+
+		for _, s := range group {
+			if toks[0] == "exclude" {
+				excluded[s] = true
+				continue
+			}
+
+			if states == nil {
+				states = make(map[mptcp.State]bool)
+			}
+			states[s] = true
+		}
+
+		toks = toks[2:]
+	}
+
+	expr := func(*mptcp.Entry) bool { return true }
+	if len(toks) > 0 {
+		p := &parser{toks: toks}
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if len(p.toks) > 0 {
+			return nil, fmt.Errorf("unexpected %q in filter", p.toks[0])
+		}
+
+		expr = f
+	}
+
+	return func(e *mptcp.Entry) bool {
+		if states != nil && !states[e.State] {
+			return false
+		}
+		if excluded[e.State] {
+			return false
+		}
+
+		return expr(e)
+	}, nil
+}
+
+// tokenize splits args into tokens, separating parentheses and '!' from
+// adjacent text.
+func tokenize(args []string) []string {
+	s := strings.Join(args, " ")
+	for _, sep := range []string{"(", ")"} {
+		s = strings.ReplaceAll(s, sep, " "+sep+" ")
+	}
+
+	var toks []string
+	for _, f := range strings.Fields(s) {
+		if len(f) > 1 && f[0] == '!' && f[1] != '=' {
+			toks = append(toks, "!")
+			f = f[1:]
+		}
+
+		toks = append(toks, f)
+	}
+
+	return toks
+}
+
+// A parser parses a filter expression using recursive descent.
+type parser struct {
+	toks []string
+}
+
+// peek returns the next token, or the empty string if none remain.
+func (p *parser) peek() string {
+	if len(p.toks) == 0 {
+		return ""
+	}
+
+	return p.toks[0]
+}
+
+// next consumes and returns the next token.
+func (p *parser) next() (string, error) {
+	if len(p.toks) == 0 {
+		return "", fmt.Errorf("unexpected end of filter")
+	}
+
+	t := p.toks[0]
+	p.toks = p.toks[1:]
+	return t, nil
+}
+
+// or parses conditions joined by "or".
+func (p *parser) or() (filter, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek() {
+		case "or", "||", "|":
+			p.toks = p.toks[1:]
+		default:
+			return l, nil
+		}
+
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		l = func(l, r filter) filter {
+			return func(e *mptcp.Entry) bool { return l(e) || r(e) }
+		}(l, r)
+	}
+}
+
+// and parses conditions joined by "and", or by nothing.
+func (p *parser) and() (filter, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek() {
+		case "", ")", "or", "||", "|":
+			return l, nil
+		case "and", "&&", "&":
+			p.toks = p.toks[1:]
+		}
+
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		l = func(l, r filter) filter {
+			return func(e *mptcp.Entry) bool { return l(e) && r(e) }
+		}(l, r)
+	}
+}
+
+// unary parses a negated condition, a parenthesized expression, or a single
+// condition.
+func (p *parser) unary() (filter, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case "not", "!":
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(e *mptcp.Entry) bool { return !f(e) }, nil
+	case "(":
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t != ")" {
+			return nil, fmt.Errorf("missing ')' in filter")
+		}
+
+		return f, nil
+	case "src", "dst":
+		v, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := parseAddrMatch(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if t == "src" {
+			return func(e *mptcp.Entry) bool { return m.match(e.Local) }, nil
+		}
+		return func(e *mptcp.Entry) bool { return m.match(e.Remote) }, nil
+	case "sport", "dport":
+		return p.port(t)
+	default:
+		return nil, fmt.Errorf("unexpected %q in filter", t)
+	}
+}
+
+// port parses a port comparison for the sport or dport keyword.
+func (p *parser) port(key string) (filter, error) {
+	op := "="
+	switch p.peek() {
+	case "=", "==", "eq", "!=", "ne", "neq", "<", "lt", "<=", "le", ">", "gt", ">=", "ge":
+		op = p.toks[0]
+		p.toks = p.toks[1:]
+	}
+
+	v, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := parsePortRange(strings.TrimPrefix(v, ":"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cmp func(port int) bool
+	switch op {
+	case "=", "==", "eq":
+		cmp = pr.contains
+	case "!=", "ne", "neq":
+		cmp = func(port int) bool { return !pr.contains(port) }
+	case "<", "lt":
+		cmp = func(port int) bool { return port < pr.lo }
+	case "<=", "le":
+		cmp = func(port int) bool { return port <= pr.hi }
+	case ">", "gt":
+		cmp = func(port int) bool { return port > pr.hi }
+	case ">=", "ge":
+		cmp = func(port int) bool { return port >= pr.lo }
+	}
+
+	if key == "sport" {
+		return func(e *mptcp.Entry) bool { return e.Local != nil && cmp(e.Local.Port) }, nil
+	}
+	return func(e *mptcp.Entry) bool { return e.Remote != nil && cmp(e.Remote.Port) }, nil
+}
+
+// A portRange is an inclusive range of ports.
+type portRange struct {
+	lo, hi int
+}
+
+// parsePortRange parses a single port, or a range of ports such as
+// "8000-8080".
+func parsePortRange(s string) (portRange, error) {
+	lo, hi, ok := strings.Cut(s, "-")
+	if !ok {
+		hi = lo
+	}
+
+	l, err := parsePort(lo)
+	if err != nil {
+		return portRange{}, err
+	}
+	h, err := parsePort(hi)
+	if err != nil {
+		return portRange{}, err
+	}
+	if l > h {
+		return portRange{}, fmt.Errorf("invalid port range %q", s)
+	}
+
+	return portRange{lo: l, hi: h}, nil
+}
+
+// parsePort parses a single port number.
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 0 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+
+	return p, nil
+}
+
+// contains reports whether port is within r.
+func (r portRange) contains(port int) bool {
+	return port >= r.lo && port <= r.hi
+}
+
+// An addrMatch matches addresses by prefix and optional port range.
+type addrMatch struct {
+	prefix *net.IPNet
+	ports  *portRange
+}
+
+// parseAddrMatch parses an address, prefix, or either with a port or port
+// range, such as "192.0.2.1", "10.0.0.0/8:443", "[2001:db8::/32]:8000-8080",
+// or ":443".  A host of "*" or an empty host matches any address.
+func parseAddrMatch(s string) (*addrMatch, error) {
+	host, port := s, ""
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.Index(s, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+
+		host, port = s[1:end], s[end+1:]
+		if port != "" && !strings.HasPrefix(port, ":") {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+		port = strings.TrimPrefix(port, ":")
+	case strings.Count(s, ":") == 1:
+		host, port, _ = strings.Cut(s, ":")
+	}
+
+	var m addrMatch
+	if host != "" && host != "*" {
+		if !strings.Contains(host, "/") {
+			if strings.Contains(host, ":") {
+				host += "/128"
+			} else {
+				host += "/32"
+			}
+		}
+
+		_, ipn, err := net.ParseCIDR(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+		m.prefix = ipn
+	}
+
+	if port != "" && port != "*" {
+		pr, err := parsePortRange(port)
+		if err != nil {
+			return nil, err
+		}
+		m.ports = &pr
+	}
+
+	return &m, nil
+}
+
+// match reports whether addr matches m.
+func (m *addrMatch) match(addr *net.TCPAddr) bool {
+	if addr == nil {
+		return false
+	}
+	if m.prefix != nil && !m.prefix.Contains(addr.IP) {
+		return false
+	}
+	if m.ports != nil && !m.ports.contains(addr.Port) {
+		return false
+	}
+
+	return true
+}
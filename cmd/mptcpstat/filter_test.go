@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// TestParseFilter verifies that parseFilter parses ss-style filters, and
+// that they match the expected connections.
+func TestParseFilter(t *testing.T) {
+	var (
+		web = &mptcp.Entry{
+			State:  mptcp.StateEstablished,
+			Local:  &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443},
+			Remote: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000},
+		}
+		ssh = &mptcp.Entry{
+			State:  mptcp.StateTimeWait,
+			Local:  &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22},
+			Remote: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 40000},
+		}
+		v6 = &mptcp.Entry{
+			IPv6:   true,
+			State:  mptcp.StateSynSent,
+			Local:  &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 51000},
+			Remote: &net.TCPAddr{IP: net.ParseIP("2001:db8:1::1"), Port: 8080},
+		}
+	)
+
+	var tests = []struct {
+		filter string
+		match  []*mptcp.Entry
+		ok     bool
+	}{
+		{filter: "", match: []*mptcp.Entry{web, ssh, v6}, ok: true},
+		{filter: "state established", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "state established state time-wait", match: []*mptcp.Entry{web, ssh}, ok: true},
+		{filter: "state connected", match: []*mptcp.Entry{web, v6}, ok: true},
+		{filter: "exclude time-wait", match: []*mptcp.Entry{web, v6}, ok: true},
+		{filter: "state all exclude syn-sent", match: []*mptcp.Entry{web, ssh}, ok: true},
+		{filter: "sport = :443", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "sport eq 22", match: []*mptcp.Entry{ssh}, ok: true},
+		{filter: "sport :443", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "sport != :443", match: []*mptcp.Entry{ssh, v6}, ok: true},
+		{filter: "sport < :443", match: []*mptcp.Entry{ssh}, ok: true},
+		{filter: "sport >= :443", match: []*mptcp.Entry{web, v6}, ok: true},
+		{filter: "dport :8000-8080", match: []*mptcp.Entry{v6}, ok: true},
+		{filter: "dport gt :40000-49999", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "dst 10.0.0.0/8", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "dst 198.51.100.1:40000", match: []*mptcp.Entry{ssh}, ok: true},
+		{filter: "src :22", match: []*mptcp.Entry{ssh}, ok: true},
+		{filter: "src *:443", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "dst [2001:db8::/32]:8080", match: []*mptcp.Entry{v6}, ok: true},
+		{filter: "src 2001:db8::1", match: []*mptcp.Entry{v6}, ok: true},
+		{filter: "src 192.0.2.1 dport > :45000", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "sport :22 or dport :8080", match: []*mptcp.Entry{ssh, v6}, ok: true},
+		{filter: "not dst 10.0.0.0/8", match: []*mptcp.Entry{ssh, v6}, ok: true},
+		{filter: "!dst 10.0.0.0/8 and !sport :22", match: []*mptcp.Entry{v6}, ok: true},
+		{filter: "src 192.0.2.1 and (sport :22 || dport :50000)", match: []*mptcp.Entry{web, ssh}, ok: true},
+		{filter: "state established ( sport :22 or sport :443 )", match: []*mptcp.Entry{web}, ok: true},
+		{filter: "state bogus"},
+		{filter: "sport"},
+		{filter: "sport :foo"},
+		{filter: "sport :70000"},
+		{filter: "sport :2-1"},
+		{filter: "dst 300.0.0.1"},
+		{filter: "dst [2001:db8::1"},
+		{filter: "( sport :22"},
+		{filter: "sport :22 )"},
+		{filter: "bogus"},
+		{filter: "sport :22 or"},
+	}
+
+	all := []*mptcp.Entry{web, ssh, v6}
+	for i, test := range tests {
+		f, err := parseFilter(strings.Fields(test.filter))
+		if ok := err == nil; ok != test.ok {
+			t.Fatalf("[%02d] filter %q, unexpected error: %v", i, test.filter, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		var match []*mptcp.Entry
+		for _, e := range all {
+			if f(e) {
+				match = append(match, e)
+			}
+		}
+
+		if len(match) != len(test.match) {
+			t.Fatalf("[%02d] filter %q, unexpected number of matches: %d != %d",
+				i, test.filter, len(test.match), len(match))
+		}
+		for j := range match {
+			if match[j] != test.match[j] {
+				t.Fatalf("[%02d] filter %q, unexpected match: %+v", i, test.filter, match[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Command mptcpstat lists multipath TCP connections on the current host, in
+// the style of ss.
+//
+// Connections may be filtered using an expression of states, addresses, and
+// ports, and are written as aligned text, JSON, or CSV.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// A row is the output for a single connection.
+type row struct {
+	State       string    `json:"state"`
+	LocalToken  string    `json:"local_token"`
+	RemoteToken string    `json:"remote_token,omitempty"`
+	Local       string    `json:"local"`
+	Remote      string    `json:"remote"`
+	Subflows    int       `json:"subflows"`
+	RxQueue     int       `json:"rx_queue"`
+	TxQueue     int       `json:"tx_queue"`
+	Inode       uint64    `json:"inode"`
+	Processes   []process `json:"processes,omitempty"`
+}
+
+func main() {
+	var (
+		format    = flag.String("o", "text", "output format: text, json, or csv")
+		processes = flag.Bool("p", false, "show processes using each connection")
+		ipv4      = flag.Bool("4", false, "only show IPv4 connections")
+		ipv6      = flag.Bool("6", false, "only show IPv6 connections")
+		noHeader  = flag.Bool("H", false, "do not print a header for text or CSV output")
+	)
+
+	log.SetPrefix("mptcpstat: ")
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mptcpstat [flags] [state STATE] [exclude STATE] [FILTER]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	f, err := parseFilter(flag.Args())
+	if err != nil {
+		log.Fatalf("invalid filter: %v", err)
+	}
+
+	write, ok := writers[*format]
+	if !ok {
+		log.Fatalf("unknown output format %q", *format)
+	}
+
+	entries, err := mptcp.Entries()
+	if err != nil {
+		log.Fatalf("failed to list connections: %v", err)
+	}
+
+	var procs map[uint64][]process
+	if *processes {
+		procs, err = socketProcesses("/proc")
+		if err != nil {
+			log.Fatalf("failed to list processes: %v", err)
+		}
+	}
+
+	var rows []row
+	for i := range entries {
+		e := &entries[i]
+		if (*ipv4 && e.IPv6) || (*ipv6 && !e.IPv6) || !f(e) {
+			continue
+		}
+
+		rows = append(rows, newRow(e, procs[e.Inode]))
+	}
+
+	if err := write(os.Stdout, rows, !*noHeader); err != nil {
+		log.Fatalf("failed to write output: %v", err)
+	}
+}
+
+// newRow creates a row for e, which is used by ps.
+func newRow(e *mptcp.Entry, ps []process) row {
+	r := row{
+		State:      e.State.String(),
+		LocalToken: e.LocalToken.String(),
+		Local:      e.Local.String(),
+		Remote:     e.Remote.String(),
+		Subflows:   e.Subflows,
+		RxQueue:    e.RxQueue,
+		TxQueue:    e.TxQueue,
+		Inode:      e.Inode,
+		Processes:  ps,
+	}
+
+	// Mainline kernels do not report the remote token.
+	if e.RemoteToken != 0 {
+		r.RemoteToken = e.RemoteToken.String()
+	}
+
+	return r
+}
+
+// writers are the functions which write rows in each output format.
+var writers = map[string]func(w io.Writer, rows []row, header bool) error{
+	"text": writeText,
+	"json": writeJSON,
+	"csv":  writeCSV,
+}
+
+// columns are the column names for text and CSV output.
+var columns = []string{
+	"State", "Subflows", "Recv-Q", "Send-Q", "Local", "Remote",
+	"Token", "Remote-Token", "Inode", "Process",
+}
+
+// fields returns the values of r's columns.
+func (r row) fields() []string {
+	return []string{
+		r.State,
+		strconv.Itoa(r.Subflows),
+		strconv.Itoa(r.RxQueue),
+		strconv.Itoa(r.TxQueue),
+		r.Local,
+		r.Remote,
+		r.LocalToken,
+		r.RemoteToken,
+		strconv.FormatUint(r.Inode, 10),
+		formatProcesses(r.Processes),
+	}
+}
+
+// writeText writes rows as aligned columns.
+func writeText(w io.Writer, rows []row, header bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	printRow := func(fields []string) {
+		for i, f := range fields {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			if f == "" {
+				f = "-"
+			}
+			fmt.Fprint(tw, f)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	if header {
+		printRow(columns)
+	}
+	for _, r := range rows {
+		printRow(r.fields())
+	}
+
+	return tw.Flush()
+}
+
+// writeJSON writes rows as a JSON array.
+func writeJSON(w io.Writer, rows []row, _ bool) error {
+	if rows == nil {
+		rows = []row{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// writeCSV writes rows as CSV records.
+func writeCSV(w io.Writer, rows []row, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range rows {
+		if err := cw.Write(r.fields()); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
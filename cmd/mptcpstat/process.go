@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A process is a process which holds a file descriptor for a socket.
+type process struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name"`
+	FD   int    `json:"fd"`
+}
+
+// formatProcesses formats ps in the style of the ss users column.
+func formatProcesses(ps []process) string {
+	if len(ps) == 0 {
+		return ""
+	}
+
+	ss := make([]string, 0, len(ps))
+	for _, p := range ps {
+		ss = append(ss, fmt.Sprintf("(%q,pid=%d,fd=%d)", p.Name, p.PID, p.FD))
+	}
+
+	return "users:(" + strings.Join(ss, ",") + ")"
+}
@@ -0,0 +1,68 @@
+// +build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// socketProcesses maps socket inode numbers to the processes which hold a
+// file descriptor for each socket, by reading the file descriptors of each
+// process in the /proc filesystem rooted at proc.  Processes which cannot be
+// inspected, such as those of other users when not running as root, are
+// skipped.
+func socketProcesses(proc string) (map[uint64][]process, error) {
+	dirs, err := os.ReadDir(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[uint64][]process)
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+
+		fds, err := os.ReadDir(filepath.Join(proc, d.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+
+		var name string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(proc, d.Name(), "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			n, err := strconv.Atoi(fd.Name())
+			if err != nil {
+				continue
+			}
+
+			if name == "" {
+				b, err := os.ReadFile(filepath.Join(proc, d.Name(), "comm"))
+				if err != nil {
+					break
+				}
+				name = strings.TrimSpace(string(b))
+			}
+
+			out[inode] = append(out[inode], process{
+				PID:  pid,
+				Name: name,
+				FD:   n,
+			})
+		}
+	}
+
+	return out, nil
+}
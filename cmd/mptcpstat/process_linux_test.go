@@ -0,0 +1,66 @@
+// +build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestSocketProcesses verifies that socketProcesses finds the processes
+// which hold each socket in a /proc filesystem.
+func TestSocketProcesses(t *testing.T) {
+	proc := t.TempDir()
+
+	mkproc := func(pid, comm string, fds map[string]string) {
+		dir := filepath.Join(proc, pid, "fd")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(proc, pid, "comm"), []byte(comm+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		for fd, target := range fds {
+			if err := os.Symlink(target, filepath.Join(dir, fd)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	mkproc("100", "nginx", map[string]string{
+		"0": "/dev/null",
+		"4": "socket:[1000]",
+		"5": "socket:[2000]",
+	})
+	mkproc("200", "curl", map[string]string{
+		"3": "socket:[2000]",
+		"4": "pipe:[3000]",
+	})
+	mkproc("self", "ignored", map[string]string{
+		"3": "socket:[1000]",
+	})
+
+	got, err := socketProcesses(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint64][]process{
+		1000: {{PID: 100, Name: "nginx", FD: 4}},
+		2000: {
+			{PID: 100, Name: "nginx", FD: 5},
+			{PID: 200, Name: "curl", FD: 3},
+		},
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected processes:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	if want, got := `users:(("nginx",pid=100,fd=5),("curl",pid=200,fd=3))`, formatProcesses(got[2000]); want != got {
+		t.Fatalf("unexpected formatted processes:\n- want: %s\n-  got: %s", want, got)
+	}
+}
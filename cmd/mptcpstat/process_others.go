@@ -0,0 +1,10 @@
+// +build !linux
+
+package main
+
+import "github.com/mdlayher/mptcp"
+
+// socketProcesses is not currently implemented on non-Linux platforms.
+func socketProcesses(_ string) (map[uint64][]process, error) {
+	return nil, mptcp.ErrNotImplemented
+}
@@ -0,0 +1,62 @@
+package main
+
+// Keys which have special meaning.
+const (
+	keyCtrlC     = 3
+	keyBackspace = 8
+	keyLF        = 10
+	keyCR        = 13
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// key updates v for a key press, and reports whether mptcptop should quit.
+func (v *view) key(k byte) bool {
+	if v.Editing {
+		switch k {
+		case keyCR, keyLF:
+			v.Filter = v.Input
+			v.Editing = false
+		case keyEscape:
+			v.Editing = false
+		case keyBackspace, keyDelete:
+			if len(v.Input) > 0 {
+				v.Input = v.Input[:len(v.Input)-1]
+			}
+		case keyCtrlC:
+			return true
+		default:
+			if k >= ' ' && k < keyDelete {
+				v.Input += string(k)
+			}
+		}
+
+		return false
+	}
+
+	switch k {
+	case 'q', keyCtrlC:
+		return true
+	case 't':
+		v.Sort = sortThroughput
+	case 's':
+		v.Sort = sortSubflows
+	case 'r':
+		v.Sort = sortRTT
+	case 'a':
+		v.Sort = sortAddress
+	case 'R':
+		v.Reverse = !v.Reverse
+	case '/':
+		v.Editing = true
+		v.Input = v.Filter
+	case keyEscape:
+		v.Filter = ""
+	case 'f':
+		v.FallbackOnly = !v.FallbackOnly
+	case 'p':
+		v.Paths = !v.Paths
+	}
+
+	return false
+}
@@ -0,0 +1,207 @@
+// Command mptcptop is an interactive, top-like view of the multipath TCP
+// connections on the current host.
+//
+// Each connection is shown with its data-level throughput since the previous
+// refresh, its number of subflows, and the round trip time of its fastest
+// path.  Connections which fell back to regular TCP are marked, and the
+// subflows of each connection may be shown with their own round trip times.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+)
+
+func main() {
+	var (
+		interval = flag.Duration("d", time.Second, "refresh interval")
+		batch    = flag.Bool("b", false, "batch mode: write plain text updates instead of using the terminal")
+		count    = flag.Int("n", 0, "number of updates in batch mode, or 0 for no limit")
+		sortBy   = flag.String("s", "throughput", "sort by: throughput, subflows, rtt, or address")
+		paths    = flag.Bool("p", true, "show the paths of each connection")
+		filter   = flag.String("filter", "", "only show connections matching this text")
+	)
+
+	log.SetPrefix("mptcptop: ")
+	log.SetFlags(0)
+	flag.Parse()
+
+	if *interval <= 0 {
+		log.Fatal("refresh interval must be positive")
+	}
+
+	v := &view{
+		Interval: *interval,
+		Filter:   *filter,
+		Paths:    *paths,
+	}
+
+	var ok bool
+	for _, k := range []sortKey{sortThroughput, sortSubflows, sortRTT, sortAddress} {
+		if k.String() == *sortBy {
+			v.Sort, ok = k, true
+		}
+	}
+	if !ok {
+		log.Fatalf("unknown sort key %q", *sortBy)
+	}
+
+	if *batch {
+		runBatch(v, *count, os.Stdout)
+		return
+	}
+
+	if err := runInteractive(v); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// takeSample takes a sample of all connections and their subflows.
+func takeSample() (*sample, error) {
+	entries, err := mptcp.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	subflows, err := mptcp.SubflowEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sample{
+		Time:     time.Now(),
+		Entries:  entries,
+		Subflows: subflows,
+	}, nil
+}
+
+// A sampler takes samples and builds conns with throughput since the
+// previous sample.
+type sampler struct {
+	prev  *sample
+	conns []conn
+}
+
+// next takes a new sample.  On error, the previous conns are kept.
+func (s *sampler) next() error {
+	cur, err := takeSample()
+	if err != nil {
+		return err
+	}
+
+	s.conns = build(s.prev, cur)
+	s.prev = cur
+	return nil
+}
+
+// runBatch writes n updates to w as plain text, or updates forever if n is
+// zero.
+func runBatch(v *view, n int, w io.Writer) {
+	var s sampler
+	for i := 0; n == 0 || i < n; i++ {
+		if i > 0 {
+			time.Sleep(v.Interval)
+		}
+
+		if err := s.next(); err != nil {
+			log.Fatalf("failed to list connections: %v", err)
+		}
+
+		lines := render(v, time.Now(), s.conns, 0, 0)
+
+		// Key help is not useful without a terminal.
+		lines = append(lines[:1], lines[2:]...)
+		fmt.Fprintf(w, "%s\n\n", strings.Join(lines, "\n"))
+	}
+}
+
+// runInteractive displays updates on the terminal until a quit key is pressed
+// or a termination signal is received.
+func runInteractive(v *view) error {
+	const fd = 0
+
+	restore, err := rawTerminal(fd)
+	if err != nil {
+		return fmt.Errorf("failed to configure terminal, use -b for batch mode: %v", err)
+	}
+	defer restore()
+
+	// Use the alternate screen and hide the cursor, restoring both on exit.
+	out := bufio.NewWriter(os.Stdout)
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		_ = out.Flush()
+	}()
+
+	keys := make(chan byte)
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(b); err != nil {
+				close(keys)
+				return
+			}
+			keys <- b[0]
+		}
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	// Redraw when the terminal is resized, on platforms which signal it.
+	resize := make(chan os.Signal, 1)
+	if len(resizeSignals) > 0 {
+		signal.Notify(resize, resizeSignals...)
+		defer signal.Stop(resize)
+	}
+
+	var s sampler
+	draw := func() error {
+		width, height, err := terminalSize(fd)
+		if err != nil {
+			return err
+		}
+
+		lines := render(v, time.Now(), s.conns, width, height)
+		fmt.Fprintf(out, "\x1b[H\x1b[2J%s", strings.Join(lines, "\n"))
+		return out.Flush()
+	}
+
+	t := time.NewTicker(v.Interval)
+	defer t.Stop()
+
+	v.Err = s.next()
+	for {
+		if err := draw(); err != nil {
+			return err
+		}
+
+		select {
+		case <-t.C:
+			v.Err = s.next()
+		case k, ok := <-keys:
+			if !ok {
+				return errors.New("failed to read keys from terminal")
+			}
+			if v.key(k) {
+				return nil
+			}
+		case <-resize:
+		case <-sigs:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// A sample is a snapshot of connections and subflows at a point in time.
+type sample struct {
+	Time     time.Time
+	Entries  []mptcp.Entry
+	Subflows []mptcp.Subflow
+}
+
+// A conn is a connection as displayed by mptcptop.
+type conn struct {
+	Entry mptcp.Entry
+
+	// Fallback reports whether the connection fell back to regular TCP.
+	Fallback bool
+
+	// TxRate and RxRate are the data-level throughput of the connection in
+	// bytes per second since the previous sample, or -1 if unknown.
+	TxRate float64
+	RxRate float64
+
+	// Paths are the subflows of the connection, and RTT is the lowest round
+	// trip time of any path.
+	Paths []mptcp.Subflow
+	RTT   time.Duration
+}
+
+// build creates the conns for the current sample, computing throughput from
+// the byte counters of connections which were also present in prev.
+func build(prev, cur *sample) []conn {
+	// Connections are identified across samples by their local token.
+	var (
+		elapsed float64
+		before  = make(map[mptcp.Token]*mptcp.Info)
+	)
+	if prev != nil {
+		elapsed = cur.Time.Sub(prev.Time).Seconds()
+		for _, e := range prev.Entries {
+			if e.Info != nil {
+				before[e.LocalToken] = e.Info
+			}
+		}
+	}
+
+	paths := make(map[mptcp.Token][]mptcp.Subflow)
+	for _, s := range cur.Subflows {
+		paths[s.Token] = append(paths[s.Token], s)
+	}
+
+	conns := make([]conn, 0, len(cur.Entries))
+	for _, e := range cur.Entries {
+		c := conn{
+			Entry:  e,
+			TxRate: -1,
+			RxRate: -1,
+			Paths:  paths[e.LocalToken],
+		}
+
+		if e.Info != nil {
+			c.Fallback = e.Info.Fallback
+			if b, ok := before[e.LocalToken]; ok && elapsed > 0 {
+				c.TxRate = rate(b.BytesSent, e.Info.BytesSent, elapsed)
+				c.RxRate = rate(b.BytesReceived, e.Info.BytesReceived, elapsed)
+			}
+		}
+
+		// Subflows are listed by address, so that paths do not move
+		// around between refreshes.
+		sort.Slice(c.Paths, func(i, j int) bool {
+			a, b := c.Paths[i], c.Paths[j]
+			if lessAddr(a.Local, b.Local) || lessAddr(b.Local, a.Local) {
+				return lessAddr(a.Local, b.Local)
+			}
+
+			return lessAddr(a.Remote, b.Remote)
+		})
+		for _, p := range c.Paths {
+			if p.RTT > 0 && (c.RTT == 0 || p.RTT < c.RTT) {
+				c.RTT = p.RTT
+			}
+		}
+
+		conns = append(conns, c)
+	}
+
+	return conns
+}
+
+// rate computes a rate in bytes per second from two counter values.  A
+// counter which went backwards is treated as a new counter.
+func rate(before, after uint64, elapsed float64) float64 {
+	if after < before {
+		return float64(after) / elapsed
+	}
+
+	return float64(after-before) / elapsed
+}
+
+// A sortKey is a column by which connections are sorted.
+type sortKey int
+
+// Possible sortKey values.
+const (
+	sortThroughput sortKey = iota
+	sortSubflows
+	sortRTT
+	sortAddress
+)
+
+// String returns the name of a sortKey.
+func (k sortKey) String() string {
+	switch k {
+	case sortThroughput:
+		return "throughput"
+	case sortSubflows:
+		return "subflows"
+	case sortRTT:
+		return "rtt"
+	case sortAddress:
+		return "address"
+	default:
+		return "unknown"
+	}
+}
+
+// sortConns sorts conns by key, with the largest values first unless
+// reverse is set.  Addresses sort in ascending order unless reverse is set.
+// Ties are broken by token, so that the order is stable between refreshes.
+func sortConns(conns []conn, key sortKey, reverse bool) {
+	// before reports whether a sorts before b, and whether they tie.
+	before := func(a, b *conn) (bool, bool) {
+		switch key {
+		case sortThroughput:
+			ta, tb := a.TxRate+a.RxRate, b.TxRate+b.RxRate
+			return ta > tb, ta == tb
+		case sortSubflows:
+			return len(a.Paths) > len(b.Paths), len(a.Paths) == len(b.Paths)
+		case sortRTT:
+			return a.RTT > b.RTT, a.RTT == b.RTT
+		default:
+			ra, rb := a.Entry.Remote, b.Entry.Remote
+			return lessAddr(ra, rb), !lessAddr(ra, rb) && !lessAddr(rb, ra)
+		}
+	}
+
+	sort.SliceStable(conns, func(i, j int) bool {
+		b, tie := before(&conns[i], &conns[j])
+		if tie {
+			return conns[i].Entry.LocalToken < conns[j].Entry.LocalToken
+		}
+
+		return b != reverse
+	})
+}
+
+// lessAddr orders TCP addresses by IP and then by port.  Nil addresses
+// order first.
+func lessAddr(a, b *net.TCPAddr) bool {
+	switch {
+	case a == nil || b == nil:
+		return a == nil && b != nil
+	}
+
+	if c := bytes.Compare(a.IP.To16(), b.IP.To16()); c != 0 {
+		return c < 0
+	}
+
+	return a.Port < b.Port
+}
+
+// filterConns returns the conns which match query, a case-insensitive
+// substring of a connection's token, state, or addresses, or of any of its
+// paths.  If fallbackOnly is set, only connections which fell back to
+// regular TCP are returned.
+func filterConns(conns []conn, query string, fallbackOnly bool) []conn {
+	query = strings.ToLower(query)
+
+	var out []conn
+	for _, c := range conns {
+		if fallbackOnly && !c.Fallback {
+			continue
+		}
+		if query != "" && !c.matches(query) {
+			continue
+		}
+
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// matches reports whether c matches a lower case query.
+func (c *conn) matches(query string) bool {
+	fields := []string{
+		c.Entry.LocalToken.String(),
+		c.Entry.State.String(),
+		c.Entry.Local.String(),
+		c.Entry.Remote.String(),
+	}
+	for _, p := range c.Paths {
+		fields = append(fields, p.Local.String(), p.Remote.String())
+	}
+
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// TestBuild verifies that build computes throughput from consecutive samples
+// and groups subflows by connection.
+func TestBuild(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	entry := func(tok mptcp.Token, port int, sent, recv uint64, fallback bool) mptcp.Entry {
+		return mptcp.Entry{
+			LocalToken: tok,
+			State:      mptcp.StateEstablished,
+			Local:      &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: port},
+			Remote:     &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 443},
+			Info: &mptcp.Info{
+				Fallback:      fallback,
+				BytesSent:     sent,
+				BytesReceived: recv,
+			},
+		}
+	}
+
+	prev := &sample{
+		Time: start,
+		Entries: []mptcp.Entry{
+			entry(1, 50000, 1000, 2000, false),
+			entry(2, 50001, 5000, 0, true),
+		},
+	}
+
+	cur := &sample{
+		Time: start.Add(2 * time.Second),
+		Entries: []mptcp.Entry{
+			entry(1, 50000, 3000, 6000, false),
+			entry(2, 50001, 100, 0, true),
+			entry(3, 50002, 100, 100, false),
+		},
+		Subflows: []mptcp.Subflow{
+			{
+				Token: 1,
+				Local: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 50010},
+				RTT:   20 * time.Millisecond,
+			},
+			{
+				Token: 1,
+				Local: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000},
+				RTT:   10 * time.Millisecond,
+			},
+			{Token: 4},
+		},
+	}
+
+	conns := build(prev, cur)
+	if len(conns) != 3 {
+		t.Fatalf("unexpected number of conns: %d", len(conns))
+	}
+
+	type result struct {
+		TxRate, RxRate float64
+		Fallback       bool
+		Paths          int
+		RTT            time.Duration
+	}
+
+	want := []result{
+		{TxRate: 1000, RxRate: 2000, Paths: 2, RTT: 10 * time.Millisecond},
+		{TxRate: 50, RxRate: 0, Fallback: true},
+		{TxRate: -1, RxRate: -1},
+	}
+
+	for i, c := range conns {
+		got := result{
+			TxRate:   c.TxRate,
+			RxRate:   c.RxRate,
+			Fallback: c.Fallback,
+			Paths:    len(c.Paths),
+			RTT:      c.RTT,
+		}
+		if got != want[i] {
+			t.Fatalf("[%02d] unexpected conn:\n- want: %+v\n-  got: %+v", i, want[i], got)
+		}
+	}
+
+	if p := conns[0].Paths[0]; p.Local.Port != 50000 {
+		t.Fatalf("paths should be ordered by address, but got first: %v", p.Local)
+	}
+
+	// Without a previous sample, throughput is unknown
+	for i, c := range build(nil, cur) {
+		if c.TxRate != -1 || c.RxRate != -1 {
+			t.Fatalf("[%02d] expected unknown throughput without previous sample: %+v", i, c)
+		}
+	}
+}
+
+// TestSortConns verifies that sortConns orders connections by each key.
+func TestSortConns(t *testing.T) {
+	mk := func(tok mptcp.Token, ip byte, rate float64, paths int, rtt time.Duration) conn {
+		return conn{
+			Entry: mptcp.Entry{
+				LocalToken: tok,
+				Remote:     &net.TCPAddr{IP: net.IPv4(192, 0, 2, ip), Port: 443},
+			},
+			TxRate: rate,
+			Paths:  make([]mptcp.Subflow, paths),
+			RTT:    rtt,
+		}
+	}
+
+	conns := []conn{
+		mk(1, 3, 100, 1, 30*time.Millisecond),
+		mk(2, 1, 300, 2, 10*time.Millisecond),
+		mk(3, 2, 200, 3, 20*time.Millisecond),
+		mk(4, 4, 200, 1, 20*time.Millisecond),
+	}
+
+	var tests = []struct {
+		key     sortKey
+		reverse bool
+		want    []mptcp.Token
+	}{
+		{key: sortThroughput, want: []mptcp.Token{2, 3, 4, 1}},
+		{key: sortThroughput, reverse: true, want: []mptcp.Token{1, 3, 4, 2}},
+		{key: sortSubflows, want: []mptcp.Token{3, 2, 1, 4}},
+		{key: sortRTT, want: []mptcp.Token{1, 3, 4, 2}},
+		{key: sortAddress, want: []mptcp.Token{2, 3, 1, 4}},
+		{key: sortAddress, reverse: true, want: []mptcp.Token{4, 1, 3, 2}},
+	}
+
+	for i, test := range tests {
+		cs := append([]conn(nil), conns...)
+		sortConns(cs, test.key, test.reverse)
+
+		var got []mptcp.Token
+		for _, c := range cs {
+			got = append(got, c.Entry.LocalToken)
+		}
+
+		if !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("[%02d] sort by %s (reverse: %v), unexpected order:\n- want: %v\n-  got: %v",
+				i, test.key, test.reverse, test.want, got)
+		}
+	}
+}
+
+// TestFilterConns verifies that filterConns matches tokens, states, and
+// addresses of connections and their paths.
+func TestFilterConns(t *testing.T) {
+	conns := []conn{
+		{
+			Entry: mptcp.Entry{
+				LocalToken: 0x9c290bf6,
+				State:      mptcp.StateEstablished,
+				Local:      &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443},
+				Remote:     &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 50000},
+			},
+			Paths: []mptcp.Subflow{{
+				Local:  &net.TCPAddr{IP: net.IPv4(203, 0, 113, 1), Port: 443},
+				Remote: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 50001},
+			}},
+		},
+		{
+			Entry: mptcp.Entry{
+				LocalToken: 0x1,
+				State:      mptcp.StateTimeWait,
+				Local:      &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 22},
+				Remote:     &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 40000},
+			},
+			Fallback: true,
+		},
+	}
+
+	var tests = []struct {
+		query    string
+		fallback bool
+		n        int
+	}{
+		{query: "", n: 2},
+		{query: "9C290BF6", n: 1},
+		{query: "9c290bf6", n: 1},
+		{query: "time_wait", n: 1},
+		{query: "203.0.113", n: 1},
+		{query: "2001:db8", n: 1},
+		{query: ":443", n: 1},
+		{query: "nothing", n: 0},
+		{fallback: true, n: 1},
+		{query: "443", fallback: true, n: 0},
+	}
+
+	for i, test := range tests {
+		if n := len(filterConns(conns, test.query, test.fallback)); n != test.n {
+			t.Fatalf("[%02d] query %q (fallback: %v), unexpected matches: %d != %d",
+				i, test.query, test.fallback, test.n, n)
+		}
+	}
+}
+
+// TestViewKey verifies that keys change the view as expected.
+func TestViewKey(t *testing.T) {
+	v := &view{}
+	for _, k := range []byte("sR/10.0\x7f1\r") {
+		if v.key(k) {
+			t.Fatalf("unexpected quit for key %q", k)
+		}
+	}
+
+	want := &view{Sort: sortSubflows, Reverse: true, Filter: "10.1", Input: "10.1"}
+	if !reflect.DeepEqual(want, v) {
+		t.Fatalf("unexpected view:\n- want: %+v\n-  got: %+v", want, v)
+	}
+
+	// Escape cancels editing, and then clears the filter
+	for _, k := range []byte("/x\x1b") {
+		v.key(k)
+	}
+	if v.Editing || v.Filter != "10.1" {
+		t.Fatalf("expected canceled edit to keep filter: %+v", v)
+	}
+	v.key(keyEscape)
+	if v.Filter != "" {
+		t.Fatalf("expected escape to clear filter: %+v", v)
+	}
+
+	if !v.key('q') || !v.key(keyCtrlC) {
+		t.Fatal("expected q and Ctrl-C to quit")
+	}
+}
+
+// TestRender verifies that render lists connections and their paths within
+// the terminal size.
+func TestRender(t *testing.T) {
+	conns := []conn{{
+		Entry: mptcp.Entry{
+			LocalToken: 0x9c290bf6,
+			State:      mptcp.StateEstablished,
+			Local:      &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443},
+			Remote:     &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 50000},
+		},
+		TxRate: 1500000,
+		RxRate: 512,
+		RTT:    12 * time.Millisecond,
+		Paths: []mptcp.Subflow{{
+			LocalID: 1,
+			Local:   &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443},
+			Remote:  &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 50000},
+			State:   mptcp.StateEstablished,
+			Backup:  true,
+			RTT:     12 * time.Millisecond,
+			RTTVar:  500 * time.Microsecond,
+		}},
+		Fallback: true,
+	}}
+
+	v := &view{Interval: time.Second, Paths: true}
+	lines := render(v, time.Unix(0, 0).UTC(), conns, 0, 0)
+
+	text := strings.Join(lines, "\n")
+	for _, s := range []string{
+		"connections: 1  subflows: 1  fallback: 1",
+		"9C290BF6  ESTABLISHED  192.0.2.1:443",
+		"1.5MB/s",
+		"512B/s",
+		"12.0ms  FB",
+		"1/0 192.0.2.1:443 -> 198.51.100.1:50000  rtt 12.0ms/500us backup",
+	} {
+		if !strings.Contains(text, s) {
+			t.Fatalf("expected output to contain %q:\n%s", s, text)
+		}
+	}
+
+	lines = render(v, time.Unix(0, 0), conns, 20, 4)
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, but got %d", len(lines))
+	}
+	for i, l := range lines {
+		if len(l) > 20 {
+			t.Fatalf("[%02d] line exceeds width: %q", i, l)
+		}
+	}
+}
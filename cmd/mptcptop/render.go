@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// A view is the display state of mptcptop, which is changed by keys.
+type view struct {
+	Interval     time.Duration
+	Sort         sortKey
+	Reverse      bool
+	Filter       string
+	FallbackOnly bool
+	Paths        bool
+
+	// Editing is set while a filter is being entered into Input.
+	Editing bool
+	Input   string
+
+	// Err is the error from the most recent sample, if any.
+	Err error
+}
+
+// help describes the keys accepted by mptcptop.
+const help = "t/s/r/a: sort by throughput/subflows/rtt/address  R: reverse  /: filter  f: fallback only  p: paths  q: quit"
+
+// render renders conns as lines of at most width characters, up to height
+// lines.  Non-positive width or height are unlimited.
+func render(v *view, now time.Time, conns []conn, width, height int) []string {
+	var (
+		subflows, fallback int
+		shown              = filterConns(conns, v.Filter, v.FallbackOnly)
+	)
+	for _, c := range conns {
+		subflows += len(c.Paths)
+		if c.Fallback {
+			fallback++
+		}
+	}
+	sortConns(shown, v.Sort, v.Reverse)
+
+	order := "desc"
+	if v.Reverse != (v.Sort == sortAddress) {
+		order = "asc"
+	}
+
+	status := fmt.Sprintf("mptcptop - %s  connections: %d  subflows: %d  fallback: %d  every %s  sort: %s %s",
+		now.Format("15:04:05"), len(conns), subflows, fallback, v.Interval, v.Sort, order)
+	if v.FallbackOnly {
+		status += "  fallback only"
+	}
+
+	var prompt string
+	switch {
+	case v.Editing:
+		prompt = "filter: " + v.Input + "_"
+	case v.Err != nil:
+		prompt = "error: " + v.Err.Error()
+	case v.Filter != "":
+		prompt = fmt.Sprintf("filter: %s (%d matching)", v.Filter, len(shown))
+	}
+
+	lines := []string{status, help, prompt}
+
+	// Size address columns to fit the longest address shown.
+	addrWidth := len("LOCAL")
+	for _, c := range shown {
+		addrWidth = max(addrWidth, len(c.Entry.Local.String()), len(c.Entry.Remote.String()))
+	}
+
+	row := func(token, state, local, remote, sf, tx, rx, rtt, flags string) string {
+		return fmt.Sprintf("%-8s  %-11s  %-*s  %-*s  %3s  %10s  %10s  %8s  %s",
+			token, state, addrWidth, local, addrWidth, remote, sf, tx, rx, rtt, flags)
+	}
+
+	lines = append(lines, row("TOKEN", "STATE", "LOCAL", "REMOTE", "SF", "TX", "RX", "RTT", "FLAGS"))
+	for _, c := range shown {
+		lines = append(lines, row(
+			c.Entry.LocalToken.String(),
+			c.Entry.State.String(),
+			c.Entry.Local.String(),
+			c.Entry.Remote.String(),
+			fmt.Sprint(len(c.Paths)),
+			formatRate(c.TxRate),
+			formatRate(c.RxRate),
+			formatRTT(c.RTT),
+			c.flags(),
+		))
+
+		if !v.Paths {
+			continue
+		}
+
+		for _, p := range c.Paths {
+			line := fmt.Sprintf("  %d/%d %s -> %s  rtt %s/%s",
+				p.LocalID, p.RemoteID, p.Local, p.Remote, formatRTT(p.RTT), formatRTT(p.RTTVar))
+			if p.State != mptcp.StateEstablished {
+				line += " " + p.State.String()
+			}
+			if p.Backup {
+				line += " backup"
+			}
+
+			lines = append(lines, line)
+		}
+	}
+
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	if width > 0 {
+		for i, l := range lines {
+			if len(l) > width {
+				lines[i] = l[:width]
+			}
+		}
+	}
+
+	return lines
+}
+
+// flags returns markers for notable connection properties: F for fallback
+// to regular TCP, and B if any path is a backup path.
+func (c *conn) flags() string {
+	var b strings.Builder
+	if c.Fallback {
+		b.WriteByte('F')
+	}
+	for _, p := range c.Paths {
+		if p.Backup {
+			b.WriteByte('B')
+			break
+		}
+	}
+
+	return b.String()
+}
+
+// formatRate formats a rate in bytes per second, or "-" if it is unknown.
+func formatRate(r float64) string {
+	if r < 0 {
+		return "-"
+	}
+
+	units := []string{"B/s", "kB/s", "MB/s", "GB/s"}
+	i := 0
+	for r >= 1000 && i < len(units)-1 {
+		r /= 1000
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%.0f%s", r, units[i])
+	}
+
+	return fmt.Sprintf("%.1f%s", r, units[i])
+}
+
+// formatRTT formats a round trip time, or "-" if it is unknown.
+func formatRTT(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d < time.Millisecond:
+		return fmt.Sprintf("%dus", d.Microseconds())
+	default:
+		return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+	}
+}
@@ -0,0 +1,23 @@
+// +build !linux
+
+package main
+
+import (
+	"os"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// resizeSignals is empty on non-Linux platforms, where interactive mode is
+// not currently implemented.
+var resizeSignals []os.Signal
+
+// rawTerminal is not currently implemented on non-Linux platforms.
+func rawTerminal(_ int) (func() error, error) {
+	return nil, mptcp.ErrNotImplemented
+}
+
+// terminalSize is not currently implemented on non-Linux platforms.
+func terminalSize(_ int) (int, int, error) {
+	return 0, 0, mptcp.ErrNotImplemented
+}
@@ -0,0 +1,189 @@
+package mptcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// A Conn is a TCP connection which was created with multipath TCP enabled.
+// Conn embeds a net.Conn, and adds methods which report the state of the
+// multipath TCP connection.
+//
+// If the peer does not support multipath TCP, the connection falls back to
+// regular TCP, and IsFallback reports true.
+type Conn struct {
+	net.Conn
+	tc *net.TCPConn
+}
+
+// A Subflow is a single TCP subflow of a multipath TCP connection.
+//
+// Fields which are not reported by the operating system, or by the function
+// which returned the Subflow, are left at their zero value.
+type Subflow struct {
+	// Token is the local token of the connection to which the subflow
+	// belongs.  Token is only set by SubflowEntries.
+	Token Token
+
+	// LocalID and RemoteID are the address IDs of the subflow's local and
+	// remote addresses.  LocalID and RemoteID are only set by
+	// SubflowEntries.
+	LocalID  uint8
+	RemoteID uint8
+
+	// Local and Remote are the addresses of the subflow.
+	Local  *net.TCPAddr
+	Remote *net.TCPAddr
+
+	// State is the TCP state of the subflow.
+	State State
+
+	// Backup reports whether either endpoint has marked the subflow as a
+	// backup subflow.  Backup is only set by SubflowEntries.
+	Backup bool
+
+	// RTT is the smoothed round trip time of the subflow, and RTTVar is its
+	// variance.
+	RTT    time.Duration
+	RTTVar time.Duration
+}
+
+// newConn wraps c in a Conn.
+func newConn(c net.Conn) (*Conn, error) {
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		_ = c.Close()
+		return nil, fmt.Errorf("mptcp: unexpected connection type %T", c)
+	}
+
+	return &Conn{
+		Conn: c,
+		tc:   tc,
+	}, nil
+}
+
+// TCPConn returns the underlying *net.TCPConn of c.
+func (c *Conn) TCPConn() *net.TCPConn {
+	return c.tc
+}
+
+// IsFallback reports whether the connection has fallen back to regular TCP,
+// either because the peer or the local host does not support multipath TCP.
+func (c *Conn) IsFallback() (bool, error) {
+	ok, err := c.tc.MultipathTCP()
+	if err != nil {
+		return false, err
+	}
+
+	return !ok, nil
+}
+
+// Info returns detailed information about the multipath TCP connection.
+//
+// If connection information is not implemented for the current operating
+// system, Info will return ErrNotImplemented.
+func (c *Conn) Info() (*Info, error) {
+	return connInfo(c.tc)
+}
+
+// Subflows returns the subflows of the multipath TCP connection.  A
+// connection which has fallen back to regular TCP reports no subflows.
+//
+// If subflow listing is not implemented for the current operating system,
+// Subflows will return ErrNotImplemented.
+func (c *Conn) Subflows() ([]Subflow, error) {
+	return connSubflows(c.tc)
+}
+
+// Dial connects to address on the named network using multipath TCP.  Only
+// the "tcp", "tcp4", and "tcp6" networks are supported.
+//
+// Dial is equivalent to using a zero value Dialer.
+func Dial(network, address string) (*Conn, error) {
+	var d Dialer
+	return d.Dial(network, address)
+}
+
+// A Dialer connects to addresses using multipath TCP.  The options of the
+// embedded net.Dialer are used for each connection, except that multipath
+// TCP is always enabled.
+type Dialer struct {
+	net.Dialer
+}
+
+// Dial connects to address on the named network using multipath TCP.
+func (d *Dialer) Dial(network, address string) (*Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to address on the named network using multipath TCP,
+// using the provided context.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (*Conn, error) {
+	if err := checkNetwork(network); err != nil {
+		return nil, err
+	}
+
+	nd := d.Dialer
+	nd.SetMultipathTCP(true)
+
+	c, err := nd.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(c)
+}
+
+// A Listener accepts multipath TCP connections.  Listener implements
+// net.Listener, and each net.Conn returned by Accept is a *Conn.
+type Listener struct {
+	net.Listener
+}
+
+// Listen announces on the local address using multipath TCP.  Only the
+// "tcp", "tcp4", and "tcp6" networks are supported.
+//
+// Peers which do not support multipath TCP may still connect, and their
+// connections fall back to regular TCP.
+func Listen(network, address string) (*Listener, error) {
+	if err := checkNetwork(network); err != nil {
+		return nil, err
+	}
+
+	var lc net.ListenConfig
+	lc.SetMultipathTCP(true)
+
+	l, err := lc.Listen(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Listener{Listener: l}, nil
+}
+
+// Accept waits for and returns the next connection, which is a *Conn.
+func (l *Listener) Accept() (net.Conn, error) {
+	return l.AcceptMPTCP()
+}
+
+// AcceptMPTCP waits for and returns the next connection.
+func (l *Listener) AcceptMPTCP() (*Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(c)
+}
+
+// checkNetwork verifies that network is a TCP network.
+func checkNetwork(network string) error {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return nil
+	default:
+		return fmt.Errorf("mptcp: unsupported network %q", network)
+	}
+}
@@ -0,0 +1,251 @@
+// +build linux
+
+package mptcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Socket options at level SOL_MPTCP.
+	sockoptInfo         = 1
+	sockoptTCPInfo      = 2
+	sockoptSubflowAddrs = 3
+
+	// subflowDataLen is the length of the Linux mptcp_subflow_data
+	// header, which precedes the per-subflow data of the MPTCP_TCPINFO
+	// and MPTCP_SUBFLOW_ADDRS socket options.
+	subflowDataLen = 16
+
+	// sockaddrStorageLen is the length of the Linux sockaddr_storage
+	// structure, and subflowAddrsLen is the length of the Linux
+	// mptcp_subflow_addrs structure: a local and remote sockaddr_storage.
+	sockaddrStorageLen = 128
+	subflowAddrsLen    = 2 * sockaddrStorageLen
+
+	// tcpInfoLen is the number of bytes of the Linux tcp_info structure
+	// which are requested per subflow, up to and including tcpi_rttvar.
+	tcpInfoLen = 76
+
+	// infoMaxLen is the size of the buffer used to retrieve mptcp_info.
+	infoMaxLen = 256
+
+	// subflowsHint is the number of subflows for which space is first
+	// allocated when listing subflows.
+	subflowsHint = 8
+)
+
+var (
+	// errInvalidSubflowData is returned when a mptcp_subflow_data
+	// structure is not in the expected format.
+	errInvalidSubflowData = errors.New("invalid MPTCP subflow data")
+)
+
+// connInfo retrieves mptcp_info for the MPTCP socket of c.  A connection
+// which has fallen back to regular TCP reports only its Fallback field.
+func connInfo(c *net.TCPConn) (*Info, error) {
+	ok, err := c.MultipathTCP()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &Info{Fallback: true}, nil
+	}
+
+	b := make([]byte, infoMaxLen)
+	n, err := getsockopt(c, unix.SOL_MPTCP, sockoptInfo, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseInfo(b[:n])
+}
+
+// connSubflows lists the subflows of the MPTCP socket of c.
+func connSubflows(c *net.TCPConn) ([]Subflow, error) {
+	ok, err := c.MultipathTCP()
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	addrs, err := subflowData(c, sockoptSubflowAddrs, subflowAddrsLen)
+	if err != nil {
+		return nil, err
+	}
+
+	subflows := make([]Subflow, 0, len(addrs))
+	for _, b := range addrs {
+		s, err := parseSubflowAddrs(b)
+		if err != nil {
+			return nil, err
+		}
+
+		subflows = append(subflows, *s)
+	}
+
+	// States and round trip times are reported separately, but in the
+	// same order.
+	// Subflows may come and go between the two requests, so only apply
+	// them when the number of subflows is unchanged.
+	infos, err := subflowData(c, sockoptTCPInfo, tcpInfoLen)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == len(subflows) {
+		for i, b := range infos {
+			subflows[i].State = State(b[0])
+			subflows[i].RTT = usDuration(binary.NativeEndian.Uint32(b[68:72]))
+			subflows[i].RTTVar = usDuration(binary.NativeEndian.Uint32(b[72:76]))
+		}
+	}
+
+	return subflows, nil
+}
+
+// subflowData retrieves per-subflow data of size bytes using the socket
+// option opt, which must use a mptcp_subflow_data header.
+func subflowData(c *net.TCPConn, opt, size int) ([][]byte, error) {
+	n := subflowsHint
+	for {
+		b := make([]byte, subflowDataLen+n*size)
+		binary.NativeEndian.PutUint32(b[0:4], subflowDataLen)
+		binary.NativeEndian.PutUint32(b[12:16], uint32(size))
+
+		if _, err := getsockopt(c, unix.SOL_MPTCP, opt, b); err != nil {
+			return nil, err
+		}
+
+		// Retry with more space if subflows were added since the
+		// previous request.
+		num := int(binary.NativeEndian.Uint32(b[4:8]))
+		if num > n {
+			n = num
+			continue
+		}
+
+		// The kernel may report less data per subflow than requested
+		// when it is older than the caller.
+		if kernel := int(binary.NativeEndian.Uint32(b[8:12])); kernel < size {
+			return nil, errInvalidSubflowData
+		}
+
+		out := make([][]byte, 0, num)
+		for i := 0; i < num; i++ {
+			off := subflowDataLen + i*size
+			out = append(out, b[off:off+size])
+		}
+
+		return out, nil
+	}
+}
+
+// parseSubflowAddrs parses a Subflow from a mptcp_subflow_addrs structure.
+func parseSubflowAddrs(b []byte) (*Subflow, error) {
+	if len(b) < subflowAddrsLen {
+		return nil, errInvalidSubflowData
+	}
+
+	local, err := parseSockaddr(b[:sockaddrStorageLen])
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := parseSockaddr(b[sockaddrStorageLen:subflowAddrsLen])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subflow{
+		Local:  local,
+		Remote: remote,
+	}, nil
+}
+
+// parseSockaddr parses a TCP address from a sockaddr_in or sockaddr_in6
+// structure.
+func parseSockaddr(b []byte) (*net.TCPAddr, error) {
+	if len(b) < unix.SizeofSockaddrInet6 {
+		return nil, errInvalidSubflowData
+	}
+
+	port := int(binary.BigEndian.Uint16(b[2:4]))
+	switch binary.NativeEndian.Uint16(b[0:2]) {
+	case unix.AF_INET:
+		return &net.TCPAddr{
+			IP:   net.IP(append([]byte(nil), b[4:8]...)),
+			Port: port,
+		}, nil
+	case unix.AF_INET6:
+		return &net.TCPAddr{
+			IP:   net.IP(append([]byte(nil), b[8:24]...)),
+			Port: port,
+		}, nil
+	default:
+		return nil, errInvalidSubflowData
+	}
+}
+
+// getsockopt retrieves the socket option at level and opt for c into b,
+// and returns the number of bytes written by the kernel.
+func getsockopt(c syscall.Conn, level, opt int, b []byte) (int, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	n := uint32(len(b))
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, fd,
+			uintptr(level), uintptr(opt),
+			uintptr(unsafe.Pointer(&b[0])), uintptr(unsafe.Pointer(&n)), 0)
+		if errno != 0 {
+			serr = errno
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if serr != nil {
+		return 0, serr
+	}
+
+	return int(n), nil
+}
+
+// usDuration converts a number of microseconds into a time.Duration.
+func usDuration(us uint32) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
+
+// isMPTCP reports whether c uses a multipath TCP socket, even if the
+// connection has fallen back to regular TCP.
+func isMPTCP(c *net.TCPConn) (bool, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		proto int
+		serr  error
+	)
+	err = rc.Control(func(fd uintptr) {
+		proto, serr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PROTOCOL)
+	})
+	if err != nil {
+		return false, err
+	}
+	if serr != nil {
+		return false, serr
+	}
+
+	return proto == unix.IPPROTO_MPTCP, nil
+}
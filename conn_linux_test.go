@@ -0,0 +1,206 @@
+// +build linux
+
+package mptcp
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// TestLinux_parseSubflowAddrs verifies that parseSubflowAddrs properly parses
+// mptcp_subflow_addrs structures.
+func TestLinux_parseSubflowAddrs(t *testing.T) {
+	inet := func(b []byte, port uint16, ip net.IP) {
+		binary.NativeEndian.PutUint16(b[0:2], 2)
+		binary.BigEndian.PutUint16(b[2:4], port)
+		copy(b[4:8], ip.To4())
+	}
+	inet6 := func(b []byte, port uint16, ip net.IP) {
+		binary.NativeEndian.PutUint16(b[0:2], 10)
+		binary.BigEndian.PutUint16(b[2:4], port)
+		copy(b[8:24], ip.To16())
+	}
+
+	ipv4 := make([]byte, subflowAddrsLen)
+	inet(ipv4[:sockaddrStorageLen], 8080, net.IPv4(192, 0, 2, 1))
+	inet(ipv4[sockaddrStorageLen:], 443, net.IPv4(198, 51, 100, 1))
+
+	ipv6 := make([]byte, subflowAddrsLen)
+	inet6(ipv6[:sockaddrStorageLen], 8080, net.ParseIP("2001:db8::1"))
+	inet6(ipv6[sockaddrStorageLen:], 443, net.ParseIP("2001:db8::2"))
+
+	var tests = []struct {
+		desc string
+		b    []byte
+		s    *Subflow
+		err  error
+	}{
+		{
+			desc: "short",
+			b:    ipv4[:subflowAddrsLen-1],
+			err:  errInvalidSubflowData,
+		},
+		{
+			desc: "unknown family",
+			b:    make([]byte, subflowAddrsLen),
+			err:  errInvalidSubflowData,
+		},
+		{
+			desc: "IPv4",
+			b:    ipv4,
+			s: &Subflow{
+				Local:  &net.TCPAddr{IP: net.IP{192, 0, 2, 1}, Port: 8080},
+				Remote: &net.TCPAddr{IP: net.IP{198, 51, 100, 1}, Port: 443},
+			},
+		},
+		{
+			desc: "IPv6",
+			b:    ipv6,
+			s: &Subflow{
+				Local:  &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 8080},
+				Remote: &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		s, err := parseSubflowAddrs(test.b)
+		if err != test.err {
+			t.Fatalf("[%02d] test %q, unexpected error: %v != %v",
+				i, test.desc, err, test.err)
+		}
+
+		if !reflect.DeepEqual(s, test.s) {
+			t.Fatalf("[%02d] test %q, unexpected Subflow:\n- want: %+v\n-  got: %+v",
+				i, test.desc, test.s, s)
+		}
+	}
+}
+
+// TestLinux_ConnLoopback verifies that a multipath TCP connection over the
+// loopback interface reports its connection information and subflows, and
+// that a connection to a regular TCP listener falls back to TCP.
+func TestLinux_ConnLoopback(t *testing.T) {
+	if ok, _ := Enabled(); !ok {
+		t.Skip("skipping, multipath TCP is not enabled")
+	}
+
+	l, err := Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		_, _ = io.Copy(c, c)
+	}()
+
+	c, err := Dial("tcp4", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Exchange data so that the connection is fully established
+	b := []byte("hello")
+	if _, err := c.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(c, b); err != nil {
+		t.Fatal(err)
+	}
+
+	fallback, err := c.IsFallback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback {
+		t.Skip("skipping, connection fell back to TCP")
+	}
+
+	info, err := c.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Fallback || info.Token == 0 {
+		t.Fatalf("unexpected Info for multipath TCP connection: %+v", info)
+	}
+
+	subflows, err := c.Subflows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subflows) == 0 {
+		t.Fatal("expected at least one subflow")
+	}
+
+	s := subflows[0]
+	if s.Local.String() != c.LocalAddr().String() || s.Remote.String() != c.RemoteAddr().String() {
+		t.Fatalf("unexpected initial subflow addresses: %v -> %v", s.Local, s.Remote)
+	}
+
+	// Connect to a regular TCP listener
+	tl := listenTCP(t)
+	defer tl.Close()
+
+	go func() {
+		c, err := tl.Accept()
+		if err != nil {
+			return
+		}
+		_ = c.Close()
+	}()
+
+	tc, err := Dial("tcp4", tl.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Close()
+
+	fallback, err = tc.IsFallback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fallback {
+		t.Fatal("expected connection to regular TCP listener to fall back")
+	}
+
+	info, err = tc.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Fallback {
+		t.Fatalf("expected fallback Info, but got: %+v", info)
+	}
+
+	subflows, err = tc.Subflows()
+	if err != nil || len(subflows) != 0 {
+		t.Fatalf("expected no subflows for fallback connection, but got: (%v, %v)", subflows, err)
+	}
+}
+
+// listenTCP creates a regular TCP listener on the IPv4 loopback interface,
+// which does not use multipath TCP even where net.Listen uses it by default.
+func listenTCP(t *testing.T) net.Listener {
+	t.Helper()
+
+	var lc net.ListenConfig
+	lc.SetMultipathTCP(false)
+
+	l, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
@@ -0,0 +1,20 @@
+// +build !linux
+
+package mptcp
+
+import "net"
+
+// connInfo is not currently implemented on non-Linux platforms.
+func connInfo(_ *net.TCPConn) (*Info, error) {
+	return nil, ErrNotImplemented
+}
+
+// connSubflows is not currently implemented on non-Linux platforms.
+func connSubflows(_ *net.TCPConn) ([]Subflow, error) {
+	return nil, ErrNotImplemented
+}
+
+// isMPTCP is not currently implemented on non-Linux platforms.
+func isMPTCP(_ *net.TCPConn) (bool, error) {
+	return false, ErrNotImplemented
+}
@@ -0,0 +1,19 @@
+// +build !linux
+
+package mptcp
+
+import "testing"
+
+// TestOthers_connInfo verifies that connInfo and connSubflows are not
+// implemented on platforms other than Linux.
+func TestOthers_connInfo(t *testing.T) {
+	info, err := connInfo(nil)
+	if info != nil || err != ErrNotImplemented {
+		t.Fatalf("connInfo is not implemented, but returned: (%v, %v)", info, err)
+	}
+
+	subflows, err := connSubflows(nil)
+	if subflows != nil || err != ErrNotImplemented {
+		t.Fatalf("connSubflows is not implemented, but returned: (%v, %v)", subflows, err)
+	}
+}
@@ -0,0 +1,70 @@
+package mptcp
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+// TestDialUnsupportedNetwork verifies that Dial and Listen only accept TCP
+// networks.
+func TestDialUnsupportedNetwork(t *testing.T) {
+	for i, network := range []string{"", "udp", "unix", "ip4:tcp"} {
+		if c, err := Dial(network, "127.0.0.1:0"); c != nil || err == nil {
+			t.Fatalf("[%02d] expected Dial error for network %q, but got: (%v, %v)",
+				i, network, c, err)
+		}
+
+		if l, err := Listen(network, "127.0.0.1:0"); l != nil || err == nil {
+			t.Fatalf("[%02d] expected Listen error for network %q, but got: (%v, %v)",
+				i, network, l, err)
+		}
+	}
+}
+
+// TestDialerDialContextCanceled verifies that DialContext respects context
+// cancelation.
+func TestDialerDialContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var d Dialer
+	c, err := d.DialContext(ctx, "tcp", "127.0.0.1:1")
+	if c != nil || err == nil {
+		t.Fatalf("expected error for canceled context, but got: (%v, %v)", c, err)
+	}
+}
+
+// TestListenerAccept verifies that a Listener returns a *Conn from Accept,
+// which may be used as a net.Conn.
+func TestListenerAccept(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("skipping, failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	var _ net.Listener = l
+
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		_ = c.Close()
+	}()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	mc, ok := c.(*Conn)
+	if !ok {
+		t.Fatalf("expected *Conn from Accept, but got %T", c)
+	}
+	if mc.TCPConn() == nil {
+		t.Fatal("expected non-nil *net.TCPConn")
+	}
+}
@@ -0,0 +1,279 @@
+package mptcp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// netstatPrefix is the prefix of the lines in /proc/net/netstat which
+	// contain mainline MPTCP counters.
+	netstatPrefix = "MPTcpExt:"
+)
+
+var (
+	// errInvalidNetstat is returned when an input netstat file is not in
+	// the expected format.
+	errInvalidNetstat = errors.New("invalid netstat counters")
+
+	// errInvalidSNMP is returned when an input MPTCP SNMP file is not in
+	// the expected format.
+	errInvalidSNMP = errors.New("invalid MPTCP SNMP counters")
+)
+
+// A MIB contains multipath TCP MIB counters, as reported by the operating
+// system.
+//
+// Commonly used counters are available as fields.  Mainline and out-of-tree
+// Linux kernels use slightly different names for some counters; each field
+// is populated from whichever name the kernel reports.
+type MIB struct {
+	// Time is the time at which the counters were sampled.
+	Time time.Time
+
+	// Interval is the time elapsed between two samples, for a MIB
+	// returned by Delta.  It is zero for a MIB returned by Counters.
+	Interval time.Duration
+
+	// MP_CAPABLE handshake counters.
+	MPCapableSYNRX          uint64
+	MPCapableSYNTX          uint64
+	MPCapableSYNACKRX       uint64
+	MPCapableACKRX          uint64
+	MPCapableFallbackACK    uint64
+	MPCapableFallbackSYNACK uint64
+	MPFallbackTokenInit     uint64
+
+	// MPTCPRetrans counts data-level retransmissions.
+	MPTCPRetrans uint64
+
+	// MP_JOIN handshake counters.
+	MPJoinNoTokenFound      uint64
+	MPJoinSynTx             uint64
+	MPJoinSynRx             uint64
+	MPJoinSynAckRx          uint64
+	MPJoinSynAckHMacFailure uint64
+	MPJoinAckRx             uint64
+	MPJoinAckHMacFailure    uint64
+
+	// Data sequence signal and checksum counters.
+	DSSNotMatching uint64
+	InfiniteMapRx  uint64
+	DSSNoMatchTCP  uint64
+	DataCsumErr    uint64
+	NoDSSInWindow  uint64
+
+	// Address announcement counters.
+	AddAddr     uint64
+	AddAddrTx   uint64
+	EchoAdd     uint64
+	AddAddrDrop uint64
+	RmAddr      uint64
+	RmAddrTx    uint64
+	RmSubflow   uint64
+
+	// MP_PRIO, MP_FAIL, MP_FASTCLOSE and MP_TCPRST counters.
+	MPPrioTx      uint64
+	MPPrioRx      uint64
+	MPFailTx      uint64
+	MPFailRx      uint64
+	MPFastcloseTx uint64
+	MPFastcloseRx uint64
+	MPRstTx       uint64
+	MPRstRx       uint64
+
+	// MPCurrEstab is the number of currently established connections.
+	// Unlike other counters, it may decrease between samples.
+	MPCurrEstab uint64
+
+	// All contains every counter reported by the operating system,
+	// by name, including those without a field.
+	All map[string]uint64
+}
+
+// fields returns pointers to each field of m, keyed by every counter name
+// which populates that field.
+func (m *MIB) fields() map[string]*uint64 {
+	return map[string]*uint64{
+		"MPCapableSYNRX":          &m.MPCapableSYNRX,
+		"MPCapableSYNTX":          &m.MPCapableSYNTX,
+		"MPCapableSYNACKRX":       &m.MPCapableSYNACKRX,
+		"MPCapableACKRX":          &m.MPCapableACKRX,
+		"MPCapableFallbackACK":    &m.MPCapableFallbackACK,
+		"MPCapableFallbackSYNACK": &m.MPCapableFallbackSYNACK,
+		"MPFallbackTokenInit":     &m.MPFallbackTokenInit,
+		"MPTCPRetrans":            &m.MPTCPRetrans,
+		"MPJoinNoTokenFound":      &m.MPJoinNoTokenFound,
+		"MPJoinNoKeyMatch":        &m.MPJoinNoTokenFound,
+		"MPJoinSynTx":             &m.MPJoinSynTx,
+		"MPJoinSynRx":             &m.MPJoinSynRx,
+		"MPJoinSynAckRx":          &m.MPJoinSynAckRx,
+		"MPJoinSynAckHMacFailure": &m.MPJoinSynAckHMacFailure,
+		"MPJoinAckRx":             &m.MPJoinAckRx,
+		"MPJoinAckHMacFailure":    &m.MPJoinAckHMacFailure,
+		"DSSNotMatching":          &m.DSSNotMatching,
+		"InfiniteMapRx":           &m.InfiniteMapRx,
+		"DSSNoMatchTCP":           &m.DSSNoMatchTCP,
+		"DataCsumErr":             &m.DataCsumErr,
+		"MPCsumFail":              &m.DataCsumErr,
+		"NoDSSInWindow":           &m.NoDSSInWindow,
+		"AddAddr":                 &m.AddAddr,
+		"AddAddrRx":               &m.AddAddr,
+		"AddAddrTx":               &m.AddAddrTx,
+		"EchoAdd":                 &m.EchoAdd,
+		"AddAddrDrop":             &m.AddAddrDrop,
+		"RmAddr":                  &m.RmAddr,
+		"RemAddrRx":               &m.RmAddr,
+		"RmAddrTx":                &m.RmAddrTx,
+		"RemAddrTx":               &m.RmAddrTx,
+		"RmSubflow":               &m.RmSubflow,
+		"MPPrioTx":                &m.MPPrioTx,
+		"MPPrioRx":                &m.MPPrioRx,
+		"MPFailTx":                &m.MPFailTx,
+		"MPFailRx":                &m.MPFailRx,
+		"MPFailRX":                &m.MPFailRx,
+		"MPFastcloseTx":           &m.MPFastcloseTx,
+		"MPFastcloseTX":           &m.MPFastcloseTx,
+		"MPFastcloseRx":           &m.MPFastcloseRx,
+		"MPFastcloseRX":           &m.MPFastcloseRx,
+		"MPRstTx":                 &m.MPRstTx,
+		"MPRstRx":                 &m.MPRstRx,
+		"MPCurrEstab":             &m.MPCurrEstab,
+	}
+}
+
+// newMIB creates a new MIB from all counters reported by the operating
+// system.
+func newMIB(all map[string]uint64, t time.Time) *MIB {
+	m := &MIB{
+		Time: t,
+		All:  all,
+	}
+
+	fields := m.fields()
+	for name, v := range all {
+		if f, ok := fields[name]; ok {
+			*f = v
+		}
+	}
+
+	return m
+}
+
+// Delta computes the increase of each counter between two samples, prev and
+// cur, taken from the same host.  The Interval field of the returned MIB is
+// set to the time elapsed between the samples, so that Rate can be used to
+// compute per-second rates.
+//
+// If a counter decreased between samples, such as when the counters are
+// reset, its current value is used as its increase.  MPCurrEstab is a gauge,
+// and is reported as its current value.
+func Delta(prev, cur *MIB) *MIB {
+	all := make(map[string]uint64, len(cur.All))
+	for name, v := range cur.All {
+		if p := prev.All[name]; v >= p && name != "MPCurrEstab" {
+			v -= p
+		}
+
+		all[name] = v
+	}
+
+	d := newMIB(all, cur.Time)
+	d.Interval = cur.Time.Sub(prev.Time)
+
+	return d
+}
+
+// Rate returns the per-second rate of a counter from a MIB returned by Delta,
+// such as:
+//
+//	d.Rate(d.MPJoinSynRx)
+//
+// If no time elapsed between samples, Rate returns 0.
+func (m *MIB) Rate(v uint64) float64 {
+	if m.Interval <= 0 {
+		return 0
+	}
+
+	return float64(v) / m.Interval.Seconds()
+}
+
+// parseNetstat parses mainline MPTCP counters from a Linux /proc/net/netstat
+// file, which contains pairs of header and value lines for each group of
+// counters.
+func parseNetstat(r io.Reader) (map[string]uint64, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// Find the header line for MPTCP counters
+		names := strings.Fields(s.Text())
+		if len(names) == 0 || names[0] != netstatPrefix {
+			continue
+		}
+
+		// Values appear on the line after the header
+		if !s.Scan() {
+			return nil, errInvalidNetstat
+		}
+
+		values := strings.Fields(s.Text())
+		if len(values) != len(names) || values[0] != netstatPrefix {
+			return nil, errInvalidNetstat
+		}
+
+		all := make(map[string]uint64, len(names)-1)
+		for i := 1; i < len(names); i++ {
+			v, err := strconv.ParseUint(values[i], 10, 64)
+			if err != nil {
+				return nil, errInvalidNetstat
+			}
+
+			all[names[i]] = v
+		}
+
+		return all, nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	// No MPTCP counters present
+	return nil, errInvalidNetstat
+}
+
+// parseSNMP parses out-of-tree MPTCP counters from a Linux
+// /proc/net/mptcp_net/snmp file, which contains one name and value pair
+// per line.
+func parseSNMP(r io.Reader) (map[string]uint64, error) {
+	all := make(map[string]uint64)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, errInvalidSNMP
+		}
+
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, errInvalidSNMP
+		}
+
+		all[fields[0]] = v
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(all) == 0 {
+		return nil, errInvalidSNMP
+	}
+
+	return all, nil
+}
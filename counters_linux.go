@@ -0,0 +1,56 @@
+// +build linux
+
+package mptcp
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	// procNetstat is the location of the Linux-specific file which contains
+	// mainline MPTCP counters.
+	procNetstat = "/proc/net/netstat"
+
+	// procMPTCPSNMP is the location of the Linux-specific file which
+	// contains out-of-tree MPTCP counters.
+	procMPTCPSNMP = "/proc/net/mptcp_net/snmp"
+)
+
+// mptcpCounters uses the Linux /proc filesystem to read MPTCP counters from
+// whichever counters file is present.
+//
+// This implementation is swappable for testing with a mock data source.
+var mptcpCounters = func() (*MIB, error) {
+	// Out-of-tree kernels provide a dedicated counters file
+	snmpFile, err := os.Open(procMPTCPSNMP)
+	if err == nil {
+		defer snmpFile.Close()
+
+		all, err := parseSNMP(snmpFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return newMIB(all, time.Now()), nil
+	}
+
+	// Return any error other than a missing file
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// Mainline kernels add counters to netstat
+	netstatFile, err := os.Open(procNetstat)
+	if err != nil {
+		return nil, err
+	}
+	defer netstatFile.Close()
+
+	all, err := parseNetstat(netstatFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMIB(all, time.Now()), nil
+}
@@ -0,0 +1,8 @@
+// +build !linux
+
+package mptcp
+
+// mptcpCounters is not currently implemented on non-Linux platforms.
+var mptcpCounters = func() (*MIB, error) {
+	return nil, ErrNotImplemented
+}
@@ -0,0 +1,173 @@
+package mptcp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseNetstat verifies that parseNetstat properly parses mainline MPTCP
+// counters from a netstat file.
+func TestParseNetstat(t *testing.T) {
+	const tcpExt = `TcpExt: SyncookiesSent SyncookiesRecv
+TcpExt: 1 2
+`
+
+	var tests = []struct {
+		desc string
+		s    string
+		all  map[string]uint64
+		err  error
+	}{
+		{"empty", "", nil, errInvalidNetstat},
+		{"no MPTCP counters", tcpExt, nil, errInvalidNetstat},
+		{"missing values", tcpExt + "MPTcpExt: MPCapableSYNRX\n", nil, errInvalidNetstat},
+		{"mismatched values", tcpExt + "MPTcpExt: MPCapableSYNRX MPJoinSynRx\nMPTcpExt: 1\n", nil, errInvalidNetstat},
+		{"mismatched prefix", tcpExt + "MPTcpExt: MPCapableSYNRX\nIpExt: 1\n", nil, errInvalidNetstat},
+		{"bad value", tcpExt + "MPTcpExt: MPCapableSYNRX\nMPTcpExt: foo\n", nil, errInvalidNetstat},
+		{
+			"OK",
+			tcpExt + "MPTcpExt: MPCapableSYNRX MPJoinSynRx MPCurrEstab\nMPTcpExt: 10 2 1\n",
+			map[string]uint64{
+				"MPCapableSYNRX": 10,
+				"MPJoinSynRx":    2,
+				"MPCurrEstab":    1,
+			},
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		all, err := parseNetstat(strings.NewReader(test.s))
+		if err != test.err {
+			t.Fatalf("[%02d] %s: unexpected err: %v != %v", i, test.desc, err, test.err)
+		}
+
+		if !reflect.DeepEqual(all, test.all) {
+			t.Fatalf("[%02d] %s: unexpected counters: %v != %v", i, test.desc, all, test.all)
+		}
+	}
+}
+
+// TestParseSNMP verifies that parseSNMP properly parses out-of-tree MPTCP
+// counters from a SNMP file.
+func TestParseSNMP(t *testing.T) {
+	var tests = []struct {
+		desc string
+		s    string
+		all  map[string]uint64
+		err  error
+	}{
+		{"empty", "", nil, errInvalidSNMP},
+		{"too many fields", "MPCapableSYNRX 1 2\n", nil, errInvalidSNMP},
+		{"bad value", "MPCapableSYNRX foo\n", nil, errInvalidSNMP},
+		{
+			"OK",
+			"MPCapableSYNRX                  \t10\nMPFailRX                        \t3\n\n",
+			map[string]uint64{
+				"MPCapableSYNRX": 10,
+				"MPFailRX":       3,
+			},
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		all, err := parseSNMP(strings.NewReader(test.s))
+		if err != test.err {
+			t.Fatalf("[%02d] %s: unexpected err: %v != %v", i, test.desc, err, test.err)
+		}
+
+		if !reflect.DeepEqual(all, test.all) {
+			t.Fatalf("[%02d] %s: unexpected counters: %v != %v", i, test.desc, all, test.all)
+		}
+	}
+}
+
+// TestNewMIB verifies that newMIB populates fields using both mainline and
+// out-of-tree counter names.
+func TestNewMIB(t *testing.T) {
+	now := time.Unix(1, 0)
+
+	mainline := newMIB(map[string]uint64{
+		"MPCapableSYNRX": 10,
+		"MPFailRx":       3,
+		"DataCsumErr":    2,
+		"MPCurrEstab":    1,
+		"Blackhole":      5,
+	}, now)
+
+	outOfTree := newMIB(map[string]uint64{
+		"MPCapableSYNRX": 10,
+		"MPFailRX":       3,
+		"MPCsumFail":     2,
+		"MPCurrEstab":    1,
+	}, now)
+
+	for i, m := range []*MIB{mainline, outOfTree} {
+		if m.MPCapableSYNRX != 10 || m.MPFailRx != 3 || m.DataCsumErr != 2 || m.MPCurrEstab != 1 {
+			t.Fatalf("[%02d] unexpected MIB fields: %+v", i, m)
+		}
+
+		if !m.Time.Equal(now) {
+			t.Fatalf("[%02d] unexpected time: %v != %v", i, m.Time, now)
+		}
+	}
+
+	// Counters without fields are still available
+	if v := mainline.All["Blackhole"]; v != 5 {
+		t.Fatalf("unexpected Blackhole counter: %d != 5", v)
+	}
+}
+
+// TestDelta verifies that Delta computes the increase of each counter between
+// two samples, and that Rate computes per-second rates.
+func TestDelta(t *testing.T) {
+	prev := newMIB(map[string]uint64{
+		"MPCapableSYNRX": 10,
+		"MPJoinSynRx":    100,
+		"MPCurrEstab":    4,
+	}, time.Unix(10, 0))
+
+	cur := newMIB(map[string]uint64{
+		"MPCapableSYNRX": 30,
+		"MPJoinSynRx":    5,
+		"MPCurrEstab":    2,
+		"MPPrioTx":       8,
+	}, time.Unix(20, 0))
+
+	d := Delta(prev, cur)
+
+	want := map[string]uint64{
+		// Normal increase
+		"MPCapableSYNRX": 20,
+		// Counter reset
+		"MPJoinSynRx": 5,
+		// Gauge
+		"MPCurrEstab": 2,
+		// New counter
+		"MPPrioTx": 8,
+	}
+
+	if !reflect.DeepEqual(d.All, want) {
+		t.Fatalf("unexpected delta counters: %v != %v", d.All, want)
+	}
+
+	if d.MPCapableSYNRX != 20 || d.MPJoinSynRx != 5 || d.MPCurrEstab != 2 || d.MPPrioTx != 8 {
+		t.Fatalf("unexpected delta fields: %+v", d)
+	}
+
+	if d.Interval != 10*time.Second {
+		t.Fatalf("unexpected interval: %v != %v", d.Interval, 10*time.Second)
+	}
+
+	if r := d.Rate(d.MPCapableSYNRX); r != 2 {
+		t.Fatalf("unexpected rate: %v != 2", r)
+	}
+
+	// Samples without an interval have no rate
+	if r := cur.Rate(cur.MPCapableSYNRX); r != 0 {
+		t.Fatalf("unexpected rate without interval: %v != 0", r)
+	}
+}
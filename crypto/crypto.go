@@ -0,0 +1,169 @@
+// Package crypto implements the cryptographic algorithms used by multipath
+// TCP, as specified by RFC 6824 (version 0) and RFC 8684 (version 1).
+//
+// Each connection endpoint chooses a 64-bit key, which it exchanges with its
+// peer in MP_CAPABLE options.  The key determines the token which identifies
+// the connection on that endpoint, and the initial data sequence number of
+// data it sends.  The keys of both endpoints are used to authenticate the
+// MP_JOIN handshakes of additional subflows and, in version 1, ADD_ADDR
+// announcements.
+//
+// Version 0 uses SHA-1 and HMAC-SHA1, and version 1 uses SHA-256 and
+// HMAC-SHA256.  Functions which accept a version use the version 1
+// algorithms for any version other than 0.
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"net/netip"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// Lengths of the HMACs carried in MP_JOIN and ADD_ADDR options: the
+// truncated HMAC of an MP_JOIN SYN/ACK and an ADD_ADDR, and the HMAC of an
+// MP_JOIN ACK.
+const (
+	truncatedHMACLen = 8
+	hmacLen          = 20
+)
+
+// newHash returns the hash function used by version.
+func newHash(version uint8) func() hash.Hash {
+	if version == 0 {
+		return sha1.New
+	}
+
+	return sha256.New
+}
+
+// keyHash returns the hash of key using the hash function of version.
+func keyHash(version uint8, key uint64) []byte {
+	h := newHash(version)()
+	_ = binary.Write(h, binary.BigEndian, key)
+
+	return h.Sum(nil)
+}
+
+// Token returns the token which identifies a connection on the endpoint
+// which chose key: the most significant 32 bits of the hash of the key.  It
+// matches the loc_tok or rem_tok values of a MPTCP connections table, and
+// the token carried by an MP_JOIN SYN sent to that endpoint.
+func Token(version uint8, key uint64) mptcp.Token {
+	return mptcp.Token(binary.BigEndian.Uint32(keyHash(version, key)))
+}
+
+// IDSN returns the initial data sequence number of data sent by the endpoint
+// which chose key: the least significant 64 bits of the hash of the key.
+func IDSN(version uint8, key uint64) uint64 {
+	sum := keyHash(version, key)
+	return binary.BigEndian.Uint64(sum[len(sum)-8:])
+}
+
+// mac computes the HMAC of msg using the concatenation of keys as the key.
+func mac(version uint8, msg []byte, keys ...uint64) []byte {
+	k := make([]byte, 0, 8*len(keys))
+	for _, key := range keys {
+		k = binary.BigEndian.AppendUint64(k, key)
+	}
+
+	m := hmac.New(newHash(version), k)
+	m.Write(msg)
+
+	return m.Sum(nil)
+}
+
+// nonces returns the concatenation of two MP_JOIN nonces.
+func nonces(a, b uint32) []byte {
+	return binary.BigEndian.AppendUint32(
+		binary.BigEndian.AppendUint32(make([]byte, 0, 8), a), b)
+}
+
+// JoinSYNACKHMAC returns the truncated HMAC sent in the MP_JOIN SYN/ACK of an
+// additional subflow, by the endpoint which receives the SYN.
+//
+// keyA and nonceA are the key and MP_JOIN nonce of the endpoint which sends
+// the SYN, and keyB and nonceB are those of the endpoint which receives it.
+func JoinSYNACKHMAC(version uint8, keyA, keyB uint64, nonceA, nonceB uint32) []byte {
+	return mac(version, nonces(nonceB, nonceA), keyB, keyA)[:truncatedHMACLen]
+}
+
+// JoinACKHMAC returns the HMAC sent in the MP_JOIN ACK of an additional
+// subflow, by the endpoint which sends the SYN.  Its arguments are the same
+// as for JoinSYNACKHMAC.
+func JoinACKHMAC(version uint8, keyA, keyB uint64, nonceA, nonceB uint32) []byte {
+	return mac(version, nonces(nonceA, nonceB), keyA, keyB)[:hmacLen]
+}
+
+// VerifyJoinSYNACK reports whether sum is the HMAC of an MP_JOIN SYN/ACK, as
+// computed by JoinSYNACKHMAC.
+func VerifyJoinSYNACK(version uint8, keyA, keyB uint64, nonceA, nonceB uint32, sum []byte) bool {
+	return hmac.Equal(sum, JoinSYNACKHMAC(version, keyA, keyB, nonceA, nonceB))
+}
+
+// VerifyJoinACK reports whether sum is the HMAC of an MP_JOIN ACK, as
+// computed by JoinACKHMAC.
+func VerifyJoinACK(version uint8, keyA, keyB uint64, nonceA, nonceB uint32, sum []byte) bool {
+	return hmac.Equal(sum, JoinACKHMAC(version, keyA, keyB, nonceA, nonceB))
+}
+
+// AddAddrHMAC returns the truncated HMAC of a version 1 ADD_ADDR
+// announcement of address ID id, addr, and port, which is zero if no port
+// is announced.  keyA is the key of the endpoint which sends the
+// announcement, and keyB is the key of its peer.
+//
+// Unlike MP_JOIN, which uses the leftmost bits of its HMAC, ADD_ADDR uses
+// the rightmost 64 bits.
+func AddAddrHMAC(keyA, keyB uint64, id uint8, addr netip.Addr, port uint16) []byte {
+	msg := append([]byte{id}, addr.AsSlice()...)
+	msg = binary.BigEndian.AppendUint16(msg, port)
+
+	sum := mac(1, msg, keyA, keyB)
+	return sum[len(sum)-truncatedHMACLen:]
+}
+
+// VerifyAddAddr reports whether sum is the HMAC of an ADD_ADDR announcement,
+// as computed by AddAddrHMAC.
+func VerifyAddAddr(keyA, keyB uint64, id uint8, addr netip.Addr, port uint16, sum []byte) bool {
+	return hmac.Equal(sum, AddAddrHMAC(keyA, keyB, id, addr, port))
+}
+
+// DSSChecksum returns the DSS checksum of a mapping of data, which is the
+// 16-bit ones' complement checksum of a pseudo-header and the data.  The
+// pseudo-header contains the full 64-bit data sequence number dsn, the
+// subflow sequence number ssn relative to the initial sequence number, and
+// the data-level length of the mapping.
+//
+// A received checksum may be verified by comparing it with the result of
+// DSSChecksum.
+func DSSChecksum(dsn uint64, ssn uint32, length uint16, data []byte) uint16 {
+	var ph [16]byte
+	binary.BigEndian.PutUint64(ph[0:8], dsn)
+	binary.BigEndian.PutUint32(ph[8:12], ssn)
+	binary.BigEndian.PutUint16(ph[12:14], length)
+
+	sum := onesSum(onesSum(0, ph[:]), data)
+	return ^uint16(sum)
+}
+
+// onesSum adds b to the 16-bit ones' complement sum, and returns the folded
+// sum.
+func onesSum(sum uint32, b []byte) uint32 {
+	for len(b) >= 2 {
+		sum += uint32(binary.BigEndian.Uint16(b))
+		b = b[2:]
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return sum
+}
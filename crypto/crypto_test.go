@@ -0,0 +1,373 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net/netip"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/option"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// The RFCs do not publish test vectors, so the version 1 vectors were taken
+// from testdata/join.pcap, a capture of a Linux 6.18 connection over the
+// loopback interface with DSS checksums enabled and an additional subflow,
+// which TestCapture verifies in full.  The version 0 vectors, which Linux no
+// longer implements, were computed from the same keys and nonces using
+// Python's hashlib and hmac modules, following RFC 6824.
+const (
+	// Keys of the endpoints which send (A) and receive (B) the MP_JOIN
+	// SYN, and their MP_JOIN nonces.
+	keyA   = 0xd94e96ca188a8dd4
+	keyB   = 0x1c5933fc33a52109
+	nonceA = 0xbf940c55
+	nonceB = 0x9f6de75d
+)
+
+// mustHex decodes a hex string, and panics if it is invalid.
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// TestTokenIDSN verifies the tokens and initial data sequence numbers
+// derived from keys.
+func TestTokenIDSN(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		version uint8
+		key     uint64
+		token   mptcp.Token
+		idsn    uint64
+	}{
+		{
+			desc:    "v0 A",
+			version: 0,
+			key:     keyA,
+			token:   0x40d2fe66,
+			idsn:    0x54c34a5bb0f8f9c5,
+		},
+		{
+			desc:    "v0 B",
+			version: 0,
+			key:     keyB,
+			token:   0x6b6eabf2,
+			idsn:    0x14d20326cfd76e46,
+		},
+		{
+			desc:    "v1 A",
+			version: 1,
+			key:     keyA,
+			token:   0x4f765ebf,
+			idsn:    0x6c9505c2c866f0dd,
+		},
+		{
+			desc:    "v1 B",
+			version: 1,
+			key:     keyB,
+			token:   0xcea62e45,
+			idsn:    0x2acf6b42daa75329,
+		},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.token, Token(tt.version, tt.key); want != got {
+			t.Fatalf("[%02d] test %q, unexpected token:\n- want: %v\n-  got: %v",
+				i, tt.desc, want, got)
+		}
+
+		if want, got := tt.idsn, IDSN(tt.version, tt.key); want != got {
+			t.Fatalf("[%02d] test %q, unexpected IDSN:\n- want: %#x\n-  got: %#x",
+				i, tt.desc, want, got)
+		}
+	}
+}
+
+// TestJoinHMAC verifies the HMACs of the MP_JOIN handshake.
+func TestJoinHMAC(t *testing.T) {
+	var tests = []struct {
+		desc        string
+		version     uint8
+		synack, ack []byte
+	}{
+		{
+			desc:    "v0",
+			version: 0,
+			synack:  mustHex("dcea046c714f9e8c"),
+			ack:     mustHex("afeb1c52bd3becf92518ba9e18108398442b6156"),
+		},
+		{
+			desc:    "v1",
+			version: 1,
+			synack:  mustHex("d243a9339180ffea"),
+			ack:     mustHex("2f3323553c2796da8cc9bd64bb9eeb0ae8df9881"),
+		},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.synack, JoinSYNACKHMAC(tt.version, keyA, keyB, nonceA, nonceB); !bytes.Equal(want, got) {
+			t.Fatalf("[%02d] test %q, unexpected SYN/ACK HMAC:\n- want: %x\n-  got: %x",
+				i, tt.desc, want, got)
+		}
+
+		if want, got := tt.ack, JoinACKHMAC(tt.version, keyA, keyB, nonceA, nonceB); !bytes.Equal(want, got) {
+			t.Fatalf("[%02d] test %q, unexpected ACK HMAC:\n- want: %x\n-  got: %x",
+				i, tt.desc, want, got)
+		}
+
+		if !VerifyJoinSYNACK(tt.version, keyA, keyB, nonceA, nonceB, tt.synack) {
+			t.Fatalf("[%02d] test %q, failed to verify SYN/ACK HMAC", i, tt.desc)
+		}
+		if !VerifyJoinACK(tt.version, keyA, keyB, nonceA, nonceB, tt.ack) {
+			t.Fatalf("[%02d] test %q, failed to verify ACK HMAC", i, tt.desc)
+		}
+
+		// Swapping the roles of the endpoints must not verify.
+		if VerifyJoinACK(tt.version, keyB, keyA, nonceB, nonceA, tt.ack) {
+			t.Fatalf("[%02d] test %q, verified ACK HMAC with swapped keys", i, tt.desc)
+		}
+	}
+}
+
+// TestAddAddrHMAC verifies the HMACs of ADD_ADDR announcements.
+func TestAddAddrHMAC(t *testing.T) {
+	// The HMAC is keyed by the key of the sender followed by the key of
+	// the receiver, so each endpoint's announcement differs.  The IPv6
+	// vector was computed using Python's hmac module.
+	var tests = []struct {
+		desc             string
+		sender, receiver uint64
+		id               uint8
+		addr             netip.Addr
+		port             uint16
+		sum              []byte
+	}{
+		{
+			desc:     "IPv4 from B",
+			sender:   keyB,
+			receiver: keyA,
+			id:       2,
+			addr:     netip.MustParseAddr("10.0.0.3"),
+			sum:      mustHex("8ef7d17ee31ceeb8"),
+		},
+		{
+			desc:     "IPv4 from A",
+			sender:   keyA,
+			receiver: keyB,
+			id:       2,
+			addr:     netip.MustParseAddr("10.0.0.3"),
+			sum:      mustHex("d88b5bbb6fa4acd9"),
+		},
+		{
+			desc:     "IPv6 port",
+			sender:   keyB,
+			receiver: keyA,
+			id:       3,
+			addr:     netip.MustParseAddr("2001:db8::1"),
+			port:     8080,
+			sum:      mustHex("3863bf97c9fa631b"),
+		},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.sum, AddAddrHMAC(tt.sender, tt.receiver, tt.id, tt.addr, tt.port); !bytes.Equal(want, got) {
+			t.Fatalf("[%02d] test %q, unexpected HMAC:\n- want: %x\n-  got: %x",
+				i, tt.desc, want, got)
+		}
+
+		if !VerifyAddAddr(tt.sender, tt.receiver, tt.id, tt.addr, tt.port, tt.sum) {
+			t.Fatalf("[%02d] test %q, failed to verify HMAC", i, tt.desc)
+		}
+		if VerifyAddAddr(tt.sender, tt.receiver, tt.id, tt.addr, tt.port+1, tt.sum) {
+			t.Fatalf("[%02d] test %q, verified HMAC with wrong port", i, tt.desc)
+		}
+	}
+}
+
+// TestDSSChecksum verifies DSS checksums.
+func TestDSSChecksum(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		dsn    uint64
+		ssn    uint32
+		length uint16
+		data   []byte
+		sum    uint16
+	}{
+		{
+			desc:   "Linux",
+			dsn:    0x6c9505c2c866f0de,
+			ssn:    1,
+			length: 100,
+			data:   bytes.Repeat([]byte("x"), 100),
+			sum:    0x4c76,
+		},
+		{
+			desc:   "odd length",
+			dsn:    0x0102030405060708,
+			ssn:    0x11121314,
+			length: 3,
+			data:   []byte{0x01, 0x02, 0x03},
+			// 0x0102 + 0x0304 + 0x0506 + 0x0708 + 0x1112 + 0x1314 +
+			// 0x0003 + 0x0102 + 0x0300 = 0x383f, complemented.
+			sum: 0xc7c0,
+		},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.sum, DSSChecksum(tt.dsn, tt.ssn, tt.length, tt.data); want != got {
+			t.Fatalf("[%02d] test %q, unexpected checksum:\n- want: %#04x\n-  got: %#04x",
+				i, tt.desc, want, got)
+		}
+	}
+}
+
+// TestCapture verifies the tokens, MP_JOIN and ADD_ADDR HMACs, and DSS
+// checksums sent by Linux in testdata/join.pcap.
+func TestCapture(t *testing.T) {
+	f, err := os.Open("testdata/join.pcap")
+	if err != nil {
+		t.Fatalf("failed to open capture: %v", err)
+	}
+	defer f.Close()
+
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	type join struct {
+		keyA, keyB     uint64
+		nonceA, nonceB uint32
+	}
+
+	var (
+		// Keys by the address of their endpoint, and by their token.
+		keys   = make(map[netip.AddrPort]uint64)
+		tokens = make(map[mptcp.Token]uint64)
+		peers  = make(map[uint64]uint64)
+
+		// Joins by the address of the endpoint which sent the SYN.
+		joins = make(map[netip.AddrPort]*join)
+
+		verified = make(map[string]int)
+	)
+
+	for {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read packet: %v", err)
+		}
+
+		src, dst, opts, payload := decodeTCP(t, p.Data)
+		for _, o := range opts {
+			switch o := o.(type) {
+			case *option.MPCapable:
+				if !o.HasSenderKey || !o.HasReceiverKey {
+					continue
+				}
+
+				keys[src], keys[dst] = o.SenderKey, o.ReceiverKey
+				peers[o.SenderKey], peers[o.ReceiverKey] = o.ReceiverKey, o.SenderKey
+				for _, k := range []uint64{o.SenderKey, o.ReceiverKey} {
+					tokens[Token(1, k)] = k
+				}
+			case *option.MPJoin:
+				switch o.Form {
+				case option.JoinSYN:
+					keyB, ok := tokens[o.Token]
+					if !ok {
+						t.Fatalf("MP_JOIN SYN from %s has unknown token %s", src, o.Token)
+					}
+
+					joins[src] = &join{keyA: peers[keyB], keyB: keyB, nonceA: o.Nonce}
+				case option.JoinSYNACK:
+					j, ok := joins[dst]
+					if !ok {
+						continue
+					}
+
+					j.nonceB = o.Nonce
+					if !VerifyJoinSYNACK(1, j.keyA, j.keyB, j.nonceA, j.nonceB, o.HMAC) {
+						t.Fatalf("failed to verify MP_JOIN SYN/ACK HMAC from %s", src)
+					}
+					verified["MP_JOIN SYN/ACK"]++
+				case option.JoinACK:
+					j, ok := joins[src]
+					if !ok {
+						continue
+					}
+
+					if !VerifyJoinACK(1, j.keyA, j.keyB, j.nonceA, j.nonceB, o.HMAC) {
+						t.Fatalf("failed to verify MP_JOIN ACK HMAC from %s", src)
+					}
+					verified["MP_JOIN ACK"]++
+				}
+			case *option.AddAddr:
+				if o.Echo {
+					continue
+				}
+
+				if !VerifyAddAddr(keys[src], keys[dst], o.AddressID, o.Address, o.Port, o.HMAC) {
+					t.Fatalf("failed to verify ADD_ADDR HMAC from %s", src)
+				}
+				verified["ADD_ADDR"]++
+			case *option.DSS:
+				if !o.HasChecksum || len(payload) == 0 {
+					continue
+				}
+
+				if want, got := o.Checksum, DSSChecksum(o.DSN, o.SubflowSeq, o.DataLength, payload); want != got {
+					t.Fatalf("unexpected DSS checksum from %s:\n- want: %#04x\n-  got: %#04x",
+						src, want, got)
+				}
+				verified["DSS checksum"]++
+			}
+		}
+	}
+
+	want := map[string]int{
+		"MP_JOIN SYN/ACK": 1,
+		"MP_JOIN ACK":     1,
+		"ADD_ADDR":        2,
+		"DSS checksum":    6,
+	}
+	if !reflect.DeepEqual(want, verified) {
+		t.Fatalf("unexpected verified options:\n- want: %v\n-  got: %v", want, verified)
+	}
+}
+
+// decodeTCP decodes the addresses, multipath TCP options, and payload of a
+// TCP segment in an IPv4 packet.
+func decodeTCP(t *testing.T, b []byte) (src, dst netip.AddrPort, opts []option.Option, payload []byte) {
+	t.Helper()
+
+	ihl := int(b[0]&0x0f) * 4
+	tcp := b[ihl:]
+	off := int(tcp[12]>>4) * 4
+
+	srcIP, _ := netip.AddrFromSlice(b[12:16])
+	dstIP, _ := netip.AddrFromSlice(b[16:20])
+	src = netip.AddrPortFrom(srcIP, binary.BigEndian.Uint16(tcp[0:2]))
+	dst = netip.AddrPortFrom(dstIP, binary.BigEndian.Uint16(tcp[2:4]))
+
+	opts, err := option.Options(tcp[20:off])
+	if err != nil {
+		t.Fatalf("failed to parse options: %v", err)
+	}
+
+	return src, dst, opts, tcp[off:]
+}
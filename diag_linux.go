@@ -0,0 +1,315 @@
+// +build linux
+
+package mptcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// diagReqLen and diagMsgLen are the lengths of the Linux
+	// inet_diag_req_v2 and inet_diag_msg structures.
+	diagReqLen = 56
+	diagMsgLen = 72
+
+	// diagInfo is the INET_DIAG_INFO attribute, which carries a
+	// mptcp_info structure for MPTCP sockets.
+	diagInfo = 2
+
+	// diagULPInfo is the INET_DIAG_ULP_INFO attribute, which carries
+	// information about the upper layer protocol of a TCP socket, such as
+	// MPTCP for subflows.
+	diagULPInfo = 19
+
+	// Attributes nested within diagULPInfo.
+	ulpInfoName  = 1
+	ulpInfoMPTCP = 3
+
+	// Attributes nested within ulpInfoMPTCP, and the backup flags of
+	// subflowAttrFlags.
+	subflowAttrTokenLoc = 2
+	subflowAttrFlags    = 8
+	subflowAttrIDRem    = 9
+	subflowAttrIDLoc    = 10
+	subflowFlagBackup   = 1<<4 | 1<<5
+
+	// diagReqProtocol is the INET_DIAG_REQ_PROTOCOL attribute, which
+	// carries protocol numbers too large for a inet_diag_req_v2 structure,
+	// such as IPPROTO_MPTCP.
+	diagReqProtocol = 3
+
+	// diagStates matches sockets in every state except TCP_LISTEN, so
+	// that listening sockets are not reported as connections.
+	diagStates = ^uint32(1 << StateListen)
+)
+
+var (
+	// errInvalidDiagMessage is returned when a sock_diag message is not
+	// in the expected format.
+	errInvalidDiagMessage = errors.New("invalid sock_diag message")
+)
+
+// diagEntries uses sock_diag to list active MPTCP connections on a mainline
+// Linux kernel.
+func diagEntries() ([]Entry, error) {
+	msgs, err := diagDump(diagRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, m := range msgs {
+		e, err := parseDiagMessage(m.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, *e)
+	}
+
+	return entries, nil
+}
+
+// diagSubflows uses sock_diag to list the TCP subflows of active MPTCP
+// connections on a mainline Linux kernel.
+func diagSubflows() ([]Subflow, error) {
+	msgs, err := diagDump(diagSubflowRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	var subflows []Subflow
+	for _, m := range msgs {
+		s, err := parseDiagSubflow(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			// Not a MPTCP subflow
+			continue
+		}
+
+		subflows = append(subflows, *s)
+	}
+
+	return subflows, nil
+}
+
+// diagDump performs a sock_diag dump for both IPv4 and IPv6, using request to
+// create the request for each address family.
+func diagDump(request func(family uint8) []byte) ([]netlink.Message, error) {
+	c, err := netlink.Dial(unix.NETLINK_SOCK_DIAG, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var out []netlink.Message
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		msgs, err := c.Execute(netlink.Message{
+			Header: netlink.Header{
+				Type:  unix.SOCK_DIAG_BY_FAMILY,
+				Flags: netlink.Request | netlink.Dump,
+			},
+			Data: request(family),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, msgs...)
+	}
+
+	return out, nil
+}
+
+// diagRequest creates a sock_diag dump request for all MPTCP sockets in
+// the specified address family.
+func diagRequest(family uint8) []byte {
+	b := make([]byte, diagReqLen)
+	b[0] = family
+	// IPPROTO_MPTCP does not fit in the protocol field; the kernel uses
+	// the value of the diagReqProtocol attribute instead.
+	b[1] = unix.IPPROTO_TCP
+	b[2] = 1 << (diagInfo - 1)
+	binary.NativeEndian.PutUint32(b[4:8], diagStates)
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(diagReqProtocol, unix.IPPROTO_MPTCP)
+	attrs, err := ae.Encode()
+	if err != nil {
+		// Encoding a single integer attribute cannot fail
+		panic(err)
+	}
+
+	return append(b, attrs...)
+}
+
+// diagSubflowRequest creates a sock_diag dump request for all TCP sockets
+// in the specified address family, which include MPTCP subflows.
+func diagSubflowRequest(family uint8) []byte {
+	b := make([]byte, diagReqLen)
+	b[0] = family
+	b[1] = unix.IPPROTO_TCP
+	b[2] = 1 << (diagInfo - 1)
+	binary.NativeEndian.PutUint32(b[4:8], diagStates)
+
+	return b
+}
+
+// parseDiagMessage creates a new Entry from a sock_diag inet_diag_msg
+// structure and its attributes.
+func parseDiagMessage(b []byte) (*Entry, error) {
+	if len(b) < diagMsgLen {
+		return nil, errInvalidDiagMessage
+	}
+
+	local, remote := parseDiagAddrs(b)
+	e := &Entry{
+		IPv6:    b[0] == unix.AF_INET6,
+		State:   State(b[1]),
+		Local:   local,
+		Remote:  remote,
+		RxQueue: int(binary.NativeEndian.Uint32(b[56:60])),
+		TxQueue: int(binary.NativeEndian.Uint32(b[60:64])),
+		Inode:   uint64(binary.NativeEndian.Uint32(b[68:72])),
+	}
+
+	ad, err := netlink.NewAttributeDecoder(b[diagMsgLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	for ad.Next() {
+		if ad.Type() != diagInfo {
+			continue
+		}
+
+		ad.Do(func(b []byte) error {
+			info, err := parseInfo(b)
+			if err != nil {
+				return err
+			}
+
+			e.Info = info
+			return nil
+		})
+	}
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+
+	// The kernel only reports the local token, and counts subflows
+	// in addition to the initial subflow
+	if e.Info != nil {
+		e.LocalToken = e.Info.Token
+		e.Subflows = e.Info.Subflows + 1
+	}
+
+	return e, nil
+}
+
+// parseDiagSubflow creates a new Subflow from a sock_diag inet_diag_msg
+// structure and its attributes for a TCP socket.  If the socket is not a
+// MPTCP subflow, it returns nil.
+func parseDiagSubflow(b []byte) (*Subflow, error) {
+	if len(b) < diagMsgLen {
+		return nil, errInvalidDiagMessage
+	}
+
+	local, remote := parseDiagAddrs(b)
+	s := &Subflow{
+		Local:  local,
+		Remote: remote,
+		State:  State(b[1]),
+	}
+
+	ad, err := netlink.NewAttributeDecoder(b[diagMsgLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	var mptcp bool
+	for ad.Next() {
+		switch ad.Type() {
+		case diagInfo:
+			ad.Do(func(b []byte) error {
+				if len(b) < tcpInfoLen {
+					return errInvalidDiagMessage
+				}
+
+				s.RTT = usDuration(binary.NativeEndian.Uint32(b[68:72]))
+				s.RTTVar = usDuration(binary.NativeEndian.Uint32(b[72:76]))
+				return nil
+			})
+		case diagULPInfo:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					switch nad.Type() {
+					case ulpInfoName:
+						mptcp = nad.String() == "mptcp"
+					case ulpInfoMPTCP:
+						nad.Nested(func(nad *netlink.AttributeDecoder) error {
+							parseSubflowAttrs(nad, s)
+							return nil
+						})
+					}
+				}
+
+				return nil
+			})
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+
+	if !mptcp {
+		return nil, nil
+	}
+
+	return s, nil
+}
+
+// parseSubflowAttrs parses MPTCP subflow attributes from ad into s.
+func parseSubflowAttrs(ad *netlink.AttributeDecoder, s *Subflow) {
+	for ad.Next() {
+		switch ad.Type() {
+		case subflowAttrTokenLoc:
+			s.Token = Token(ad.Uint32())
+		case subflowAttrFlags:
+			s.Backup = ad.Uint32()&subflowFlagBackup != 0
+		case subflowAttrIDRem:
+			s.RemoteID = ad.Uint8()
+		case subflowAttrIDLoc:
+			s.LocalID = ad.Uint8()
+		}
+	}
+}
+
+// parseDiagAddrs parses the local and remote addresses of an inet_diag_msg
+// structure.
+func parseDiagAddrs(b []byte) (local, remote *net.TCPAddr) {
+	// Addresses are stored in network byte order, and are sized
+	// according to address family
+	ipLen := net.IPv4len
+	if b[0] == unix.AF_INET6 {
+		ipLen = net.IPv6len
+	}
+
+	local = &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), b[8:8+ipLen]...)),
+		Port: int(binary.BigEndian.Uint16(b[4:6])),
+	}
+	remote = &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), b[24:24+ipLen]...)),
+		Port: int(binary.BigEndian.Uint16(b[6:8])),
+	}
+
+	return local, remote
+}
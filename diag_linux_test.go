@@ -0,0 +1,219 @@
+// +build linux
+
+package mptcp
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// TestLinux_diagRequest verifies that diagRequest requests MPTCP sockets
+// and their mptcp_info structures.
+func TestLinux_diagRequest(t *testing.T) {
+	b := diagRequest(unix.AF_INET6)
+	if len(b) < diagReqLen {
+		t.Fatalf("request too short: %d bytes", len(b))
+	}
+
+	if b[0] != unix.AF_INET6 || b[1] != unix.IPPROTO_TCP || b[2] != 1<<(diagInfo-1) {
+		t.Fatalf("unexpected request header: %v", b[:4])
+	}
+
+	if states := binary.NativeEndian.Uint32(b[4:8]); states&(1<<StateListen) != 0 {
+		t.Fatalf("listening sockets should not be requested: %#x", states)
+	}
+
+	attrs, err := netlink.UnmarshalAttributes(b[diagReqLen:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attrs) != 1 || attrs[0].Type != diagReqProtocol ||
+		binary.NativeEndian.Uint32(attrs[0].Data) != unix.IPPROTO_MPTCP {
+		t.Fatalf("unexpected request attributes: %v", attrs)
+	}
+}
+
+// TestLinux_parseDiagMessage verifies that parseDiagMessage properly parses
+// sock_diag messages into entries.
+func TestLinux_parseDiagMessage(t *testing.T) {
+	// An inet_diag_msg for an IPv4 connection, without attributes
+	msg := make([]byte, diagMsgLen)
+	msg[0] = unix.AF_INET
+	msg[1] = uint8(StateEstablished)
+	binary.BigEndian.PutUint16(msg[4:6], 22)
+	binary.BigEndian.PutUint16(msg[6:8], 48104)
+	copy(msg[8:12], net.IPv4(104, 131, 14, 231).To4())
+	copy(msg[24:28], net.IPv4(24, 176, 52, 17).To4())
+	binary.NativeEndian.PutUint32(msg[56:60], 10)
+	binary.NativeEndian.PutUint32(msg[60:64], 20)
+	binary.NativeEndian.PutUint32(msg[68:72], 15666)
+
+	entry := &Entry{
+		Local: &net.TCPAddr{
+			IP:   net.IPv4(104, 131, 14, 231).To4(),
+			Port: 22,
+		},
+		Remote: &net.TCPAddr{
+			IP:   net.IPv4(24, 176, 52, 17).To4(),
+			Port: 48104,
+		},
+		State:   StateEstablished,
+		RxQueue: 10,
+		TxQueue: 20,
+		Inode:   15666,
+	}
+
+	// The same message, with an INET_DIAG_INFO attribute
+	info := make([]byte, infoMinLen)
+	info[0] = 1
+	binary.NativeEndian.PutUint32(info[12:16], 0x9c290bf6)
+
+	attrs, err := netlink.MarshalAttributes([]netlink.Attribute{{
+		Type: diagInfo,
+		Data: info,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infoEntry := *entry
+	infoEntry.LocalToken = 0x9c290bf6
+	infoEntry.Subflows = 2
+	infoEntry.Info = &Info{
+		Subflows: 1,
+		Token:    0x9c290bf6,
+	}
+
+	var tests = []struct {
+		desc  string
+		b     []byte
+		entry *Entry
+		err   error
+	}{
+		{"short", msg[:diagMsgLen-1], nil, errInvalidDiagMessage},
+		{"no info", msg, entry, nil},
+		{"info", append(append([]byte(nil), msg...), attrs...), &infoEntry, nil},
+	}
+
+	for i, test := range tests {
+		e, err := parseDiagMessage(test.b)
+		if err != test.err {
+			t.Fatalf("[%02d] %s: unexpected err: %v != %v", i, test.desc, err, test.err)
+		}
+
+		if !reflect.DeepEqual(e, test.entry) {
+			t.Fatalf("[%02d] %s: unexpected entry:\n- want: %+v\n-  got: %+v", i, test.desc, test.entry, e)
+		}
+	}
+}
+
+// TestLinux_parseDiagSubflow verifies that parseDiagSubflow properly parses
+// sock_diag messages for TCP sockets into subflows, and ignores sockets
+// which are not MPTCP subflows.
+func TestLinux_parseDiagSubflow(t *testing.T) {
+	// An inet_diag_msg for an IPv6 TCP socket, without attributes
+	msg := make([]byte, diagMsgLen)
+	msg[0] = unix.AF_INET6
+	msg[1] = uint8(StateEstablished)
+	binary.BigEndian.PutUint16(msg[4:6], 443)
+	binary.BigEndian.PutUint16(msg[6:8], 50000)
+	copy(msg[8:24], net.ParseIP("2001:db8::1"))
+	copy(msg[24:40], net.ParseIP("2001:db8::2"))
+
+	tcpInfo := make([]byte, tcpInfoLen)
+	binary.NativeEndian.PutUint32(tcpInfo[68:72], 12000)
+	binary.NativeEndian.PutUint32(tcpInfo[72:76], 500)
+
+	ulp := func(name string) []byte {
+		ae := netlink.NewAttributeEncoder()
+		ae.String(ulpInfoName, name)
+		ae.Nested(ulpInfoMPTCP, func(nae *netlink.AttributeEncoder) error {
+			nae.Uint32(1, 0x4cc0a727)
+			nae.Uint32(subflowAttrTokenLoc, 0x9c290bf6)
+			nae.Uint32(subflowAttrFlags, 1<<5)
+			nae.Uint8(subflowAttrIDRem, 2)
+			nae.Uint8(subflowAttrIDLoc, 1)
+			return nil
+		})
+		b, err := ae.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return b
+	}
+
+	attrs := func(ulpInfo []byte) []byte {
+		ae := netlink.NewAttributeEncoder()
+		ae.Bytes(diagInfo, tcpInfo)
+		if ulpInfo != nil {
+			ae.Bytes(diagULPInfo, ulpInfo)
+		}
+		b, err := ae.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return append(append([]byte(nil), msg...), b...)
+	}
+
+	var tests = []struct {
+		desc string
+		b    []byte
+		s    *Subflow
+		err  error
+	}{
+		{
+			desc: "short",
+			b:    msg[:diagMsgLen-1],
+			err:  errInvalidDiagMessage,
+		},
+		{
+			desc: "no ULP",
+			b:    attrs(nil),
+		},
+		{
+			desc: "TLS ULP",
+			b:    attrs(ulp("tls")),
+		},
+		{
+			desc: "MPTCP subflow",
+			b:    attrs(ulp("mptcp")),
+			s: &Subflow{
+				Token:    0x9c290bf6,
+				LocalID:  1,
+				RemoteID: 2,
+				Local: &net.TCPAddr{
+					IP:   net.ParseIP("2001:db8::1"),
+					Port: 443,
+				},
+				Remote: &net.TCPAddr{
+					IP:   net.ParseIP("2001:db8::2"),
+					Port: 50000,
+				},
+				State:  StateEstablished,
+				Backup: true,
+				RTT:    12 * time.Millisecond,
+				RTTVar: 500 * time.Microsecond,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		s, err := parseDiagSubflow(test.b)
+		if err != test.err {
+			t.Fatalf("[%02d] %s: unexpected err: %v != %v", i, test.desc, err, test.err)
+		}
+
+		if !reflect.DeepEqual(s, test.s) {
+			t.Fatalf("[%02d] %s: unexpected subflow:\n- want: %+v\n-  got: %+v", i, test.desc, test.s, s)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// +build linux
+
+package mptcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// mptcpEntries uses the Linux /proc filesystem to list active MPTCP
+// connections on kernels with out-of-tree MPTCP support, and falls back to
+// sock_diag on mainline kernels, which do not provide a connections table.
+//
+// This implementation is swappable for testing with a mock data source.
+var mptcpEntries = func() ([]Entry, error) {
+	// Open Linux MPTCP table, if one exists
+	mptcpFile, err := os.Open(procMPTCP)
+	if err == nil {
+		defer mptcpFile.Close()
+		return mptcpEntriesReaderLinux(mptcpFile)
+	}
+
+	// Return any error other than a missing table
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// No table, so ask the kernel using sock_diag instead
+	return diagEntries()
+}
+
+// errSubflowsPermission is returned when subflows cannot be listed without
+// the CAP_NET_ADMIN capability.
+var errSubflowsPermission = fmt.Errorf("listing subflows requires CAP_NET_ADMIN: %w", os.ErrPermission)
+
+// mptcpSubflowEntries uses sock_diag to list the subflows of active MPTCP
+// connections.  Out-of-tree kernels do not report subflows to sock_diag, so
+// no subflows are found.
+//
+// This implementation is swappable for testing with a mock data source.
+var mptcpSubflowEntries = func() ([]Subflow, error) {
+	// The kernel only identifies MPTCP subflows to callers with
+	// CAP_NET_ADMIN, so no subflows would be found without it
+	ok, err := netAdmin()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errSubflowsPermission
+	}
+
+	return diagSubflows()
+}
+
+// netAdmin reports whether the current process has the CAP_NET_ADMIN
+// capability.
+//
+// This implementation is swappable for testing.
+var netAdmin = func() (bool, error) {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return false, err
+	}
+
+	const c = unix.CAP_NET_ADMIN
+	return data[c/32].Effective&(1<<(c%32)) != 0, nil
+}
+
+// mptcpEntriesReaderLinux reads all entries from a MPTCP connections table
+// in an input stream.
+func mptcpEntriesReaderLinux(r io.Reader) ([]Entry, error) {
+	// Open text scanner to split lines, skip header line
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	if !scanner.Scan() {
+		// If file was empty, return unexpected EOF
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	// Ensure first line was valid MPTCP connections table header
+	if !bytes.Equal(scanner.Bytes(), mptcpTableHeader) {
+		return nil, errInvalidMPTCPTable
+	}
+
+	// Parse every entry until EOF
+	var entries []Entry
+	for scanner.Scan() {
+		e, err := parseEntry(strings.Fields(scanner.Text()))
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, *e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// parseEntry creates a new Entry from the fields of a MPTCP connections
+// table entry.
+func parseEntry(fields []string) (*Entry, error) {
+	if len(fields) != mptcpTableColumns {
+		return nil, errInvalidMPTCPEntry
+	}
+
+	// Parse tokens, which identify the connection at either end
+	locTok, err := ParseToken(fields[1])
+	if err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+	remTok, err := ParseToken(fields[2])
+	if err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+
+	e := &Entry{
+		LocalToken:  locTok,
+		RemoteToken: remTok,
+		IPv6:        fields[3] == "1",
+	}
+
+	// Parse hex encoded local and remote addresses
+	if e.Local, err = hexToTCPAddr(fields[4]); err != nil {
+		return nil, err
+	}
+	if e.Remote, err = hexToTCPAddr(fields[5]); err != nil {
+		return nil, err
+	}
+
+	// Parse state and number of subflows, both in hex
+	st, err := strconv.ParseUint(fields[6], 16, 8)
+	if err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+	e.State = State(st)
+
+	ns, err := strconv.ParseUint(fields[7], 16, 8)
+	if err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+	e.Subflows = int(ns)
+
+	// Parse transmit and receive queue lengths, separated by a colon
+	txq, rxq, ok := strings.Cut(fields[8], ":")
+	if !ok {
+		return nil, errInvalidMPTCPEntry
+	}
+	tx, err := strconv.ParseUint(txq, 16, 32)
+	if err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+	rx, err := strconv.ParseUint(rxq, 16, 32)
+	if err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+	e.TxQueue = int(tx)
+	e.RxQueue = int(rx)
+
+	// Parse socket inode, in decimal
+	if e.Inode, err = strconv.ParseUint(fields[9], 10, 64); err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+
+	return e, nil
+}
+
+// hexToTCPAddr converts a hex host:port pair from a MPTCP connections table
+// into a *net.TCPAddr.  It performs the inverse of hostToHex and u16PortToHex.
+func hexToTCPAddr(hexHostPort string) (*net.TCPAddr, error) {
+	hexHost, hexPort, ok := strings.Cut(hexHostPort, ":")
+	if !ok {
+		return nil, errInvalidMPTCPEntry
+	}
+
+	// Addresses are stored as 32-bit words in host byte order
+	if len(hexHost) != 2*net.IPv4len && len(hexHost) != 2*net.IPv6len {
+		return nil, errInvalidMPTCPEntry
+	}
+
+	ip := make(net.IP, len(hexHost)/2)
+	for i := 0; i < len(ip); i += 4 {
+		w, err := strconv.ParseUint(hexHost[2*i:2*i+8], 16, 32)
+		if err != nil {
+			return nil, errInvalidMPTCPEntry
+		}
+
+		binary.LittleEndian.PutUint32(ip[i:i+4], uint32(w))
+	}
+
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return nil, errInvalidMPTCPEntry
+	}
+
+	return &net.TCPAddr{
+		IP:   ip,
+		Port: int(port),
+	}, nil
+}
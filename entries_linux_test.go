@@ -0,0 +1,126 @@
+// +build linux
+
+package mptcp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestLinux_mptcpEntriesReaderLinux verifies that mptcpEntriesReaderLinux can
+// properly parse all entries from a Linux MPTCP connections table.
+func TestLinux_mptcpEntriesReaderLinux(t *testing.T) {
+	ipv4Entry := Entry{
+		LocalToken:  0x9c290bf6,
+		RemoteToken: 0x4cc0a727,
+		Local: &net.TCPAddr{
+			IP:   net.IPv4(104, 131, 14, 231).To4(),
+			Port: 22,
+		},
+		Remote: &net.TCPAddr{
+			IP:   net.IPv4(24, 176, 52, 17).To4(),
+			Port: 48104,
+		},
+		State:    StateEstablished,
+		Subflows: 1,
+		Inode:    15666,
+	}
+
+	ipv6Entry := Entry{
+		LocalToken:  0xf6635734,
+		RemoteToken: 0x353f1e98,
+		IPv6:        true,
+		Local: &net.TCPAddr{
+			IP:   net.ParseIP("2604:a880:800:10::74:c001"),
+			Port: 8080,
+		},
+		Remote: &net.TCPAddr{
+			IP:   net.ParseIP("2604:a880:800:10::289:2001"),
+			Port: 37797,
+		},
+		State:    StateEstablished,
+		Subflows: 1,
+		Inode:    39893,
+	}
+
+	var tests = []struct {
+		lines   [][]byte
+		entries []Entry
+		err     error
+	}{
+		// Empty file
+		{nil, nil, io.ErrUnexpectedEOF},
+		// Invalid header
+		{[][]byte{[]byte("foobar")}, nil, errInvalidMPTCPTable},
+		// Header only, no entries
+		{[][]byte{mptcpTableHeader}, nil, nil},
+		// Header, bad entry
+		{[][]byte{mptcpTableHeader, []byte("foobar")}, nil, errInvalidMPTCPEntry},
+		// Header, good IPv4 entry
+		{[][]byte{mptcpTableHeader, testIPv4MPTCPEntry}, []Entry{ipv4Entry}, nil},
+		// Header, good IPv4 and IPv6 entries
+		{[][]byte{mptcpTableHeader, testIPv4MPTCPEntry, testIPv6MPTCPEntry}, []Entry{ipv4Entry, ipv6Entry}, nil},
+	}
+
+	for i, test := range tests {
+		// Store input lines in a buffer, appending each with newline
+		buf := bytes.NewBuffer(nil)
+		for _, l := range test.lines {
+			if _, err := buf.Write(append(l, '\n')); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		entries, err := mptcpEntriesReaderLinux(buf)
+		if err != test.err {
+			t.Fatalf("[%02d] unexpected err: %v != %v", i, err, test.err)
+		}
+
+		if !reflect.DeepEqual(entries, test.entries) {
+			t.Fatalf("[%02d] unexpected entries:\n- want: %+v\n-  got: %+v", i, test.entries, entries)
+		}
+	}
+}
+
+// TestLinux_parseEntry verifies that parseEntry rejects malformed MPTCP
+// connections table entries.
+func TestLinux_parseEntry(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		fields []string
+	}{
+		{"too few fields", []string{"1:", "9C290BF6"}},
+		{"bad local token", []string{"1:", "foobar", "4CC0A727", "0", "E70E8368:0016", "1134B018:BBE8", "01", "01", "00000000:00000000", "15666"}},
+		{"bad remote token", []string{"1:", "9C290BF6", "foobar", "0", "E70E8368:0016", "1134B018:BBE8", "01", "01", "00000000:00000000", "15666"}},
+		{"bad local address", []string{"1:", "9C290BF6", "4CC0A727", "0", "E70E83:0016", "1134B018:BBE8", "01", "01", "00000000:00000000", "15666"}},
+		{"bad remote port", []string{"1:", "9C290BF6", "4CC0A727", "0", "E70E8368:0016", "1134B018:FOOO", "01", "01", "00000000:00000000", "15666"}},
+		{"bad state", []string{"1:", "9C290BF6", "4CC0A727", "0", "E70E8368:0016", "1134B018:BBE8", "XX", "01", "00000000:00000000", "15666"}},
+		{"bad queues", []string{"1:", "9C290BF6", "4CC0A727", "0", "E70E8368:0016", "1134B018:BBE8", "01", "01", "00000000", "15666"}},
+		{"bad inode", []string{"1:", "9C290BF6", "4CC0A727", "0", "E70E8368:0016", "1134B018:BBE8", "01", "01", "00000000:00000000", "foo"}},
+	}
+
+	for i, test := range tests {
+		if _, err := parseEntry(test.fields); err != errInvalidMPTCPEntry {
+			t.Fatalf("[%02d] %s: unexpected err: %v != %v", i, test.desc, err, errInvalidMPTCPEntry)
+		}
+	}
+}
+
+// TestLinux_mptcpSubflowEntriesPermission verifies that mptcpSubflowEntries
+// reports a permission error, rather than no subflows, without CAP_NET_ADMIN.
+func TestLinux_mptcpSubflowEntriesPermission(t *testing.T) {
+	defer func(fn func() (bool, error)) { netAdmin = fn }(netAdmin)
+	netAdmin = func() (bool, error) {
+		return false, nil
+	}
+
+	subflows, err := mptcpSubflowEntries()
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected permission error, but got: (%v, %v)", subflows, err)
+	}
+}
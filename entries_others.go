@@ -0,0 +1,13 @@
+// +build !linux
+
+package mptcp
+
+// mptcpEntries is not currently implemented on non-Linux platforms.
+var mptcpEntries = func() ([]Entry, error) {
+	return nil, ErrNotImplemented
+}
+
+// mptcpSubflowEntries is not currently implemented on non-Linux platforms.
+var mptcpSubflowEntries = func() ([]Subflow, error) {
+	return nil, ErrNotImplemented
+}
@@ -0,0 +1,23 @@
+// +build !linux
+
+package mptcp
+
+import "testing"
+
+// TestOthers_mptcpEntries verifies that mptcpEntries is not implemented on
+// platforms other than Linux.
+func TestOthers_mptcpEntries(t *testing.T) {
+	entries, err := mptcpEntries()
+	if entries != nil || err != ErrNotImplemented {
+		t.Fatalf("mptcpEntries is not implemented, but returned: (%v, %v)", entries, err)
+	}
+}
+
+// TestOthers_mptcpSubflowEntries verifies that mptcpSubflowEntries is not
+// implemented on platforms other than Linux.
+func TestOthers_mptcpSubflowEntries(t *testing.T) {
+	subflows, err := mptcpSubflowEntries()
+	if subflows != nil || err != ErrNotImplemented {
+		t.Fatalf("mptcpSubflowEntries is not implemented, but returned: (%v, %v)", subflows, err)
+	}
+}
@@ -0,0 +1,107 @@
+package mptcp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// A Token is a 32-bit multipath TCP connection token, which uniquely
+// identifies a connection on one of its endpoints.
+type Token uint32
+
+// ParseToken parses a hex token string, such as one found in the loc_tok or
+// rem_tok columns of a MPTCP connections table.
+func ParseToken(s string) (Token, error) {
+	t, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return Token(t), nil
+}
+
+// String returns the hex representation of a Token, in the same format
+// used by a MPTCP connections table.
+func (t Token) String() string {
+	return fmt.Sprintf("%08X", uint32(t))
+}
+
+// A State is the TCP state of a multipath TCP connection.
+type State uint8
+
+// Possible State values, which match the values used by the Linux kernel.
+const (
+	StateEstablished State = iota + 1
+	StateSynSent
+	StateSynRecv
+	StateFinWait1
+	StateFinWait2
+	StateTimeWait
+	StateClose
+	StateCloseWait
+	StateLastAck
+	StateListen
+	StateClosing
+)
+
+// stateNames maps each State to its name.
+var stateNames = map[State]string{
+	StateEstablished: "ESTABLISHED",
+	StateSynSent:     "SYN_SENT",
+	StateSynRecv:     "SYN_RECV",
+	StateFinWait1:    "FIN_WAIT1",
+	StateFinWait2:    "FIN_WAIT2",
+	StateTimeWait:    "TIME_WAIT",
+	StateClose:       "CLOSE",
+	StateCloseWait:   "CLOSE_WAIT",
+	StateLastAck:     "LAST_ACK",
+	StateListen:      "LISTEN",
+	StateClosing:     "CLOSING",
+}
+
+// String returns the name of a State, or its number if the State is unknown.
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("State(%d)", uint8(s))
+}
+
+// An Entry is an active multipath TCP connection, as reported by the
+// operating system.
+type Entry struct {
+	// LocalToken and RemoteToken are the tokens which identify this
+	// connection on this host and on its peer.  RemoteToken is zero if
+	// the operating system does not report it.
+	LocalToken  Token
+	RemoteToken Token
+
+	// IPv6 reports whether or not the connection uses IPv6.
+	IPv6 bool
+
+	// Local and Remote are the addresses of the connection's initial
+	// subflow.
+	Local  *net.TCPAddr
+	Remote *net.TCPAddr
+
+	// State is the TCP state of the connection.
+	State State
+
+	// Subflows is the number of subflows which belong to the connection.
+	Subflows int
+
+	// TxQueue and RxQueue are the number of bytes queued for
+	// transmission and reception.
+	TxQueue int
+	RxQueue int
+
+	// Inode is the inode number of the connection's socket.
+	Inode uint64
+
+	// Info contains detailed information about the connection, if the
+	// operating system reports it.  Info is nil for connections read from
+	// an out-of-tree kernel's connections table.
+	Info *Info
+}
@@ -0,0 +1,65 @@
+package mptcp
+
+import (
+	"testing"
+)
+
+// TestParseToken verifies that ParseToken properly parses hex tokens, and
+// that Token.String produces the same format.
+func TestParseToken(t *testing.T) {
+	var tests = []struct {
+		s     string
+		token Token
+		ok    bool
+	}{
+		// Invalid tokens
+		{"", 0, false},
+		{"foobar", 0, false},
+		{"1FFFFFFFF", 0, false},
+
+		// Valid tokens
+		{"00000000", 0, true},
+		{"9C290BF6", 0x9c290bf6, true},
+		{"4CC0A727", 0x4cc0a727, true},
+		{"FFFFFFFF", 0xffffffff, true},
+	}
+
+	for i, test := range tests {
+		token, err := ParseToken(test.s)
+		if ok := err == nil; ok != test.ok {
+			t.Fatalf("[%02d] unexpected ok: %v != %v [err: %v]", i, ok, test.ok, err)
+		}
+		if !test.ok {
+			continue
+		}
+
+		if token != test.token {
+			t.Fatalf("[%02d] unexpected token: %v != %v [test: %v]", i, token, test.token, test)
+		}
+
+		if s := token.String(); s != test.s {
+			t.Fatalf("[%02d] unexpected token string: %v != %v [test: %v]", i, s, test.s, test)
+		}
+	}
+}
+
+// TestStateString verifies that State.String produces the proper name for
+// known and unknown states.
+func TestStateString(t *testing.T) {
+	var tests = []struct {
+		state State
+		s     string
+	}{
+		{StateEstablished, "ESTABLISHED"},
+		{StateTimeWait, "TIME_WAIT"},
+		{StateClosing, "CLOSING"},
+		{0, "State(0)"},
+		{255, "State(255)"},
+	}
+
+	for i, test := range tests {
+		if s := test.state.String(); s != test.s {
+			t.Fatalf("[%02d] unexpected state string: %v != %v", i, s, test.s)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package mptcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	// infoMinLen is the length of the smallest mptcp_info structure
+	// reported by a Linux kernel.
+	infoMinLen = 40
+
+	// infoFlagFallback and infoFlagRemoteKeyReceived are the flags
+	// found in the mptcpi_flags field of mptcp_info.
+	infoFlagFallback          = 1 << 0
+	infoFlagRemoteKeyReceived = 1 << 1
+)
+
+var (
+	// errInvalidInfo is returned when an input mptcp_info structure is
+	// not in the expected format.
+	errInvalidInfo = errors.New("invalid MPTCP info structure")
+)
+
+// Info contains detailed information about a multipath TCP connection,
+// from the Linux kernel's mptcp_info structure.
+//
+// Fields which were added in newer kernels are left at their zero value
+// when reported by an older kernel.
+type Info struct {
+	// Subflows is the number of additional subflows, and SubflowsMax
+	// is the maximum number of additional subflows allowed.
+	Subflows    int
+	SubflowsMax int
+
+	// AddAddrSignal and AddAddrAccepted are the number of addresses
+	// announced to and accepted from the peer, alongside their limits.
+	AddAddrSignal      int
+	AddAddrAccepted    int
+	AddAddrSignalMax   int
+	AddAddrAcceptedMax int
+
+	// Fallback reports whether the connection has fallen back to
+	// regular TCP.
+	Fallback bool
+
+	// RemoteKeyReceived reports whether the peer's key has been received.
+	RemoteKeyReceived bool
+
+	// Token is the local token of the connection.
+	Token Token
+
+	// WriteSeq, SndUna and RcvNxt are the data-level sequence numbers
+	// of the connection.
+	WriteSeq uint64
+	SndUna   uint64
+	RcvNxt   uint64
+
+	// LocalAddrUsed and LocalAddrMax are the number of local addresses
+	// used by the connection, and the maximum number allowed.
+	LocalAddrUsed int
+	LocalAddrMax  int
+
+	// ChecksumEnabled reports whether DSS checksums are in use.
+	ChecksumEnabled bool
+
+	// Retransmits is the number of data-level retransmissions.
+	Retransmits uint32
+
+	// Byte counters for the connection, at the data level.
+	BytesRetrans  uint64
+	BytesSent     uint64
+	BytesReceived uint64
+	BytesAcked    uint64
+
+	// SubflowsTotal is the total number of subflows, including the
+	// initial subflow.
+	SubflowsTotal int
+
+	// Time elapsed since data was last sent and received, and since an
+	// acknowledgement was last received.
+	LastDataSent time.Duration
+	LastDataRecv time.Duration
+	LastAckRecv  time.Duration
+}
+
+// parseInfo parses an Info structure from a Linux mptcp_info structure,
+// in native byte order.
+func parseInfo(b []byte) (*Info, error) {
+	if len(b) < infoMinLen {
+		return nil, errInvalidInfo
+	}
+
+	flags := binary.NativeEndian.Uint32(b[8:12])
+	i := &Info{
+		Subflows:           int(b[0]),
+		AddAddrSignal:      int(b[1]),
+		AddAddrAccepted:    int(b[2]),
+		SubflowsMax:        int(b[3]),
+		AddAddrSignalMax:   int(b[4]),
+		AddAddrAcceptedMax: int(b[5]),
+		Fallback:           flags&infoFlagFallback != 0,
+		RemoteKeyReceived:  flags&infoFlagRemoteKeyReceived != 0,
+		Token:              Token(binary.NativeEndian.Uint32(b[12:16])),
+		WriteSeq:           binary.NativeEndian.Uint64(b[16:24]),
+		SndUna:             binary.NativeEndian.Uint64(b[24:32]),
+		RcvNxt:             binary.NativeEndian.Uint64(b[32:40]),
+	}
+
+	// Linux 5.18 and newer report local address usage and checksums
+	if len(b) >= 48 {
+		i.LocalAddrUsed = int(b[40])
+		i.LocalAddrMax = int(b[41])
+		i.ChecksumEnabled = b[42] != 0
+	}
+
+	// Linux 6.5 and newer report retransmissions and byte counters
+	if len(b) >= 80 {
+		i.Retransmits = binary.NativeEndian.Uint32(b[44:48])
+		i.BytesRetrans = binary.NativeEndian.Uint64(b[48:56])
+		i.BytesSent = binary.NativeEndian.Uint64(b[56:64])
+		i.BytesReceived = binary.NativeEndian.Uint64(b[64:72])
+		i.BytesAcked = binary.NativeEndian.Uint64(b[72:80])
+	}
+
+	// Linux 6.10 and newer report total subflows and idle times
+	if len(b) >= 96 {
+		i.SubflowsTotal = int(b[80])
+		i.LastDataSent = msDuration(binary.NativeEndian.Uint32(b[84:88]))
+		i.LastDataRecv = msDuration(binary.NativeEndian.Uint32(b[88:92]))
+		i.LastAckRecv = msDuration(binary.NativeEndian.Uint32(b[92:96]))
+	}
+
+	return i, nil
+}
+
+// msDuration converts a number of milliseconds into a time.Duration.
+func msDuration(ms uint32) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,98 @@
+package mptcp
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestParseInfo verifies that parseInfo properly parses mptcp_info
+// structures of every length reported by the Linux kernel.
+func TestParseInfo(t *testing.T) {
+	// Build a full length mptcp_info structure from Linux 6.10
+	b := make([]byte, 96)
+	copy(b[0:6], []byte{2, 1, 1, 4, 2, 3})
+	binary.NativeEndian.PutUint32(b[8:12], infoFlagRemoteKeyReceived)
+	binary.NativeEndian.PutUint32(b[12:16], 0x9c290bf6)
+	binary.NativeEndian.PutUint64(b[16:24], 1000)
+	binary.NativeEndian.PutUint64(b[24:32], 900)
+	binary.NativeEndian.PutUint64(b[32:40], 2000)
+	copy(b[40:43], []byte{2, 8, 1})
+	binary.NativeEndian.PutUint32(b[44:48], 3)
+	binary.NativeEndian.PutUint64(b[48:56], 300)
+	binary.NativeEndian.PutUint64(b[56:64], 10000)
+	binary.NativeEndian.PutUint64(b[64:72], 20000)
+	binary.NativeEndian.PutUint64(b[72:80], 9000)
+	b[80] = 3
+	binary.NativeEndian.PutUint32(b[84:88], 10)
+	binary.NativeEndian.PutUint32(b[88:92], 20)
+	binary.NativeEndian.PutUint32(b[92:96], 30)
+
+	base := Info{
+		Subflows:           2,
+		AddAddrSignal:      1,
+		AddAddrAccepted:    1,
+		SubflowsMax:        4,
+		AddAddrSignalMax:   2,
+		AddAddrAcceptedMax: 3,
+		RemoteKeyReceived:  true,
+		Token:              0x9c290bf6,
+		WriteSeq:           1000,
+		SndUna:             900,
+		RcvNxt:             2000,
+	}
+
+	v518 := base
+	v518.LocalAddrUsed = 2
+	v518.LocalAddrMax = 8
+	v518.ChecksumEnabled = true
+
+	v65 := v518
+	v65.Retransmits = 3
+	v65.BytesRetrans = 300
+	v65.BytesSent = 10000
+	v65.BytesReceived = 20000
+	v65.BytesAcked = 9000
+
+	v610 := v65
+	v610.SubflowsTotal = 3
+	v610.LastDataSent = 10 * time.Millisecond
+	v610.LastDataRecv = 20 * time.Millisecond
+	v610.LastAckRecv = 30 * time.Millisecond
+
+	var tests = []struct {
+		desc string
+		b    []byte
+		info *Info
+		err  error
+	}{
+		{"empty", nil, nil, errInvalidInfo},
+		{"short", b[:39], nil, errInvalidInfo},
+		{"Linux 5.7", b[:40], &base, nil},
+		{"Linux 5.18", b[:48], &v518, nil},
+		{"Linux 6.5", b[:80], &v65, nil},
+		{"Linux 6.10", b, &v610, nil},
+	}
+
+	for i, test := range tests {
+		info, err := parseInfo(test.b)
+		if err != test.err {
+			t.Fatalf("[%02d] %s: unexpected err: %v != %v", i, test.desc, err, test.err)
+		}
+
+		if !reflect.DeepEqual(info, test.info) {
+			t.Fatalf("[%02d] %s: unexpected info:\n- want: %+v\n-  got: %+v", i, test.desc, test.info, info)
+		}
+	}
+
+	// Fallback is reported using flags
+	binary.NativeEndian.PutUint32(b[8:12], infoFlagFallback)
+	info, err := parseInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Fallback || info.RemoteKeyReceived {
+		t.Fatalf("unexpected flags: fallback: %v, remote key received: %v", info.Fallback, info.RemoteKeyReceived)
+	}
+}
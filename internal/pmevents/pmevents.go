@@ -0,0 +1,48 @@
+// Package pmevents contains the parts of the Linux multipath TCP path
+// manager's generic netlink event interface which are shared by package
+// mptcp and package pm.
+package pmevents
+
+const (
+	// Family is the name of the path manager's generic netlink family.
+	Family = "mptcp_pm"
+
+	// Group is the name of the path manager's event multicast group.
+	Group = "mptcp_pm_events"
+)
+
+// Event types, from enum mptcp_event_type.
+const (
+	Created            = 1
+	Established        = 2
+	Closed             = 3
+	Announced          = 6
+	Removed            = 7
+	SubflowEstablished = 10
+	SubflowClosed      = 11
+	SubflowPriority    = 13
+	ListenerCreated    = 15
+	ListenerClosed     = 16
+)
+
+// Event attributes, from enum mptcp_event_attr.
+const (
+	AttrToken       = 1
+	AttrFamily      = 2
+	AttrLocID       = 3
+	AttrRemID       = 4
+	AttrSaddr4      = 5
+	AttrSaddr6      = 6
+	AttrDaddr4      = 7
+	AttrDaddr6      = 8
+	AttrSport       = 9
+	AttrDport       = 10
+	AttrBackup      = 11
+	AttrError       = 12
+	AttrFlags       = 13
+	AttrTimeout     = 14
+	AttrIfIdx       = 15
+	AttrResetReason = 16
+	AttrResetFlags  = 17
+	AttrServerSide  = 18
+)
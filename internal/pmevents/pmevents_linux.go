@@ -0,0 +1,42 @@
+// +build linux
+
+package pmevents
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mdlayher/genetlink"
+)
+
+// GroupID returns the ID of the event multicast group of the path manager's
+// family f.
+func GroupID(f genetlink.Family) (uint32, error) {
+	for _, g := range f.Groups {
+		if g.Name == Group {
+			return g.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("family %q has no %q multicast group", f.Name, Group)
+}
+
+// Subscribe joins c to the event multicast group of the path manager's family
+// f, so that events are received by c.Receive.  Once ctx is canceled, a
+// blocked c.Receive is interrupted and returns an error.  The returned stop
+// function must be called before c is closed.
+func Subscribe(ctx context.Context, c *genetlink.Conn, f genetlink.Family) (stop func() bool, err error) {
+	group, err := GroupID(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.JoinGroup(group); err != nil {
+		return nil, err
+	}
+
+	// Interrupt a blocked Receive when ctx is canceled.
+	return context.AfterFunc(ctx, func() {
+		_ = c.SetReadDeadline(time.Unix(1, 0))
+	}), nil
+}
@@ -0,0 +1,31 @@
+// +build linux
+
+package pmevents
+
+import (
+	"testing"
+
+	"github.com/mdlayher/genetlink"
+)
+
+// TestLinux_GroupID verifies that GroupID finds the path manager's multicast
+// group.
+func TestLinux_GroupID(t *testing.T) {
+	f := genetlink.Family{ID: 20, Name: Family}
+	if _, err := GroupID(f); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	f.Groups = []genetlink.MulticastGroup{
+		{ID: 10, Name: "other"},
+		{ID: 11, Name: Group},
+	}
+
+	id, err := GroupID(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 11 {
+		t.Fatalf("unexpected group ID: %d", id)
+	}
+}
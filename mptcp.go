@@ -17,7 +17,8 @@ var (
 	ErrInvalidIPAddress = errors.New("invalid IP address")
 
 	// ErrIPv6NotImplemented is returned when an IPv6 address is passed to a
-	// function, because IPv6 detection is not yet implemented.
+	// function, because IPv6 detection is not yet implemented using the
+	// connections table of kernels with out-of-tree multipath TCP support.
 	ErrIPv6NotImplemented = errors.New("IPv6 detection not yet implemented")
 
 	// ErrNotImplemented is returned when MPTCP detection functionality is not
@@ -30,12 +31,59 @@ var (
 // If it is not enabled on this host, or an error occurs, this function will
 // return false.
 //
+// On Linux, multipath TCP is enabled if the /proc/net/mptcp table exists on
+// kernels with out-of-tree multipath TCP support, or if the net.mptcp.enabled
+// sysctl is set on mainline kernels.
+//
 // It is recommended to check the result of Enabled before attempting to check
 // for active multipath TCP connections using Check.
 func Enabled() (bool, error) {
 	return mptcpEnabled()
 }
 
+// Entries returns all active multipath TCP connections on the current host.
+//
+// On Linux, connections are read from the /proc/net/mptcp table on kernels
+// with out-of-tree multipath TCP support, or using sock_diag on mainline
+// kernels.  Connections reported by sock_diag also carry detailed
+// connection information in the Info field of each Entry.
+//
+// If multipath TCP connection listing is not implemented for the current
+// operating system, this function will return ErrNotImplemented.
+func Entries() ([]Entry, error) {
+	return mptcpEntries()
+}
+
+// SubflowEntries returns the subflows of all active multipath TCP connections
+// on the current host.  Subflows may be matched to the Entry of their
+// connection using Token and Entry.LocalToken.
+//
+// On Linux, subflows are read using sock_diag on mainline kernels, and
+// include their round trip times.  No subflows are reported on kernels with
+// out-of-tree multipath TCP support.  The kernel only identifies multipath
+// TCP subflows to callers with the CAP_NET_ADMIN capability, so without it,
+// SubflowEntries returns an error for which errors.Is(err, os.ErrPermission)
+// is true.
+//
+// If multipath TCP subflow listing is not implemented for the current
+// operating system, this function will return ErrNotImplemented.
+func SubflowEntries() ([]Subflow, error) {
+	return mptcpSubflowEntries()
+}
+
+// Counters returns the multipath TCP MIB counters for the current host.
+//
+// On Linux, counters are read from the MPTcpExt section of /proc/net/netstat
+// on mainline kernels, or from /proc/net/mptcp_net/snmp on kernels with
+// out-of-tree multipath TCP support.  Use Delta to compute the change in
+// counters between two samples.
+//
+// If multipath TCP counters are not implemented for the current operating
+// system, this function will return ErrNotImplemented.
+func Counters() (*MIB, error) {
+	return mptcpCounters()
+}
+
 // Check detects if there is an active multipath TCP connection to this machine,
 // originating from the input host:port string, such as one returned from the
 // RemoteAddr method of a net.Conn.
@@ -51,6 +99,13 @@ func Enabled() (bool, error) {
 // If multipath TCP detection is implemented on the current operating system,
 // this function will return true or false, depending on if a connection with
 // the input host:port string is active and is using multipath TCP.
+//
+// On Linux, connections are looked up in the /proc/net/mptcp table on kernels
+// with out-of-tree multipath TCP support, which only supports IPv4.  On
+// mainline kernels, connections are looked up using sock_diag, and those
+// which have fallen back to regular TCP are not reported.  A server on a
+// mainline kernel only accepts multipath TCP connections if its listener
+// enables multipath TCP, for example using net.ListenConfig.SetMultipathTCP.
 func Check(hostport string) (bool, error) {
 	// Split input hostport pair
 	host, port, err := net.SplitHostPort(hostport)
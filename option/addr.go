@@ -0,0 +1,269 @@
+package option
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// An AddAddr is an ADD_ADDR option, which announces an additional address
+// of the sender, or echoes an announcement to acknowledge it.
+//
+// In version 0, the option carries the IP version of the address.  In
+// version 1, an announcement carries a truncated HMAC of the address, and an
+// echo carries none.
+type AddAddr struct {
+	// Version is the multipath TCP version, 0 or 1.
+	Version uint8
+
+	// Echo indicates that the option echoes an announcement from the
+	// peer, in version 1.
+	Echo bool
+
+	// AddressID is the address ID of the address.
+	AddressID uint8
+
+	// Address is the announced IPv4 or IPv6 address.
+	Address netip.Addr
+
+	// Port is the announced port, or zero if none is announced.
+	Port uint16
+
+	// HMAC is the TruncatedHMACLen byte HMAC of the announcement, in
+	// version 1 when Echo is not set.
+	HMAC []byte
+}
+
+// Subtype implements Option.
+func (*AddAddr) Subtype() Subtype { return SubtypeAddAddr }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (a *AddAddr) String() string {
+	b := newDescription(SubtypeAddAddr)
+	switch {
+	case a.Version == 0:
+		b.printf("v0")
+	case a.Echo:
+		b.printf("echo")
+	}
+	b.printf("id=%d addr=%s", a.AddressID, a.Address)
+	if a.Port != 0 {
+		b.printf("port=%d", a.Port)
+	}
+	if len(a.HMAC) > 0 {
+		b.printf("hmac=%x", a.HMAC)
+	}
+
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (a *AddAddr) MarshalBinary() ([]byte, error) {
+	if !a.Address.IsValid() {
+		return nil, errInvalidOption
+	}
+
+	var nibble byte
+	switch a.Version {
+	case 0:
+		if a.Echo || a.HMAC != nil {
+			return nil, errInvalidOption
+		}
+
+		nibble = 4
+		if a.Address.Is6() {
+			nibble = 6
+		}
+	case 1:
+		if a.Echo != (a.HMAC == nil) || (!a.Echo && len(a.HMAC) != TruncatedHMACLen) {
+			return nil, errInvalidOption
+		}
+
+		nibble = boolByte(a.Echo, 0x01)
+	default:
+		return nil, errInvalidOption
+	}
+
+	ip := a.Address.AsSlice()
+	n := 4 + len(ip) + len(a.HMAC)
+	if a.Port != 0 {
+		n += 2
+	}
+
+	b, _ := newOption(SubtypeAddAddr, n)
+	b[2] |= nibble
+	b[3] = a.AddressID
+
+	i := 4 + copy(b[4:], ip)
+	if a.Port != 0 {
+		binary.BigEndian.PutUint16(b[i:i+2], a.Port)
+		i += 2
+	}
+	copy(b[i:], a.HMAC)
+
+	return b, nil
+}
+
+// parseAddAddr parses an ADD_ADDR option.
+func parseAddAddr(b []byte) (*AddAddr, error) {
+	if len(b) < 4 {
+		return nil, errInvalidOption
+	}
+
+	a := &AddAddr{AddressID: b[3]}
+
+	// The remaining length is the length of the address, plus the
+	// optional port and the HMAC, if any.
+	var ipLen, rest int
+	switch nibble := b[2] & 0x0f; nibble {
+	case 4, 6:
+		// Version 0 carries the IP version of the address.
+		ipLen = 4
+		if nibble == 6 {
+			ipLen = 16
+		}
+		rest = len(b) - 4 - ipLen
+	default:
+		a.Version = 1
+		a.Echo = nibble&0x01 != 0
+
+		rest = len(b) - 4
+		if !a.Echo {
+			rest -= TruncatedHMACLen
+		}
+
+		switch rest {
+		case 4, 4 + 2:
+			ipLen = 4
+		case 16, 16 + 2:
+			ipLen = 16
+		default:
+			return nil, errInvalidOption
+		}
+		rest -= ipLen
+	}
+
+	var hasPort bool
+	switch rest {
+	case 0:
+	case 2:
+		hasPort = true
+	default:
+		return nil, errInvalidOption
+	}
+
+	a.Address, _ = netip.AddrFromSlice(b[4 : 4+ipLen])
+	i := 4 + ipLen
+	if hasPort {
+		a.Port = binary.BigEndian.Uint16(b[i : i+2])
+		i += 2
+	}
+	if a.Version == 1 && !a.Echo {
+		a.HMAC = append([]byte(nil), b[i:i+TruncatedHMACLen]...)
+	}
+
+	return a, nil
+}
+
+// A RemoveAddr is a REMOVE_ADDR option, which withdraws one or more
+// addresses of the sender.
+type RemoveAddr struct {
+	// AddressIDs are the address IDs of the withdrawn addresses.
+	AddressIDs []uint8
+}
+
+// Subtype implements Option.
+func (*RemoveAddr) Subtype() Subtype { return SubtypeRemoveAddr }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (r *RemoveAddr) String() string {
+	ids := make([]string, 0, len(r.AddressIDs))
+	for _, id := range r.AddressIDs {
+		ids = append(ids, strconv.Itoa(int(id)))
+	}
+
+	b := newDescription(SubtypeRemoveAddr)
+	b.printf("ids=%s", strings.Join(ids, ","))
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (r *RemoveAddr) MarshalBinary() ([]byte, error) {
+	if len(r.AddressIDs) == 0 {
+		return nil, errInvalidOption
+	}
+
+	b, err := newOption(SubtypeRemoveAddr, 3+len(r.AddressIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(b[3:], r.AddressIDs)
+	return b, nil
+}
+
+// parseRemoveAddr parses a REMOVE_ADDR option.
+func parseRemoveAddr(b []byte) (*RemoveAddr, error) {
+	if len(b) < 4 {
+		return nil, errInvalidOption
+	}
+
+	return &RemoveAddr{AddressIDs: append([]uint8(nil), b[3:]...)}, nil
+}
+
+// An MPPrio is an MP_PRIO option, which changes the priority of a subflow.
+type MPPrio struct {
+	// Backup indicates that the subflow should be used as a backup path.
+	Backup bool
+
+	// AddressID is the address ID of the subflows to change, present if
+	// HasAddressID is set.  It is only used by version 0.
+	AddressID    uint8
+	HasAddressID bool
+}
+
+// Subtype implements Option.
+func (*MPPrio) Subtype() Subtype { return SubtypeMPPrio }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (p *MPPrio) String() string {
+	b := newDescription(SubtypeMPPrio)
+	b.printf("backup=%d", boolByte(p.Backup, 1))
+	if p.HasAddressID {
+		b.printf("addrid=%d", p.AddressID)
+	}
+
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (p *MPPrio) MarshalBinary() ([]byte, error) {
+	n := 3
+	if p.HasAddressID {
+		n = 4
+	}
+
+	b, _ := newOption(SubtypeMPPrio, n)
+	b[2] |= boolByte(p.Backup, 0x01)
+	if p.HasAddressID {
+		b[3] = p.AddressID
+	}
+
+	return b, nil
+}
+
+// parseMPPrio parses an MP_PRIO option.
+func parseMPPrio(b []byte) (*MPPrio, error) {
+	p := &MPPrio{Backup: b[2]&0x01 != 0}
+	switch len(b) {
+	case 3:
+	case 4:
+		p.AddressID = b[3]
+		p.HasAddressID = true
+	default:
+		return nil, errInvalidOption
+	}
+
+	return p, nil
+}
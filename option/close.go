@@ -0,0 +1,153 @@
+package option
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// An MPFail is an MP_FAIL option, which reports a DSS checksum failure and
+// falls back to regular TCP.
+type MPFail struct {
+	// DSN is the data sequence number at the start of the mapping which
+	// failed its checksum.
+	DSN uint64
+}
+
+// Subtype implements Option.
+func (*MPFail) Subtype() Subtype { return SubtypeMPFail }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (f *MPFail) String() string {
+	b := newDescription(SubtypeMPFail)
+	b.printf("dsn=%d", f.DSN)
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (f *MPFail) MarshalBinary() ([]byte, error) {
+	b, _ := newOption(SubtypeMPFail, 12)
+	binary.BigEndian.PutUint64(b[4:12], f.DSN)
+
+	return b, nil
+}
+
+// parseMPFail parses an MP_FAIL option.
+func parseMPFail(b []byte) (*MPFail, error) {
+	if len(b) != 12 {
+		return nil, errInvalidOption
+	}
+
+	return &MPFail{DSN: binary.BigEndian.Uint64(b[4:12])}, nil
+}
+
+// An MPFastclose is an MP_FASTCLOSE option, which abruptly closes a
+// connection and all of its subflows.
+type MPFastclose struct {
+	// ReceiverKey is the key of the receiver of the option.
+	ReceiverKey uint64
+}
+
+// Subtype implements Option.
+func (*MPFastclose) Subtype() Subtype { return SubtypeMPFastclose }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (f *MPFastclose) String() string {
+	b := newDescription(SubtypeMPFastclose)
+	b.printf("rkey=%016x", f.ReceiverKey)
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (f *MPFastclose) MarshalBinary() ([]byte, error) {
+	b, _ := newOption(SubtypeMPFastclose, 12)
+	binary.BigEndian.PutUint64(b[4:12], f.ReceiverKey)
+
+	return b, nil
+}
+
+// parseMPFastclose parses an MP_FASTCLOSE option.
+func parseMPFastclose(b []byte) (*MPFastclose, error) {
+	if len(b) != 12 {
+		return nil, errInvalidOption
+	}
+
+	return &MPFastclose{ReceiverKey: binary.BigEndian.Uint64(b[4:12])}, nil
+}
+
+// A ResetReason is the reason a subflow was reset, carried by MP_TCPRST.
+type ResetReason uint8
+
+// Possible ResetReason values, from RFC 8684, section 3.6.
+const (
+	ResetUnspecified          ResetReason = 0x00
+	ResetMPTCPError           ResetReason = 0x01
+	ResetLackOfResources      ResetReason = 0x02
+	ResetAdminProhibited      ResetReason = 0x03
+	ResetTooMuchOutstanding   ResetReason = 0x04
+	ResetUnacceptablePerf     ResetReason = 0x05
+	ResetMiddleboxInterfering ResetReason = 0x06
+)
+
+// String returns a description of a ResetReason.
+func (r ResetReason) String() string {
+	switch r {
+	case ResetUnspecified:
+		return "unspecified"
+	case ResetMPTCPError:
+		return "mptcp error"
+	case ResetLackOfResources:
+		return "lack of resources"
+	case ResetAdminProhibited:
+		return "administratively prohibited"
+	case ResetTooMuchOutstanding:
+		return "too much outstanding data"
+	case ResetUnacceptablePerf:
+		return "unacceptable performance"
+	case ResetMiddleboxInterfering:
+		return "middlebox interference"
+	default:
+		return fmt.Sprintf("ResetReason(%d)", uint8(r))
+	}
+}
+
+// An MPTCPRst is an MP_TCPRST option, which is sent with a TCP RST to give
+// the reason a subflow was reset.
+type MPTCPRst struct {
+	// Transient indicates that the condition which caused the reset is
+	// temporary, so the subflow may be established again.
+	Transient bool
+
+	// Reason is the reason for the reset.
+	Reason ResetReason
+}
+
+// Subtype implements Option.
+func (*MPTCPRst) Subtype() Subtype { return SubtypeMPTCPRst }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (r *MPTCPRst) String() string {
+	b := newDescription(SubtypeMPTCPRst)
+	b.printf("transient=%d reason=%q", boolByte(r.Transient, 1), r.Reason)
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (r *MPTCPRst) MarshalBinary() ([]byte, error) {
+	b, _ := newOption(SubtypeMPTCPRst, 4)
+	b[2] |= boolByte(r.Transient, 0x01)
+	b[3] = byte(r.Reason)
+
+	return b, nil
+}
+
+// parseMPTCPRst parses an MP_TCPRST option.
+func parseMPTCPRst(b []byte) (*MPTCPRst, error) {
+	if len(b) != 4 {
+		return nil, errInvalidOption
+	}
+
+	return &MPTCPRst{
+		Transient: b[2]&0x01 != 0,
+		Reason:    ResetReason(b[3]),
+	}, nil
+}
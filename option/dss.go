@@ -0,0 +1,173 @@
+package option
+
+import "encoding/binary"
+
+// Flags of the DSS option.
+const (
+	dssDataACK   = 0x01 // A
+	dssDataACK64 = 0x02 // a
+	dssMapping   = 0x04 // M
+	dssDSN64     = 0x08 // m
+	dssDataFIN   = 0x10 // F
+)
+
+// A DSS is a Data Sequence Signal option, which acknowledges data at the
+// connection level, and maps the data in a segment of a subflow to the
+// connection's data sequence space.
+type DSS struct {
+	// DataFIN indicates that the mapping covers the end of the
+	// connection's data.
+	DataFIN bool
+
+	// DataACK is the connection-level acknowledgement, present if
+	// HasDataACK is set.  DataACK64 indicates that it is encoded as 8
+	// bytes rather than 4.
+	DataACK    uint64
+	HasDataACK bool
+	DataACK64  bool
+
+	// DSN, SubflowSeq, and DataLength are the mapping of data in the
+	// subflow, present if HasMapping is set: the data sequence number,
+	// the subflow sequence number relative to the initial sequence
+	// number, and the length of the mapping.  DSN64 indicates that the
+	// data sequence number is encoded as 8 bytes rather than 4.
+	DSN        uint64
+	SubflowSeq uint32
+	DataLength uint16
+	HasMapping bool
+	DSN64      bool
+
+	// Checksum is the DSS checksum of the mapping, present if
+	// HasChecksum is set.  It requires a mapping.
+	Checksum    uint16
+	HasChecksum bool
+}
+
+// Subtype implements Option.
+func (*DSS) Subtype() Subtype { return SubtypeDSS }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (d *DSS) String() string {
+	b := newDescription(SubtypeDSS)
+	if d.DataFIN {
+		b.printf("fin")
+	}
+	if d.HasDataACK {
+		b.printf("ack=%d", d.DataACK)
+	}
+	if d.HasMapping {
+		b.printf("dsn=%d ssn=%d len=%d", d.DSN, d.SubflowSeq, d.DataLength)
+	}
+	if d.HasChecksum {
+		b.printf("csum=0x%04x", d.Checksum)
+	}
+
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (d *DSS) MarshalBinary() ([]byte, error) {
+	if d.HasChecksum && !d.HasMapping {
+		return nil, errInvalidOption
+	}
+
+	var flags byte
+	n := 4
+	if d.HasDataACK {
+		flags |= dssDataACK | boolByte(d.DataACK64, dssDataACK64)
+		n += seqLen(d.DataACK64)
+	}
+	if d.HasMapping {
+		flags |= dssMapping | boolByte(d.DSN64, dssDSN64)
+		n += seqLen(d.DSN64) + 4 + 2
+	}
+	if d.HasChecksum {
+		n += 2
+	}
+	flags |= boolByte(d.DataFIN, dssDataFIN)
+
+	b, _ := newOption(SubtypeDSS, n)
+	b[3] = flags
+
+	i := 4
+	if d.HasDataACK {
+		n := seqLen(d.DataACK64)
+		putUint(b[i:i+n], d.DataACK)
+		i += n
+	}
+	if d.HasMapping {
+		n := seqLen(d.DSN64)
+		putUint(b[i:i+n], d.DSN)
+		i += n
+
+		binary.BigEndian.PutUint32(b[i:i+4], d.SubflowSeq)
+		binary.BigEndian.PutUint16(b[i+4:i+6], d.DataLength)
+		i += 6
+	}
+	if d.HasChecksum {
+		binary.BigEndian.PutUint16(b[i:i+2], d.Checksum)
+	}
+
+	return b, nil
+}
+
+// parseDSS parses a DSS option.
+func parseDSS(b []byte) (*DSS, error) {
+	if len(b) < 4 {
+		return nil, errInvalidOption
+	}
+
+	flags := b[3]
+	d := &DSS{
+		DataFIN:    flags&dssDataFIN != 0,
+		HasDataACK: flags&dssDataACK != 0,
+		DataACK64:  flags&dssDataACK != 0 && flags&dssDataACK64 != 0,
+		HasMapping: flags&dssMapping != 0,
+		DSN64:      flags&dssMapping != 0 && flags&dssDSN64 != 0,
+	}
+
+	// next returns the next n bytes of the option, or nil if too few
+	// remain.
+	b = b[4:]
+	next := func(n int) []byte {
+		if len(b) < n {
+			return nil
+		}
+
+		v := b[:n]
+		b = b[n:]
+		return v
+	}
+
+	if d.HasDataACK {
+		v := next(seqLen(d.DataACK64))
+		if v == nil {
+			return nil, errInvalidOption
+		}
+
+		d.DataACK = getUint(v)
+	}
+
+	if d.HasMapping {
+		dsn := next(seqLen(d.DSN64))
+		m := next(6)
+		if dsn == nil || m == nil {
+			return nil, errInvalidOption
+		}
+
+		d.DSN = getUint(dsn)
+		d.SubflowSeq = binary.BigEndian.Uint32(m[0:4])
+		d.DataLength = binary.BigEndian.Uint16(m[4:6])
+
+		if v := next(2); v != nil {
+			d.Checksum = binary.BigEndian.Uint16(v)
+			d.HasChecksum = true
+		}
+	}
+
+	if len(b) != 0 {
+		return nil, errInvalidOption
+	}
+
+	return d, nil
+}
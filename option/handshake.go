@@ -0,0 +1,298 @@
+package option
+
+import (
+	"encoding/binary"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// CapableFlags are the flags of an MP_CAPABLE option.
+type CapableFlags uint8
+
+// Possible CapableFlags values.
+const (
+	// CapableChecksum (A) requires that DSS checksums are used.
+	CapableChecksum CapableFlags = 0x80
+
+	// CapableExtensibility (B) is reserved for extensions.
+	CapableExtensibility CapableFlags = 0x40
+
+	// CapableNoSourceAddr (C) indicates that the sender will not accept
+	// additional subflows to the source address of the initial subflow.
+	// It is only used by version 1.
+	CapableNoSourceAddr CapableFlags = 0x20
+
+	// CapableHMACSHA (H) indicates the use of HMAC-SHA1 for version 0,
+	// or HMAC-SHA256 for version 1.
+	CapableHMACSHA CapableFlags = 0x01
+)
+
+// An MPCapable is an MP_CAPABLE option, which is used in the handshake of
+// the initial subflow to exchange keys.
+//
+// The form of the option is determined by which fields are present: a
+// version 1 SYN carries no keys, a SYN or SYN/ACK carries the sender's key,
+// and the third ACK carries both keys.  In version 1, the third ACK may also
+// carry the length of data sent with it, and optionally a DSS checksum.
+type MPCapable struct {
+	// Version is the multipath TCP version, 0 or 1.
+	Version uint8
+
+	// Flags are the flags of the option.
+	Flags CapableFlags
+
+	// SenderKey is the key of the sender of the option, present if
+	// HasSenderKey is set.
+	SenderKey    uint64
+	HasSenderKey bool
+
+	// ReceiverKey is the key of the receiver of the option, present if
+	// HasReceiverKey is set.  It requires SenderKey.
+	ReceiverKey    uint64
+	HasReceiverKey bool
+
+	// DataLength is the data-level length of data sent with the option,
+	// present if HasDataLength is set.  It requires ReceiverKey.
+	DataLength    uint16
+	HasDataLength bool
+
+	// Checksum is the DSS checksum of data sent with the option, present
+	// if HasChecksum is set.  It requires DataLength.
+	Checksum    uint16
+	HasChecksum bool
+}
+
+// Subtype implements Option.
+func (*MPCapable) Subtype() Subtype { return SubtypeMPCapable }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (c *MPCapable) String() string {
+	b := newDescription(SubtypeMPCapable)
+	b.printf("v%d flags=0x%02x", c.Version, uint8(c.Flags))
+	if c.HasSenderKey {
+		b.printf("skey=%016x", c.SenderKey)
+	}
+	if c.HasReceiverKey {
+		b.printf("rkey=%016x", c.ReceiverKey)
+	}
+	if c.HasDataLength {
+		b.printf("len=%d", c.DataLength)
+	}
+	if c.HasChecksum {
+		b.printf("csum=0x%04x", c.Checksum)
+	}
+
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (c *MPCapable) MarshalBinary() ([]byte, error) {
+	if c.Version > 0xf ||
+		(c.HasReceiverKey && !c.HasSenderKey) ||
+		(c.HasDataLength && !c.HasReceiverKey) ||
+		(c.HasChecksum && !c.HasDataLength) {
+		return nil, errInvalidOption
+	}
+
+	n := 4
+	switch {
+	case c.HasChecksum:
+		n = 24
+	case c.HasDataLength:
+		n = 22
+	case c.HasReceiverKey:
+		n = 20
+	case c.HasSenderKey:
+		n = 12
+	}
+
+	b, _ := newOption(SubtypeMPCapable, n)
+	b[2] |= c.Version
+	b[3] = byte(c.Flags)
+
+	if c.HasSenderKey {
+		binary.BigEndian.PutUint64(b[4:12], c.SenderKey)
+	}
+	if c.HasReceiverKey {
+		binary.BigEndian.PutUint64(b[12:20], c.ReceiverKey)
+	}
+	if c.HasDataLength {
+		binary.BigEndian.PutUint16(b[20:22], c.DataLength)
+	}
+	if c.HasChecksum {
+		binary.BigEndian.PutUint16(b[22:24], c.Checksum)
+	}
+
+	return b, nil
+}
+
+// parseMPCapable parses an MP_CAPABLE option.
+func parseMPCapable(b []byte) (*MPCapable, error) {
+	switch len(b) {
+	case 4, 12, 20, 22, 24:
+	default:
+		return nil, errInvalidOption
+	}
+
+	c := &MPCapable{
+		Version: b[2] & 0x0f,
+		Flags:   CapableFlags(b[3]),
+	}
+
+	if len(b) >= 12 {
+		c.SenderKey = binary.BigEndian.Uint64(b[4:12])
+		c.HasSenderKey = true
+	}
+	if len(b) >= 20 {
+		c.ReceiverKey = binary.BigEndian.Uint64(b[12:20])
+		c.HasReceiverKey = true
+	}
+	if len(b) >= 22 {
+		c.DataLength = binary.BigEndian.Uint16(b[20:22])
+		c.HasDataLength = true
+	}
+	if len(b) == 24 {
+		c.Checksum = binary.BigEndian.Uint16(b[22:24])
+		c.HasChecksum = true
+	}
+
+	return c, nil
+}
+
+// A JoinForm is the form of an MP_JOIN option, which depends on the segment
+// of the subflow handshake which carries it.
+type JoinForm int
+
+// Possible JoinForm values.
+const (
+	// JoinSYN carries the token of the connection and a nonce.
+	JoinSYN JoinForm = iota + 1
+
+	// JoinSYNACK carries a truncated HMAC and a nonce.
+	JoinSYNACK
+
+	// JoinACK carries a full HMAC.
+	JoinACK
+)
+
+// Lengths of each form of MP_JOIN, and of the HMACs they carry.
+const (
+	joinSYNLen    = 12
+	joinSYNACKLen = 16
+	joinACKLen    = 24
+
+	// TruncatedHMACLen is the length of the HMAC in a JoinSYNACK, and
+	// HMACLen is the length of the HMAC in a JoinACK.
+	TruncatedHMACLen = 8
+	HMACLen          = 20
+)
+
+// An MPJoin is an MP_JOIN option, which is used in the handshake of an
+// additional subflow to associate it with an existing connection.
+type MPJoin struct {
+	// Form is the form of the option.
+	Form JoinForm
+
+	// Backup indicates that the sender wishes the subflow to be used as a
+	// backup path, for JoinSYN and JoinSYNACK.
+	Backup bool
+
+	// AddressID is the address ID of the sender's address, for JoinSYN
+	// and JoinSYNACK.
+	AddressID uint8
+
+	// Token is the receiver's token for the connection, for JoinSYN.
+	Token mptcp.Token
+
+	// Nonce is a random number chosen by the sender, for JoinSYN and
+	// JoinSYNACK.
+	Nonce uint32
+
+	// HMAC is the sender's HMAC, for JoinSYNACK (TruncatedHMACLen bytes)
+	// and JoinACK (HMACLen bytes).
+	HMAC []byte
+}
+
+// Subtype implements Option.
+func (*MPJoin) Subtype() Subtype { return SubtypeMPJoin }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (j *MPJoin) String() string {
+	b := newDescription(SubtypeMPJoin)
+	switch j.Form {
+	case JoinSYN:
+		b.printf("syn token=0x%s nonce=0x%08x backup=%d addrid=%d",
+			j.Token, j.Nonce, boolByte(j.Backup, 1), j.AddressID)
+	case JoinSYNACK:
+		b.printf("synack hmac=%x nonce=0x%08x backup=%d addrid=%d",
+			j.HMAC, j.Nonce, boolByte(j.Backup, 1), j.AddressID)
+	case JoinACK:
+		b.printf("ack hmac=%x", j.HMAC)
+	default:
+		b.printf("form=%d", int(j.Form))
+	}
+
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (j *MPJoin) MarshalBinary() ([]byte, error) {
+	var b []byte
+	switch j.Form {
+	case JoinSYN:
+		b, _ = newOption(SubtypeMPJoin, joinSYNLen)
+		binary.BigEndian.PutUint32(b[4:8], uint32(j.Token))
+		binary.BigEndian.PutUint32(b[8:12], j.Nonce)
+	case JoinSYNACK:
+		if len(j.HMAC) != TruncatedHMACLen {
+			return nil, errInvalidOption
+		}
+
+		b, _ = newOption(SubtypeMPJoin, joinSYNACKLen)
+		copy(b[4:12], j.HMAC)
+		binary.BigEndian.PutUint32(b[12:16], j.Nonce)
+	case JoinACK:
+		if len(j.HMAC) != HMACLen {
+			return nil, errInvalidOption
+		}
+
+		b, _ = newOption(SubtypeMPJoin, joinACKLen)
+		copy(b[4:24], j.HMAC)
+
+		return b, nil
+	default:
+		return nil, errInvalidOption
+	}
+
+	b[2] |= boolByte(j.Backup, 0x01)
+	b[3] = j.AddressID
+
+	return b, nil
+}
+
+// parseMPJoin parses an MP_JOIN option.
+func parseMPJoin(b []byte) (*MPJoin, error) {
+	j := new(MPJoin)
+	switch len(b) {
+	case joinSYNLen:
+		j.Form = JoinSYN
+		j.Token = mptcp.Token(binary.BigEndian.Uint32(b[4:8]))
+		j.Nonce = binary.BigEndian.Uint32(b[8:12])
+	case joinSYNACKLen:
+		j.Form = JoinSYNACK
+		j.HMAC = append([]byte(nil), b[4:12]...)
+		j.Nonce = binary.BigEndian.Uint32(b[12:16])
+	case joinACKLen:
+		j.Form = JoinACK
+		j.HMAC = append([]byte(nil), b[4:24]...)
+
+		return j, nil
+	default:
+		return nil, errInvalidOption
+	}
+
+	j.Backup = b[2]&0x01 != 0
+	j.AddressID = b[3]
+
+	return j, nil
+}
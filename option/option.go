@@ -0,0 +1,285 @@
+// Package option parses and marshals multipath TCP options, carried in TCP
+// option kind 30, as specified by RFC 6824 (version 0) and RFC 8684
+// (version 1).
+//
+// Parse parses a single option into one of the types in this package, such
+// as *MPCapable or *DSS, and Options parses every multipath TCP option in
+// the options of a TCP header.  Each type's MarshalBinary method produces
+// the option in wire format, including its kind and length.
+package option
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind is the TCP option kind used by multipath TCP.
+const Kind = 30
+
+// A Subtype is the subtype of a multipath TCP option.
+type Subtype uint8
+
+// Possible Subtype values.
+const (
+	SubtypeMPCapable    Subtype = 0x0
+	SubtypeMPJoin       Subtype = 0x1
+	SubtypeDSS          Subtype = 0x2
+	SubtypeAddAddr      Subtype = 0x3
+	SubtypeRemoveAddr   Subtype = 0x4
+	SubtypeMPPrio       Subtype = 0x5
+	SubtypeMPFail       Subtype = 0x6
+	SubtypeMPFastclose  Subtype = 0x7
+	SubtypeMPTCPRst     Subtype = 0x8
+	SubtypeExperimental Subtype = 0xf
+)
+
+// String returns the name of a Subtype, as used by the RFCs.
+func (s Subtype) String() string {
+	switch s {
+	case SubtypeMPCapable:
+		return "MP_CAPABLE"
+	case SubtypeMPJoin:
+		return "MP_JOIN"
+	case SubtypeDSS:
+		return "DSS"
+	case SubtypeAddAddr:
+		return "ADD_ADDR"
+	case SubtypeRemoveAddr:
+		return "REMOVE_ADDR"
+	case SubtypeMPPrio:
+		return "MP_PRIO"
+	case SubtypeMPFail:
+		return "MP_FAIL"
+	case SubtypeMPFastclose:
+		return "MP_FASTCLOSE"
+	case SubtypeMPTCPRst:
+		return "MP_TCPRST"
+	case SubtypeExperimental:
+		return "MP_EXPERIMENTAL"
+	default:
+		return fmt.Sprintf("Subtype(%d)", uint8(s))
+	}
+}
+
+// An Option is a multipath TCP option.
+type Option interface {
+	// Subtype returns the subtype of the option.
+	Subtype() Subtype
+
+	// MarshalBinary returns the option in wire format, beginning with
+	// its kind and length.
+	MarshalBinary() ([]byte, error)
+}
+
+var (
+	// errInvalidOption is returned when an option is not in the expected
+	// format.
+	errInvalidOption = errors.New("invalid MPTCP option")
+
+	// errInvalidOptions is returned when the options of a TCP header are
+	// not in the expected format.
+	errInvalidOptions = errors.New("invalid TCP options")
+)
+
+// Parse parses a single multipath TCP option from b, which must contain
+// exactly one option, beginning with its kind and length.  Options with an
+// unknown subtype are returned as *Unknown.
+func Parse(b []byte) (Option, error) {
+	if len(b) < 3 || b[0] != Kind || int(b[1]) != len(b) {
+		return nil, errInvalidOption
+	}
+
+	var (
+		o   Option
+		err error
+	)
+
+	switch st := Subtype(b[2] >> 4); st {
+	case SubtypeMPCapable:
+		o, err = parseMPCapable(b)
+	case SubtypeMPJoin:
+		o, err = parseMPJoin(b)
+	case SubtypeDSS:
+		o, err = parseDSS(b)
+	case SubtypeAddAddr:
+		o, err = parseAddAddr(b)
+	case SubtypeRemoveAddr:
+		o, err = parseRemoveAddr(b)
+	case SubtypeMPPrio:
+		o, err = parseMPPrio(b)
+	case SubtypeMPFail:
+		o, err = parseMPFail(b)
+	case SubtypeMPFastclose:
+		o, err = parseMPFastclose(b)
+	case SubtypeMPTCPRst:
+		o, err = parseMPTCPRst(b)
+	default:
+		o = &Unknown{
+			Type: st,
+			Data: append([]byte(nil), b[2:]...),
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", err, Subtype(b[2]>>4))
+	}
+
+	return o, nil
+}
+
+// Options parses all multipath TCP options from opts, the options of a TCP
+// header.  Other options are skipped.
+func Options(opts []byte) ([]Option, error) {
+	var os []Option
+	for len(opts) > 0 {
+		switch opts[0] {
+		case 0:
+			// End of option list.
+			return os, nil
+		case 1:
+			// No-operation.
+			opts = opts[1:]
+			continue
+		}
+
+		if len(opts) < 2 || opts[1] < 2 || int(opts[1]) > len(opts) {
+			return nil, errInvalidOptions
+		}
+
+		b := opts[:opts[1]]
+		opts = opts[opts[1]:]
+
+		if b[0] != Kind {
+			continue
+		}
+
+		o, err := Parse(b)
+		if err != nil {
+			return nil, err
+		}
+
+		os = append(os, o)
+	}
+
+	return os, nil
+}
+
+// An Unknown is a multipath TCP option with a subtype not known to this
+// package, such as MP_EXPERIMENTAL.
+type Unknown struct {
+	// Type is the subtype of the option.
+	Type Subtype
+
+	// Data is the option following its kind and length, including the
+	// byte which contains the subtype.
+	Data []byte
+}
+
+// Subtype implements Option.
+func (u *Unknown) Subtype() Subtype { return u.Type }
+
+// String returns a description of the option, in the form used by mptcpdump.
+func (u *Unknown) String() string {
+	b := newDescription(u.Type)
+	b.printf("data=%x", u.Data)
+	return b.String()
+}
+
+// MarshalBinary implements Option.
+func (u *Unknown) MarshalBinary() ([]byte, error) {
+	if len(u.Data) == 0 || u.Type > 0xf {
+		return nil, errInvalidOption
+	}
+
+	b, err := newOption(u.Type, 2+len(u.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(b[2:], u.Data)
+	b[2] = byte(u.Type)<<4 | u.Data[0]&0x0f
+
+	return b, nil
+}
+
+// newOption allocates an n byte option with the kind, length, and subtype
+// set.
+func newOption(st Subtype, n int) ([]byte, error) {
+	// The options of a TCP header are at most 40 bytes long, but only
+	// reject lengths which cannot be encoded at all.
+	if n > 255 {
+		return nil, errInvalidOption
+	}
+
+	b := make([]byte, n)
+	b[0] = Kind
+	b[1] = byte(n)
+	b[2] = byte(st) << 4
+
+	return b, nil
+}
+
+// seqLen returns the length of a sequence number or acknowledgement, which
+// is 8 bytes if wide is set, or 4 bytes otherwise.
+func seqLen(wide bool) int {
+	if wide {
+		return 8
+	}
+
+	return 4
+}
+
+// putUint is binary.BigEndian.PutUint64 or PutUint32, depending on the
+// length of b.
+func putUint(b []byte, v uint64) {
+	if len(b) == 8 {
+		binary.BigEndian.PutUint64(b, v)
+		return
+	}
+
+	binary.BigEndian.PutUint32(b, uint32(v))
+}
+
+// getUint is binary.BigEndian.Uint64 or Uint32, depending on the length of
+// b.
+func getUint(b []byte) uint64 {
+	if len(b) == 8 {
+		return binary.BigEndian.Uint64(b)
+	}
+
+	return uint64(binary.BigEndian.Uint32(b))
+}
+
+// boolByte returns v if ok is true, or zero otherwise.
+func boolByte(ok bool, v byte) byte {
+	if ok {
+		return v
+	}
+
+	return 0
+}
+
+// A description builds the String of an option: the name of its subtype,
+// followed by fields separated by spaces.
+type description struct {
+	b strings.Builder
+}
+
+// newDescription creates a description of an option with subtype st.
+func newDescription(st Subtype) *description {
+	var d description
+	d.b.WriteString(st.String())
+	return &d
+}
+
+// printf appends a field to the description.
+func (d *description) printf(format string, v ...interface{}) {
+	d.b.WriteByte(' ')
+	fmt.Fprintf(&d.b, format, v...)
+}
+
+// String returns the description.
+func (d *description) String() string {
+	return d.b.String()
+}
@@ -0,0 +1,640 @@
+package option
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// hmac is a 20 byte HMAC for tests.
+var hmac = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09,
+	0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13,
+}
+
+// optionTests are valid options in wire format, and their parsed form.
+var optionTests = []struct {
+	desc string
+	b    []byte
+	o    Option
+}{
+	{
+		desc: "MP_CAPABLE v0 SYN",
+		b: []byte{
+			30, 12, 0x00, 0x81,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		},
+		o: &MPCapable{
+			Version:      0,
+			Flags:        CapableChecksum | CapableHMACSHA,
+			SenderKey:    0x0102030405060708,
+			HasSenderKey: true,
+		},
+	},
+	{
+		desc: "MP_CAPABLE v1 SYN",
+		b:    []byte{30, 4, 0x01, 0x01},
+		o: &MPCapable{
+			Version: 1,
+			Flags:   CapableHMACSHA,
+		},
+	},
+	{
+		desc: "MP_CAPABLE v1 ACK",
+		b: []byte{
+			30, 20, 0x01, 0x21,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18,
+		},
+		o: &MPCapable{
+			Version:        1,
+			Flags:          CapableNoSourceAddr | CapableHMACSHA,
+			SenderKey:      0x0102030405060708,
+			HasSenderKey:   true,
+			ReceiverKey:    0x1112131415161718,
+			HasReceiverKey: true,
+		},
+	},
+	{
+		desc: "MP_CAPABLE v1 ACK data checksum",
+		b: []byte{
+			30, 24, 0x01, 0x81,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18,
+			0x05, 0xdc, 0xbe, 0xef,
+		},
+		o: &MPCapable{
+			Version:        1,
+			Flags:          CapableChecksum | CapableHMACSHA,
+			SenderKey:      0x0102030405060708,
+			HasSenderKey:   true,
+			ReceiverKey:    0x1112131415161718,
+			HasReceiverKey: true,
+			DataLength:     1500,
+			HasDataLength:  true,
+			Checksum:       0xbeef,
+			HasChecksum:    true,
+		},
+	},
+	{
+		desc: "MP_JOIN SYN",
+		b: []byte{
+			30, 12, 0x11, 0x02,
+			0x9c, 0x29, 0x0b, 0xf6,
+			0xde, 0xad, 0xbe, 0xef,
+		},
+		o: &MPJoin{
+			Form:      JoinSYN,
+			Backup:    true,
+			AddressID: 2,
+			Token:     mptcp.Token(0x9c290bf6),
+			Nonce:     0xdeadbeef,
+		},
+	},
+	{
+		desc: "MP_JOIN SYN/ACK",
+		b: []byte{
+			30, 16, 0x10, 0x01,
+			0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+			0xca, 0xfe, 0xba, 0xbe,
+		},
+		o: &MPJoin{
+			Form:      JoinSYNACK,
+			AddressID: 1,
+			HMAC:      hmac[:8],
+			Nonce:     0xcafebabe,
+		},
+	},
+	{
+		desc: "MP_JOIN ACK",
+		b:    append([]byte{30, 24, 0x10, 0x00}, hmac...),
+		o: &MPJoin{
+			Form: JoinACK,
+			HMAC: hmac,
+		},
+	},
+	{
+		desc: "DSS data ACK",
+		b:    []byte{30, 8, 0x20, 0x01, 0x01, 0x02, 0x03, 0x04},
+		o: &DSS{
+			DataACK:    0x01020304,
+			HasDataACK: true,
+		},
+	},
+	{
+		desc: "DSS 64-bit data ACK and mapping with checksum",
+		b: []byte{
+			30, 28, 0x20, 0x0f,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18,
+			0x00, 0x00, 0x00, 0x01,
+			0x05, 0xa0,
+			0xbe, 0xef,
+		},
+		o: &DSS{
+			DataACK:     0x0102030405060708,
+			HasDataACK:  true,
+			DataACK64:   true,
+			DSN:         0x1112131415161718,
+			SubflowSeq:  1,
+			DataLength:  1440,
+			HasMapping:  true,
+			DSN64:       true,
+			Checksum:    0xbeef,
+			HasChecksum: true,
+		},
+	},
+	{
+		desc: "DSS DATA_FIN mapping",
+		b: []byte{
+			30, 14, 0x20, 0x14,
+			0x00, 0x00, 0x10, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x01,
+		},
+		o: &DSS{
+			DataFIN:    true,
+			DSN:        0x1000,
+			DataLength: 1,
+			HasMapping: true,
+		},
+	},
+	{
+		desc: "ADD_ADDR v0 IPv4",
+		b:    []byte{30, 8, 0x34, 0x01, 192, 0, 2, 1},
+		o: &AddAddr{
+			AddressID: 1,
+			Address:   netip.MustParseAddr("192.0.2.1"),
+		},
+	},
+	{
+		desc: "ADD_ADDR v0 IPv6 port",
+		b: []byte{
+			30, 22, 0x36, 0x02,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+			0x01, 0xbb,
+		},
+		o: &AddAddr{
+			AddressID: 2,
+			Address:   netip.MustParseAddr("2001:db8::1"),
+			Port:      443,
+		},
+	},
+	{
+		desc: "ADD_ADDR v1 IPv4 HMAC",
+		b: []byte{
+			30, 16, 0x30, 0x01,
+			192, 0, 2, 1,
+			0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		},
+		o: &AddAddr{
+			Version:   1,
+			AddressID: 1,
+			Address:   netip.MustParseAddr("192.0.2.1"),
+			HMAC:      hmac[:8],
+		},
+	},
+	{
+		desc: "ADD_ADDR v1 IPv6 port HMAC",
+		b: append([]byte{
+			30, 30, 0x30, 0x03,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+			0x1f, 0x90,
+		}, hmac[:8]...),
+		o: &AddAddr{
+			Version:   1,
+			AddressID: 3,
+			Address:   netip.MustParseAddr("2001:db8::1"),
+			Port:      8080,
+			HMAC:      hmac[:8],
+		},
+	},
+	{
+		desc: "ADD_ADDR v1 IPv4 echo port",
+		b:    []byte{30, 10, 0x31, 0x01, 192, 0, 2, 1, 0x01, 0xbb},
+		o: &AddAddr{
+			Version:   1,
+			Echo:      true,
+			AddressID: 1,
+			Address:   netip.MustParseAddr("192.0.2.1"),
+			Port:      443,
+		},
+	},
+	{
+		desc: "REMOVE_ADDR",
+		b:    []byte{30, 5, 0x40, 0x01, 0x02},
+		o:    &RemoveAddr{AddressIDs: []uint8{1, 2}},
+	},
+	{
+		desc: "MP_PRIO v0",
+		b:    []byte{30, 4, 0x51, 0x02},
+		o: &MPPrio{
+			Backup:       true,
+			AddressID:    2,
+			HasAddressID: true,
+		},
+	},
+	{
+		desc: "MP_PRIO v1",
+		b:    []byte{30, 3, 0x50},
+		o:    &MPPrio{},
+	},
+	{
+		desc: "MP_FAIL",
+		b: []byte{
+			30, 12, 0x60, 0x00,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		},
+		o: &MPFail{DSN: 0x0102030405060708},
+	},
+	{
+		desc: "MP_FASTCLOSE",
+		b: []byte{
+			30, 12, 0x70, 0x00,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		},
+		o: &MPFastclose{ReceiverKey: 0x0102030405060708},
+	},
+	{
+		desc: "MP_TCPRST",
+		b:    []byte{30, 4, 0x81, 0x06},
+		o: &MPTCPRst{
+			Transient: true,
+			Reason:    ResetMiddleboxInterfering,
+		},
+	},
+	{
+		desc: "MP_EXPERIMENTAL",
+		b:    []byte{30, 5, 0xf3, 0x01, 0x02},
+		o: &Unknown{
+			Type: SubtypeExperimental,
+			Data: []byte{0xf3, 0x01, 0x02},
+		},
+	},
+}
+
+// TestParse verifies that Parse parses each subtype of option.
+func TestParse(t *testing.T) {
+	for i, tt := range optionTests {
+		o, err := Parse(tt.b)
+		if err != nil {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.desc, err)
+		}
+
+		if want, got := tt.o, o; !reflect.DeepEqual(want, got) {
+			t.Fatalf("[%02d] test %q, unexpected option:\n- want: %+v\n-  got: %+v",
+				i, tt.desc, want, got)
+		}
+
+		if want, got := tt.o.Subtype(), Subtype(tt.b[2]>>4); want != got {
+			t.Fatalf("[%02d] test %q, unexpected subtype:\n- want: %v\n-  got: %v",
+				i, tt.desc, want, got)
+		}
+	}
+}
+
+// TestMarshalBinary verifies that each subtype of option marshals to its
+// wire format.
+func TestMarshalBinary(t *testing.T) {
+	for i, tt := range optionTests {
+		b, err := tt.o.MarshalBinary()
+		if err != nil {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.desc, err)
+		}
+
+		if want, got := tt.b, b; !bytes.Equal(want, got) {
+			t.Fatalf("[%02d] test %q, unexpected bytes:\n- want: %#v\n-  got: %#v",
+				i, tt.desc, want, got)
+		}
+	}
+}
+
+// TestParseInvalid verifies that Parse rejects malformed options.
+func TestParseInvalid(t *testing.T) {
+	var tests = []struct {
+		desc string
+		b    []byte
+	}{
+		{
+			desc: "short",
+			b:    []byte{30, 2},
+		},
+		{
+			desc: "wrong kind",
+			b:    []byte{29, 4, 0x01, 0x01},
+		},
+		{
+			desc: "length mismatch",
+			b:    []byte{30, 5, 0x01, 0x01},
+		},
+		{
+			desc: "MP_CAPABLE length",
+			b:    []byte{30, 5, 0x01, 0x01, 0x00},
+		},
+		{
+			desc: "MP_JOIN length",
+			b:    []byte{30, 4, 0x10, 0x00},
+		},
+		{
+			desc: "DSS truncated data ACK",
+			b:    []byte{30, 6, 0x20, 0x01, 0x00, 0x00},
+		},
+		{
+			desc: "DSS truncated mapping",
+			b:    []byte{30, 8, 0x20, 0x04, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			desc: "DSS trailing bytes",
+			b:    []byte{30, 6, 0x20, 0x00, 0x00, 0x00},
+		},
+		{
+			desc: "ADD_ADDR v0 length",
+			b:    []byte{30, 9, 0x34, 0x01, 192, 0, 2, 1, 0},
+		},
+		{
+			desc: "ADD_ADDR v1 length",
+			b:    []byte{30, 8, 0x30, 0x01, 192, 0, 2, 1},
+		},
+		{
+			desc: "REMOVE_ADDR empty",
+			b:    []byte{30, 3, 0x40},
+		},
+		{
+			desc: "MP_PRIO length",
+			b:    []byte{30, 5, 0x50, 0x00, 0x00},
+		},
+		{
+			desc: "MP_FAIL length",
+			b:    []byte{30, 4, 0x60, 0x00},
+		},
+		{
+			desc: "MP_FASTCLOSE length",
+			b:    []byte{30, 4, 0x70, 0x00},
+		},
+		{
+			desc: "MP_TCPRST length",
+			b:    []byte{30, 3, 0x80},
+		},
+	}
+
+	for i, tt := range tests {
+		if _, err := Parse(tt.b); err == nil {
+			t.Fatalf("[%02d] test %q, expected an error, but none occurred",
+				i, tt.desc)
+		}
+	}
+}
+
+// TestMarshalBinaryInvalid verifies that options which cannot be encoded are
+// rejected.
+func TestMarshalBinaryInvalid(t *testing.T) {
+	var tests = []struct {
+		desc string
+		o    Option
+	}{
+		{
+			desc: "MP_CAPABLE receiver key without sender key",
+			o:    &MPCapable{HasReceiverKey: true},
+		},
+		{
+			desc: "MP_CAPABLE checksum without data length",
+			o:    &MPCapable{HasSenderKey: true, HasReceiverKey: true, HasChecksum: true},
+		},
+		{
+			desc: "MP_JOIN no form",
+			o:    &MPJoin{},
+		},
+		{
+			desc: "MP_JOIN SYN/ACK HMAC length",
+			o:    &MPJoin{Form: JoinSYNACK, HMAC: hmac},
+		},
+		{
+			desc: "DSS checksum without mapping",
+			o:    &DSS{HasChecksum: true},
+		},
+		{
+			desc: "ADD_ADDR no address",
+			o:    &AddAddr{},
+		},
+		{
+			desc: "ADD_ADDR v0 echo",
+			o:    &AddAddr{Echo: true, Address: netip.MustParseAddr("192.0.2.1")},
+		},
+		{
+			desc: "ADD_ADDR v1 no HMAC",
+			o:    &AddAddr{Version: 1, Address: netip.MustParseAddr("192.0.2.1")},
+		},
+		{
+			desc: "ADD_ADDR v1 echo HMAC",
+			o:    &AddAddr{Version: 1, Echo: true, Address: netip.MustParseAddr("192.0.2.1"), HMAC: hmac[:8]},
+		},
+		{
+			desc: "REMOVE_ADDR empty",
+			o:    &RemoveAddr{},
+		},
+		{
+			desc: "REMOVE_ADDR too long",
+			o:    &RemoveAddr{AddressIDs: make([]uint8, 253)},
+		},
+	}
+
+	for i, tt := range tests {
+		if _, err := tt.o.MarshalBinary(); err == nil {
+			t.Fatalf("[%02d] test %q, expected an error, but none occurred",
+				i, tt.desc)
+		}
+	}
+}
+
+// TestOptions verifies that Options parses multipath TCP options from the
+// options of a TCP header, skipping other options.
+func TestOptions(t *testing.T) {
+	opts := []byte{
+		// MSS 1460.
+		2, 4, 0x05, 0xb4,
+		// NOP, NOP, SACK permitted.
+		1, 1, 4, 2,
+	}
+	opts = append(opts, optionTests[1].b...)
+	opts = append(opts, optionTests[7].b...)
+	// End of option list and padding.
+	opts = append(opts, 0, 0x99, 0x99)
+
+	os, err := Options(opts)
+	if err != nil {
+		t.Fatalf("failed to parse options: %v", err)
+	}
+
+	if want, got := []Option{optionTests[1].o, optionTests[7].o}, os; !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected options:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	for i, b := range [][]byte{{2}, {2, 1}, {2, 8, 0, 0}, append([]byte{30, 3, 0x01}, 0)} {
+		if _, err := Options(b); err == nil {
+			t.Fatalf("[%02d] expected an error, but none occurred", i)
+		}
+	}
+}
+
+// TestString verifies the descriptions of options.
+func TestString(t *testing.T) {
+	var tests = []struct {
+		desc string
+		o    fmt.Stringer
+		s    string
+	}{
+		{
+			desc: "MP_CAPABLE",
+			o: &MPCapable{
+				Version:       1,
+				Flags:         CapableChecksum | CapableHMACSHA,
+				SenderKey:     0x0102030405060708,
+				HasSenderKey:  true,
+				DataLength:    100,
+				HasDataLength: true,
+			},
+			s: "MP_CAPABLE v1 flags=0x81 skey=0102030405060708 len=100",
+		},
+		{
+			desc: "MP_JOIN SYN",
+			o: &MPJoin{
+				Form:      JoinSYN,
+				AddressID: 2,
+				Token:     0x9c290bf6,
+				Nonce:     0xdeadbeef,
+			},
+			s: "MP_JOIN syn token=0x9C290BF6 nonce=0xdeadbeef backup=0 addrid=2",
+		},
+		{
+			desc: "MP_JOIN SYN/ACK",
+			o: &MPJoin{
+				Form:   JoinSYNACK,
+				Backup: true,
+				Nonce:  1,
+				HMAC:   []byte{0xff, 0x00},
+			},
+			s: "MP_JOIN synack hmac=ff00 nonce=0x00000001 backup=1 addrid=0",
+		},
+		{
+			desc: "MP_JOIN ACK",
+			o:    &MPJoin{Form: JoinACK, HMAC: []byte{0x01}},
+			s:    "MP_JOIN ack hmac=01",
+		},
+		{
+			desc: "DSS",
+			o: &DSS{
+				DataFIN:     true,
+				DataACK:     10,
+				HasDataACK:  true,
+				DSN:         20,
+				SubflowSeq:  1,
+				DataLength:  30,
+				HasMapping:  true,
+				Checksum:    0xbeef,
+				HasChecksum: true,
+			},
+			s: "DSS fin ack=10 dsn=20 ssn=1 len=30 csum=0xbeef",
+		},
+		{
+			desc: "ADD_ADDR",
+			o: &AddAddr{
+				Version:   1,
+				AddressID: 1,
+				Address:   netip.MustParseAddr("2001:db8::1"),
+				Port:      443,
+				HMAC:      []byte{0xab},
+			},
+			s: "ADD_ADDR id=1 addr=2001:db8::1 port=443 hmac=ab",
+		},
+		{
+			desc: "ADD_ADDR echo",
+			o: &AddAddr{
+				Version: 1,
+				Echo:    true,
+				Address: netip.MustParseAddr("192.0.2.1"),
+			},
+			s: "ADD_ADDR echo id=0 addr=192.0.2.1",
+		},
+		{
+			desc: "REMOVE_ADDR",
+			o:    &RemoveAddr{AddressIDs: []uint8{1, 3}},
+			s:    "REMOVE_ADDR ids=1,3",
+		},
+		{
+			desc: "MP_PRIO",
+			o:    &MPPrio{Backup: true, AddressID: 2, HasAddressID: true},
+			s:    "MP_PRIO backup=1 addrid=2",
+		},
+		{
+			desc: "MP_FAIL",
+			o:    &MPFail{DSN: 42},
+			s:    "MP_FAIL dsn=42",
+		},
+		{
+			desc: "MP_FASTCLOSE",
+			o:    &MPFastclose{ReceiverKey: 1},
+			s:    "MP_FASTCLOSE rkey=0000000000000001",
+		},
+		{
+			desc: "MP_TCPRST",
+			o:    &MPTCPRst{Transient: true, Reason: ResetLackOfResources},
+			s:    `MP_TCPRST transient=1 reason="lack of resources"`,
+		},
+		{
+			desc: "unknown",
+			o:    &Unknown{Type: SubtypeExperimental, Data: []byte{0xf0, 0x01}},
+			s:    "MP_EXPERIMENTAL data=f001",
+		},
+	}
+
+	for i, tt := range tests {
+		if want, got := tt.s, tt.o.String(); want != got {
+			t.Fatalf("[%02d] test %q, unexpected string:\n- want: %q\n-  got: %q",
+				i, tt.desc, want, got)
+		}
+	}
+}
+
+// FuzzParse verifies that any option accepted by Parse can be marshaled and
+// parsed again without change.
+func FuzzParse(f *testing.F) {
+	for _, tt := range optionTests {
+		f.Add(tt.b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		o, err := Parse(b)
+		if err != nil {
+			return
+		}
+
+		mb, err := o.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal %+v: %v", o, err)
+		}
+
+		o2, err := Parse(mb)
+		if err != nil {
+			t.Fatalf("failed to parse marshaled option %#v: %v", mb, err)
+		}
+
+		if !reflect.DeepEqual(o, o2) {
+			t.Fatalf("option changed by round trip:\n- want: %+v\n-  got: %+v", o, o2)
+		}
+	})
+}
+
+// FuzzOptions verifies that Options does not panic on arbitrary TCP options.
+func FuzzOptions(f *testing.F) {
+	for _, tt := range optionTests {
+		f.Add(append([]byte{1, 1}, tt.b...))
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = Options(b)
+	})
+}
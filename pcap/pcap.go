@@ -0,0 +1,79 @@
+// Package pcap reads packets from pcap and pcapng capture files, such as
+// those written by tcpdump and Wireshark, and writes pcap files.
+package pcap
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A LinkType is the link-layer header type of captured packets, from the
+// tcpdump.org registry of link-layer header types.
+type LinkType uint16
+
+// Link types which are commonly used to capture TCP traffic.
+const (
+	LinkTypeNull      LinkType = 0
+	LinkTypeEthernet  LinkType = 1
+	LinkTypeRaw       LinkType = 101
+	LinkTypeLoop      LinkType = 108
+	LinkTypeLinuxSLL  LinkType = 113
+	LinkTypeIPv4      LinkType = 228
+	LinkTypeIPv6      LinkType = 229
+	LinkTypeLinuxSLL2 LinkType = 276
+)
+
+// String returns the name of a LinkType.
+func (t LinkType) String() string {
+	switch t {
+	case LinkTypeNull:
+		return "NULL"
+	case LinkTypeEthernet:
+		return "EN10MB"
+	case LinkTypeRaw:
+		return "RAW"
+	case LinkTypeLoop:
+		return "LOOP"
+	case LinkTypeLinuxSLL:
+		return "LINUX_SLL"
+	case LinkTypeIPv4:
+		return "IPV4"
+	case LinkTypeIPv6:
+		return "IPV6"
+	case LinkTypeLinuxSLL2:
+		return "LINUX_SLL2"
+	default:
+		return fmt.Sprintf("LinkType(%d)", uint16(t))
+	}
+}
+
+// A Packet is a captured packet.
+type Packet struct {
+	// Time is the time the packet was captured.
+	Time time.Time
+
+	// LinkType is the link-layer header type of Data.
+	LinkType LinkType
+
+	// Data is the captured packet, beginning with its link-layer header.
+	// It may be shorter than Length if the capture was truncated.
+	Data []byte
+
+	// Length is the original length of the packet.
+	Length int
+}
+
+// maxPacket is the largest packet which will be read, which protects
+// against allocating large buffers for malformed files.
+const maxPacket = 256 * 1024
+
+var (
+	// errInvalidFile is returned when a file is not in pcap or pcapng
+	// format.
+	errInvalidFile = errors.New("pcap: not a pcap or pcapng file")
+
+	// errInvalidPacket is returned when a packet record or block is
+	// malformed.
+	errInvalidPacket = errors.New("pcap: invalid packet record")
+)
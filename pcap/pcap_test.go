@@ -0,0 +1,233 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestWriterReader verifies that packets written by a Writer are read back
+// by a Reader.
+func TestWriterReader(t *testing.T) {
+	t0 := time.Date(2026, 10, 18, 12, 0, 0, 123456000, time.UTC)
+
+	want := []*Packet{
+		{
+			Time:     t0,
+			LinkType: LinkTypeRaw,
+			Data:     []byte{0x45, 0x00, 0x00, 0x14},
+			Length:   4,
+		},
+		{
+			Time:     t0.Add(time.Second),
+			LinkType: LinkTypeRaw,
+			Data:     []byte{0x60, 0x00},
+			Length:   1500,
+		},
+	}
+
+	var b bytes.Buffer
+	w, err := NewWriter(&b, LinkTypeRaw)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+
+	for _, p := range want {
+		if err := w.WritePacket(p); err != nil {
+			t.Fatalf("failed to write packet: %v", err)
+		}
+	}
+
+	if err := w.WritePacket(&Packet{LinkType: LinkTypeEthernet}); err == nil {
+		t.Fatal("expected an error for mismatched link type, but none occurred")
+	}
+
+	if got := readAll(t, &b); !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected packets:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
+
+// TestReaderPcapBigEndianNano verifies that Reader reads big endian pcap files
+// with nanosecond timestamps.
+func TestReaderPcapBigEndianNano(t *testing.T) {
+	var b bytes.Buffer
+	put := func(vs ...uint32) {
+		for _, v := range vs {
+			_ = binary.Write(&b, binary.BigEndian, v)
+		}
+	}
+
+	put(magicNano, 0x00020004, 0, 0, 65535, uint32(LinkTypeEthernet))
+	put(1, 999999999, 2, 60)
+	b.Write([]byte{0xff, 0xff})
+
+	want := []*Packet{{
+		Time:     time.Unix(1, 999999999).UTC(),
+		LinkType: LinkTypeEthernet,
+		Data:     []byte{0xff, 0xff},
+		Length:   60,
+	}}
+
+	if got := readAll(t, &b); !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected packets:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
+
+// ngBlock creates a little endian pcapng block.
+func ngBlock(typ uint32, body ...[]byte) []byte {
+	var bb []byte
+	for _, b := range body {
+		bb = append(bb, b...)
+	}
+	for len(bb)%4 != 0 {
+		bb = append(bb, 0)
+	}
+
+	n := uint32(12 + len(bb))
+	b := binary.LittleEndian.AppendUint32(nil, typ)
+	b = binary.LittleEndian.AppendUint32(b, n)
+	b = append(b, bb...)
+
+	return binary.LittleEndian.AppendUint32(b, n)
+}
+
+// le encodes little endian values.
+func le(vs ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, v := range vs {
+		_ = binary.Write(&b, binary.LittleEndian, v)
+	}
+
+	return b.Bytes()
+}
+
+// TestReaderPcapng verifies that Reader reads pcapng files with multiple
+// interfaces and timestamp resolutions.
+func TestReaderPcapng(t *testing.T) {
+	var f []byte
+	f = append(f, ngBlock(blockSectionHeader,
+		le(uint32(byteOrderMagic), uint16(1), uint16(0), int64(-1)))...)
+	// Interface 0: Ethernet, default microsecond resolution.
+	f = append(f, ngBlock(blockInterface,
+		le(uint16(LinkTypeEthernet), uint16(0), uint32(4)))...)
+	// Interface 1: raw IP, nanosecond resolution.
+	f = append(f, ngBlock(blockInterface,
+		le(uint16(LinkTypeRaw), uint16(0), uint32(0)),
+		le(uint16(optionInterfaceTSResol), uint16(1), []byte{9, 0, 0, 0}),
+		le(uint16(optionEnd), uint16(0)))...)
+	// An unknown block, which is skipped.
+	f = append(f, ngBlock(0x0bad, []byte{1, 2, 3, 4})...)
+	f = append(f, ngBlock(blockEnhancedPacket,
+		le(uint32(1), uint32(0), uint32(1500000001), uint32(3), uint32(3)),
+		[]byte{0x45, 0x00, 0x01})...)
+	f = append(f, ngBlock(blockEnhancedPacket,
+		le(uint32(0), uint32(0), uint32(2500000), uint32(2), uint32(64)),
+		[]byte{0xaa, 0xbb})...)
+	// Simple packets are truncated to the snapshot length of interface 0.
+	f = append(f, ngBlock(blockSimplePacket,
+		le(uint32(6)), []byte{1, 2, 3, 4, 5, 6})...)
+
+	want := []*Packet{
+		{
+			Time:     time.Unix(1, 500000001).UTC(),
+			LinkType: LinkTypeRaw,
+			Data:     []byte{0x45, 0x00, 0x01},
+			Length:   3,
+		},
+		{
+			Time:     time.Unix(2, 500000000).UTC(),
+			LinkType: LinkTypeEthernet,
+			Data:     []byte{0xaa, 0xbb},
+			Length:   64,
+		},
+		{
+			LinkType: LinkTypeEthernet,
+			Data:     []byte{1, 2, 3, 4},
+			Length:   6,
+		},
+	}
+
+	if got := readAll(t, bytes.NewReader(f)); !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected packets:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
+
+// TestReaderInvalid verifies that Reader rejects malformed files.
+func TestReaderInvalid(t *testing.T) {
+	shb := ngBlock(blockSectionHeader,
+		le(uint32(byteOrderMagic), uint16(1), uint16(0), int64(-1)))
+
+	var tests = []struct {
+		desc string
+		b    []byte
+	}{
+		{
+			desc: "empty",
+		},
+		{
+			desc: "bad magic",
+			b:    []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			desc: "short pcap header",
+			b:    le(uint32(magicMicro), uint32(0)),
+		},
+		{
+			desc: "truncated pcap record",
+			b: append(le(uint32(magicMicro), uint16(2), uint16(4), uint32(0), uint32(0), uint32(65535), uint32(1)),
+				le(uint32(0), uint32(0), uint32(10), uint32(10), []byte{1})...),
+		},
+		{
+			desc: "huge pcap record",
+			b: append(le(uint32(magicMicro), uint16(2), uint16(4), uint32(0), uint32(0), uint32(65535), uint32(1)),
+				le(uint32(0), uint32(0), uint32(1<<30), uint32(1<<30))...),
+		},
+		{
+			desc: "pcapng packet without interface",
+			b: append(shb, ngBlock(blockEnhancedPacket,
+				le(uint32(0), uint32(0), uint32(0), uint32(0), uint32(0)))...),
+		},
+		{
+			desc: "pcapng bad block length",
+			b:    append(shb, le(uint32(blockEnhancedPacket), uint32(5))...),
+		},
+	}
+
+	for i, tt := range tests {
+		r, err := NewReader(bytes.NewReader(tt.b))
+		if err != nil {
+			continue
+		}
+
+		if _, err := r.ReadPacket(); err == nil || err == io.EOF {
+			t.Fatalf("[%02d] test %q, expected an error, but got: %v",
+				i, tt.desc, err)
+		}
+	}
+}
+
+// readAll reads all packets from r.
+func readAll(t *testing.T, r io.Reader) []*Packet {
+	t.Helper()
+
+	pr, err := NewReader(r)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	var ps []*Packet
+	for {
+		p, err := pr.ReadPacket()
+		if err == io.EOF {
+			return ps
+		}
+		if err != nil {
+			t.Fatalf("failed to read packet: %v", err)
+		}
+
+		ps = append(ps, p)
+	}
+}
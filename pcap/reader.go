@@ -0,0 +1,366 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// Magic numbers of pcap files with microsecond and nanosecond timestamps, and
+// block types and byte order magic of pcapng files.
+const (
+	magicMicro = 0xa1b2c3d4
+	magicNano  = 0xa1b23c4d
+
+	blockSectionHeader     = 0x0a0d0d0a
+	blockInterface         = 0x00000001
+	blockPacket            = 0x00000002
+	blockSimplePacket      = 0x00000003
+	blockEnhancedPacket    = 0x00000006
+	byteOrderMagic         = 0x1a2b3c4d
+	optionEnd              = 0
+	optionInterfaceTSResol = 9
+)
+
+// A Reader reads packets from a pcap or pcapng file.
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+
+	// Fields for pcap files.
+	linkType LinkType
+	nano     bool
+
+	// Fields for pcapng files, which describe the interfaces of the
+	// current section.
+	ng     bool
+	ifaces []iface
+}
+
+// An iface is an interface of a pcapng section.
+type iface struct {
+	linkType LinkType
+	snapLen  uint32
+
+	// tps is the number of timestamp units per second.
+	tps uint64
+}
+
+// NewReader creates a Reader which reads packets from r, which must contain
+// a pcap or pcapng file.
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: bufio.NewReader(r)}
+
+	b, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, errInvalidFile
+	}
+
+	switch {
+	case binary.BigEndian.Uint32(b) == blockSectionHeader:
+		pr.ng = true
+		if err := pr.readSectionHeader(); err != nil {
+			return nil, err
+		}
+
+		return pr, nil
+	case binary.LittleEndian.Uint32(b) == magicMicro || binary.LittleEndian.Uint32(b) == magicNano:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(b) == magicMicro || binary.BigEndian.Uint32(b) == magicNano:
+		pr.order = binary.BigEndian
+	default:
+		return nil, errInvalidFile
+	}
+
+	var h [24]byte
+	if _, err := io.ReadFull(pr.r, h[:]); err != nil {
+		return nil, errInvalidFile
+	}
+
+	pr.nano = pr.order.Uint32(h[0:4]) == magicNano
+	// The upper bits of the link type field may carry FCS information.
+	pr.linkType = LinkType(pr.order.Uint32(h[20:24]))
+
+	return pr, nil
+}
+
+// ReadPacket reads the next packet.  At the end of the file, ReadPacket
+// returns io.EOF.
+func (r *Reader) ReadPacket() (*Packet, error) {
+	if r.ng {
+		return r.readBlock()
+	}
+
+	var h [16]byte
+	if _, err := io.ReadFull(r.r, h[:]); err != nil {
+		return nil, err
+	}
+
+	var (
+		sec  = r.order.Uint32(h[0:4])
+		frac = r.order.Uint32(h[4:8])
+		n    = r.order.Uint32(h[8:12])
+		orig = r.order.Uint32(h[12:16])
+	)
+
+	if n > maxPacket {
+		return nil, errInvalidPacket
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	nsec := int64(frac)
+	if !r.nano {
+		nsec *= 1000
+	}
+
+	return &Packet{
+		Time:     time.Unix(int64(sec), nsec).UTC(),
+		LinkType: r.linkType,
+		Data:     b,
+		Length:   int(orig),
+	}, nil
+}
+
+// readBlock reads pcapng blocks until a packet is found.
+func (r *Reader) readBlock() (*Packet, error) {
+	for {
+		b, err := r.r.Peek(4)
+		if err != nil {
+			return nil, err
+		}
+
+		if binary.BigEndian.Uint32(b) == blockSectionHeader {
+			if err := r.readSectionHeader(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+
+			continue
+		}
+
+		typ, body, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+
+		var p *Packet
+		switch typ {
+		case blockInterface:
+			err = r.parseInterface(body)
+		case blockEnhancedPacket:
+			p, err = r.parseEnhancedPacket(body)
+		case blockSimplePacket:
+			p, err = r.parseSimplePacket(body)
+		case blockPacket:
+			p, err = r.parseObsoletePacket(body)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+}
+
+// next reads the next block, and returns its type and body.
+func (r *Reader) next() (uint32, []byte, error) {
+	var h [8]byte
+	if _, err := io.ReadFull(r.r, h[:]); err != nil {
+		return 0, nil, err
+	}
+
+	typ := r.order.Uint32(h[0:4])
+	n := r.order.Uint32(h[4:8])
+	if n < 12 || n%4 != 0 || n > maxPacket+256 {
+		return 0, nil, errInvalidPacket
+	}
+
+	// The body is followed by a copy of the block's total length.
+	b := make([]byte, n-8)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+
+	return typ, b[:len(b)-4], nil
+}
+
+// readSectionHeader reads a section header block, which determines the byte
+// order of the section and resets its interfaces.
+func (r *Reader) readSectionHeader() error {
+	var h [12]byte
+	if _, err := io.ReadFull(r.r, h[:]); err != nil {
+		return errInvalidFile
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(h[8:12]) == byteOrderMagic:
+		r.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(h[8:12]) == byteOrderMagic:
+		r.order = binary.BigEndian
+	default:
+		return errInvalidFile
+	}
+
+	n := r.order.Uint32(h[4:8])
+	if n < 28 || n%4 != 0 || n > maxPacket {
+		return errInvalidFile
+	}
+
+	// Skip the remainder of the block.
+	if _, err := io.CopyN(io.Discard, r.r, int64(n-12)); err != nil {
+		return err
+	}
+
+	r.ifaces = r.ifaces[:0]
+	return nil
+}
+
+// parseInterface parses an interface description block.
+func (r *Reader) parseInterface(b []byte) error {
+	if len(b) < 8 {
+		return errInvalidPacket
+	}
+
+	ifi := iface{
+		linkType: LinkType(r.order.Uint16(b[0:2])),
+		snapLen:  r.order.Uint32(b[4:8]),
+		tps:      1e6,
+	}
+
+	opts := b[8:]
+	for len(opts) >= 4 {
+		code := r.order.Uint16(opts[0:2])
+		n := int(r.order.Uint16(opts[2:4]))
+		if code == optionEnd || 4+n > len(opts) {
+			break
+		}
+
+		if code == optionInterfaceTSResol && n >= 1 {
+			tps, ok := resolution(opts[4])
+			if !ok {
+				return errInvalidPacket
+			}
+
+			ifi.tps = tps
+		}
+
+		// Option values are padded to 32 bits.
+		n = (n + 3) &^ 3
+		if 4+n > len(opts) {
+			break
+		}
+		opts = opts[4+n:]
+	}
+
+	r.ifaces = append(r.ifaces, ifi)
+	return nil
+}
+
+// resolution returns the number of timestamp units per second from the
+// value of an if_tsresol option.
+func resolution(v byte) (uint64, bool) {
+	if v&0x80 != 0 {
+		e := v & 0x7f
+		if e > 63 {
+			return 0, false
+		}
+
+		return 1 << e, true
+	}
+
+	if v > 19 {
+		return 0, false
+	}
+
+	tps := uint64(1)
+	for i := byte(0); i < v; i++ {
+		tps *= 10
+	}
+
+	return tps, true
+}
+
+// parseEnhancedPacket parses an enhanced packet block.
+func (r *Reader) parseEnhancedPacket(b []byte) (*Packet, error) {
+	if len(b) < 20 {
+		return nil, errInvalidPacket
+	}
+
+	return r.packet(
+		r.order.Uint32(b[0:4]),
+		uint64(r.order.Uint32(b[4:8]))<<32|uint64(r.order.Uint32(b[8:12])),
+		r.order.Uint32(b[12:16]),
+		r.order.Uint32(b[16:20]),
+		b[20:],
+	)
+}
+
+// parseObsoletePacket parses an obsolete packet block.
+func (r *Reader) parseObsoletePacket(b []byte) (*Packet, error) {
+	if len(b) < 20 {
+		return nil, errInvalidPacket
+	}
+
+	return r.packet(
+		uint32(r.order.Uint16(b[0:2])),
+		uint64(r.order.Uint32(b[4:8]))<<32|uint64(r.order.Uint32(b[8:12])),
+		r.order.Uint32(b[12:16]),
+		r.order.Uint32(b[16:20]),
+		b[20:],
+	)
+}
+
+// parseSimplePacket parses a simple packet block, which has no timestamp
+// and belongs to the first interface.
+func (r *Reader) parseSimplePacket(b []byte) (*Packet, error) {
+	if len(b) < 4 || len(r.ifaces) == 0 {
+		return nil, errInvalidPacket
+	}
+
+	orig := r.order.Uint32(b[0:4])
+	n := orig
+	if snap := r.ifaces[0].snapLen; snap != 0 && n > snap {
+		n = snap
+	}
+
+	return &Packet{
+		LinkType: r.ifaces[0].linkType,
+		Data:     append([]byte(nil), b[4:4+min(int(n), len(b)-4)]...),
+		Length:   int(orig),
+	}, nil
+}
+
+// packet creates a Packet captured on interface id at timestamp ts.
+func (r *Reader) packet(id uint32, ts uint64, n, orig uint32, b []byte) (*Packet, error) {
+	if int(id) >= len(r.ifaces) || int(n) > len(b) {
+		return nil, errInvalidPacket
+	}
+	ifi := r.ifaces[id]
+
+	// Convert the fraction of a second to nanoseconds without overflow.
+	hi, lo := bits.Mul64(ts%ifi.tps, 1e9)
+	nsec, _ := bits.Div64(hi, lo, ifi.tps)
+
+	return &Packet{
+		Time:     time.Unix(int64(ts/ifi.tps), int64(nsec)).UTC(),
+		LinkType: ifi.linkType,
+		Data:     append([]byte(nil), b[:n]...),
+		Length:   int(orig),
+	}, nil
+}
+
+// unexpectedEOF converts io.EOF in the middle of a record to
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
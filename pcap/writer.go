@@ -0,0 +1,58 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// snapLen is the snapshot length recorded in pcap files written by a Writer.
+const snapLen = maxPacket
+
+// A Writer writes packets to a pcap file with microsecond timestamps.
+type Writer struct {
+	w        io.Writer
+	linkType LinkType
+}
+
+// NewWriter creates a Writer which writes a pcap file of packets with link
+// type lt to w, and writes the file header.
+func NewWriter(w io.Writer, lt LinkType) (*Writer, error) {
+	var h [24]byte
+	binary.LittleEndian.PutUint32(h[0:4], magicMicro)
+	binary.LittleEndian.PutUint16(h[4:6], 2)
+	binary.LittleEndian.PutUint16(h[6:8], 4)
+	binary.LittleEndian.PutUint32(h[16:20], snapLen)
+	binary.LittleEndian.PutUint32(h[20:24], uint32(lt))
+
+	if _, err := w.Write(h[:]); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:        w,
+		linkType: lt,
+	}, nil
+}
+
+// WritePacket writes a packet.  Its LinkType must match the Writer's.  If
+// Length is zero, the length of Data is used.
+func (w *Writer) WritePacket(p *Packet) error {
+	if p.LinkType != w.linkType || len(p.Data) > snapLen || (p.Length != 0 && p.Length < len(p.Data)) {
+		return errInvalidPacket
+	}
+
+	n := p.Length
+	if n == 0 {
+		n = len(p.Data)
+	}
+
+	ns := p.Time.UnixNano()
+	b := make([]byte, 16, 16+len(p.Data))
+	binary.LittleEndian.PutUint32(b[0:4], uint32(ns/1e9))
+	binary.LittleEndian.PutUint32(b[4:8], uint32(ns%1e9/1e3))
+	binary.LittleEndian.PutUint32(b[8:12], uint32(len(p.Data)))
+	binary.LittleEndian.PutUint32(b[12:16], uint32(n))
+
+	_, err := w.w.Write(append(b, p.Data...))
+	return err
+}
@@ -0,0 +1,87 @@
+package pm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// Announcements counts the addresses announced by the peers of multipath TCP
+// connections, using EventAnnounced.  Unlike mptcp.Info.AddAddrAccepted, the
+// count does not depend on the add_addr_accepted limit, which is zero by
+// default, so it may be used to report announcements with mptcp.Prober.
+type Announcements struct {
+	mu     sync.Mutex
+	counts map[mptcp.Token]map[uint8]struct{}
+	err    error
+}
+
+// WatchAnnouncements counts the addresses announced to connections in the
+// network namespace of c until ctx is canceled.  Only addresses announced
+// after WatchAnnouncements returns are counted.
+func (c *Client) WatchAnnouncements(ctx context.Context) (*Announcements, error) {
+	events, err := c.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a := newAnnouncements()
+	go a.run(events)
+
+	return a, nil
+}
+
+// newAnnouncements creates an empty Announcements.
+func newAnnouncements() *Announcements {
+	return &Announcements{
+		counts: make(map[mptcp.Token]map[uint8]struct{}),
+	}
+}
+
+// run counts the announcements carried by events until the channel is
+// closed.
+func (a *Announcements) run(events <-chan Event) {
+	for e := range events {
+		a.mu.Lock()
+		switch e.Type {
+		case EventAnnounced:
+			ids, ok := a.counts[e.Token]
+			if !ok {
+				ids = make(map[uint8]struct{})
+				a.counts[e.Token] = ids
+			}
+
+			// Retransmitted announcements carry the same address ID.
+			ids[e.RemoteID] = struct{}{}
+		case EventRemoved:
+			delete(a.counts[e.Token], e.RemoteID)
+		case EventClosed:
+			delete(a.counts, e.Token)
+		case EventError:
+			a.err = e.Err
+		}
+		a.mu.Unlock()
+	}
+}
+
+// Count returns the number of addresses currently announced by the peer of
+// the connection with local token t.  Connections are forgotten once they
+// are closed, so Count must be called while the connection is open.
+//
+// Count has the signature of mptcp.Prober.Announced.
+func (a *Announcements) Count(t mptcp.Token) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return len(a.counts[t])
+}
+
+// Err returns the error which stopped the delivery of events, if any, after
+// which announcements are no longer counted.
+func (a *Announcements) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.err
+}
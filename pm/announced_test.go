@@ -0,0 +1,60 @@
+package pm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// TestAnnouncementsCount verifies that Announcements counts the distinct
+// addresses announced to each open connection.
+func TestAnnouncementsCount(t *testing.T) {
+	const (
+		a mptcp.Token = 0x11111111
+		b mptcp.Token = 0x22222222
+		c mptcp.Token = 0x33333333
+	)
+
+	errRecv := errors.New("receive failed")
+
+	events := make(chan Event, 16)
+	for _, e := range []Event{
+		{Type: EventCreated, Token: a},
+		{Type: EventAnnounced, Token: a, RemoteID: 1},
+		{Type: EventAnnounced, Token: a, RemoteID: 2},
+		{Type: EventAnnounced, Token: a, RemoteID: 1},
+		{Type: EventAnnounced, Token: b, RemoteID: 1},
+		{Type: EventAnnounced, Token: b, RemoteID: 2},
+		{Type: EventRemoved, Token: b, RemoteID: 1},
+		{Type: EventAnnounced, Token: c, RemoteID: 1},
+		{Type: EventClosed, Token: c},
+		{Type: EventError, Err: errRecv},
+	} {
+		events <- e
+	}
+	close(events)
+
+	an := newAnnouncements()
+	an.run(events)
+
+	var tests = []struct {
+		t mptcp.Token
+		n int
+	}{
+		{a, 2},
+		{b, 1},
+		{c, 0},
+		{0x44444444, 0},
+	}
+
+	for i, test := range tests {
+		if n := an.Count(test.t); n != test.n {
+			t.Fatalf("[%02d] unexpected count for token %s: %d != %d", i, test.t, n, test.n)
+		}
+	}
+
+	if err := an.Err(); !errors.Is(err, errRecv) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package pm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A State is the configuration of the in-kernel path manager.
+type State struct {
+	// Limits are the per-connection limits.  When a State is used as the
+	// desired state, nil Limits are left unchanged.
+	Limits *Limits
+
+	// Endpoints are the configured endpoints.  Endpoints with FlagImplicit
+	// are created by the kernel, and are ignored.
+	Endpoints []Endpoint
+}
+
+// A ChangeKind is the kind of operation performed by a Change.
+type ChangeKind int
+
+// Possible ChangeKind values.
+const (
+	ChangeDeleteEndpoint ChangeKind = iota
+	ChangeAddEndpoint
+	ChangeSetEndpointFlags
+	ChangeSetLimits
+)
+
+// A Change is a single operation which moves the path manager towards a
+// desired State.
+type Change struct {
+	// Kind is the kind of operation.
+	Kind ChangeKind
+
+	// Endpoint is the endpoint to delete, add, or change, for all kinds
+	// except ChangeSetLimits.
+	Endpoint Endpoint
+
+	// Limits are the limits to set for ChangeSetLimits.
+	Limits Limits
+}
+
+// String returns a description of a Change, in a form similar to the
+// arguments of "ip mptcp".
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeDeleteEndpoint:
+		return "endpoint delete " + formatEndpoint(c.Endpoint)
+	case ChangeAddEndpoint:
+		return "endpoint add " + formatEndpoint(c.Endpoint)
+	case ChangeSetEndpointFlags:
+		return fmt.Sprintf("endpoint change id %d %s %s", c.Endpoint.ID,
+			formatFlag(c.Endpoint.Flags, FlagBackup, "backup"),
+			formatFlag(c.Endpoint.Flags, FlagFullmesh, "fullmesh"))
+	case ChangeSetLimits:
+		return fmt.Sprintf("limits set subflows %d add_addr_accepted %d",
+			c.Limits.Subflows, c.Limits.AddAddrAccepted)
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(c.Kind))
+	}
+}
+
+// formatEndpoint formats the non-zero fields of e.
+func formatEndpoint(e Endpoint) string {
+	var b strings.Builder
+	if e.ID != 0 {
+		fmt.Fprintf(&b, "id %d ", e.ID)
+	}
+	fmt.Fprintf(&b, "%s", e.Address)
+	if e.Port != 0 {
+		fmt.Fprintf(&b, " port %d", e.Port)
+	}
+	if e.Interface != 0 {
+		fmt.Fprintf(&b, " dev %d", e.Interface)
+	}
+	if e.Flags != 0 {
+		fmt.Fprintf(&b, " %s", e.Flags)
+	}
+
+	return b.String()
+}
+
+// formatFlag formats whether flag is set in f, using the "name" and "noname"
+// form of "ip mptcp endpoint change".
+func formatFlag(f, flag Flags, name string) string {
+	if f&flag == 0 {
+		return "no" + name
+	}
+
+	return name
+}
+
+// State returns the current configuration of the in-kernel path manager.
+func (c *Client) State() (*State, error) {
+	l, err := c.Limits()
+	if err != nil {
+		return nil, err
+	}
+
+	es, err := c.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	return &State{
+		Limits:    l,
+		Endpoints: es,
+	}, nil
+}
+
+// Plan compares the current configuration of the in-kernel path manager with
+// desired, and returns the Changes which Apply must perform to reach the
+// desired State.  If the configuration already matches, Plan returns no
+// Changes.
+func (c *Client) Plan(desired State) ([]Change, error) {
+	current, err := c.State()
+	if err != nil {
+		return nil, err
+	}
+
+	return Diff(*current, desired)
+}
+
+// Apply performs changes in order, stopping at the first error.
+func (c *Client) Apply(changes []Change) error {
+	for _, ch := range changes {
+		var err error
+		switch ch.Kind {
+		case ChangeDeleteEndpoint:
+			err = c.DeleteEndpoint(ch.Endpoint.ID)
+		case ChangeAddEndpoint:
+			err = c.AddEndpoint(ch.Endpoint)
+		case ChangeSetEndpointFlags:
+			err = c.SetEndpointFlags(ch.Endpoint.ID, ch.Endpoint.Flags&settableFlags)
+		case ChangeSetLimits:
+			err = c.SetLimits(ch.Limits)
+		default:
+			err = fmt.Errorf("pm: unknown change kind %d", ch.Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("pm: failed to apply %q: %w", ch, err)
+		}
+	}
+
+	return nil
+}
+
+// Diff returns the minimal Changes needed to move the path manager from the
+// current State to the desired State.
+//
+// Endpoints are matched by address and port.  Endpoints whose backup or
+// fullmesh flags differ are changed in place, and endpoints whose ID,
+// interface, or other flags differ are deleted and added again, because the
+// kernel cannot change them.  A desired endpoint with a zero ID matches a
+// current endpoint with any ID.  Deletions are ordered first, so that
+// addresses and IDs are free before endpoints are added, and endpoints with
+// an ID are added before endpoints whose ID is assigned by the kernel.
+func Diff(current, desired State) ([]Change, error) {
+	if err := checkState(desired); err != nil {
+		return nil, err
+	}
+
+	// IDs requested by desired endpoints may not be kept by other
+	// endpoints.
+	claimed := make(map[uint8]bool)
+	for _, d := range desired.Endpoints {
+		if d.ID != 0 {
+			claimed[d.ID] = true
+		}
+	}
+
+	var (
+		deletes, adds, sets []Change
+		matched             = make(map[int]bool)
+	)
+
+	for _, d := range desired.Endpoints {
+		i := findEndpoint(current.Endpoints, d)
+		if i == -1 {
+			adds = append(adds, Change{Kind: ChangeAddEndpoint, Endpoint: d})
+			continue
+		}
+		matched[i] = true
+
+		cur := current.Endpoints[i]
+		switch {
+		case d.ID != 0 && d.ID != cur.ID,
+			d.ID == 0 && claimed[cur.ID],
+			d.Interface != cur.Interface,
+			d.Flags&^settableFlags != cur.Flags&^(settableFlags|FlagImplicit):
+			deletes = append(deletes, Change{Kind: ChangeDeleteEndpoint, Endpoint: cur})
+			adds = append(adds, Change{Kind: ChangeAddEndpoint, Endpoint: d})
+		case d.Flags&settableFlags != cur.Flags&settableFlags:
+			cur.Flags = cur.Flags&^settableFlags | d.Flags&settableFlags
+			sets = append(sets, Change{Kind: ChangeSetEndpointFlags, Endpoint: cur})
+		}
+	}
+
+	for i, cur := range current.Endpoints {
+		if !matched[i] && cur.Flags&FlagImplicit == 0 {
+			deletes = append(deletes, Change{Kind: ChangeDeleteEndpoint, Endpoint: cur})
+		}
+	}
+
+	// The kernel may assign an ID requested by a later endpoint to an
+	// endpoint added without one, so endpoints with IDs are added first.
+	sort.SliceStable(adds, func(i, j int) bool {
+		return adds[i].Endpoint.ID != 0 && adds[j].Endpoint.ID == 0
+	})
+
+	changes := append(append(deletes, adds...), sets...)
+
+	if desired.Limits != nil && (current.Limits == nil || *desired.Limits != *current.Limits) {
+		changes = append(changes, Change{Kind: ChangeSetLimits, Limits: *desired.Limits})
+	}
+
+	return changes, nil
+}
+
+// findEndpoint returns the index of the endpoint in es with the same address
+// and port as e, or -1 if none exists.
+func findEndpoint(es []Endpoint, e Endpoint) int {
+	for i, c := range es {
+		if c.Flags&FlagImplicit == 0 && c.Address.Equal(e.Address) && c.Port == e.Port {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// checkState verifies that a desired State can be applied.
+func checkState(s State) error {
+	if l := s.Limits; l != nil && (l.Subflows < 0 || l.AddAddrAccepted < 0) {
+		return fmt.Errorf("pm: invalid limits: %+v", *l)
+	}
+
+	ids := make(map[uint8]bool)
+	for i, e := range s.Endpoints {
+		if e.Address == nil {
+			return fmt.Errorf("pm: endpoint %d has no address", i)
+		}
+		if e.Flags&FlagImplicit != 0 {
+			return fmt.Errorf("pm: endpoint %s may not use the implicit flag", e.Address)
+		}
+
+		if e.ID != 0 {
+			if ids[e.ID] {
+				return fmt.Errorf("pm: duplicate endpoint ID %d", e.ID)
+			}
+			ids[e.ID] = true
+		}
+
+		if findEndpoint(s.Endpoints[:i], e) != -1 {
+			return fmt.Errorf("pm: duplicate endpoint address %s port %d", e.Address, e.Port)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package pm
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// TestDiff verifies that Diff produces the minimal changes needed to reach a
+// desired state, and no changes once it is reached.
+func TestDiff(t *testing.T) {
+	var (
+		ip1 = net.IPv4(192, 0, 2, 1).To4()
+		ip2 = net.IPv4(192, 0, 2, 2).To4()
+		ip3 = net.ParseIP("2001:db8::1")
+
+		e1 = Endpoint{ID: 1, Address: ip1, Interface: 2, Flags: FlagSubflow}
+		e2 = Endpoint{ID: 2, Address: ip2, Flags: FlagSignal}
+		e3 = Endpoint{ID: 3, Address: ip3, Flags: FlagSubflow | FlagBackup}
+
+		implicit = Endpoint{ID: 4, Address: net.IPv4(192, 0, 2, 4).To4(), Flags: FlagImplicit}
+		limits   = &Limits{Subflows: 2, AddAddrAccepted: 2}
+	)
+
+	var tests = []struct {
+		desc             string
+		current, desired State
+		changes          []Change
+		ok               bool
+	}{
+		{
+			desc: "empty",
+			ok:   true,
+		},
+		{
+			desc:    "up to date",
+			current: State{Limits: limits, Endpoints: []Endpoint{e1, e2, implicit}},
+			desired: State{Limits: limits, Endpoints: []Endpoint{e2, e1}},
+			ok:      true,
+		},
+		{
+			desc:    "up to date, automatic IDs",
+			current: State{Endpoints: []Endpoint{e1, e2}},
+			desired: State{Endpoints: []Endpoint{
+				{Address: ip1, Interface: 2, Flags: FlagSubflow},
+				{Address: ip2, Flags: FlagSignal},
+			}},
+			ok: true,
+		},
+		{
+			desc:    "add, delete, set limits",
+			current: State{Limits: &Limits{}, Endpoints: []Endpoint{e1, implicit}},
+			desired: State{Limits: limits, Endpoints: []Endpoint{e2, e3}},
+			changes: []Change{
+				{Kind: ChangeDeleteEndpoint, Endpoint: e1},
+				{Kind: ChangeAddEndpoint, Endpoint: e2},
+				{Kind: ChangeAddEndpoint, Endpoint: e3},
+				{Kind: ChangeSetLimits, Limits: *limits},
+			},
+			ok: true,
+		},
+		{
+			desc:    "set flags",
+			current: State{Endpoints: []Endpoint{e1, e3}},
+			desired: State{Endpoints: []Endpoint{
+				{ID: 1, Address: ip1, Interface: 2, Flags: FlagSubflow | FlagBackup | FlagFullmesh},
+				{Address: ip3, Flags: FlagSubflow},
+			}},
+			changes: []Change{
+				{Kind: ChangeSetEndpointFlags, Endpoint: Endpoint{
+					ID: 1, Address: ip1, Interface: 2, Flags: FlagSubflow | FlagBackup | FlagFullmesh,
+				}},
+				{Kind: ChangeSetEndpointFlags, Endpoint: Endpoint{
+					ID: 3, Address: ip3, Flags: FlagSubflow,
+				}},
+			},
+			ok: true,
+		},
+		{
+			desc:    "replace",
+			current: State{Endpoints: []Endpoint{e1, e2, e3}},
+			desired: State{Endpoints: []Endpoint{
+				// Changed ID
+				{ID: 5, Address: ip1, Interface: 2, Flags: FlagSubflow},
+				// Changed flags which cannot be set
+				{ID: 2, Address: ip2, Flags: FlagSubflow},
+				// Changed interface
+				{ID: 3, Address: ip3, Interface: 3, Flags: FlagSubflow | FlagBackup},
+			}},
+			changes: []Change{
+				{Kind: ChangeDeleteEndpoint, Endpoint: e1},
+				{Kind: ChangeDeleteEndpoint, Endpoint: e2},
+				{Kind: ChangeDeleteEndpoint, Endpoint: e3},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{ID: 5, Address: ip1, Interface: 2, Flags: FlagSubflow}},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{ID: 2, Address: ip2, Flags: FlagSubflow}},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{ID: 3, Address: ip3, Interface: 3, Flags: FlagSubflow | FlagBackup}},
+			},
+			ok: true,
+		},
+		{
+			desc:    "claimed ID",
+			current: State{Endpoints: []Endpoint{e1}},
+			desired: State{Endpoints: []Endpoint{
+				{Address: ip1, Interface: 2, Flags: FlagSubflow},
+				{ID: 1, Address: ip2, Flags: FlagSignal},
+			}},
+			changes: []Change{
+				{Kind: ChangeDeleteEndpoint, Endpoint: e1},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{ID: 1, Address: ip2, Flags: FlagSignal}},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{Address: ip1, Interface: 2, Flags: FlagSubflow}},
+			},
+			ok: true,
+		},
+		{
+			desc: "explicit IDs before automatic IDs",
+			desired: State{Endpoints: []Endpoint{
+				{Address: ip1, Flags: FlagSubflow},
+				{ID: 1, Address: ip2, Flags: FlagSignal},
+				{Address: ip3, Flags: FlagSubflow},
+				{ID: 3, Address: net.IPv4(192, 0, 2, 3).To4(), Flags: FlagSignal},
+			}},
+			changes: []Change{
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{ID: 1, Address: ip2, Flags: FlagSignal}},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{ID: 3, Address: net.IPv4(192, 0, 2, 3).To4(), Flags: FlagSignal}},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{Address: ip1, Flags: FlagSubflow}},
+				{Kind: ChangeAddEndpoint, Endpoint: Endpoint{Address: ip3, Flags: FlagSubflow}},
+			},
+			ok: true,
+		},
+		{
+			desc:    "duplicate ID",
+			desired: State{Endpoints: []Endpoint{e1, {ID: 1, Address: ip2}}},
+		},
+		{
+			desc:    "duplicate address",
+			desired: State{Endpoints: []Endpoint{e1, {Address: ip1}}},
+		},
+		{
+			desc:    "no address",
+			desired: State{Endpoints: []Endpoint{{ID: 1}}},
+		},
+		{
+			desc:    "implicit",
+			desired: State{Endpoints: []Endpoint{implicit}},
+		},
+		{
+			desc:    "negative limits",
+			desired: State{Limits: &Limits{Subflows: -1}},
+		},
+	}
+
+	for i, test := range tests {
+		changes, err := Diff(test.current, test.desired)
+		if err != nil && test.ok {
+			t.Fatalf("[%02d] %s: unexpected error: %v", i, test.desc, err)
+		}
+		if err == nil && !test.ok {
+			t.Fatalf("[%02d] %s: expected an error, but none occurred", i, test.desc)
+		}
+
+		if !reflect.DeepEqual(changes, test.changes) {
+			t.Fatalf("[%02d] %s: unexpected changes:\n- want: %v\n-  got: %v", i, test.desc, test.changes, changes)
+		}
+	}
+}
+
+// TestChangeString verifies that Changes are described in a form similar to
+// the arguments of "ip mptcp".
+func TestChangeString(t *testing.T) {
+	var tests = []struct {
+		c Change
+		s string
+	}{
+		{
+			c: Change{Kind: ChangeAddEndpoint, Endpoint: Endpoint{
+				ID: 1, Address: net.IPv4(192, 0, 2, 1), Port: 8080, Interface: 2, Flags: FlagSignal,
+			}},
+			s: "endpoint add id 1 192.0.2.1 port 8080 dev 2 signal",
+		},
+		{
+			c: Change{Kind: ChangeDeleteEndpoint, Endpoint: Endpoint{ID: 2, Address: net.ParseIP("2001:db8::1")}},
+			s: "endpoint delete id 2 2001:db8::1",
+		},
+		{
+			c: Change{Kind: ChangeSetEndpointFlags, Endpoint: Endpoint{ID: 3, Flags: FlagSubflow | FlagBackup}},
+			s: "endpoint change id 3 backup nofullmesh",
+		},
+		{
+			c: Change{Kind: ChangeSetLimits, Limits: Limits{Subflows: 2, AddAddrAccepted: 1}},
+			s: "limits set subflows 2 add_addr_accepted 1",
+		},
+	}
+
+	for i, test := range tests {
+		if s := test.c.String(); s != test.s {
+			t.Fatalf("[%02d] unexpected change string:\n- want: %q\n-  got: %q", i, test.s, s)
+		}
+	}
+}
+
+// TestClientApply verifies that Apply performs each change in order, and
+// stops at the first error.
+func TestClientApply(t *testing.T) {
+	ac := &applyClient{}
+	c := &Client{c: ac}
+
+	e := Endpoint{ID: 1, Address: net.IPv4(192, 0, 2, 1), Flags: FlagSubflow | FlagBackup}
+	changes := []Change{
+		{Kind: ChangeDeleteEndpoint, Endpoint: Endpoint{ID: 2}},
+		{Kind: ChangeAddEndpoint, Endpoint: e},
+		{Kind: ChangeSetEndpointFlags, Endpoint: e},
+		{Kind: ChangeSetLimits, Limits: Limits{Subflows: 2}},
+	}
+
+	if err := c.Apply(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"delete 2",
+		"add 1",
+		"set 1 backup",
+		"limits {Subflows:2 AddAddrAccepted:0}",
+	}
+	if !reflect.DeepEqual(ac.ops, want) {
+		t.Fatalf("unexpected operations:\n- want: %v\n-  got: %v", want, ac.ops)
+	}
+
+	if err := c.Apply([]Change{{Kind: ChangeSetLimits, Limits: Limits{Subflows: -1}}}); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+// An applyClient is an osClient which records endpoint and limits changes.
+// Other methods panic.
+type applyClient struct {
+	osClient
+	ops []string
+}
+
+func (c *applyClient) DeleteEndpoint(id uint8) error {
+	c.ops = append(c.ops, fmt.Sprintf("delete %d", id))
+	return nil
+}
+
+func (c *applyClient) AddEndpoint(e Endpoint) error {
+	c.ops = append(c.ops, fmt.Sprintf("add %d", e.ID))
+	return nil
+}
+
+func (c *applyClient) SetEndpointFlags(id uint8, f Flags) error {
+	c.ops = append(c.ops, fmt.Sprintf("set %d %s", id, f))
+	return nil
+}
+
+func (c *applyClient) SetLimits(l Limits) error {
+	c.ops = append(c.ops, fmt.Sprintf("limits %+v", l))
+	return nil
+}
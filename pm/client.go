@@ -0,0 +1,254 @@
+// Package pm provides access to the Linux kernel's multipath TCP path manager,
+// using the "mptcp_pm" generic netlink family.
+//
+// Package pm requires a mainline Linux kernel with multipath TCP support.
+// Most operations which modify path manager state require the CAP_NET_ADMIN
+// capability.
+//
+// When the userspace path manager is in use (net.mptcp.pm_type=1), a Client
+// may announce addresses and create or destroy subflows for each connection,
+// and Client.Serve invokes Handler callbacks as connection events occur, so a
+// path manager may be implemented in Go.
+package pm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+
+	"github.com/mdlayher/mptcp"
+)
+
+var (
+	// errUnimplemented is returned by all functions on platforms that
+	// do not have package pm implemented.
+	errUnimplemented = errors.New("pm: not implemented on this platform")
+)
+
+// A Client provides access to the Linux kernel's multipath TCP path manager.
+// Clients are safe for concurrent use.
+type Client struct {
+	c osClient
+}
+
+// Dial dials a new Client which manages the path manager of the current
+// network namespace.
+func Dial() (*Client, error) {
+	return DialNamespace("")
+}
+
+// DialNamespace dials a new Client which manages the path manager of the
+// named network namespace, as created by "ip netns add".  If name is empty,
+// the current network namespace is used.
+func DialNamespace(name string) (*Client, error) {
+	c, err := newClient(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		c: c,
+	}, nil
+}
+
+// netnsDir is the directory in which "ip netns" creates named network
+// namespaces.
+const netnsDir = "/run/netns"
+
+// NamespacePath returns the path of the named network namespace, as created
+// by "ip netns add".  An error is returned if name is empty or is not a
+// single path element, so that names which are valid for DialNamespace may be
+// checked before dialing.
+func NamespacePath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) {
+		return "", fmt.Errorf("pm: invalid network namespace name %q", name)
+	}
+
+	return filepath.Join(netnsDir, name), nil
+}
+
+// Close releases resources used by a Client.
+func (c *Client) Close() error {
+	return c.c.Close()
+}
+
+// Endpoints returns all endpoints configured for the in-kernel path manager.
+func (c *Client) Endpoints() ([]Endpoint, error) {
+	return c.c.Endpoints()
+}
+
+// Endpoint returns the endpoint with the specified address ID.  If no such
+// endpoint exists, an error which satisfies errors.Is(err, os.ErrNotExist)
+// is returned.
+func (c *Client) Endpoint(id uint8) (*Endpoint, error) {
+	return c.c.Endpoint(id)
+}
+
+// AddEndpoint adds a new endpoint to the in-kernel path manager.  If the
+// endpoint's ID is zero, the kernel assigns an ID automatically.
+func (c *Client) AddEndpoint(e Endpoint) error {
+	return c.c.AddEndpoint(e)
+}
+
+// DeleteEndpoint deletes the endpoint with the specified address ID.
+func (c *Client) DeleteEndpoint(id uint8) error {
+	return c.c.DeleteEndpoint(id)
+}
+
+// FlushEndpoints deletes all endpoints configured for the in-kernel path
+// manager.
+func (c *Client) FlushEndpoints() error {
+	return c.c.FlushEndpoints()
+}
+
+// Limits returns the per-connection limits of the in-kernel path manager.
+func (c *Client) Limits() (*Limits, error) {
+	return c.c.Limits()
+}
+
+// SetLimits sets the per-connection limits of the in-kernel path manager.
+func (c *Client) SetLimits(l Limits) error {
+	if l.Subflows < 0 || l.AddAddrAccepted < 0 {
+		return fmt.Errorf("pm: invalid limits: %+v", l)
+	}
+
+	return c.c.SetLimits(l)
+}
+
+// SetEndpointFlags sets the flags of the endpoint with the specified address
+// ID.  Only FlagBackup and FlagFullmesh may be changed: each is set if present
+// in f, and cleared otherwise.  Changing FlagBackup causes the kernel to send
+// MP_PRIO on existing subflows which use the endpoint.
+func (c *Client) SetEndpointFlags(id uint8, f Flags) error {
+	if id == 0 {
+		return fmt.Errorf("pm: invalid endpoint ID %d", id)
+	}
+	if err := checkSettableFlags(f); err != nil {
+		return err
+	}
+
+	return c.c.SetEndpointFlags(id, f)
+}
+
+// SetSubflowFlags sets the flags of a single subflow of the connection
+// identified by token, which may be the LocalToken of an mptcp.Entry or the
+// Token of an mptcp.Info.  Setting or clearing FlagBackup causes the kernel to
+// send MP_PRIO to the peer, to change the subflow's priority.
+//
+// The kernel only permits changing the flags of connections managed by the
+// userspace path manager.
+func (c *Client) SetSubflowFlags(token mptcp.Token, s Subflow, f Flags) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if err := checkSettableFlags(f); err != nil {
+		return err
+	}
+
+	return c.c.SetSubflowFlags(token, s, f)
+}
+
+// Announce announces a local address to the peer of the connection identified
+// by token, using ADD_ADDR.  The endpoint must have a non-zero ID, which the
+// peer uses to refer to the address, and FlagSignal is always set.
+//
+// Announce, Remove, CreateSubflow and DestroySubflow may only be used with
+// connections managed by the userspace path manager.
+func (c *Client) Announce(token mptcp.Token, e Endpoint) error {
+	if e.ID == 0 {
+		return fmt.Errorf("pm: invalid endpoint ID %d", e.ID)
+	}
+
+	e.Flags |= FlagSignal
+	return c.c.Announce(token, e)
+}
+
+// Remove withdraws the local address with the specified ID from the
+// connection identified by token, using REMOVE_ADDR, and closes any subflows
+// which use it.
+func (c *Client) Remove(token mptcp.Token, id uint8) error {
+	if id == 0 {
+		return fmt.Errorf("pm: invalid endpoint ID %d", id)
+	}
+
+	return c.c.Remove(token, id)
+}
+
+// CreateSubflow creates a new subflow for the connection identified by token,
+// from the local endpoint to the remote address.  The local endpoint's ID
+// identifies its address to the peer, and FlagBackup may be set to create a
+// backup subflow.
+func (c *Client) CreateSubflow(token mptcp.Token, local Endpoint, remote *net.TCPAddr) error {
+	if err := (Subflow{
+		Local:  &net.TCPAddr{IP: local.Address, Port: local.Port},
+		Remote: remote,
+	}).check(); err != nil {
+		return err
+	}
+	if local.Flags&FlagSignal != 0 {
+		return fmt.Errorf("pm: subflow endpoint may not use the signal flag: %s", local.Flags)
+	}
+
+	return c.c.CreateSubflow(token, local, remote)
+}
+
+// DestroySubflow closes a single subflow of the connection identified by
+// token.  Both addresses of the subflow must include a port.
+func (c *Client) DestroySubflow(token mptcp.Token, s Subflow) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if s.Local.Port == 0 {
+		return fmt.Errorf("pm: subflow requires a local port: %s", s)
+	}
+
+	return c.c.DestroySubflow(token, s)
+}
+
+// Events subscribes to path manager events for all multipath TCP connections
+// in the current network namespace.  Events are delivered on the returned
+// channel until ctx is canceled, at which point the channel is closed.
+//
+// If the kernel drops events because they are not received quickly enough,
+// an Event with type EventLost is delivered and the subscription continues.
+// If receiving events fails, an Event with type EventError is delivered
+// before the channel is closed.
+func (c *Client) Events(ctx context.Context) (<-chan Event, error) {
+	return c.c.Events(ctx)
+}
+
+// settableFlags are the Flags which may be changed after an endpoint is
+// created.
+const settableFlags = FlagBackup | FlagFullmesh
+
+// checkSettableFlags verifies that f contains only flags which may be changed
+// after an endpoint is created.
+func checkSettableFlags(f Flags) error {
+	if f&^settableFlags != 0 {
+		return fmt.Errorf("pm: only backup and fullmesh flags may be changed: %s", f)
+	}
+
+	return nil
+}
+
+// An osClient is the operating system-specific implementation of Client.
+type osClient interface {
+	io.Closer
+	Endpoints() ([]Endpoint, error)
+	Endpoint(id uint8) (*Endpoint, error)
+	AddEndpoint(e Endpoint) error
+	DeleteEndpoint(id uint8) error
+	FlushEndpoints() error
+	Limits() (*Limits, error)
+	SetLimits(l Limits) error
+	SetEndpointFlags(id uint8, f Flags) error
+	SetSubflowFlags(token mptcp.Token, s Subflow, f Flags) error
+	Announce(token mptcp.Token, e Endpoint) error
+	Remove(token mptcp.Token, id uint8) error
+	CreateSubflow(token mptcp.Token, local Endpoint, remote *net.TCPAddr) error
+	DestroySubflow(token mptcp.Token, s Subflow) error
+	Events(ctx context.Context) (<-chan Event, error)
+}
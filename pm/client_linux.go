@@ -0,0 +1,462 @@
+// +build linux
+
+package pm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/internal/pmevents"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// familyName is the name of the MPTCP path manager generic netlink
+	// family.
+	familyName = pmevents.Family
+)
+
+// Path manager commands, from enum mptcp_pm_cmds.
+const (
+	cmdAddAddr    = 1
+	cmdDelAddr    = 2
+	cmdGetAddr    = 3
+	cmdFlushAddrs = 4
+	cmdSetLimits  = 5
+	cmdGetLimits  = 6
+	cmdSetFlags   = 7
+	cmdAnnounce   = 8
+	cmdRemove     = 9
+	cmdSubCreate  = 10
+	cmdSubDestroy = 11
+)
+
+// Path manager request attributes, from enum mptcp_pm_attrs.
+const (
+	attrAddr        = 1
+	attrRcvAddAddrs = 2
+	attrSubflows    = 3
+	attrToken       = 4
+	attrLocID       = 5
+	attrAddrRemote  = 6
+)
+
+// Path manager address attributes, from enum mptcp_pm_addr_attrs.
+const (
+	addrAttrFamily = 1
+	addrAttrID     = 2
+	addrAttrAddr4  = 3
+	addrAttrAddr6  = 4
+	addrAttrPort   = 5
+	addrAttrFlags  = 6
+	addrAttrIfIdx  = 7
+)
+
+var (
+	// Ensure that client implements osClient.
+	_ osClient = &client{}
+
+	// errInvalidEndpoint is returned when an endpoint cannot be encoded
+	// or decoded.
+	errInvalidEndpoint = errors.New("pm: invalid endpoint")
+)
+
+// A client is a Linux-specific MPTCP path manager client.
+type client struct {
+	c      *genetlink.Conn
+	family genetlink.Family
+
+	// dial opens additional connections, which receive events.
+	dial func() (*genetlink.Conn, error)
+}
+
+// newClient opens a connection to the mptcp_pm generic netlink family in the
+// named network namespace, or the current namespace if netns is empty.
+func newClient(netns string) (*client, error) {
+	dial := func() (*genetlink.Conn, error) {
+		return dialNetNS(netns)
+	}
+
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := initClient(c)
+	if err != nil {
+		return nil, err
+	}
+	cc.dial = dial
+
+	return cc, nil
+}
+
+// dialNetNS dials a generic netlink connection in the named network
+// namespace, or the current namespace if netns is empty.
+func dialNetNS(netns string) (*genetlink.Conn, error) {
+	if netns == "" {
+		return genetlink.Dial(nil)
+	}
+
+	path, err := NamespacePath(netns)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// The socket remains in the namespace after f is closed
+	defer f.Close()
+
+	return genetlink.Dial(&netlink.Config{NetNS: int(f.Fd())})
+}
+
+// initClient is the internal constructor for a client, used in tests.
+func initClient(c *genetlink.Conn) (*client, error) {
+	family, err := c.GetFamily(familyName)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	return &client{
+		c:      c,
+		family: family,
+		dial: func() (*genetlink.Conn, error) {
+			return genetlink.Dial(nil)
+		},
+	}, nil
+}
+
+// Close implements osClient.
+func (c *client) Close() error {
+	return c.c.Close()
+}
+
+// Endpoints implements osClient.
+func (c *client) Endpoints() ([]Endpoint, error) {
+	msgs, err := c.execute(cmdGetAddr, netlink.Dump, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEndpoints(msgs)
+}
+
+// Endpoint implements osClient.
+func (c *client) Endpoint(id uint8) (*Endpoint, error) {
+	// The kernel reports a missing endpoint using EINVAL, which cannot be
+	// distinguished from other errors, so search a dump instead
+	es, err := c.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range es {
+		if e.ID == id {
+			return &e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("pm: endpoint %d: %w", id, os.ErrNotExist)
+}
+
+// AddEndpoint implements osClient.
+func (c *client) AddEndpoint(e Endpoint) error {
+	_, err := c.execute(cmdAddAddr, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Nested(attrAddr, encodeEndpoint(e))
+		return nil
+	})
+	return err
+}
+
+// DeleteEndpoint implements osClient.
+func (c *client) DeleteEndpoint(id uint8) error {
+	_, err := c.execute(cmdDelAddr, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Nested(attrAddr, func(nae *netlink.AttributeEncoder) error {
+			nae.Uint8(addrAttrID, id)
+			return nil
+		})
+		return nil
+	})
+	return err
+}
+
+// FlushEndpoints implements osClient.
+func (c *client) FlushEndpoints() error {
+	_, err := c.execute(cmdFlushAddrs, netlink.Acknowledge, nil)
+	return err
+}
+
+// Limits implements osClient.
+func (c *client) Limits() (*Limits, error) {
+	msgs, err := c.execute(cmdGetLimits, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("pm: expected 1 limits message, but got %d", len(msgs))
+	}
+
+	ad, err := netlink.NewAttributeDecoder(msgs[0].Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var l Limits
+	for ad.Next() {
+		switch ad.Type() {
+		case attrRcvAddAddrs:
+			l.AddAddrAccepted = int(ad.Uint32())
+		case attrSubflows:
+			l.Subflows = int(ad.Uint32())
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// SetLimits implements osClient.
+func (c *client) SetLimits(l Limits) error {
+	_, err := c.execute(cmdSetLimits, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Uint32(attrRcvAddAddrs, uint32(l.AddAddrAccepted))
+		ae.Uint32(attrSubflows, uint32(l.Subflows))
+		return nil
+	})
+	return err
+}
+
+// SetEndpointFlags implements osClient.
+func (c *client) SetEndpointFlags(id uint8, f Flags) error {
+	_, err := c.execute(cmdSetFlags, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Nested(attrAddr, func(nae *netlink.AttributeEncoder) error {
+			nae.Uint8(addrAttrID, id)
+			nae.Uint32(addrAttrFlags, uint32(f))
+			return nil
+		})
+		return nil
+	})
+	return err
+}
+
+// SetSubflowFlags implements osClient.
+func (c *client) SetSubflowFlags(token mptcp.Token, s Subflow, f Flags) error {
+	_, err := c.execute(cmdSetFlags, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Uint32(attrToken, uint32(token))
+		ae.Nested(attrAddr, func(nae *netlink.AttributeEncoder) error {
+			if err := encodeTCPAddr(nae, s.Local); err != nil {
+				return err
+			}
+
+			// Flags must always be sent, so backup can be cleared
+			nae.Uint32(addrAttrFlags, uint32(f))
+			return nil
+		})
+		ae.Nested(attrAddrRemote, func(nae *netlink.AttributeEncoder) error {
+			return encodeTCPAddr(nae, s.Remote)
+		})
+		return nil
+	})
+	return err
+}
+
+// Announce implements osClient.
+func (c *client) Announce(token mptcp.Token, e Endpoint) error {
+	_, err := c.execute(cmdAnnounce, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Uint32(attrToken, uint32(token))
+		ae.Nested(attrAddr, encodeEndpoint(e))
+		return nil
+	})
+	return err
+}
+
+// Remove implements osClient.
+func (c *client) Remove(token mptcp.Token, id uint8) error {
+	_, err := c.execute(cmdRemove, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Uint32(attrToken, uint32(token))
+		ae.Uint8(attrLocID, id)
+		return nil
+	})
+	return err
+}
+
+// CreateSubflow implements osClient.
+func (c *client) CreateSubflow(token mptcp.Token, local Endpoint, remote *net.TCPAddr) error {
+	_, err := c.execute(cmdSubCreate, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Uint32(attrToken, uint32(token))
+		ae.Nested(attrAddr, encodeEndpoint(local))
+		ae.Nested(attrAddrRemote, func(nae *netlink.AttributeEncoder) error {
+			return encodeTCPAddr(nae, remote)
+		})
+		return nil
+	})
+	return err
+}
+
+// DestroySubflow implements osClient.
+func (c *client) DestroySubflow(token mptcp.Token, s Subflow) error {
+	_, err := c.execute(cmdSubDestroy, netlink.Acknowledge, func(ae *netlink.AttributeEncoder) error {
+		ae.Uint32(attrToken, uint32(token))
+		ae.Nested(attrAddr, func(nae *netlink.AttributeEncoder) error {
+			return encodeTCPAddr(nae, s.Local)
+		})
+		ae.Nested(attrAddrRemote, func(nae *netlink.AttributeEncoder) error {
+			return encodeTCPAddr(nae, s.Remote)
+		})
+		return nil
+	})
+	return err
+}
+
+// execute executes a path manager command with attributes produced by fn,
+// which may be nil if the command requires no attributes.
+func (c *client) execute(
+	cmd uint8,
+	flags netlink.HeaderFlags,
+	fn func(ae *netlink.AttributeEncoder) error,
+) ([]genetlink.Message, error) {
+	var b []byte
+	if fn != nil {
+		ae := netlink.NewAttributeEncoder()
+		if err := fn(ae); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if b, err = ae.Encode(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.c.Execute(
+		genetlink.Message{
+			Header: genetlink.Header{
+				Command: cmd,
+				Version: c.family.Version,
+			},
+			Data: b,
+		},
+		c.family.ID,
+		netlink.Request|flags,
+	)
+}
+
+// parseEndpoints parses Endpoints from generic netlink messages.
+func parseEndpoints(msgs []genetlink.Message) ([]Endpoint, error) {
+	es := make([]Endpoint, 0, len(msgs))
+	for _, m := range msgs {
+		ad, err := netlink.NewAttributeDecoder(m.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		for ad.Next() {
+			if ad.Type() != attrAddr {
+				continue
+			}
+
+			var e Endpoint
+			ad.Nested(decodeEndpoint(&e))
+			es = append(es, e)
+		}
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return es, nil
+}
+
+// encodeEndpoint encodes an Endpoint as nested address attributes.
+func encodeEndpoint(e Endpoint) func(ae *netlink.AttributeEncoder) error {
+	return func(ae *netlink.AttributeEncoder) error {
+		if err := encodeIP(ae, e.Address); err != nil {
+			return err
+		}
+
+		if e.ID != 0 {
+			ae.Uint8(addrAttrID, e.ID)
+		}
+		if e.Port != 0 {
+			ae.Uint16(addrAttrPort, uint16(e.Port))
+		}
+		if e.Interface != 0 {
+			ae.Int32(addrAttrIfIdx, int32(e.Interface))
+		}
+		if e.Flags != 0 {
+			ae.Uint32(addrAttrFlags, uint32(e.Flags))
+		}
+
+		return nil
+	}
+}
+
+// encodeIP encodes the family and address attributes for an IP address.
+func encodeIP(ae *netlink.AttributeEncoder, ip net.IP) error {
+	if ip4 := ip.To4(); ip4 != nil {
+		ae.Uint16(addrAttrFamily, unix.AF_INET)
+		ae.Bytes(addrAttrAddr4, ip4)
+		return nil
+	}
+
+	if ip6 := ip.To16(); ip6 != nil {
+		ae.Uint16(addrAttrFamily, unix.AF_INET6)
+		ae.Bytes(addrAttrAddr6, ip6)
+		return nil
+	}
+
+	return fmt.Errorf("%w: IP address %q", errInvalidEndpoint, ip)
+}
+
+// encodeTCPAddr encodes the family, address and port attributes for a TCP
+// address.  The port is omitted if it is zero.
+func encodeTCPAddr(ae *netlink.AttributeEncoder, addr *net.TCPAddr) error {
+	if err := encodeIP(ae, addr.IP); err != nil {
+		return err
+	}
+
+	if addr.Port != 0 {
+		ae.Uint16(addrAttrPort, uint16(addr.Port))
+	}
+
+	return nil
+}
+
+// decodeEndpoint decodes nested address attributes into e.
+func decodeEndpoint(e *Endpoint) func(ad *netlink.AttributeDecoder) error {
+	return func(ad *netlink.AttributeDecoder) error {
+		for ad.Next() {
+			switch ad.Type() {
+			case addrAttrID:
+				e.ID = ad.Uint8()
+			case addrAttrAddr4, addrAttrAddr6:
+				ad.Do(func(b []byte) error {
+					if len(b) != net.IPv4len && len(b) != net.IPv6len {
+						return fmt.Errorf("%w: %d byte IP address", errInvalidEndpoint, len(b))
+					}
+
+					e.Address = net.IP(append([]byte(nil), b...))
+					return nil
+				})
+			case addrAttrPort:
+				e.Port = int(ad.Uint16())
+			case addrAttrFlags:
+				e.Flags = Flags(ad.Uint32())
+			case addrAttrIfIdx:
+				e.Interface = int(ad.Int32())
+			}
+		}
+
+		return nil
+	}
+}
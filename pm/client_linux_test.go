@@ -0,0 +1,458 @@
+// +build linux
+
+package pm
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/genetlink/genltest"
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// testFamily is the generic netlink family served by testClient.
+var testFamily = genetlink.Family{
+	ID:      20,
+	Version: 1,
+	Name:    familyName,
+}
+
+// Endpoints used for testing
+var (
+	testEndpointIPv4 = Endpoint{
+		ID:        1,
+		Address:   net.IPv4(192, 0, 2, 1).To4(),
+		Interface: 2,
+		Flags:     FlagSubflow | FlagBackup,
+	}
+
+	testEndpointIPv6 = Endpoint{
+		ID:      2,
+		Address: net.ParseIP("2001:db8::1"),
+		Port:    8080,
+		Flags:   FlagSignal,
+	}
+)
+
+// TestLinux_clientFamilyNotFound verifies that initClient returns an error
+// when the mptcp_pm family is not available.
+func TestLinux_clientFamilyNotFound(t *testing.T) {
+	conn := genltest.Dial(func(_ genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		return nil, genltest.Error(int(unix.ENOENT))
+	})
+
+	_, err := initClient(conn)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error, but got: %v", err)
+	}
+}
+
+// TestLinux_clientEndpoints verifies that Endpoints dumps and parses all
+// endpoints.
+func TestLinux_clientEndpoints(t *testing.T) {
+	c := testClient(t, genltest.CheckRequest(testFamily.ID, cmdGetAddr, netlink.Request|netlink.Dump,
+		func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			if len(greq.Data) != 0 {
+				t.Fatalf("unexpected request attributes: %v", greq.Data)
+			}
+
+			return []genetlink.Message{
+				mustEndpointMessage(t, testEndpointIPv4),
+				mustEndpointMessage(t, testEndpointIPv6),
+			}, nil
+		},
+	))
+
+	es, err := c.Endpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Endpoint{testEndpointIPv4, testEndpointIPv6}; !reflect.DeepEqual(es, want) {
+		t.Fatalf("unexpected endpoints:\n- want: %+v\n-  got: %+v", want, es)
+	}
+
+	e, err := c.Endpoint(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*e, testEndpointIPv6) {
+		t.Fatalf("unexpected endpoint:\n- want: %+v\n-  got: %+v", testEndpointIPv6, *e)
+	}
+
+	if _, err := c.Endpoint(3); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error, but got: %v", err)
+	}
+}
+
+// TestLinux_clientAddEndpoint verifies that AddEndpoint encodes endpoints
+// in the format expected by the kernel.
+func TestLinux_clientAddEndpoint(t *testing.T) {
+	for i, want := range []Endpoint{testEndpointIPv4, testEndpointIPv6} {
+		c := testClient(t, genltest.CheckRequest(testFamily.ID, cmdAddAddr, netlink.Request|netlink.Acknowledge,
+			func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+				es, err := parseEndpoints([]genetlink.Message{greq})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(es, []Endpoint{want}) {
+					t.Fatalf("[%02d] unexpected endpoint:\n- want: %+v\n-  got: %+v", i, want, es)
+				}
+
+				return nil, io.EOF
+			},
+		))
+
+		if err := c.AddEndpoint(want); err != nil {
+			t.Fatalf("[%02d] failed to add endpoint: %v", i, err)
+		}
+	}
+}
+
+// TestLinux_clientAddEndpointInvalid verifies that AddEndpoint rejects an
+// endpoint without a valid address.
+func TestLinux_clientAddEndpointInvalid(t *testing.T) {
+	c := testClient(t, func(_ genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		t.Fatal("no request should be sent")
+		return nil, nil
+	})
+
+	if err := c.AddEndpoint(Endpoint{ID: 1}); !errors.Is(err, errInvalidEndpoint) {
+		t.Fatalf("expected invalid endpoint error, but got: %v", err)
+	}
+}
+
+// TestLinux_clientDeleteEndpoint verifies that DeleteEndpoint deletes an
+// endpoint by its address ID.
+func TestLinux_clientDeleteEndpoint(t *testing.T) {
+	c := testClient(t, genltest.CheckRequest(testFamily.ID, cmdDelAddr, netlink.Request|netlink.Acknowledge,
+		func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			es, err := parseEndpoints([]genetlink.Message{greq})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := []Endpoint{{ID: 3}}; !reflect.DeepEqual(es, want) {
+				t.Fatalf("unexpected endpoint:\n- want: %+v\n-  got: %+v", want, es)
+			}
+
+			return nil, io.EOF
+		},
+	))
+
+	if err := c.DeleteEndpoint(3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLinux_clientFlushEndpoints verifies that FlushEndpoints sends a flush
+// command, and returns kernel errors.
+func TestLinux_clientFlushEndpoints(t *testing.T) {
+	c := testClient(t, genltest.CheckRequest(testFamily.ID, cmdFlushAddrs, netlink.Request|netlink.Acknowledge,
+		func(_ genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			return nil, genltest.Error(int(unix.EPERM))
+		},
+	))
+
+	if err := c.FlushEndpoints(); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected permission error, but got: %v", err)
+	}
+}
+
+// TestLinux_clientLimits verifies that Limits and SetLimits encode and decode
+// limits in the format used by the kernel.
+func TestLinux_clientLimits(t *testing.T) {
+	want := Limits{
+		Subflows:        4,
+		AddAddrAccepted: 2,
+	}
+
+	c := testClient(t, func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch greq.Header.Command {
+		case cmdGetLimits:
+			ae := netlink.NewAttributeEncoder()
+			ae.Uint32(attrRcvAddAddrs, 2)
+			ae.Uint32(attrSubflows, 4)
+
+			b, err := ae.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return []genetlink.Message{{Data: b}}, nil
+		case cmdSetLimits:
+			attrs, err := netlink.UnmarshalAttributes(greq.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(attrs) != 2 || attrs[0].Type != attrRcvAddAddrs || attrs[1].Type != attrSubflows {
+				t.Fatalf("unexpected limits attributes: %v", attrs)
+			}
+
+			return nil, io.EOF
+		default:
+			t.Fatalf("unexpected command: %d", greq.Header.Command)
+			return nil, nil
+		}
+	})
+
+	l, err := c.Limits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*l, want) {
+		t.Fatalf("unexpected limits:\n- want: %+v\n-  got: %+v", want, *l)
+	}
+
+	if err := c.SetLimits(want); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLinux_clientSetEndpointFlags verifies that SetEndpointFlags sets the
+// flags of an endpoint by its address ID, including when clearing flags.
+func TestLinux_clientSetEndpointFlags(t *testing.T) {
+	for i, f := range []Flags{FlagBackup, FlagBackup | FlagFullmesh, 0} {
+		c := testClient(t, genltest.CheckRequest(testFamily.ID, cmdSetFlags, netlink.Request|netlink.Acknowledge,
+			func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+				attrs, err := netlink.UnmarshalAttributes(greq.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(attrs) != 1 || attrs[0].Type&^netlink.Nested != attrAddr {
+					t.Fatalf("[%02d] unexpected attributes: %v", i, attrs)
+				}
+
+				// Flags must be sent even when zero
+				nattrs, err := netlink.UnmarshalAttributes(attrs[0].Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(nattrs) != 2 {
+					t.Fatalf("[%02d] unexpected address attributes: %v", i, nattrs)
+				}
+
+				es, err := parseEndpoints([]genetlink.Message{greq})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := []Endpoint{{ID: 1, Flags: f}}; !reflect.DeepEqual(es, want) {
+					t.Fatalf("[%02d] unexpected endpoint:\n- want: %+v\n-  got: %+v", i, want, es)
+				}
+
+				return nil, io.EOF
+			},
+		))
+
+		if err := c.SetEndpointFlags(1, f); err != nil {
+			t.Fatalf("[%02d] failed to set flags: %v", i, err)
+		}
+	}
+}
+
+// TestLinux_clientSetSubflowFlags verifies that SetSubflowFlags identifies a
+// subflow by its connection token and addresses.
+func TestLinux_clientSetSubflowFlags(t *testing.T) {
+	s := Subflow{
+		Local:  &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4()},
+		Remote: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1).To4(), Port: 443},
+	}
+
+	c := testClient(t, genltest.CheckRequest(testFamily.ID, cmdSetFlags, netlink.Request|netlink.Acknowledge,
+		func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			ad, err := netlink.NewAttributeDecoder(greq.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				token         mptcp.Token
+				local, remote Endpoint
+			)
+			for ad.Next() {
+				switch ad.Type() {
+				case attrToken:
+					token = mptcp.Token(ad.Uint32())
+				case attrAddr:
+					ad.Nested(decodeEndpoint(&local))
+				case attrAddrRemote:
+					ad.Nested(decodeEndpoint(&remote))
+				default:
+					t.Fatalf("unexpected attribute: %d", ad.Type())
+				}
+			}
+			if err := ad.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if token != 0x9c290bf6 {
+				t.Fatalf("unexpected token: %v", token)
+			}
+			if want := (Endpoint{Address: s.Local.IP, Flags: FlagBackup}); !reflect.DeepEqual(local, want) {
+				t.Fatalf("unexpected local address:\n- want: %+v\n-  got: %+v", want, local)
+			}
+			if want := (Endpoint{Address: s.Remote.IP, Port: 443}); !reflect.DeepEqual(remote, want) {
+				t.Fatalf("unexpected remote address:\n- want: %+v\n-  got: %+v", want, remote)
+			}
+
+			return nil, io.EOF
+		},
+	))
+
+	if err := c.SetSubflowFlags(0x9c290bf6, s, FlagBackup); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLinux_clientUserspace verifies that the userspace path manager commands
+// are encoded with the connection's token and addresses.
+func TestLinux_clientUserspace(t *testing.T) {
+	const token = 0x9c290bf6
+
+	var (
+		local  = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 40000}
+		remote = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1).To4(), Port: 443}
+	)
+
+	var tests = []struct {
+		desc          string
+		cmd           uint8
+		fn            func(c *client) error
+		locID         uint8
+		local, remote Endpoint
+	}{
+		{
+			desc: "announce",
+			cmd:  cmdAnnounce,
+			fn: func(c *client) error {
+				return c.Announce(token, testEndpointIPv6)
+			},
+			local: testEndpointIPv6,
+		},
+		{
+			desc: "remove",
+			cmd:  cmdRemove,
+			fn: func(c *client) error {
+				return c.Remove(token, 2)
+			},
+			locID: 2,
+		},
+		{
+			desc: "create subflow",
+			cmd:  cmdSubCreate,
+			fn: func(c *client) error {
+				return c.CreateSubflow(token, testEndpointIPv4, remote)
+			},
+			local:  testEndpointIPv4,
+			remote: Endpoint{Address: remote.IP, Port: remote.Port},
+		},
+		{
+			desc: "destroy subflow",
+			cmd:  cmdSubDestroy,
+			fn: func(c *client) error {
+				return c.DestroySubflow(token, Subflow{Local: local, Remote: remote})
+			},
+			local:  Endpoint{Address: local.IP, Port: local.Port},
+			remote: Endpoint{Address: remote.IP, Port: remote.Port},
+		},
+	}
+
+	for i, test := range tests {
+		c := testClient(t, genltest.CheckRequest(testFamily.ID, test.cmd, netlink.Request|netlink.Acknowledge,
+			func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+				ad, err := netlink.NewAttributeDecoder(greq.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var (
+					tok           mptcp.Token
+					locID         uint8
+					local, remote Endpoint
+				)
+				for ad.Next() {
+					switch ad.Type() {
+					case attrToken:
+						tok = mptcp.Token(ad.Uint32())
+					case attrLocID:
+						locID = ad.Uint8()
+					case attrAddr:
+						ad.Nested(decodeEndpoint(&local))
+					case attrAddrRemote:
+						ad.Nested(decodeEndpoint(&remote))
+					default:
+						t.Fatalf("[%02d] %s: unexpected attribute: %d", i, test.desc, ad.Type())
+					}
+				}
+				if err := ad.Err(); err != nil {
+					t.Fatal(err)
+				}
+
+				if tok != token {
+					t.Fatalf("[%02d] %s: unexpected token: %v", i, test.desc, tok)
+				}
+				if locID != test.locID {
+					t.Fatalf("[%02d] %s: unexpected local ID: %d", i, test.desc, locID)
+				}
+				if !reflect.DeepEqual(local, test.local) {
+					t.Fatalf("[%02d] %s: unexpected local address:\n- want: %+v\n-  got: %+v", i, test.desc, test.local, local)
+				}
+				if !reflect.DeepEqual(remote, test.remote) {
+					t.Fatalf("[%02d] %s: unexpected remote address:\n- want: %+v\n-  got: %+v", i, test.desc, test.remote, remote)
+				}
+
+				return nil, io.EOF
+			},
+		))
+
+		if err := test.fn(c); err != nil {
+			t.Fatalf("[%02d] %s: %v", i, test.desc, err)
+		}
+	}
+}
+
+// testClient creates a client which communicates with an in-process
+// generic netlink server for the mptcp_pm family, using fn.
+func testClient(t *testing.T, fn genltest.Func) *client {
+	t.Helper()
+
+	c, err := initClient(genltest.Dial(genltest.ServeFamily(testFamily, fn)))
+	if err != nil {
+		t.Fatalf("failed to open client: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+// mustEndpointMessage encodes an Endpoint as a generic netlink message, in
+// the same format used by the kernel.
+func mustEndpointMessage(t *testing.T, e Endpoint) genetlink.Message {
+	t.Helper()
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Nested(attrAddr, encodeEndpoint(e))
+
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return genetlink.Message{
+		Header: genetlink.Header{
+			Command: cmdGetAddr,
+			Version: testFamily.Version,
+		},
+		Data: b,
+	}
+}
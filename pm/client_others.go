@@ -0,0 +1,98 @@
+// +build !linux
+
+package pm
+
+import (
+	"context"
+	"net"
+
+	"github.com/mdlayher/mptcp"
+)
+
+var (
+	// Ensure that client implements osClient.
+	_ osClient = &client{}
+)
+
+// A client is an unimplemented osClient.
+type client struct{}
+
+// newClient always returns an error.
+func newClient(_ string) (*client, error) {
+	return nil, errUnimplemented
+}
+
+// Close implements osClient.
+func (c *client) Close() error {
+	return errUnimplemented
+}
+
+// Endpoints implements osClient.
+func (c *client) Endpoints() ([]Endpoint, error) {
+	return nil, errUnimplemented
+}
+
+// Endpoint implements osClient.
+func (c *client) Endpoint(_ uint8) (*Endpoint, error) {
+	return nil, errUnimplemented
+}
+
+// AddEndpoint implements osClient.
+func (c *client) AddEndpoint(_ Endpoint) error {
+	return errUnimplemented
+}
+
+// DeleteEndpoint implements osClient.
+func (c *client) DeleteEndpoint(_ uint8) error {
+	return errUnimplemented
+}
+
+// FlushEndpoints implements osClient.
+func (c *client) FlushEndpoints() error {
+	return errUnimplemented
+}
+
+// Limits implements osClient.
+func (c *client) Limits() (*Limits, error) {
+	return nil, errUnimplemented
+}
+
+// SetLimits implements osClient.
+func (c *client) SetLimits(_ Limits) error {
+	return errUnimplemented
+}
+
+// SetEndpointFlags implements osClient.
+func (c *client) SetEndpointFlags(_ uint8, _ Flags) error {
+	return errUnimplemented
+}
+
+// SetSubflowFlags implements osClient.
+func (c *client) SetSubflowFlags(_ mptcp.Token, _ Subflow, _ Flags) error {
+	return errUnimplemented
+}
+
+// Announce implements osClient.
+func (c *client) Announce(_ mptcp.Token, _ Endpoint) error {
+	return errUnimplemented
+}
+
+// Remove implements osClient.
+func (c *client) Remove(_ mptcp.Token, _ uint8) error {
+	return errUnimplemented
+}
+
+// CreateSubflow implements osClient.
+func (c *client) CreateSubflow(_ mptcp.Token, _ Endpoint, _ *net.TCPAddr) error {
+	return errUnimplemented
+}
+
+// DestroySubflow implements osClient.
+func (c *client) DestroySubflow(_ mptcp.Token, _ Subflow) error {
+	return errUnimplemented
+}
+
+// Events implements osClient.
+func (c *client) Events(_ context.Context) (<-chan Event, error) {
+	return nil, errUnimplemented
+}
@@ -0,0 +1,52 @@
+package pm
+
+import "testing"
+
+// TestNamespacePath verifies that NamespacePath accepts only names which are
+// a single path element.
+func TestNamespacePath(t *testing.T) {
+	var tests = []struct {
+		name string
+		path string
+		ok   bool
+	}{
+		{
+			name: "blue",
+			path: "/run/netns/blue",
+			ok:   true,
+		},
+		{
+			name: "",
+		},
+		{
+			name: ".",
+		},
+		{
+			name: "..",
+		},
+		{
+			name: "a/b",
+		},
+		{
+			name: "../b",
+		},
+		{
+			name: "/proc/1/ns/net",
+		},
+	}
+
+	for i, tt := range tests {
+		path, err := NamespacePath(tt.name)
+		if err != nil && tt.ok {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.name, err)
+		}
+		if err == nil && !tt.ok {
+			t.Fatalf("[%02d] test %q, expected an error, but none occurred", i, tt.name)
+		}
+
+		if want, got := tt.path, path; want != got {
+			t.Fatalf("[%02d] test %q, unexpected path:\n- want: %q\n-  got: %q",
+				i, tt.name, want, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package pm
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// An Endpoint is a local address which the in-kernel path manager may use
+// for multipath TCP connections, as configured by "ip mptcp endpoint".
+type Endpoint struct {
+	// ID is the address ID of the endpoint.  When adding an endpoint, an
+	// ID of zero requests that the kernel assign one automatically.
+	ID uint8
+
+	// Address is the IPv4 or IPv6 address of the endpoint.
+	Address net.IP
+
+	// Port is an optional port for the endpoint.  It may only be set for
+	// endpoints with FlagSignal.
+	Port int
+
+	// Interface is the optional index of the network interface to which
+	// subflows using the endpoint are bound.
+	Interface int
+
+	// Flags controls how the path manager uses the endpoint.
+	Flags Flags
+}
+
+// Flags are flags which control how the path manager uses an Endpoint.
+type Flags uint32
+
+// Possible Flags values, which match the values used by the Linux kernel.
+const (
+	// FlagSignal announces the endpoint to peers using ADD_ADDR.
+	FlagSignal Flags = 1 << iota
+
+	// FlagSubflow creates additional subflows from the endpoint to peers.
+	FlagSubflow
+
+	// FlagBackup marks subflows using the endpoint as backup subflows.
+	FlagBackup
+
+	// FlagFullmesh creates subflows from the endpoint to every address
+	// announced by peers.
+	FlagFullmesh
+
+	// FlagImplicit is set by the kernel for endpoints it creates
+	// automatically.
+	FlagImplicit
+)
+
+// flagNames are the names of each Flags value, in the same form used by
+// "ip mptcp endpoint".
+var flagNames = []struct {
+	f    Flags
+	name string
+}{
+	{FlagSignal, "signal"},
+	{FlagSubflow, "subflow"},
+	{FlagBackup, "backup"},
+	{FlagFullmesh, "fullmesh"},
+	{FlagImplicit, "implicit"},
+}
+
+// String returns the names of all flags set in f, separated by '|'.
+func (f Flags) String() string {
+	if f == 0 {
+		return "0"
+	}
+
+	var names []string
+	for _, n := range flagNames {
+		if f&n.f != 0 {
+			names = append(names, n.name)
+			f &^= n.f
+		}
+	}
+
+	// Report any unknown flags in hex
+	if f != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint32(f)))
+	}
+
+	return strings.Join(names, "|")
+}
+
+// ParseFlags parses Flags from the names of one or more flags, in the form
+// produced by Flags.String.  Flag names may also be separated by commas or
+// spaces, as accepted by "ip mptcp endpoint".
+func ParseFlags(s string) (Flags, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '|' || r == ',' || r == ' '
+	})
+
+	var f Flags
+	for _, field := range fields {
+		if v, err := strconv.ParseUint(field, 0, 32); err == nil {
+			f |= Flags(v)
+			continue
+		}
+
+		var ok bool
+		for _, n := range flagNames {
+			if field == n.name {
+				f |= n.f
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return 0, fmt.Errorf("pm: unknown endpoint flag %q", field)
+		}
+	}
+
+	return f, nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f Flags) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *Flags) UnmarshalText(b []byte) error {
+	v, err := ParseFlags(string(b))
+	if err != nil {
+		return err
+	}
+
+	*f = v
+	return nil
+}
@@ -0,0 +1,80 @@
+package pm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestFlagsString verifies that Flags.String produces the names of all flags
+// which are set.
+func TestFlagsString(t *testing.T) {
+	var tests = []struct {
+		f Flags
+		s string
+	}{
+		{0, "0"},
+		{FlagSignal, "signal"},
+		{FlagSubflow | FlagBackup, "subflow|backup"},
+		{FlagSignal | FlagSubflow | FlagBackup | FlagFullmesh | FlagImplicit, "signal|subflow|backup|fullmesh|implicit"},
+		{FlagBackup | 1<<8, "backup|0x100"},
+	}
+
+	for i, test := range tests {
+		if s := test.f.String(); s != test.s {
+			t.Fatalf("[%02d] unexpected flags string: %q != %q", i, s, test.s)
+		}
+	}
+}
+
+// TestParseFlags verifies that ParseFlags parses the output of Flags.String,
+// and the forms accepted by "ip mptcp endpoint".
+func TestParseFlags(t *testing.T) {
+	var tests = []struct {
+		s  string
+		f  Flags
+		ok bool
+	}{
+		{"", 0, true},
+		{"0", 0, true},
+		{"signal", FlagSignal, true},
+		{"subflow|backup", FlagSubflow | FlagBackup, true},
+		{"subflow backup,fullmesh", FlagSubflow | FlagBackup | FlagFullmesh, true},
+		{"backup|0x100", FlagBackup | 1<<8, true},
+		{"subflow|foo", 0, false},
+	}
+
+	for i, test := range tests {
+		f, err := ParseFlags(test.s)
+		if err != nil && test.ok {
+			t.Fatalf("[%02d] unexpected error: %v", i, err)
+		}
+		if err == nil && !test.ok {
+			t.Fatalf("[%02d] expected an error, but none occurred", i)
+		}
+
+		if f != test.f {
+			t.Fatalf("[%02d] unexpected flags: %s != %s", i, f, test.f)
+		}
+	}
+}
+
+// TestFlagsJSON verifies that Flags are encoded in JSON using their names.
+func TestFlagsJSON(t *testing.T) {
+	want := FlagSubflow | FlagBackup
+
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `"subflow|backup"` {
+		t.Fatalf("unexpected JSON: %s", s)
+	}
+
+	var f Flags
+	if err := json.Unmarshal(b, &f); err != nil {
+		t.Fatal(err)
+	}
+	if f != want {
+		t.Fatalf("unexpected flags: %s != %s", f, want)
+	}
+}
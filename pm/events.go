@@ -0,0 +1,119 @@
+package pm
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/internal/pmevents"
+)
+
+// An EventType is the type of a path manager Event.
+type EventType uint8
+
+// Possible EventType values, from enum mptcp_event_type.
+const (
+	EventCreated            EventType = pmevents.Created
+	EventEstablished        EventType = pmevents.Established
+	EventClosed             EventType = pmevents.Closed
+	EventAnnounced          EventType = pmevents.Announced
+	EventRemoved            EventType = pmevents.Removed
+	EventSubflowEstablished EventType = pmevents.SubflowEstablished
+	EventSubflowClosed      EventType = pmevents.SubflowClosed
+	EventSubflowPriority    EventType = pmevents.SubflowPriority
+	EventListenerCreated    EventType = pmevents.ListenerCreated
+	EventListenerClosed     EventType = pmevents.ListenerClosed
+)
+
+// EventType values which are not sent by the kernel, but are produced by
+// Client.Events to report problems with the subscription itself.
+const (
+	// EventLost indicates that the kernel dropped one or more events
+	// because they were not received quickly enough.  Events continue to
+	// be delivered after EventLost.
+	EventLost EventType = 254
+
+	// EventError indicates that receiving events failed, and is always the
+	// final event before the channel is closed.  The Event's Err field
+	// contains the error.
+	EventError EventType = 255
+)
+
+// String returns the string representation of an EventType.
+func (t EventType) String() string {
+	switch t {
+	case EventCreated:
+		return "CREATED"
+	case EventEstablished:
+		return "ESTABLISHED"
+	case EventClosed:
+		return "CLOSED"
+	case EventAnnounced:
+		return "ANNOUNCED"
+	case EventRemoved:
+		return "REMOVED"
+	case EventSubflowEstablished:
+		return "SUB_ESTABLISHED"
+	case EventSubflowClosed:
+		return "SUB_CLOSED"
+	case EventSubflowPriority:
+		return "SUB_PRIORITY"
+	case EventListenerCreated:
+		return "LISTENER_CREATED"
+	case EventListenerClosed:
+		return "LISTENER_CLOSED"
+	case EventLost:
+		return "LOST"
+	case EventError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("EventType(%d)", uint8(t))
+	}
+}
+
+// An Event is a notification from the kernel's path manager about a change
+// to a multipath TCP connection, subflow, or listener.  Fields which are not
+// carried by an event's type are left as their zero value.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+
+	// Token is the local token of the connection.
+	Token mptcp.Token
+
+	// Local and Remote are the local and remote addresses of the
+	// connection or subflow.  For EventAnnounced, Remote is the address
+	// announced by the peer, and for listener events, Local is the
+	// listening address.
+	Local  *net.TCPAddr
+	Remote *net.TCPAddr
+
+	// LocalID and RemoteID are the local and remote address IDs.
+	LocalID  uint8
+	RemoteID uint8
+
+	// Backup reports whether a subflow is a backup subflow.
+	Backup bool
+
+	// Error is the error which caused a subflow to close, if any.
+	Error syscall.Errno
+
+	// Interface is the index of the network interface used by a subflow.
+	Interface int
+
+	// ServerSide reports whether the connection was accepted by a
+	// listener, rather than dialed.
+	ServerSide bool
+
+	// Flags contains raw event flags, from MPTCP_PM_EV_FLAG_*.
+	Flags uint16
+
+	// ResetReason and ResetFlags are the MP_TCPRST reason and flags
+	// received from the peer when a subflow is reset.
+	ResetReason uint32
+	ResetFlags  uint32
+
+	// Err is set only for EventError.
+	Err error
+}
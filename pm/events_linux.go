@@ -0,0 +1,185 @@
+// +build linux
+
+package pm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/internal/pmevents"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// eventsBuffer is the capacity of the channel returned by Events.
+const eventsBuffer = 64
+
+// errInvalidEvent is returned when an event cannot be decoded.
+var errInvalidEvent = errors.New("pm: invalid event")
+
+// Events implements osClient.
+func (c *client) Events(ctx context.Context) (<-chan Event, error) {
+	// Events are received on their own connection, so they are never
+	// interleaved with replies to requests made using c.
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	stop, err := pmevents.Subscribe(ctx, conn, c.family)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("pm: %w", err)
+	}
+
+	ch := make(chan Event, eventsBuffer)
+	go func() {
+		defer func() {
+			stop()
+			_ = conn.Close()
+		}()
+
+		receiveEvents(ctx, conn, ch)
+	}()
+
+	return ch, nil
+}
+
+// A receiver receives generic netlink messages.
+type receiver interface {
+	Receive() ([]genetlink.Message, []netlink.Message, error)
+}
+
+// receiveEvents receives events from r and sends them on ch until ctx is
+// canceled or a fatal error occurs, and then closes ch.
+func receiveEvents(ctx context.Context, r receiver, ch chan<- Event) {
+	defer close(ch)
+
+	send := func(e Event) bool {
+		select {
+		case ch <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		msgs, _, err := r.Receive()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, unix.ENOBUFS):
+			// The socket's receive buffer overflowed and events were
+			// dropped, but the subscription remains usable
+			if !send(Event{Type: EventLost}) {
+				return
+			}
+			continue
+		case err != nil:
+			send(Event{Type: EventError, Err: err})
+			return
+		}
+
+		for _, m := range msgs {
+			e, err := parseEvent(m)
+			if err != nil {
+				send(Event{Type: EventError, Err: err})
+				return
+			}
+
+			if !send(*e) {
+				return
+			}
+		}
+	}
+}
+
+// parseEvent parses an Event from a generic netlink message.
+func parseEvent(m genetlink.Message) (*Event, error) {
+	ad, err := netlink.NewAttributeDecoder(m.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	e := Event{Type: EventType(m.Header.Command)}
+
+	// Ports are stored separately, because their address attributes
+	// may be absent
+	var sport, dport int
+	for ad.Next() {
+		switch ad.Type() {
+		case pmevents.AttrToken:
+			e.Token = mptcp.Token(ad.Uint32())
+		case pmevents.AttrLocID:
+			e.LocalID = ad.Uint8()
+		case pmevents.AttrRemID:
+			e.RemoteID = ad.Uint8()
+		case pmevents.AttrSaddr4, pmevents.AttrSaddr6:
+			ad.Do(decodeEventAddr(&e.Local))
+		case pmevents.AttrDaddr4, pmevents.AttrDaddr6:
+			ad.Do(decodeEventAddr(&e.Remote))
+		case pmevents.AttrSport:
+			ad.Do(decodeEventPort(&sport))
+		case pmevents.AttrDport:
+			ad.Do(decodeEventPort(&dport))
+		case pmevents.AttrBackup:
+			e.Backup = ad.Uint8() != 0
+		case pmevents.AttrError:
+			e.Error = syscall.Errno(ad.Uint8())
+		case pmevents.AttrFlags:
+			e.Flags = ad.Uint16()
+		case pmevents.AttrIfIdx:
+			e.Interface = int(ad.Int32())
+		case pmevents.AttrResetReason:
+			e.ResetReason = ad.Uint32()
+		case pmevents.AttrResetFlags:
+			e.ResetFlags = ad.Uint32()
+		case pmevents.AttrServerSide:
+			e.ServerSide = ad.Uint8() != 0
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+
+	if e.Local != nil {
+		e.Local.Port = sport
+	}
+	if e.Remote != nil {
+		e.Remote.Port = dport
+	}
+
+	return &e, nil
+}
+
+// decodeEventAddr decodes a raw IPv4 or IPv6 address attribute into addr.
+func decodeEventAddr(addr **net.TCPAddr) func(b []byte) error {
+	return func(b []byte) error {
+		if len(b) != net.IPv4len && len(b) != net.IPv6len {
+			return fmt.Errorf("%w: %d byte IP address", errInvalidEvent, len(b))
+		}
+
+		*addr = &net.TCPAddr{IP: net.IP(append([]byte(nil), b...))}
+		return nil
+	}
+}
+
+// decodeEventPort decodes a port attribute, which is stored in network byte
+// order, into port.
+func decodeEventPort(port *int) func(b []byte) error {
+	return func(b []byte) error {
+		if len(b) != 2 {
+			return fmt.Errorf("%w: %d byte port", errInvalidEvent, len(b))
+		}
+
+		*port = int(binary.BigEndian.Uint16(b))
+		return nil
+	}
+}
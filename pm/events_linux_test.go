@@ -0,0 +1,244 @@
+// +build linux
+
+package pm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/mptcp/internal/pmevents"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// TestLinux_parseEvent verifies that parseEvent decodes the attributes sent by
+// the kernel for each kind of event.
+func TestLinux_parseEvent(t *testing.T) {
+	var tests = []struct {
+		desc string
+		t    EventType
+		fn   func(ae *netlink.AttributeEncoder)
+		e    *Event
+		ok   bool
+	}{
+		{
+			desc: "created IPv4",
+			t:    EventCreated,
+			fn: func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(pmevents.AttrToken, 0xdeadbeef)
+				ae.Uint16(pmevents.AttrFamily, unix.AF_INET)
+				ae.Bytes(pmevents.AttrSaddr4, []byte{192, 0, 2, 1})
+				ae.Bytes(pmevents.AttrDaddr4, []byte{192, 0, 2, 2})
+				ae.Bytes(pmevents.AttrSport, []byte{0x9c, 0x40})
+				ae.Bytes(pmevents.AttrDport, []byte{0x00, 0x50})
+				ae.Uint8(pmevents.AttrServerSide, 1)
+			},
+			e: &Event{
+				Type:       EventCreated,
+				Token:      0xdeadbeef,
+				Local:      &net.TCPAddr{IP: net.IP{192, 0, 2, 1}, Port: 40000},
+				Remote:     &net.TCPAddr{IP: net.IP{192, 0, 2, 2}, Port: 80},
+				ServerSide: true,
+			},
+			ok: true,
+		},
+		{
+			desc: "announced IPv6",
+			t:    EventAnnounced,
+			fn: func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(pmevents.AttrToken, 1)
+				ae.Uint8(pmevents.AttrRemID, 2)
+				ae.Bytes(pmevents.AttrDaddr6, net.ParseIP("2001:db8::2"))
+				ae.Bytes(pmevents.AttrDport, []byte{0x1f, 0x90})
+			},
+			e: &Event{
+				Type:     EventAnnounced,
+				Token:    1,
+				RemoteID: 2,
+				Remote:   &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 8080},
+			},
+			ok: true,
+		},
+		{
+			desc: "subflow closed",
+			t:    EventSubflowClosed,
+			fn: func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(pmevents.AttrToken, 1)
+				ae.Uint8(pmevents.AttrLocID, 1)
+				ae.Uint8(pmevents.AttrRemID, 0)
+				ae.Bytes(pmevents.AttrSaddr4, []byte{192, 0, 2, 1})
+				ae.Bytes(pmevents.AttrDaddr4, []byte{192, 0, 2, 2})
+				ae.Bytes(pmevents.AttrSport, []byte{0x9c, 0x40})
+				ae.Bytes(pmevents.AttrDport, []byte{0x00, 0x50})
+				ae.Uint8(pmevents.AttrBackup, 1)
+				ae.Uint8(pmevents.AttrError, uint8(unix.ECONNRESET))
+				ae.Int32(pmevents.AttrIfIdx, 2)
+				ae.Uint32(pmevents.AttrResetReason, 1)
+			},
+			e: &Event{
+				Type:        EventSubflowClosed,
+				Token:       1,
+				LocalID:     1,
+				Local:       &net.TCPAddr{IP: net.IP{192, 0, 2, 1}, Port: 40000},
+				Remote:      &net.TCPAddr{IP: net.IP{192, 0, 2, 2}, Port: 80},
+				Backup:      true,
+				Error:       syscall.ECONNRESET,
+				Interface:   2,
+				ResetReason: 1,
+			},
+			ok: true,
+		},
+		{
+			desc: "bad address",
+			t:    EventCreated,
+			fn: func(ae *netlink.AttributeEncoder) {
+				ae.Bytes(pmevents.AttrSaddr4, []byte{192, 0, 2})
+			},
+		},
+		{
+			desc: "bad port",
+			t:    EventCreated,
+			fn: func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(pmevents.AttrSport, 80)
+			},
+		},
+	}
+
+	for i, test := range tests {
+		e, err := parseEvent(mustEventMessage(t, test.t, test.fn))
+		if err != nil && test.ok {
+			t.Fatalf("[%02d] %s: unexpected error: %v", i, test.desc, err)
+		}
+		if err == nil && !test.ok {
+			t.Fatalf("[%02d] %s: expected an error, but none occurred", i, test.desc)
+		}
+
+		if !reflect.DeepEqual(e, test.e) {
+			t.Fatalf("[%02d] %s: unexpected event:\n- want: %+v\n-  got: %+v", i, test.desc, test.e, e)
+		}
+	}
+}
+
+// TestLinux_receiveEvents verifies that receiveEvents reports dropped events
+// and continues, and reports fatal errors before closing its channel.
+func TestLinux_receiveEvents(t *testing.T) {
+	created := mustEventMessage(t, EventCreated, func(ae *netlink.AttributeEncoder) {
+		ae.Uint32(pmevents.AttrToken, 1)
+	})
+	closed := mustEventMessage(t, EventClosed, func(ae *netlink.AttributeEncoder) {
+		ae.Uint32(pmevents.AttrToken, 1)
+	})
+
+	errFatal := errors.New("fatal")
+	r := &testReceiver{
+		results: []testReceive{
+			{msgs: []genetlink.Message{created}},
+			{err: &netlink.OpError{Op: "receive", Err: os.NewSyscallError("recvmsg", unix.ENOBUFS)}},
+			{msgs: []genetlink.Message{closed}},
+			{err: errFatal},
+		},
+	}
+
+	ch := make(chan Event, eventsBuffer)
+	receiveEvents(context.Background(), r, ch)
+
+	var types []EventType
+	var last Event
+	for e := range ch {
+		types = append(types, e.Type)
+		last = e
+	}
+
+	if want := []EventType{EventCreated, EventLost, EventClosed, EventError}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("unexpected event types:\n- want: %v\n-  got: %v", want, types)
+	}
+	if !errors.Is(last.Err, errFatal) {
+		t.Fatalf("unexpected final error: %v", last.Err)
+	}
+}
+
+// TestLinux_receiveEventsCanceled verifies that receiveEvents returns without
+// blocking once its context is canceled, even if no one receives events.
+func TestLinux_receiveEventsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	created := mustEventMessage(t, EventCreated, nil)
+	r := &testReceiver{
+		results: []testReceive{
+			{msgs: []genetlink.Message{created, created}},
+		},
+	}
+
+	// Unbuffered, so the second send blocks until ctx is canceled
+	ch := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		receiveEvents(ctx, r, ch)
+	}()
+
+	if e := <-ch; e.Type != EventCreated {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+
+	cancel()
+	<-done
+
+	if _, ok := <-ch; ok {
+		t.Fatal("expected closed channel")
+	}
+}
+
+// A testReceive is the result of a single call to testReceiver.Receive.
+type testReceive struct {
+	msgs []genetlink.Message
+	err  error
+}
+
+// A testReceiver is a receiver which returns canned results, and then blocks
+// with an error once they are exhausted.
+type testReceiver struct {
+	results []testReceive
+}
+
+// Receive implements receiver.
+func (r *testReceiver) Receive() ([]genetlink.Message, []netlink.Message, error) {
+	if len(r.results) == 0 {
+		return nil, nil, os.ErrDeadlineExceeded
+	}
+
+	res := r.results[0]
+	r.results = r.results[1:]
+	return res.msgs, nil, res.err
+}
+
+// mustEventMessage encodes an event as a generic netlink message, using
+// attributes produced by fn, which may be nil.
+func mustEventMessage(t *testing.T, typ EventType, fn func(ae *netlink.AttributeEncoder)) genetlink.Message {
+	t.Helper()
+
+	ae := netlink.NewAttributeEncoder()
+	if fn != nil {
+		fn(ae)
+	}
+
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return genetlink.Message{
+		Header: genetlink.Header{
+			Command: uint8(typ),
+			Version: testFamily.Version,
+		},
+		Data: b,
+	}
+}
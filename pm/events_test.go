@@ -0,0 +1,27 @@
+package pm
+
+import (
+	"testing"
+)
+
+// TestEventTypeString verifies that EventType.String produces the kernel's
+// names for event types.
+func TestEventTypeString(t *testing.T) {
+	var tests = []struct {
+		t EventType
+		s string
+	}{
+		{EventCreated, "CREATED"},
+		{EventSubflowEstablished, "SUB_ESTABLISHED"},
+		{EventListenerClosed, "LISTENER_CLOSED"},
+		{EventLost, "LOST"},
+		{EventError, "ERROR"},
+		{4, "EventType(4)"},
+	}
+
+	for i, test := range tests {
+		if s := test.t.String(); s != test.s {
+			t.Fatalf("[%02d] unexpected event type string: %q != %q", i, s, test.s)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package pm
+
+import (
+	"errors"
+	"os"
+
+	"github.com/mdlayher/mptcp"
+)
+
+var (
+	// ErrDisabled is reported by Diagnose when multipath TCP is disabled.
+	ErrDisabled = errors.New("pm: multipath TCP is disabled")
+
+	// ErrZeroSubflows is reported by Diagnose when the subflows limit is
+	// zero, so connections never establish additional subflows.
+	ErrZeroSubflows = errors.New("pm: subflows limit is zero, so connections will only use their initial subflow")
+
+	// ErrZeroAddAddrAccepted is reported by Diagnose as an advisory when
+	// the add_addr_accepted limit is zero, so addresses announced by peers
+	// are ignored.
+	ErrZeroAddAddrAccepted = errors.New("pm: add_addr_accepted limit is zero, so addresses announced by peers will be ignored")
+
+	// ErrNoEndpoints is reported by Diagnose as an advisory when no
+	// endpoints are configured, so no additional local addresses are used.
+	ErrNoEndpoints = errors.New("pm: no endpoints are configured, so only the initial local address will be used")
+)
+
+// Limits are the per-connection limits of the in-kernel path manager, as
+// configured by "ip mptcp limits".
+type Limits struct {
+	// Subflows is the maximum number of additional subflows which may be
+	// created for each connection.
+	Subflows int
+
+	// AddAddrAccepted is the maximum number of addresses announced by a
+	// peer using ADD_ADDR which are accepted for each connection.
+	AddAddrAccepted int
+}
+
+// Diagnostics reports whether the in-kernel path manager is configured to
+// use more than one subflow for multipath TCP connections.
+type Diagnostics struct {
+	// Enabled is the result of mptcp.Enabled.
+	Enabled bool
+
+	// PMType is the type of path manager used for new connections.
+	PMType mptcp.PMType
+
+	// Limits and Endpoints are the configuration of the in-kernel path
+	// manager.
+	Limits    Limits
+	Endpoints []Endpoint
+
+	// Problems contains the configuration problems found, which may be
+	// compared with ErrDisabled and ErrZeroSubflows using errors.Is.
+	// Problems is empty if connections may use additional subflows.
+	Problems []error
+
+	// Advisories contains configuration which is correct for some hosts
+	// but prevents others from using additional subflows, which may be
+	// compared with ErrZeroAddAddrAccepted and ErrNoEndpoints using
+	// errors.Is.  A server typically ignores addresses announced by
+	// clients and has no endpoints, the kernel's defaults, while a client
+	// usually needs one or the other.
+	Advisories []error
+}
+
+// Diagnose checks whether multipath TCP is enabled, and whether the in-kernel
+// path manager's limits and endpoints allow connections to use additional
+// subflows.  Zero limits are a common misconfiguration: the kernel silently
+// never creates a second subflow.
+//
+// Limits and endpoints are only checked when the in-kernel path manager is
+// in use, because the userspace path manager ignores them.  Kernels without
+// the net.mptcp.pm_type sysctl, before Linux 5.19, only provide the in-kernel
+// path manager.
+func (c *Client) Diagnose() (*Diagnostics, error) {
+	enabled, err := mptcp.Enabled()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Diagnostics{Enabled: enabled}
+	if !enabled {
+		d.Problems, d.Advisories = diagnose(d)
+		return d, nil
+	}
+
+	if d.PMType, err = pmType(mptcp.NewSysctl().PMType); err != nil {
+		return nil, err
+	}
+
+	l, err := c.Limits()
+	if err != nil {
+		return nil, err
+	}
+	d.Limits = *l
+
+	if d.Endpoints, err = c.Endpoints(); err != nil {
+		return nil, err
+	}
+
+	d.Problems, d.Advisories = diagnose(d)
+	return d, nil
+}
+
+// pmType returns the path manager type using read, or the in-kernel path
+// manager if the kernel predates the pm_type sysctl.
+func pmType(read func() (mptcp.PMType, error)) (mptcp.PMType, error) {
+	t, err := read()
+	if os.IsNotExist(err) {
+		return mptcp.PMTypeKernel, nil
+	}
+
+	return t, err
+}
+
+// diagnose finds the configuration problems and advisories in d.
+func diagnose(d *Diagnostics) (problems, advisories []error) {
+	if !d.Enabled {
+		return []error{ErrDisabled}, nil
+	}
+
+	// Limits and endpoints only apply to the in-kernel path manager
+	if d.PMType != mptcp.PMTypeKernel {
+		return nil, nil
+	}
+
+	if d.Limits.Subflows == 0 {
+		problems = append(problems, ErrZeroSubflows)
+	}
+	if d.Limits.AddAddrAccepted == 0 {
+		advisories = append(advisories, ErrZeroAddAddrAccepted)
+	}
+	if len(d.Endpoints) == 0 {
+		advisories = append(advisories, ErrNoEndpoints)
+	}
+
+	return problems, advisories
+}
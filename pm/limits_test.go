@@ -0,0 +1,144 @@
+package pm
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// TestDiagnose verifies that diagnose reports configuration problems which
+// prevent connections from using additional subflows.
+func TestDiagnose(t *testing.T) {
+	endpoints := []Endpoint{{
+		ID:      1,
+		Address: net.IPv4(192, 0, 2, 1),
+		Flags:   FlagSubflow,
+	}}
+
+	var tests = []struct {
+		desc       string
+		d          Diagnostics
+		problems   []error
+		advisories []error
+	}{
+		{
+			desc:     "disabled",
+			problems: []error{ErrDisabled},
+		},
+		{
+			desc: "OK",
+			d: Diagnostics{
+				Enabled:   true,
+				Limits:    Limits{Subflows: 2, AddAddrAccepted: 2},
+				Endpoints: endpoints,
+			},
+		},
+		{
+			desc: "zero limits, no endpoints",
+			d: Diagnostics{
+				Enabled: true,
+			},
+			problems:   []error{ErrZeroSubflows},
+			advisories: []error{ErrZeroAddAddrAccepted, ErrNoEndpoints},
+		},
+		{
+			desc: "server defaults",
+			d: Diagnostics{
+				Enabled: true,
+				Limits:  Limits{Subflows: 2},
+			},
+			advisories: []error{ErrZeroAddAddrAccepted, ErrNoEndpoints},
+		},
+		{
+			desc: "zero subflows",
+			d: Diagnostics{
+				Enabled:   true,
+				Limits:    Limits{AddAddrAccepted: 2},
+				Endpoints: endpoints,
+			},
+			problems: []error{ErrZeroSubflows},
+		},
+		{
+			desc: "userspace path manager",
+			d: Diagnostics{
+				Enabled: true,
+				PMType:  mptcp.PMTypeUserspace,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		problems, advisories := diagnose(&test.d)
+		if !reflect.DeepEqual(problems, test.problems) {
+			t.Fatalf("[%02d] %s: unexpected problems:\n- want: %v\n-  got: %v", i, test.desc, test.problems, problems)
+		}
+		if !reflect.DeepEqual(advisories, test.advisories) {
+			t.Fatalf("[%02d] %s: unexpected advisories:\n- want: %v\n-  got: %v", i, test.desc, test.advisories, advisories)
+		}
+	}
+}
+
+// TestPMType verifies that kernels without the pm_type sysctl are treated as
+// using the in-kernel path manager.
+func TestPMType(t *testing.T) {
+	errNotExist := &os.PathError{
+		Op:   "open",
+		Path: "/proc/sys/net/mptcp/pm_type",
+		Err:  syscall.ENOENT,
+	}
+	errAccess := &os.PathError{
+		Op:   "open",
+		Path: "/proc/sys/net/mptcp/pm_type",
+		Err:  syscall.EACCES,
+	}
+
+	var tests = []struct {
+		desc string
+		t    mptcp.PMType
+		err  error
+		want mptcp.PMType
+		ok   bool
+	}{
+		{
+			desc: "userspace",
+			t:    mptcp.PMTypeUserspace,
+			want: mptcp.PMTypeUserspace,
+			ok:   true,
+		},
+		{
+			desc: "no pm_type sysctl",
+			err:  errNotExist,
+			want: mptcp.PMTypeKernel,
+			ok:   true,
+		},
+		{
+			desc: "permission denied",
+			err:  errAccess,
+		},
+	}
+
+	for i, test := range tests {
+		got, err := pmType(func() (mptcp.PMType, error) {
+			return test.t, test.err
+		})
+		if ok := err == nil; ok != test.ok {
+			t.Fatalf("[%02d] %s: unexpected error: %v", i, test.desc, err)
+		}
+		if got != test.want {
+			t.Fatalf("[%02d] %s: unexpected path manager type:\n- want: %v\n-  got: %v", i, test.desc, test.want, got)
+		}
+	}
+}
+
+// TestClientSetLimitsInvalid verifies that SetLimits rejects negative limits
+// before sending a request.
+func TestClientSetLimitsInvalid(t *testing.T) {
+	c := &Client{}
+	if err := c.SetLimits(Limits{Subflows: -1}); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
@@ -0,0 +1,36 @@
+package pm
+
+import (
+	"fmt"
+	"net"
+)
+
+// A Subflow identifies a single subflow of a multipath TCP connection by its
+// local and remote addresses.
+type Subflow struct {
+	// Local is the local address of the subflow.  Its port may be zero
+	// to match a subflow using any local port.
+	Local *net.TCPAddr
+
+	// Remote is the remote address and port of the subflow.
+	Remote *net.TCPAddr
+}
+
+// check verifies that a Subflow identifies a subflow using addresses of the
+// same family.
+func (s Subflow) check() error {
+	if s.Local == nil || s.Remote == nil || s.Remote.Port == 0 {
+		return fmt.Errorf("pm: subflow requires local and remote addresses, and a remote port: %s", s)
+	}
+
+	if (s.Local.IP.To4() == nil) != (s.Remote.IP.To4() == nil) {
+		return fmt.Errorf("pm: subflow addresses must be of the same family: %s", s)
+	}
+
+	return nil
+}
+
+// String returns the local and remote addresses of a Subflow.
+func (s Subflow) String() string {
+	return fmt.Sprintf("%s->%s", s.Local, s.Remote)
+}
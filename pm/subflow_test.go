@@ -0,0 +1,49 @@
+package pm
+
+import (
+	"net"
+	"testing"
+)
+
+// TestClientSetFlagsInvalid verifies that SetEndpointFlags and
+// SetSubflowFlags reject invalid input before sending a request.
+func TestClientSetFlagsInvalid(t *testing.T) {
+	var (
+		local4  = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1)}
+		remote4 = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 443}
+		remote6 = &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}
+	)
+
+	c := &Client{}
+
+	var tests = []struct {
+		desc string
+		fn   func() error
+	}{
+		{"endpoint ID zero", func() error { return c.SetEndpointFlags(0, FlagBackup) }},
+		{"endpoint signal flag", func() error { return c.SetEndpointFlags(1, FlagSignal) }},
+		{"subflow flag", func() error { return c.SetSubflowFlags(1, Subflow{local4, remote4}, FlagSubflow) }},
+		{"no local address", func() error { return c.SetSubflowFlags(1, Subflow{nil, remote4}, FlagBackup) }},
+		{"no remote port", func() error { return c.SetSubflowFlags(1, Subflow{local4, local4}, FlagBackup) }},
+		{"mixed families", func() error { return c.SetSubflowFlags(1, Subflow{local4, remote6}, FlagBackup) }},
+	}
+
+	for i, test := range tests {
+		if err := test.fn(); err == nil {
+			t.Fatalf("[%02d] %s: expected an error, but none occurred", i, test.desc)
+		}
+	}
+}
+
+// TestSubflowString verifies that Subflow.String produces the local and
+// remote addresses of a subflow.
+func TestSubflowString(t *testing.T) {
+	s := Subflow{
+		Local:  &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 40000},
+		Remote: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 443},
+	}
+
+	if str, want := s.String(), "192.0.2.1:40000->198.51.100.1:443"; str != want {
+		t.Fatalf("unexpected subflow string: %q != %q", str, want)
+	}
+}
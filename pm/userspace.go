@@ -0,0 +1,218 @@
+package pm
+
+import (
+	"context"
+	"net"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// A Connection is a multipath TCP connection observed by Client.Serve.  Its
+// methods act on the connection using the userspace path manager.
+//
+// A Connection's fields are updated by Serve as events arrive, and must only
+// be accessed from within Handler callbacks.
+type Connection struct {
+	// Token is the local token of the connection.
+	Token mptcp.Token
+
+	// Local and Remote are the addresses of the connection's initial
+	// subflow, as reported when the connection was created or
+	// established.
+	Local  *net.TCPAddr
+	Remote *net.TCPAddr
+
+	// ServerSide reports whether the connection was accepted by a
+	// listener, rather than dialed.
+	ServerSide bool
+
+	// Established reports whether the connection is fully established.
+	Established bool
+
+	// Subflows contains the additional subflows which are established,
+	// in the order they were established.
+	Subflows []Subflow
+
+	// Announced contains the addresses announced by the peer, keyed by
+	// their remote address ID.
+	Announced map[uint8]*net.TCPAddr
+
+	c *Client
+}
+
+// Announce announces a local address to the peer.  See Client.Announce.
+func (c *Connection) Announce(e Endpoint) error {
+	return c.c.Announce(c.Token, e)
+}
+
+// Remove withdraws an announced local address.  See Client.Remove.
+func (c *Connection) Remove(id uint8) error {
+	return c.c.Remove(c.Token, id)
+}
+
+// CreateSubflow creates a new subflow.  See Client.CreateSubflow.
+func (c *Connection) CreateSubflow(local Endpoint, remote *net.TCPAddr) error {
+	return c.c.CreateSubflow(c.Token, local, remote)
+}
+
+// DestroySubflow closes a single subflow.  See Client.DestroySubflow.
+func (c *Connection) DestroySubflow(s Subflow) error {
+	return c.c.DestroySubflow(c.Token, s)
+}
+
+// SetSubflowFlags sets the flags of a single subflow.  See
+// Client.SetSubflowFlags.
+func (c *Connection) SetSubflowFlags(s Subflow, f Flags) error {
+	return c.c.SetSubflowFlags(c.Token, s, f)
+}
+
+// A Handler contains callbacks which are invoked by Client.Serve when events
+// occur on a Connection.  Any callback may be nil.  Callbacks are invoked
+// sequentially from a single goroutine, so a slow callback delays events for
+// all connections.
+type Handler struct {
+	// Created is called when a connection is created, before it is
+	// established.
+	Created func(c *Connection)
+
+	// Established is called when a connection is fully established, and
+	// additional subflows may be created.
+	Established func(c *Connection)
+
+	// Closed is called when a connection is closed.
+	Closed func(c *Connection)
+
+	// Announced and Removed are called when the peer announces or
+	// withdraws an address.  The Connection's Announced field is updated
+	// before they are called.
+	Announced func(c *Connection, e Event)
+	Removed   func(c *Connection, e Event)
+
+	// SubflowEstablished and SubflowClosed are called when an additional
+	// subflow is established or closed.  The Connection's Subflows field
+	// is updated before they are called.
+	SubflowEstablished func(c *Connection, e Event)
+	SubflowClosed      func(c *Connection, e Event)
+
+	// SubflowPriority is called when the backup flag of a subflow changes.
+	SubflowPriority func(c *Connection, e Event)
+
+	// Lost is called when events were dropped, so the state of some
+	// connections may be out of date.
+	Lost func()
+}
+
+// Serve subscribes to path manager events and invokes h's callbacks for each
+// connection until ctx is canceled, at which point it returns ctx.Err.  If
+// receiving events fails, Serve returns the error.
+//
+// Callbacks are only invoked for connections which are created or established
+// after Serve is called.  Events for other connections are ignored.
+//
+// Serve may be used to observe connections managed by either path manager,
+// but the Connection methods used to manage a connection only succeed when
+// the userspace path manager is in use.
+func (c *Client) Serve(ctx context.Context, h Handler) error {
+	events, err := c.Events(ctx)
+	if err != nil {
+		return err
+	}
+
+	s := &server{
+		c:     c,
+		h:     h,
+		conns: make(map[mptcp.Token]*Connection),
+	}
+
+	for e := range events {
+		if e.Type == EventError {
+			return e.Err
+		}
+
+		s.handle(e)
+	}
+
+	return ctx.Err()
+}
+
+// A server tracks connections for Client.Serve.
+type server struct {
+	c     *Client
+	h     Handler
+	conns map[mptcp.Token]*Connection
+}
+
+// handle updates connection state for e and invokes the matching callback.
+func (s *server) handle(e Event) {
+	switch e.Type {
+	case EventLost:
+		if s.h.Lost != nil {
+			s.h.Lost()
+		}
+		return
+	case EventListenerCreated, EventListenerClosed:
+		// Listeners are not connections
+		return
+	}
+
+	conn, ok := s.conns[e.Token]
+	if !ok {
+		// Only track connections from their creation, so that events for
+		// connections which predate Serve, or whose creation was lost,
+		// do not leave entries which are never closed.
+		if e.Type != EventCreated && e.Type != EventEstablished {
+			return
+		}
+
+		conn = &Connection{
+			Token:     e.Token,
+			Announced: make(map[uint8]*net.TCPAddr),
+			c:         s.c,
+		}
+		s.conns[e.Token] = conn
+	}
+
+	call := func(fn func(c *Connection, e Event)) {
+		if fn != nil {
+			fn(conn, e)
+		}
+	}
+
+	switch e.Type {
+	case EventCreated:
+		conn.Local, conn.Remote, conn.ServerSide = e.Local, e.Remote, e.ServerSide
+		if s.h.Created != nil {
+			s.h.Created(conn)
+		}
+	case EventEstablished:
+		conn.Local, conn.Remote, conn.ServerSide = e.Local, e.Remote, e.ServerSide
+		conn.Established = true
+		if s.h.Established != nil {
+			s.h.Established(conn)
+		}
+	case EventClosed:
+		delete(s.conns, e.Token)
+		if s.h.Closed != nil {
+			s.h.Closed(conn)
+		}
+	case EventAnnounced:
+		conn.Announced[e.RemoteID] = e.Remote
+		call(s.h.Announced)
+	case EventRemoved:
+		delete(conn.Announced, e.RemoteID)
+		call(s.h.Removed)
+	case EventSubflowEstablished:
+		conn.Subflows = append(conn.Subflows, Subflow{Local: e.Local, Remote: e.Remote})
+		call(s.h.SubflowEstablished)
+	case EventSubflowClosed:
+		for i, sf := range conn.Subflows {
+			if sf.String() == (Subflow{Local: e.Local, Remote: e.Remote}).String() {
+				conn.Subflows = append(conn.Subflows[:i], conn.Subflows[i+1:]...)
+				break
+			}
+		}
+		call(s.h.SubflowClosed)
+	case EventSubflowPriority:
+		call(s.h.SubflowPriority)
+	}
+}
@@ -0,0 +1,252 @@
+package pm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/mptcp"
+)
+
+// TestClientServe verifies that Serve tracks connection state and invokes
+// Handler callbacks for each connection.
+func TestClientServe(t *testing.T) {
+	var (
+		local  = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 40000}
+		local2 = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2).To4(), Port: 40001}
+		remote = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1).To4(), Port: 443}
+		added  = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 2).To4(), Port: 443}
+
+		errFatal = errors.New("fatal")
+	)
+
+	fc := &fakeClient{
+		events: []Event{
+			{Type: EventListenerCreated, Local: local},
+			{Type: EventCreated, Token: 1, Local: local, Remote: remote},
+			{Type: EventEstablished, Token: 1, Local: local, Remote: remote},
+			{Type: EventAnnounced, Token: 1, RemoteID: 1, Remote: added},
+			{Type: EventSubflowEstablished, Token: 1, Local: local2, Remote: added},
+			{Type: EventLost},
+			{Type: EventSubflowClosed, Token: 1, Local: local2, Remote: added},
+			{Type: EventRemoved, Token: 1, RemoteID: 1},
+			{Type: EventClosed, Token: 1},
+			{Type: EventError, Err: errFatal},
+		},
+	}
+
+	var (
+		calls    []string
+		subflows []int
+		lost     bool
+	)
+
+	h := Handler{
+		Created: func(c *Connection) {
+			calls = append(calls, "created")
+			if c.Token != 1 || c.Local != local || c.Remote != remote {
+				t.Fatalf("unexpected created connection: %+v", c)
+			}
+		},
+		Established: func(c *Connection) {
+			calls = append(calls, "established")
+			if !c.Established {
+				t.Fatal("connection should be established")
+			}
+
+			// Create an additional subflow once established
+			if err := c.CreateSubflow(Endpoint{ID: 2, Address: local2.IP}, remote); err != nil {
+				t.Fatalf("failed to create subflow: %v", err)
+			}
+		},
+		Announced: func(c *Connection, e Event) {
+			calls = append(calls, "announced")
+			if want := map[uint8]*net.TCPAddr{1: added}; !reflect.DeepEqual(c.Announced, want) {
+				t.Fatalf("unexpected announced addresses: %v", c.Announced)
+			}
+		},
+		Removed: func(c *Connection, _ Event) {
+			calls = append(calls, "removed")
+			if len(c.Announced) != 0 {
+				t.Fatalf("unexpected announced addresses: %v", c.Announced)
+			}
+		},
+		SubflowEstablished: func(c *Connection, _ Event) {
+			subflows = append(subflows, len(c.Subflows))
+		},
+		SubflowClosed: func(c *Connection, _ Event) {
+			subflows = append(subflows, len(c.Subflows))
+		},
+		Closed: func(c *Connection) {
+			calls = append(calls, "closed")
+		},
+		Lost: func() { lost = true },
+	}
+
+	c := &Client{c: fc}
+	if err := c.Serve(context.Background(), h); !errors.Is(err, errFatal) {
+		t.Fatalf("expected fatal error, but got: %v", err)
+	}
+
+	if want := []string{"created", "established", "announced", "removed", "closed"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("unexpected callbacks:\n- want: %v\n-  got: %v", want, calls)
+	}
+	if want := []int{1, 0}; !reflect.DeepEqual(subflows, want) {
+		t.Fatalf("unexpected subflow counts:\n- want: %v\n-  got: %v", want, subflows)
+	}
+	if !lost {
+		t.Fatal("lost callback was not called")
+	}
+	if fc.created != 1 {
+		t.Fatalf("unexpected number of created subflows: %d", fc.created)
+	}
+}
+
+// TestServerUnknownConnection verifies that events for connections which
+// were not created while serving are ignored, and do not track connections.
+func TestServerUnknownConnection(t *testing.T) {
+	var (
+		local  = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 40000}
+		remote = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1).To4(), Port: 443}
+	)
+
+	var calls int
+	call := func(*Connection, Event) { calls++ }
+
+	s := &server{
+		c: &Client{c: &fakeClient{}},
+		h: Handler{
+			Closed:             func(*Connection) { calls++ },
+			Announced:          call,
+			Removed:            call,
+			SubflowEstablished: call,
+			SubflowClosed:      call,
+			SubflowPriority:    call,
+		},
+		conns: make(map[mptcp.Token]*Connection),
+	}
+
+	for _, e := range []Event{
+		{Type: EventAnnounced, Token: 1, RemoteID: 1, Remote: remote},
+		{Type: EventRemoved, Token: 2, RemoteID: 1},
+		{Type: EventSubflowEstablished, Token: 3, Local: local, Remote: remote},
+		{Type: EventSubflowPriority, Token: 3, Local: local, Remote: remote},
+		{Type: EventSubflowClosed, Token: 3, Local: local, Remote: remote},
+		{Type: EventClosed, Token: 4},
+	} {
+		s.handle(e)
+	}
+
+	if calls != 0 {
+		t.Fatalf("unexpected number of callbacks: %d", calls)
+	}
+	if len(s.conns) != 0 {
+		t.Fatalf("unexpected tracked connections: %v", s.conns)
+	}
+
+	// A connection is tracked from its creation until it is closed.
+	s.handle(Event{Type: EventCreated, Token: 1, Local: local, Remote: remote})
+	s.handle(Event{Type: EventAnnounced, Token: 1, RemoteID: 1, Remote: remote})
+	if calls != 1 || len(s.conns) != 1 {
+		t.Fatalf("unexpected state after creation: %d callbacks, %d connections",
+			calls, len(s.conns))
+	}
+
+	s.handle(Event{Type: EventClosed, Token: 1})
+	if calls != 2 || len(s.conns) != 0 {
+		t.Fatalf("unexpected state after close: %d callbacks, %d connections",
+			calls, len(s.conns))
+	}
+}
+
+// TestClientServeCanceled verifies that Serve returns the context's error
+// once its events are exhausted.
+func TestClientServeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &Client{c: &fakeClient{}}
+	if err := c.Serve(ctx, Handler{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled error, but got: %v", err)
+	}
+}
+
+// TestClientUserspaceInvalid verifies that the userspace path manager methods
+// reject invalid arguments before sending a request.
+func TestClientUserspaceInvalid(t *testing.T) {
+	var (
+		local  = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4()}
+		remote = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1).To4(), Port: 443}
+	)
+
+	c := &Client{}
+
+	var tests = []struct {
+		desc string
+		fn   func() error
+	}{
+		{
+			desc: "announce zero ID",
+			fn: func() error {
+				return c.Announce(1, Endpoint{Address: local.IP})
+			},
+		},
+		{
+			desc: "remove zero ID",
+			fn: func() error {
+				return c.Remove(1, 0)
+			},
+		},
+		{
+			desc: "create subflow signal",
+			fn: func() error {
+				return c.CreateSubflow(1, Endpoint{ID: 1, Address: local.IP, Flags: FlagSignal}, remote)
+			},
+		},
+		{
+			desc: "create subflow no remote port",
+			fn: func() error {
+				return c.CreateSubflow(1, Endpoint{ID: 1, Address: local.IP}, &net.TCPAddr{IP: remote.IP})
+			},
+		},
+		{
+			desc: "destroy subflow no local port",
+			fn: func() error {
+				return c.DestroySubflow(1, Subflow{Local: local, Remote: remote})
+			},
+		},
+	}
+
+	for i, test := range tests {
+		if err := test.fn(); err == nil {
+			t.Fatalf("[%02d] %s: expected an error, but none occurred", i, test.desc)
+		}
+	}
+}
+
+// A fakeClient is an osClient which delivers canned events and counts
+// created subflows.  Other methods panic.
+type fakeClient struct {
+	osClient
+	events  []Event
+	created int
+}
+
+// CreateSubflow implements osClient.
+func (c *fakeClient) CreateSubflow(_ mptcp.Token, _ Endpoint, _ *net.TCPAddr) error {
+	c.created++
+	return nil
+}
+
+// Events implements osClient.
+func (c *fakeClient) Events(_ context.Context) (<-chan Event, error) {
+	ch := make(chan Event, len(c.events))
+	for _, e := range c.events {
+		ch <- e
+	}
+	close(ch)
+
+	return ch, nil
+}
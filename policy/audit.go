@@ -0,0 +1,139 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// An Action is an action taken by an Engine.
+type Action int
+
+// Possible Action values.
+const (
+	// ActionCreate creates a subflow.
+	ActionCreate Action = iota
+
+	// ActionSkip does not create a subflow, because a Rule denied it.
+	ActionSkip
+
+	// ActionAllow keeps a newly established subflow.
+	ActionAllow
+
+	// ActionDestroy destroys an established subflow, because a Rule
+	// denied it.
+	ActionDestroy
+
+	// ActionBackup marks an established subflow as a backup subflow.
+	ActionBackup
+
+	// ActionError reports an error which is not specific to a subflow.
+	ActionError
+)
+
+// String returns the string representation of an Action.
+func (a Action) String() string {
+	switch a {
+	case ActionCreate:
+		return "create"
+	case ActionSkip:
+		return "skip"
+	case ActionAllow:
+		return "allow"
+	case ActionDestroy:
+		return "destroy"
+	case ActionBackup:
+		return "backup"
+	case ActionError:
+		return "error"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+// A Decision is an audit record of a single decision made by an Engine.
+type Decision struct {
+	// Time is the time of the decision.
+	Time time.Time
+
+	// Event is the path manager event which caused the decision, or zero
+	// if the decision was made when connection statistics were refreshed.
+	Event pm.EventType
+
+	// Action is the action taken.
+	Action Action
+
+	// Token and Subflow identify the connection and subflow.
+	Token   mptcp.Token
+	Subflow Subflow
+
+	// Rule describes the Rule which decided the action, or is empty if no
+	// rule affected the decision.
+	Rule string
+
+	// Err is the error returned by the path manager when performing the
+	// action, if any.
+	Err error
+}
+
+// JSONAudit returns a function for Config.Audit which writes each Decision
+// to w as a line of JSON.  Errors writing to w are ignored.
+func JSONAudit(w io.Writer) func(Decision) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+
+	return func(d Decision) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		_ = enc.Encode(jsonDecision(d))
+	}
+}
+
+// A decisionJSON is the JSON representation of a Decision.
+type decisionJSON struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event,omitempty"`
+	Action    string    `json:"action"`
+	Token     string    `json:"token,omitempty"`
+	Local     string    `json:"local,omitempty"`
+	Remote    string    `json:"remote,omitempty"`
+	Interface string    `json:"interface,omitempty"`
+	Backup    bool      `json:"backup,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// jsonDecision converts a Decision into its JSON representation.
+func jsonDecision(d Decision) decisionJSON {
+	j := decisionJSON{
+		Time:      d.Time,
+		Action:    d.Action.String(),
+		Interface: d.Subflow.Interface,
+		Backup:    d.Subflow.Backup,
+		Rule:      d.Rule,
+	}
+
+	if d.Event != 0 {
+		j.Event = d.Event.String()
+	}
+	if d.Token != 0 {
+		j.Token = d.Token.String()
+	}
+	if d.Subflow.Local != nil {
+		j.Local = d.Subflow.Local.String()
+	}
+	if d.Subflow.Remote != nil {
+		j.Remote = d.Subflow.Remote.String()
+	}
+	if d.Err != nil {
+		j.Error = d.Err.Error()
+	}
+
+	return j
+}
@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// TestJSONAudit verifies that JSONAudit writes one line of JSON per Decision.
+func TestJSONAudit(t *testing.T) {
+	var b bytes.Buffer
+	audit := JSONAudit(&b)
+
+	audit(Decision{
+		Time:   time.Date(2014, time.October, 27, 18, 0, 0, 0, time.UTC),
+		Event:  pm.EventSubflowEstablished,
+		Action: ActionDestroy,
+		Token:  0x9c290bf6,
+		Subflow: Subflow{
+			Local:     &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 40000},
+			Remote:    &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 443},
+			Interface: "eth0",
+		},
+		Rule: "deny_remote 10.0.0.0/8",
+		Err:  errors.New("failed"),
+	})
+	audit(Decision{Action: ActionError})
+
+	want := `{"time":"2014-10-27T18:00:00Z","event":"SUB_ESTABLISHED","action":"destroy","token":"9C290BF6","local":"192.0.2.1:40000","remote":"10.0.0.1:443","interface":"eth0","rule":"deny_remote 10.0.0.0/8","error":"failed"}
+{"time":"0001-01-01T00:00:00Z","action":"error"}
+`
+	if got := b.String(); got != want {
+		t.Fatalf("unexpected audit log:\n- want: %s\n-  got: %s", want, got)
+	}
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// A ruleConfig is the JSON representation of a Rule.
+type ruleConfig struct {
+	Type       string   `json:"type"`
+	Max        int      `json:"max"`
+	Interfaces []string `json:"interfaces"`
+	Bytes      uint64   `json:"bytes"`
+	Prefixes   []string `json:"prefixes"`
+}
+
+// ParseRules parses Rules from a JSON array, in which each object has a
+// "type" field and the parameters for that type of rule:
+//
+//	[
+//	  {"type": "max_subflows", "max": 4},
+//	  {"type": "interfaces", "interfaces": ["eth*", "wwan0"]},
+//	  {"type": "backup_after", "interfaces": ["wwan*"], "bytes": 10000000},
+//	  {"type": "deny_remote", "prefixes": ["rfc1918", "100.64.0.0/10"]}
+//	]
+//
+// The prefix "rfc1918" is shorthand for the prefixes in RFC1918.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+
+	var rcs []ruleConfig
+	if err := d.Decode(&rcs); err != nil {
+		return nil, err
+	}
+
+	rules := make([]Rule, 0, len(rcs))
+	for i, rc := range rcs {
+		r, err := rc.rule()
+		if err != nil {
+			return nil, fmt.Errorf("policy: rule %d: %v", i, err)
+		}
+
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// rule creates a Rule from its configuration.
+func (rc ruleConfig) rule() (Rule, error) {
+	switch rc.Type {
+	case "max_subflows":
+		if rc.Max < 1 {
+			return nil, fmt.Errorf("max_subflows requires a maximum of at least 1, but got %d", rc.Max)
+		}
+
+		return MaxSubflows(rc.Max), nil
+	case "interfaces":
+		return Interfaces(rc.Interfaces...)
+	case "backup_after":
+		return BackupAfter(rc.Bytes, rc.Interfaces...)
+	case "deny_remote":
+		if len(rc.Prefixes) == 0 {
+			return nil, fmt.Errorf("deny_remote requires at least one prefix")
+		}
+
+		var prefixes []*net.IPNet
+		for _, p := range rc.Prefixes {
+			if strings.EqualFold(p, "rfc1918") {
+				prefixes = append(prefixes, RFC1918...)
+				continue
+			}
+
+			_, ipn, err := net.ParseCIDR(p)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, ipn)
+		}
+
+		return DenyRemote(prefixes...), nil
+	default:
+		return nil, fmt.Errorf("unknown rule type %q", rc.Type)
+	}
+}
@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseRules verifies that ParseRules creates the configured Rules, and
+// rejects invalid configurations.
+func TestParseRules(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		s     string
+		rules []string
+		ok    bool
+	}{
+		{
+			desc: "OK",
+			s: `[
+				{"type": "max_subflows", "max": 4},
+				{"type": "interfaces", "interfaces": ["eth*", "wwan0"]},
+				{"type": "backup_after", "interfaces": ["wwan*"], "bytes": 1000},
+				{"type": "deny_remote", "prefixes": ["RFC1918", "100.64.0.0/10"]}
+			]`,
+			rules: []string{
+				"max_subflows 4",
+				"interfaces eth*,wwan0",
+				"backup_after 1000 wwan*",
+				"deny_remote 10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,100.64.0.0/10",
+			},
+			ok: true,
+		},
+		{
+			desc: "unknown type",
+			s:    `[{"type": "foo"}]`,
+		},
+		{
+			desc: "unknown field",
+			s:    `[{"type": "max_subflows", "max": 1, "foo": 1}]`,
+		},
+		{
+			desc: "zero max subflows",
+			s:    `[{"type": "max_subflows"}]`,
+		},
+		{
+			desc: "no interfaces",
+			s:    `[{"type": "interfaces"}]`,
+		},
+		{
+			desc: "no prefixes",
+			s:    `[{"type": "deny_remote"}]`,
+		},
+		{
+			desc: "bad prefix",
+			s:    `[{"type": "deny_remote", "prefixes": ["10.0.0.0"]}]`,
+		},
+	}
+
+	for i, test := range tests {
+		rules, err := ParseRules(strings.NewReader(test.s))
+		if err != nil && test.ok {
+			t.Fatalf("[%02d] %s: unexpected error: %v", i, test.desc, err)
+		}
+		if err == nil && !test.ok {
+			t.Fatalf("[%02d] %s: expected an error, but none occurred", i, test.desc)
+		}
+
+		if len(rules) != len(test.rules) {
+			t.Fatalf("[%02d] %s: unexpected number of rules: %d", i, test.desc, len(rules))
+		}
+		for j, r := range rules {
+			if s := r.String(); s != test.rules[j] {
+				t.Fatalf("[%02d] %s: unexpected rule %d:\n- want: %q\n-  got: %q", i, test.desc, j, test.rules[j], s)
+			}
+		}
+	}
+}
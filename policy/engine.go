@@ -0,0 +1,494 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// A Connection is the state of a multipath TCP connection observed by an
+// Engine, as passed to Rules.
+type Connection struct {
+	// Token is the local token of the connection.
+	Token mptcp.Token
+
+	// Local and Remote are the addresses of the initial subflow.
+	Local  *net.TCPAddr
+	Remote *net.TCPAddr
+
+	// ServerSide reports whether the connection was accepted by a
+	// listener.
+	ServerSide bool
+
+	// Subflows are the additional subflows which are established, in the
+	// order they were established.
+	Subflows []Subflow
+
+	// Pending are subflows which the Engine has requested, but which are
+	// not yet established.
+	Pending []Subflow
+
+	// Announced are the addresses announced by the peer, keyed by their
+	// remote address ID.
+	Announced map[uint8]*net.TCPAddr
+
+	// Bytes is the number of bytes sent and received at the data level,
+	// as of the last time connection statistics were refreshed.
+	Bytes uint64
+}
+
+// index returns the index of s in c.Subflows, or -1 if it is not established.
+func (c *Connection) index(s Subflow) int {
+	return indexSubflow(c.Subflows, s)
+}
+
+// A Subflow is an additional subflow of a Connection, which may be
+// established or only a candidate for creation.
+type Subflow struct {
+	// Local and Remote are the subflow's addresses.  The local port is
+	// zero for subflows which are not yet established.
+	Local  *net.TCPAddr
+	Remote *net.TCPAddr
+
+	// Interface is the name of the local network interface used by the
+	// subflow, if known.
+	Interface string
+
+	// Backup reports whether the subflow is a backup subflow.
+	Backup bool
+}
+
+// same reports whether s and t use the same local address and remote address
+// and port, ignoring the local port.
+func (s Subflow) same(t Subflow) bool {
+	return s.Local.IP.Equal(t.Local.IP) &&
+		s.Remote.IP.Equal(t.Remote.IP) && s.Remote.Port == t.Remote.Port
+}
+
+// pmSubflow converts s into a pm.Subflow.
+func (s Subflow) pmSubflow() pm.Subflow {
+	return pm.Subflow{Local: s.Local, Remote: s.Remote}
+}
+
+// String returns the local and remote addresses of a Subflow.
+func (s Subflow) String() string {
+	return s.pmSubflow().String()
+}
+
+// Config configures an Engine.
+type Config struct {
+	// Rules are evaluated for every subflow.  A subflow is denied if any
+	// rule denies it, and is a backup subflow if any rule returns Backup.
+	Rules []Rule
+
+	// Endpoints are the local addresses from which the Engine creates
+	// subflows.  Each endpoint must have a non-zero ID, and its Interface
+	// is used to evaluate Rules which match interface names.
+	Endpoints []pm.Endpoint
+
+	// Interval is how often connection statistics are refreshed and Rules
+	// are evaluated again for established subflows.  If zero, Rules are
+	// only evaluated when path manager events occur.
+	Interval time.Duration
+
+	// Audit, if set, is called with every Decision made by the Engine.
+	// It is called with the Engine's lock held, so it must not block.
+	Audit func(Decision)
+}
+
+// An Engine applies Rules to multipath TCP connections managed by the
+// userspace path manager.
+type Engine struct {
+	c   client
+	cfg Config
+
+	// Sources of external data, swappable for tests.
+	now     func() time.Time
+	entries func() ([]mptcp.Entry, error)
+	ifname  func(index int) string
+
+	mu    sync.Mutex
+	conns map[mptcp.Token]*Connection
+}
+
+// A client is the subset of *pm.Client used by an Engine.
+type client interface {
+	Serve(ctx context.Context, h pm.Handler) error
+	CreateSubflow(token mptcp.Token, local pm.Endpoint, remote *net.TCPAddr) error
+	DestroySubflow(token mptcp.Token, s pm.Subflow) error
+	SetSubflowFlags(token mptcp.Token, s pm.Subflow, f pm.Flags) error
+}
+
+var _ client = &pm.Client{}
+
+// New creates an Engine which uses c to receive path manager events and to
+// manage subflows.
+func New(c *pm.Client, cfg Config) (*Engine, error) {
+	return newEngine(c, cfg, mptcp.Entries, interfaceName)
+}
+
+// newEngine creates an Engine which uses the input functions as its data
+// sources.
+func newEngine(
+	c client,
+	cfg Config,
+	entries func() ([]mptcp.Entry, error),
+	ifname func(index int) string,
+) (*Engine, error) {
+	for _, e := range cfg.Endpoints {
+		if e.ID == 0 || e.Address == nil {
+			return nil, fmt.Errorf("policy: endpoints require an ID and address: %+v", e)
+		}
+		if e.Flags&pm.FlagSignal != 0 {
+			return nil, fmt.Errorf("policy: endpoint %d may not use the signal flag", e.ID)
+		}
+	}
+
+	return &Engine{
+		c:   c,
+		cfg: cfg,
+
+		now:     time.Now,
+		entries: entries,
+		ifname:  ifname,
+
+		conns: make(map[mptcp.Token]*Connection),
+	}, nil
+}
+
+// interfaceName returns the name of the network interface with index, or
+// the empty string if it does not exist.
+func interfaceName(index int) string {
+	if index == 0 {
+		return ""
+	}
+
+	ifi, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return ""
+	}
+
+	return ifi.Name
+}
+
+// Run receives path manager events and applies the Engine's Rules until ctx
+// is canceled, or receiving events fails.
+func (e *Engine) Run(ctx context.Context) error {
+	if e.cfg.Interval > 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go func() {
+			t := time.NewTicker(e.cfg.Interval)
+			defer t.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					e.refresh()
+				}
+			}
+		}()
+	}
+
+	return e.c.Serve(ctx, e.Handler())
+}
+
+// Handler returns a pm.Handler which applies the Engine's Rules, for use with
+// pm.Client.Serve when Run is not used.
+func (e *Engine) Handler() pm.Handler {
+	return pm.Handler{
+		Established:        e.established,
+		Closed:             e.closed,
+		Announced:          e.announced,
+		Removed:            e.removed,
+		SubflowEstablished: e.subflowEstablished,
+		SubflowClosed:      e.subflowClosed,
+		SubflowPriority:    e.subflowPriority,
+	}
+}
+
+// established creates subflows from each endpoint to the peer's initial
+// address when a connection dialed by this host is established.
+func (e *Engine) established(pc *pm.Connection) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c := e.conn(pc.Token)
+	c.Local, c.Remote, c.ServerSide = pc.Local, pc.Remote, pc.ServerSide
+
+	// Only the client creates subflows to the peer's initial address,
+	// because the server's peer is usually not reachable at its address
+	if !c.ServerSide && c.Remote != nil {
+		e.createSubflows(pm.EventEstablished, c, c.Remote)
+	}
+}
+
+// closed forgets a connection.
+func (e *Engine) closed(pc *pm.Connection) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.conns, pc.Token)
+}
+
+// announced creates subflows from each endpoint to an address announced by
+// the peer.
+func (e *Engine) announced(pc *pm.Connection, ev pm.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if ev.Remote == nil {
+		return
+	}
+
+	c := e.conn(pc.Token)
+	c.Announced[ev.RemoteID] = ev.Remote
+	e.createSubflows(ev.Type, c, ev.Remote)
+}
+
+// removed forgets an address withdrawn by the peer.  The kernel closes any
+// subflows which use it.
+func (e *Engine) removed(pc *pm.Connection, ev pm.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.conn(pc.Token).Announced, ev.RemoteID)
+}
+
+// subflowEstablished applies Rules to a newly established subflow.
+func (e *Engine) subflowEstablished(pc *pm.Connection, ev pm.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if ev.Local == nil || ev.Remote == nil {
+		return
+	}
+
+	c := e.conn(pc.Token)
+	sf := Subflow{
+		Local:     ev.Local,
+		Remote:    ev.Remote,
+		Interface: e.ifname(ev.Interface),
+		Backup:    ev.Backup,
+	}
+
+	c.Pending = removeSubflow(c.Pending, sf)
+	c.Subflows = append(c.Subflows, sf)
+
+	e.enforce(ev.Type, c, sf)
+}
+
+// subflowClosed forgets a subflow which was closed or failed to be created.
+func (e *Engine) subflowClosed(pc *pm.Connection, ev pm.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if ev.Local == nil || ev.Remote == nil {
+		return
+	}
+
+	c := e.conn(pc.Token)
+	sf := Subflow{Local: ev.Local, Remote: ev.Remote}
+	c.Pending = removeSubflow(c.Pending, sf)
+	c.Subflows = removeSubflow(c.Subflows, sf)
+}
+
+// subflowPriority records a change to a subflow's backup flag.
+func (e *Engine) subflowPriority(pc *pm.Connection, ev pm.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if ev.Local == nil || ev.Remote == nil {
+		return
+	}
+
+	c := e.conn(pc.Token)
+	if i := c.index(Subflow{Local: ev.Local, Remote: ev.Remote}); i != -1 {
+		c.Subflows[i].Backup = ev.Backup
+	}
+}
+
+// refresh updates connection statistics and applies Rules to all established
+// subflows again.
+func (e *Engine) refresh() {
+	entries, err := e.entries()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		e.audit(Decision{Action: ActionError, Err: fmt.Errorf("policy: failed to refresh connections: %w", err)})
+		return
+	}
+
+	for _, en := range entries {
+		c, ok := e.conns[en.LocalToken]
+		if !ok || en.Info == nil {
+			continue
+		}
+
+		c.Bytes = en.Info.BytesSent + en.Info.BytesReceived
+	}
+
+	for _, c := range e.conns {
+		// enforce may destroy subflows, so iterate over a copy
+		for _, sf := range append([]Subflow(nil), c.Subflows...) {
+			e.enforce(0, c, sf)
+		}
+	}
+}
+
+// conn returns the Connection for token, creating it if necessary.  e.mu
+// must be held.
+func (e *Engine) conn(token mptcp.Token) *Connection {
+	c, ok := e.conns[token]
+	if !ok {
+		c = &Connection{
+			Token:     token,
+			Announced: make(map[uint8]*net.TCPAddr),
+		}
+		e.conns[token] = c
+	}
+
+	return c
+}
+
+// createSubflows evaluates Rules for a subflow from each endpoint to remote,
+// and creates those which are permitted.  e.mu must be held.
+func (e *Engine) createSubflows(typ pm.EventType, c *Connection, remote *net.TCPAddr) {
+	for _, ep := range e.cfg.Endpoints {
+		// Endpoints must use the same address family as the peer
+		if (ep.Address.To4() == nil) != (remote.IP.To4() == nil) {
+			continue
+		}
+
+		sf := Subflow{
+			Local:     &net.TCPAddr{IP: ep.Address},
+			Remote:    remote,
+			Interface: e.ifname(ep.Interface),
+			Backup:    ep.Flags&pm.FlagBackup != 0,
+		}
+
+		// Skip the initial subflow and any subflow which already exists
+		if c.Local != nil && c.Remote != nil && sf.same(Subflow{Local: c.Local, Remote: c.Remote}) {
+			continue
+		}
+		if c.index(sf) != -1 || indexSubflow(c.Pending, sf) != -1 {
+			continue
+		}
+
+		v, rule := e.evaluate(c, sf)
+		d := Decision{Event: typ, Token: c.Token, Subflow: sf, Rule: rule}
+		if v == Deny {
+			d.Action = ActionSkip
+			e.audit(d)
+			continue
+		}
+
+		if v == Backup {
+			sf.Backup = true
+			ep.Flags |= pm.FlagBackup
+		}
+
+		d.Action = ActionCreate
+		d.Subflow = sf
+		d.Err = e.c.CreateSubflow(c.Token, ep, remote)
+		if d.Err == nil {
+			c.Pending = append(c.Pending, sf)
+		}
+		e.audit(d)
+	}
+}
+
+// enforce evaluates Rules for an established subflow, and destroys it or
+// makes it a backup subflow as needed.  e.mu must be held.
+func (e *Engine) enforce(typ pm.EventType, c *Connection, sf Subflow) {
+	v, rule := e.evaluate(c, sf)
+	d := Decision{Event: typ, Token: c.Token, Subflow: sf, Rule: rule}
+
+	switch {
+	case v == Deny:
+		d.Action = ActionDestroy
+		d.Err = e.c.DestroySubflow(c.Token, sf.pmSubflow())
+		if d.Err == nil {
+			c.Subflows = removeSubflow(c.Subflows, sf)
+		}
+	case v == Backup && !sf.Backup:
+		d.Action = ActionBackup
+		d.Err = e.c.SetSubflowFlags(c.Token, sf.pmSubflow(), pm.FlagBackup)
+		if d.Err == nil {
+			if i := c.index(sf); i != -1 {
+				c.Subflows[i].Backup = true
+			}
+		}
+	case typ == pm.EventSubflowEstablished:
+		// Report that new subflows are permitted, but don't report
+		// unchanged subflows on every refresh
+		d.Action = ActionAllow
+	default:
+		return
+	}
+
+	e.audit(d)
+}
+
+// evaluate applies all Rules to a subflow, and returns the combined Verdict
+// and a description of the rule which decided it.
+func (e *Engine) evaluate(c *Connection, sf Subflow) (Verdict, string) {
+	var (
+		v    = Allow
+		rule string
+	)
+
+	for _, r := range e.cfg.Rules {
+		switch r.Evaluate(c, sf) {
+		case Deny:
+			return Deny, r.String()
+		case Backup:
+			if v != Backup {
+				v, rule = Backup, r.String()
+			}
+		}
+	}
+
+	return v, rule
+}
+
+// audit reports a Decision, if an audit function is configured.  e.mu must be
+// held.
+func (e *Engine) audit(d Decision) {
+	if e.cfg.Audit == nil {
+		return
+	}
+
+	d.Time = e.now()
+	e.cfg.Audit(d)
+}
+
+// indexSubflow returns the index of s in ss, or -1 if it is not present.
+func indexSubflow(ss []Subflow, s Subflow) int {
+	for i, sf := range ss {
+		if sf.same(s) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// removeSubflow removes s from ss, if present.
+func removeSubflow(ss []Subflow, s Subflow) []Subflow {
+	if i := indexSubflow(ss, s); i != -1 {
+		return append(ss[:i], ss[i+1:]...)
+	}
+
+	return ss
+}
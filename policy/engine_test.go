@@ -0,0 +1,215 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// TestEngine verifies that an Engine creates, skips, destroys, and changes
+// subflows according to its Rules, and audits each decision.
+func TestEngine(t *testing.T) {
+	var (
+		local   = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 40000}
+		remote  = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1).To4(), Port: 443}
+		private = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1).To4(), Port: 443}
+
+		ep1 = pm.Endpoint{ID: 1, Address: net.IPv4(192, 0, 2, 2).To4(), Interface: 2}
+		ep2 = pm.Endpoint{ID: 2, Address: net.IPv4(192, 0, 2, 3).To4(), Interface: 3}
+		ep3 = pm.Endpoint{ID: 3, Address: net.ParseIP("2001:db8::2"), Interface: 2}
+	)
+
+	backup, err := BackupAfter(1000, "wwan*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decisions []string
+	fc := &fakeClient{}
+	e, err := newEngine(fc, Config{
+		Rules:     []Rule{DenyRemote(RFC1918...), MaxSubflows(3), backup},
+		Endpoints: []pm.Endpoint{ep1, ep2, ep3},
+		Audit: func(d Decision) {
+			decisions = append(decisions, fmt.Sprintf("%s %s %s", d.Action, d.Subflow, d.Rule))
+		},
+	}, func() ([]mptcp.Entry, error) {
+		return []mptcp.Entry{
+			{LocalToken: 1, Info: &mptcp.Info{BytesSent: 1500, BytesReceived: 500}},
+			{LocalToken: 2, Info: &mptcp.Info{BytesSent: 1500}},
+		}, nil
+	}, func(index int) string {
+		return map[int]string{2: "eth1", 3: "wwan0"}[index]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := e.Handler()
+	pc := &pm.Connection{Token: 1, Local: local, Remote: remote}
+
+	sub := func(ep pm.Endpoint, port int) pm.Event {
+		return pm.Event{
+			Type:      pm.EventSubflowEstablished,
+			Token:     1,
+			Local:     &net.TCPAddr{IP: ep.Address, Port: port},
+			Remote:    remote,
+			Interface: ep.Interface,
+		}
+	}
+
+	// Subflows are created from each IPv4 endpoint once established
+	h.Established(pc)
+
+	// Private addresses announced by the peer are never used
+	h.Announced(pc, pm.Event{Type: pm.EventAnnounced, Token: 1, RemoteID: 1, Remote: private})
+
+	// Both subflows are within the limit, and the peer joins one more
+	h.SubflowEstablished(pc, sub(ep1, 40001))
+	h.SubflowEstablished(pc, sub(ep2, 40002))
+	h.SubflowEstablished(pc, pm.Event{
+		Type:   pm.EventSubflowEstablished,
+		Token:  1,
+		Local:  &net.TCPAddr{IP: local.IP, Port: 40003},
+		Remote: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 2).To4(), Port: 443},
+	})
+
+	// The wwan0 subflow becomes a backup once enough data is transferred
+	e.refresh()
+	e.refresh()
+
+	if c := e.conns[1]; len(c.Subflows) != 2 || len(c.Pending) != 0 || c.Bytes != 2000 {
+		t.Fatalf("unexpected connection state: %+v", c)
+	}
+
+	h.Closed(pc)
+	if len(e.conns) != 0 {
+		t.Fatalf("unexpected connections: %v", e.conns)
+	}
+
+	wantOps := []string{
+		"create 1 192.0.2.2 -> 198.51.100.1:443 0",
+		"create 1 192.0.2.3 -> 198.51.100.1:443 0",
+		"destroy 1 192.0.2.1:40003->198.51.100.2:443",
+		"set 1 192.0.2.3:40002->198.51.100.1:443 backup",
+	}
+	if !reflect.DeepEqual(fc.ops, wantOps) {
+		t.Fatalf("unexpected operations:\n- want: %q\n-  got: %q", wantOps, fc.ops)
+	}
+
+	wantDecisions := []string{
+		"create 192.0.2.2:0->198.51.100.1:443 ",
+		"create 192.0.2.3:0->198.51.100.1:443 ",
+		"skip 192.0.2.2:0->10.0.0.1:443 deny_remote 10.0.0.0/8,172.16.0.0/12,192.168.0.0/16",
+		"skip 192.0.2.3:0->10.0.0.1:443 deny_remote 10.0.0.0/8,172.16.0.0/12,192.168.0.0/16",
+		"allow 192.0.2.2:40001->198.51.100.1:443 ",
+		"allow 192.0.2.3:40002->198.51.100.1:443 ",
+		"destroy 192.0.2.1:40003->198.51.100.2:443 max_subflows 3",
+		"backup 192.0.2.3:40002->198.51.100.1:443 backup_after 1000 wwan*",
+	}
+	if !reflect.DeepEqual(decisions, wantDecisions) {
+		t.Fatalf("unexpected decisions:\n- want: %q\n-  got: %q", wantDecisions, decisions)
+	}
+}
+
+// TestEngineErrors verifies that an Engine audits errors from the path
+// manager, and does not track subflows it failed to create.
+func TestEngineErrors(t *testing.T) {
+	errCreate := errors.New("create failed")
+
+	var decisions []Decision
+	e, err := newEngine(&fakeClient{err: errCreate}, Config{
+		Endpoints: []pm.Endpoint{{ID: 1, Address: net.IPv4(192, 0, 2, 2).To4()}},
+		Audit: func(d Decision) {
+			decisions = append(decisions, d)
+		},
+	}, func() ([]mptcp.Entry, error) {
+		return nil, errors.New("entries failed")
+	}, func(int) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.Handler().Established(&pm.Connection{
+		Token:  1,
+		Local:  &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 40000},
+		Remote: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1).To4(), Port: 443},
+	})
+	e.refresh()
+
+	if len(decisions) != 2 {
+		t.Fatalf("unexpected decisions: %v", decisions)
+	}
+	if d := decisions[0]; d.Action != ActionCreate || !errors.Is(d.Err, errCreate) || d.Event != pm.EventEstablished {
+		t.Fatalf("unexpected create decision: %+v", d)
+	}
+	if d := decisions[1]; d.Action != ActionError || d.Err == nil {
+		t.Fatalf("unexpected error decision: %+v", d)
+	}
+	if c := e.conns[1]; len(c.Pending) != 0 {
+		t.Fatalf("unexpected pending subflows: %v", c.Pending)
+	}
+}
+
+// TestNewEngineInvalid verifies that newEngine rejects unusable endpoints.
+func TestNewEngineInvalid(t *testing.T) {
+	for i, ep := range []pm.Endpoint{
+		{Address: net.IPv4(192, 0, 2, 1)},
+		{ID: 1},
+		{ID: 1, Address: net.IPv4(192, 0, 2, 1), Flags: pm.FlagSignal},
+	} {
+		if _, err := newEngine(&fakeClient{}, Config{Endpoints: []pm.Endpoint{ep}}, nil, nil); err == nil {
+			t.Fatalf("[%02d] expected an error, but none occurred", i)
+		}
+	}
+}
+
+// TestEngineRun verifies that Run serves events until its context is
+// canceled.
+func TestEngineRun(t *testing.T) {
+	e, err := newEngine(&fakeClient{}, Config{Interval: time.Millisecond}, func() ([]mptcp.Entry, error) {
+		return nil, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := e.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, but got: %v", err)
+	}
+}
+
+// A fakeClient is a client which records operations.
+type fakeClient struct {
+	ops []string
+	err error
+}
+
+func (c *fakeClient) Serve(ctx context.Context, _ pm.Handler) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *fakeClient) CreateSubflow(token mptcp.Token, local pm.Endpoint, remote *net.TCPAddr) error {
+	c.ops = append(c.ops, fmt.Sprintf("create %d %s -> %s %s", token, local.Address, remote, local.Flags))
+	return c.err
+}
+
+func (c *fakeClient) DestroySubflow(token mptcp.Token, s pm.Subflow) error {
+	c.ops = append(c.ops, fmt.Sprintf("destroy %d %s", token, s))
+	return c.err
+}
+
+func (c *fakeClient) SetSubflowFlags(token mptcp.Token, s pm.Subflow, f pm.Flags) error {
+	c.ops = append(c.ops, fmt.Sprintf("set %d %s %s", token, s, f))
+	return c.err
+}
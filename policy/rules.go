@@ -0,0 +1,222 @@
+// Package policy provides a rule-based engine which decides how multipath TCP
+// connections use additional subflows, on top of the kernel's userspace path
+// manager.
+//
+// An Engine receives path manager events using package pm, evaluates its
+// Rules for each candidate or established subflow, and creates subflows,
+// destroys them, or marks them as backup subflows accordingly.  Each decision
+// is reported to an audit function.
+//
+// Package policy requires the userspace path manager, enabled by setting the
+// net.mptcp.pm_type sysctl to 1.
+package policy
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// A Verdict is the result of evaluating a Rule for a subflow.
+type Verdict int
+
+// Possible Verdict values.
+const (
+	// Allow permits a subflow, unless another rule denies it.
+	Allow Verdict = iota
+
+	// Backup permits a subflow, but marks it as a backup subflow.
+	Backup
+
+	// Deny prevents a subflow from being created, or destroys it if it
+	// is already established.
+	Deny
+)
+
+// String returns the string representation of a Verdict.
+func (v Verdict) String() string {
+	switch v {
+	case Allow:
+		return "allow"
+	case Backup:
+		return "backup"
+	case Deny:
+		return "deny"
+	default:
+		return fmt.Sprintf("Verdict(%d)", int(v))
+	}
+}
+
+// A Rule decides whether a subflow of a Connection is permitted.  Rules are
+// evaluated for subflows which the Engine may create, and again for subflows
+// once they are established and each time connection statistics are
+// refreshed.
+type Rule interface {
+	// Evaluate returns a Verdict for subflow s of connection c.
+	Evaluate(c *Connection, s Subflow) Verdict
+
+	// String describes the rule, for the audit log.
+	String() string
+}
+
+// RFC1918 contains the IPv4 private address prefixes from RFC 1918, for use
+// with DenyRemote.
+var RFC1918 = []*net.IPNet{
+	mustCIDR("10.0.0.0/8"),
+	mustCIDR("172.16.0.0/12"),
+	mustCIDR("192.168.0.0/16"),
+}
+
+// mustCIDR parses a CIDR prefix, and panics if it is invalid.
+func mustCIDR(s string) *net.IPNet {
+	_, ipn, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return ipn
+}
+
+// MaxSubflows returns a Rule which permits at most n subflows per connection,
+// including the initial subflow.  Subflows which are being created count
+// towards the limit.
+func MaxSubflows(n int) Rule {
+	return maxSubflows(n)
+}
+
+type maxSubflows int
+
+func (r maxSubflows) Evaluate(c *Connection, s Subflow) Verdict {
+	// The initial subflow is always present.  Established subflows are
+	// counted in the order they were established, so the newest subflows
+	// are denied when over the limit.
+	count := 1 + len(c.Subflows) + len(c.Pending)
+	if i := c.index(s); i != -1 {
+		count = 1 + i
+	}
+
+	if count >= int(r) {
+		return Deny
+	}
+
+	return Allow
+}
+
+func (r maxSubflows) String() string {
+	return fmt.Sprintf("max_subflows %d", int(r))
+}
+
+// Interfaces returns a Rule which only permits subflows using local network
+// interfaces whose names match one of patterns, using path.Match.
+func Interfaces(patterns ...string) (Rule, error) {
+	if err := checkPatterns(patterns); err != nil {
+		return nil, err
+	}
+
+	return interfaces(patterns), nil
+}
+
+type interfaces []string
+
+func (r interfaces) Evaluate(_ *Connection, s Subflow) Verdict {
+	if matchAny(r, s.Interface) {
+		return Allow
+	}
+
+	return Deny
+}
+
+func (r interfaces) String() string {
+	return "interfaces " + strings.Join(r, ",")
+}
+
+// BackupAfter returns a Rule which marks subflows using local network
+// interfaces whose names match one of patterns as backup subflows, once a
+// connection has transferred at least n bytes.  This may be used to prefer
+// other paths for large transfers over metered links, such as cellular.
+func BackupAfter(n uint64, patterns ...string) (Rule, error) {
+	if err := checkPatterns(patterns); err != nil {
+		return nil, err
+	}
+
+	return &backupAfter{
+		n:        n,
+		patterns: patterns,
+	}, nil
+}
+
+type backupAfter struct {
+	n        uint64
+	patterns []string
+}
+
+func (r *backupAfter) Evaluate(c *Connection, s Subflow) Verdict {
+	if c.Bytes >= r.n && matchAny(r.patterns, s.Interface) {
+		return Backup
+	}
+
+	return Allow
+}
+
+func (r *backupAfter) String() string {
+	return fmt.Sprintf("backup_after %d %s", r.n, strings.Join(r.patterns, ","))
+}
+
+// DenyRemote returns a Rule which denies subflows whose remote address is
+// within one of prefixes, such as RFC1918.
+func DenyRemote(prefixes ...*net.IPNet) Rule {
+	return denyRemote(prefixes)
+}
+
+type denyRemote []*net.IPNet
+
+func (r denyRemote) Evaluate(_ *Connection, s Subflow) Verdict {
+	if s.Remote == nil {
+		return Allow
+	}
+
+	for _, ipn := range r {
+		if ipn.Contains(s.Remote.IP) {
+			return Deny
+		}
+	}
+
+	return Allow
+}
+
+func (r denyRemote) String() string {
+	ss := make([]string, 0, len(r))
+	for _, ipn := range r {
+		ss = append(ss, ipn.String())
+	}
+
+	return "deny_remote " + strings.Join(ss, ",")
+}
+
+// checkPatterns verifies that patterns are valid for path.Match.
+func checkPatterns(patterns []string) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("policy: at least one interface pattern is required")
+	}
+
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("policy: invalid interface pattern %q: %v", p, err)
+		}
+	}
+
+	return nil
+}
+
+// matchAny reports whether name matches any of patterns, which must have been
+// checked by checkPatterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
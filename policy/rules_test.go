@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"net"
+	"testing"
+)
+
+// TestRules verifies the Verdicts of the built-in Rules.
+func TestRules(t *testing.T) {
+	var (
+		local  = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1)}
+		remote = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 443}
+
+		sf1 = Subflow{Local: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 40000}, Remote: remote, Interface: "eth1"}
+		sf2 = Subflow{Local: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 3), Port: 40001}, Remote: remote, Interface: "wwan0"}
+		new = Subflow{Local: local, Remote: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 443}, Interface: "eth0"}
+	)
+
+	c := &Connection{Subflows: []Subflow{sf1, sf2}}
+
+	var tests = []struct {
+		desc string
+		r    Rule
+		c    *Connection
+		s    Subflow
+		v    Verdict
+	}{
+		{
+			desc: "max subflows, room for new",
+			r:    MaxSubflows(4),
+			c:    c,
+			s:    new,
+			v:    Allow,
+		},
+		{
+			desc: "max subflows, pending fills limit",
+			r:    MaxSubflows(3),
+			c:    &Connection{Subflows: []Subflow{sf1}, Pending: []Subflow{sf2}},
+			s:    new,
+			v:    Deny,
+		},
+		{
+			desc: "max subflows, established within limit",
+			r:    MaxSubflows(2),
+			c:    c,
+			s:    sf1,
+			v:    Allow,
+		},
+		{
+			desc: "max subflows, established over limit",
+			r:    MaxSubflows(2),
+			c:    c,
+			s:    sf2,
+			v:    Deny,
+		},
+		{
+			desc: "interfaces match",
+			r:    mustRule(Interfaces("eth*")),
+			c:    c,
+			s:    sf1,
+			v:    Allow,
+		},
+		{
+			desc: "interfaces no match",
+			r:    mustRule(Interfaces("eth*")),
+			c:    c,
+			s:    sf2,
+			v:    Deny,
+		},
+		{
+			desc: "backup after, below threshold",
+			r:    mustRule(BackupAfter(1000, "wwan*")),
+			c:    &Connection{Bytes: 999},
+			s:    sf2,
+			v:    Allow,
+		},
+		{
+			desc: "backup after, above threshold",
+			r:    mustRule(BackupAfter(1000, "wwan*")),
+			c:    &Connection{Bytes: 1000},
+			s:    sf2,
+			v:    Backup,
+		},
+		{
+			desc: "backup after, other interface",
+			r:    mustRule(BackupAfter(1000, "wwan*")),
+			c:    &Connection{Bytes: 1000},
+			s:    sf1,
+			v:    Allow,
+		},
+		{
+			desc: "deny remote, public",
+			r:    DenyRemote(RFC1918...),
+			c:    c,
+			s:    sf1,
+			v:    Allow,
+		},
+		{
+			desc: "deny remote, RFC 1918",
+			r:    DenyRemote(RFC1918...),
+			c:    c,
+			s:    new,
+			v:    Deny,
+		},
+	}
+
+	for i, test := range tests {
+		if v := test.r.Evaluate(test.c, test.s); v != test.v {
+			t.Fatalf("[%02d] %s: unexpected verdict: %s != %s", i, test.desc, v, test.v)
+		}
+	}
+}
+
+// TestRulesInvalid verifies that Rules reject invalid interface patterns.
+func TestRulesInvalid(t *testing.T) {
+	if _, err := Interfaces(); err == nil {
+		t.Fatal("expected an error for no patterns, but none occurred")
+	}
+	if _, err := BackupAfter(1, "eth["); err == nil {
+		t.Fatal("expected an error for an invalid pattern, but none occurred")
+	}
+}
+
+// mustRule panics if err is not nil.
+func mustRule(r Rule, err error) Rule {
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
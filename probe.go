@@ -0,0 +1,135 @@
+package mptcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultProbeWait is the time Probe waits after connecting for a peer to
+// announce additional addresses.
+const DefaultProbeWait = 250 * time.Millisecond
+
+var (
+	// ErrLocalUnsupported is returned by Probe when the local host could
+	// not create a multipath TCP socket, so the peer's support cannot be
+	// determined.
+	ErrLocalUnsupported = errors.New("multipath TCP is not supported by the local host")
+)
+
+// A ProbeResult reports whether a peer supports multipath TCP.
+type ProbeResult struct {
+	// Remote is the address of the peer which was probed.
+	Remote *net.TCPAddr
+
+	// Capable reports whether the peer negotiated multipath TCP.  If
+	// false, the connection fell back to regular TCP.
+	Capable bool
+
+	// Version is the multipath TCP version of the connection, when
+	// Capable is true.  The kernel does not report the negotiated version,
+	// and mainline Linux kernels only implement version 1, from RFC 8684,
+	// so Version is always 1 when Capable is true.
+	Version int
+
+	// Announced is the number of additional addresses which the peer
+	// announced using ADD_ADDR.  It is counted using Prober.Announced if
+	// set.  Otherwise it is the number of addresses accepted by the local
+	// path manager, which is at most its add_addr_accepted limit: zero by
+	// default, in which case Announced is always zero.
+	Announced int
+
+	// Info is detailed information about the connection at the end of the
+	// probe, when Capable is true.
+	Info *Info
+}
+
+// A Prober probes whether peers support multipath TCP.
+type Prober struct {
+	// Dialer is used to connect to each peer.
+	Dialer Dialer
+
+	// Wait is the time to wait after connecting for the peer to announce
+	// additional addresses.  If zero, the peer is not given time to
+	// announce addresses.
+	Wait time.Duration
+
+	// Announced, if set, returns the number of addresses announced by the
+	// peer of the connection with local token t, such as the Count method
+	// of package pm's Announcements.  It is called after Wait, before the
+	// connection is closed.  If nil, addresses accepted by the local path
+	// manager are counted instead; see ProbeResult.Announced.
+	Announced func(t Token) int
+}
+
+// Probe connects to hostport using a Prober which waits DefaultProbeWait for
+// additional addresses, and reports whether the peer supports multipath TCP.
+func Probe(ctx context.Context, hostport string) (*ProbeResult, error) {
+	p := Prober{Wait: DefaultProbeWait}
+	return p.Probe(ctx, hostport)
+}
+
+// Probe connects to hostport and reports whether the peer supports multipath
+// TCP.  The connection is closed before Probe returns.  If ctx is done while
+// waiting for additional addresses, Probe stops waiting and reports the
+// result.
+//
+// If probing is not implemented for the current operating system, Probe will
+// return ErrNotImplemented.
+func (p *Prober) Probe(ctx context.Context, hostport string) (*ProbeResult, error) {
+	c, err := p.Dialer.DialContext(ctx, "tcp", hostport)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	// A regular TCP socket is silently used when the local host cannot
+	// create a multipath TCP socket, which would appear to be a fallback.
+	ok, err := isMPTCP(c.tc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLocalUnsupported
+	}
+
+	r := &ProbeResult{
+		Remote: c.RemoteAddr().(*net.TCPAddr),
+	}
+
+	fallback, err := c.IsFallback()
+	if err != nil || fallback {
+		return r, err
+	}
+
+	if p.Wait > 0 {
+		t := time.NewTimer(p.Wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+		}
+	}
+
+	info, err := c.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	// The peer's key confirms that it negotiated multipath TCP, rather
+	// than the connection falling back later.
+	if info.Fallback || !info.RemoteKeyReceived {
+		return r, nil
+	}
+
+	r.Capable = true
+	r.Version = 1
+	r.Announced = info.AddAddrAccepted
+	if p.Announced != nil {
+		r.Announced = p.Announced(info.Token)
+	}
+	r.Info = info
+
+	return r, nil
+}
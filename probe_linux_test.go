@@ -0,0 +1,144 @@
+// +build linux
+
+package mptcp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestLinux_ProbeLoopback verifies that Probe distinguishes multipath TCP
+// listeners from regular TCP listeners over the loopback interface.
+func TestLinux_ProbeLoopback(t *testing.T) {
+	if ok, _ := Enabled(); !ok {
+		t.Skip("skipping, multipath TCP is not enabled")
+	}
+
+	ml, err := Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ml.Close()
+	go serveDiscard(ml)
+
+	tl := listenTCP(t)
+	defer tl.Close()
+	go serveDiscard(tl)
+
+	var tests = []struct {
+		desc    string
+		l       net.Listener
+		capable bool
+		version int
+	}{
+		{
+			desc:    "multipath TCP",
+			l:       ml,
+			capable: true,
+			version: 1,
+		},
+		{
+			desc: "regular TCP",
+			l:    tl,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i, test := range tests {
+		p := Prober{Wait: 10 * time.Millisecond}
+		r, err := p.Probe(ctx, test.l.Addr().String())
+		if err != nil {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, test.desc, err)
+		}
+
+		if want, got := test.l.Addr().String(), r.Remote.String(); want != got {
+			t.Fatalf("[%02d] test %q, unexpected remote address: %v != %v",
+				i, test.desc, want, got)
+		}
+
+		if r.Capable != test.capable || r.Version != test.version {
+			t.Fatalf("[%02d] test %q, unexpected result: capable %v, version %d",
+				i, test.desc, r.Capable, r.Version)
+		}
+
+		if r.Capable != (r.Info != nil) {
+			t.Fatalf("[%02d] test %q, Info must be set only when capable: %+v",
+				i, test.desc, r.Info)
+		}
+	}
+}
+
+// TestLinux_ProbeAnnounced verifies that Probe counts announced addresses
+// using Prober.Announced when it is set, rather than the addresses accepted by
+// the local path manager.
+func TestLinux_ProbeAnnounced(t *testing.T) {
+	if ok, _ := Enabled(); !ok {
+		t.Skip("skipping, multipath TCP is not enabled")
+	}
+
+	l, err := Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go serveDiscard(l)
+
+	var token Token
+	p := Prober{
+		Announced: func(t Token) int {
+			token = t
+			return 2
+		},
+	}
+
+	r, err := p.Probe(context.Background(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to probe: %v", err)
+	}
+
+	if !r.Capable || r.Announced != 2 || token != r.Info.Token {
+		t.Fatalf("unexpected result: capable %v, announced %d, token %s != %s",
+			r.Capable, r.Announced, token, r.Info.Token)
+	}
+}
+
+// TestLinux_ProbeRefused verifies that Probe returns an error when a
+// connection cannot be established.
+func TestLinux_ProbeRefused(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	r, err := Probe(context.Background(), addr)
+	if r != nil || err == nil {
+		t.Fatalf("expected error for refused connection, but got: (%+v, %v)", r, err)
+	}
+}
+
+// serveDiscard accepts connections from l until it is closed, and reads from
+// each connection until the peer closes it.
+func serveDiscard(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer c.Close()
+			b := make([]byte, 64)
+			for {
+				if _, err := c.Read(b); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
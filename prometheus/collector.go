@@ -0,0 +1,190 @@
+// Package prometheus provides a Prometheus collector for host-wide multipath
+// TCP connection and subflow metrics.
+package prometheus
+
+import (
+	"github.com/mdlayher/mptcp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// subflowBuckets are the histogram buckets used to count subflows
+	// per connection.
+	subflowBuckets = prometheus.LinearBuckets(1, 1, 8)
+)
+
+// A collector is a prometheus.Collector for multipath TCP metrics.
+type collector struct {
+	Enabled     *prometheus.Desc
+	Connections *prometheus.Desc
+	Subflows    *prometheus.Desc
+	TxQueue     *prometheus.Desc
+	RxQueue     *prometheus.Desc
+
+	enabled func() (bool, error)
+	entries func() ([]mptcp.Entry, error)
+}
+
+// Verify that collector implements prometheus.Collector.
+var _ prometheus.Collector = &collector{}
+
+// NewCollector creates a new prometheus.Collector which gathers metrics
+// about the multipath TCP connections on the current host, using
+// mptcp.Enabled and mptcp.Entries.
+func NewCollector() prometheus.Collector {
+	return newCollector(mptcp.Enabled, mptcp.Entries)
+}
+
+// newCollector creates a collector which uses the input functions as its
+// data sources.
+func newCollector(
+	enabled func() (bool, error),
+	entries func() ([]mptcp.Entry, error),
+) *collector {
+	const subsystem = "mptcp"
+
+	return &collector{
+		Enabled: prometheus.NewDesc(
+			prometheus.BuildFQName("", subsystem, "enabled"),
+			"Whether or not multipath TCP is enabled on this host.",
+			nil, nil,
+		),
+
+		Connections: prometheus.NewDesc(
+			prometheus.BuildFQName("", subsystem, "connections"),
+			"Number of active multipath TCP connections.",
+			[]string{"state", "family"}, nil,
+		),
+
+		Subflows: prometheus.NewDesc(
+			prometheus.BuildFQName("", subsystem, "connection_subflows"),
+			"Distribution of the number of subflows per multipath TCP connection.",
+			nil, nil,
+		),
+
+		TxQueue: prometheus.NewDesc(
+			prometheus.BuildFQName("", subsystem, "tx_queue_bytes"),
+			"Total bytes queued for transmission on multipath TCP connections.",
+			nil, nil,
+		),
+
+		RxQueue: prometheus.NewDesc(
+			prometheus.BuildFQName("", subsystem, "rx_queue_bytes"),
+			"Total bytes queued for reception on multipath TCP connections.",
+			nil, nil,
+		),
+
+		enabled: enabled,
+		entries: entries,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ds := []*prometheus.Desc{
+		c.Enabled,
+		c.Connections,
+		c.Subflows,
+		c.TxQueue,
+		c.RxQueue,
+	}
+
+	for _, d := range ds {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	enabled, err := c.enabled()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.Enabled, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.Enabled,
+		prometheus.GaugeValue,
+		boolFloat(enabled),
+	)
+
+	// No connections can exist without multipath TCP
+	if !enabled {
+		return
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.Connections, err)
+		return
+	}
+
+	type key struct {
+		state  mptcp.State
+		family string
+	}
+
+	var (
+		conns   = make(map[key]int)
+		buckets = make(map[float64]uint64, len(subflowBuckets))
+		sum     int
+		tx, rx  int
+	)
+
+	for _, e := range entries {
+		family := "ipv4"
+		if e.IPv6 {
+			family = "ipv6"
+		}
+		conns[key{state: e.State, family: family}]++
+
+		// Buckets are cumulative, so count this connection in every
+		// bucket it fits within
+		for _, b := range subflowBuckets {
+			if float64(e.Subflows) <= b {
+				buckets[b]++
+			}
+		}
+		sum += e.Subflows
+
+		tx += e.TxQueue
+		rx += e.RxQueue
+	}
+
+	for k, n := range conns {
+		ch <- prometheus.MustNewConstMetric(
+			c.Connections,
+			prometheus.GaugeValue,
+			float64(n),
+			k.state.String(), k.family,
+		)
+	}
+
+	ch <- prometheus.MustNewConstHistogram(
+		c.Subflows,
+		uint64(len(entries)),
+		float64(sum),
+		buckets,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.TxQueue,
+		prometheus.GaugeValue,
+		float64(tx),
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.RxQueue,
+		prometheus.GaugeValue,
+		float64(rx),
+	)
+}
+
+// boolFloat converts a bool to a float64 for use as a metric value.
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
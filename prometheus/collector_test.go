@@ -0,0 +1,127 @@
+package prometheus
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestCollector verifies that collector produces the expected metrics from
+// mock data sources.
+func TestCollector(t *testing.T) {
+	entries := []mptcp.Entry{
+		{
+			State:    mptcp.StateEstablished,
+			Local:    &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1)},
+			Subflows: 1,
+			TxQueue:  100,
+			RxQueue:  10,
+		},
+		{
+			State:    mptcp.StateEstablished,
+			Local:    &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1)},
+			Subflows: 3,
+			TxQueue:  200,
+		},
+		{
+			IPv6:     true,
+			State:    mptcp.StateCloseWait,
+			Local:    &net.TCPAddr{IP: net.ParseIP("2001:db8::1")},
+			Subflows: 2,
+			RxQueue:  20,
+		},
+	}
+
+	var tests = []struct {
+		desc    string
+		enabled bool
+		entries []mptcp.Entry
+		metrics string
+	}{
+		{
+			desc: "disabled",
+			metrics: `
+# HELP mptcp_enabled Whether or not multipath TCP is enabled on this host.
+# TYPE mptcp_enabled gauge
+mptcp_enabled 0
+`,
+		},
+		{
+			desc:    "enabled, connections",
+			enabled: true,
+			entries: entries,
+			metrics: `
+# HELP mptcp_connection_subflows Distribution of the number of subflows per multipath TCP connection.
+# TYPE mptcp_connection_subflows histogram
+mptcp_connection_subflows_bucket{le="1"} 1
+mptcp_connection_subflows_bucket{le="2"} 2
+mptcp_connection_subflows_bucket{le="3"} 3
+mptcp_connection_subflows_bucket{le="4"} 3
+mptcp_connection_subflows_bucket{le="5"} 3
+mptcp_connection_subflows_bucket{le="6"} 3
+mptcp_connection_subflows_bucket{le="7"} 3
+mptcp_connection_subflows_bucket{le="8"} 3
+mptcp_connection_subflows_bucket{le="+Inf"} 3
+mptcp_connection_subflows_sum 6
+mptcp_connection_subflows_count 3
+# HELP mptcp_connections Number of active multipath TCP connections.
+# TYPE mptcp_connections gauge
+mptcp_connections{family="ipv4",state="ESTABLISHED"} 2
+mptcp_connections{family="ipv6",state="CLOSE_WAIT"} 1
+# HELP mptcp_enabled Whether or not multipath TCP is enabled on this host.
+# TYPE mptcp_enabled gauge
+mptcp_enabled 1
+# HELP mptcp_rx_queue_bytes Total bytes queued for reception on multipath TCP connections.
+# TYPE mptcp_rx_queue_bytes gauge
+mptcp_rx_queue_bytes 30
+# HELP mptcp_tx_queue_bytes Total bytes queued for transmission on multipath TCP connections.
+# TYPE mptcp_tx_queue_bytes gauge
+mptcp_tx_queue_bytes 300
+`,
+		},
+	}
+
+	for i, test := range tests {
+		c := newCollector(
+			func() (bool, error) { return test.enabled, nil },
+			func() ([]mptcp.Entry, error) { return test.entries, nil },
+		)
+
+		if err := testutil.CollectAndCompare(c, strings.NewReader(test.metrics)); err != nil {
+			t.Fatalf("[%02d] %s: unexpected metrics: %v", i, test.desc, err)
+		}
+	}
+}
+
+// TestCollectorError verifies that collector reports errors from its data
+// sources as invalid metrics.
+func TestCollectorError(t *testing.T) {
+	errFoo := errors.New("foo")
+
+	var tests = []struct {
+		desc    string
+		enabled func() (bool, error)
+		entries func() ([]mptcp.Entry, error)
+	}{
+		{
+			desc:    "enabled",
+			enabled: func() (bool, error) { return false, errFoo },
+		},
+		{
+			desc:    "entries",
+			enabled: func() (bool, error) { return true, nil },
+			entries: func() ([]mptcp.Entry, error) { return nil, errFoo },
+		},
+	}
+
+	for i, test := range tests {
+		c := newCollector(test.enabled, test.entries)
+		if err := testutil.CollectAndCompare(c, strings.NewReader("")); err == nil {
+			t.Fatalf("[%02d] %s: expected an error, but none occurred", i, test.desc)
+		}
+	}
+}
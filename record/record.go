@@ -0,0 +1,500 @@
+// Package record records snapshots of multipath TCP connections and path
+// manager events to a log, and replays them.
+//
+// A log is a file of JSON objects, one per line, each with a timestamp.  A
+// Recorder writes periodic snapshots from mptcp.Entries and events from
+// pm.Client.Events to a Writer.  A Reader reads the log back, and may replay
+// it to the same consumers as a live host: its Entries method stands in for
+// mptcp.Entries, such as in an mptcp.Watcher, Watch replays snapshots as
+// mptcp.Events using mptcp.Diff, and Events replays path manager events.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// A Kind is the kind of a Record.
+type Kind int
+
+// Possible Kind values.
+const (
+	// KindSnapshot is a snapshot of all connections.
+	KindSnapshot Kind = iota + 1
+
+	// KindEvent is a path manager event.
+	KindEvent
+)
+
+// String returns the name of a Kind, as used in a log.
+func (k Kind) String() string {
+	switch k {
+	case KindSnapshot:
+		return "snapshot"
+	case KindEvent:
+		return "event"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// A Record is a single entry in a log.
+type Record struct {
+	// Kind is the kind of the record.
+	Kind Kind
+
+	// Time is the time at which the snapshot was taken or the event was
+	// received.
+	Time time.Time
+
+	// Entries are the connections of a snapshot, for KindSnapshot.
+	Entries []mptcp.Entry
+
+	// Event is the path manager event, for KindEvent.
+	Event *pm.Event
+}
+
+// A Writer writes records to a log.  Writers are safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewWriter creates a Writer which writes a log to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w)}
+}
+
+// WriteSnapshot writes a snapshot of connections taken at time t.
+func (w *Writer) WriteSnapshot(t time.Time, entries []mptcp.Entry) error {
+	return w.Write(Record{
+		Kind:    KindSnapshot,
+		Time:    t,
+		Entries: entries,
+	})
+}
+
+// WriteEvent writes a path manager event received at time t.
+func (w *Writer) WriteEvent(t time.Time, e pm.Event) error {
+	return w.Write(Record{
+		Kind:  KindEvent,
+		Time:  t,
+		Event: &e,
+	})
+}
+
+// Write writes a Record.
+func (w *Writer) Write(r Record) error {
+	rj, err := marshalRecord(r)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(rj)
+}
+
+// A Reader reads records from a log.
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// maxLine is the maximum length of a line in a log, which bounds the size of
+// a snapshot.
+const maxLine = 64 << 20
+
+// NewReader creates a Reader which reads a log from r.
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLine)
+
+	return &Reader{s: s}
+}
+
+// Next returns the next Record in the log.  At the end of the log, Next
+// returns io.EOF.
+func (r *Reader) Next() (*Record, error) {
+	for r.s.Scan() {
+		r.line++
+
+		b := r.s.Bytes()
+		if len(strings.TrimSpace(string(b))) == 0 {
+			continue
+		}
+
+		var rj recordJSON
+		if err := json.Unmarshal(b, &rj); err != nil {
+			return nil, fmt.Errorf("record: line %d: %v", r.line, err)
+		}
+
+		rec, err := rj.record()
+		if err != nil {
+			return nil, fmt.Errorf("record: line %d: %v", r.line, err)
+		}
+
+		return rec, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+// recordJSON is the JSON representation of a Record.
+type recordJSON struct {
+	Time    time.Time   `json:"time"`
+	Kind    string      `json:"kind"`
+	Entries []entryJSON `json:"entries,omitempty"`
+	Event   *eventJSON  `json:"event,omitempty"`
+}
+
+// entryJSON is the JSON representation of an mptcp.Entry.
+type entryJSON struct {
+	LocalToken  string    `json:"local_token"`
+	RemoteToken string    `json:"remote_token,omitempty"`
+	IPv6        bool      `json:"ipv6,omitempty"`
+	Local       string    `json:"local"`
+	Remote      string    `json:"remote"`
+	State       uint8     `json:"state"`
+	Subflows    int       `json:"subflows"`
+	TxQueue     int       `json:"tx_queue,omitempty"`
+	RxQueue     int       `json:"rx_queue,omitempty"`
+	Inode       uint64    `json:"inode,omitempty"`
+	Info        *infoJSON `json:"info,omitempty"`
+}
+
+// infoJSON is the JSON representation of an mptcp.Info.  Durations are in
+// milliseconds, as reported by the kernel.
+type infoJSON struct {
+	Subflows           int    `json:"subflows"`
+	SubflowsMax        int    `json:"subflows_max"`
+	AddAddrSignal      int    `json:"add_addr_signal"`
+	AddAddrAccepted    int    `json:"add_addr_accepted"`
+	AddAddrSignalMax   int    `json:"add_addr_signal_max"`
+	AddAddrAcceptedMax int    `json:"add_addr_accepted_max"`
+	Fallback           bool   `json:"fallback,omitempty"`
+	RemoteKeyReceived  bool   `json:"remote_key_received,omitempty"`
+	Token              string `json:"token"`
+	WriteSeq           uint64 `json:"write_seq"`
+	SndUna             uint64 `json:"snd_una"`
+	RcvNxt             uint64 `json:"rcv_nxt"`
+	LocalAddrUsed      int    `json:"local_addr_used,omitempty"`
+	LocalAddrMax       int    `json:"local_addr_max,omitempty"`
+	ChecksumEnabled    bool   `json:"checksum_enabled,omitempty"`
+	Retransmits        uint32 `json:"retransmits,omitempty"`
+	BytesRetrans       uint64 `json:"bytes_retrans,omitempty"`
+	BytesSent          uint64 `json:"bytes_sent,omitempty"`
+	BytesReceived      uint64 `json:"bytes_received,omitempty"`
+	BytesAcked         uint64 `json:"bytes_acked,omitempty"`
+	SubflowsTotal      int    `json:"subflows_total,omitempty"`
+	LastDataSent       int64  `json:"last_data_sent_ms,omitempty"`
+	LastDataRecv       int64  `json:"last_data_recv_ms,omitempty"`
+	LastAckRecv        int64  `json:"last_ack_recv_ms,omitempty"`
+}
+
+// eventJSON is the JSON representation of a pm.Event.
+type eventJSON struct {
+	Type        string `json:"type"`
+	Token       string `json:"token"`
+	Local       string `json:"local,omitempty"`
+	Remote      string `json:"remote,omitempty"`
+	LocalID     uint8  `json:"local_id,omitempty"`
+	RemoteID    uint8  `json:"remote_id,omitempty"`
+	Backup      bool   `json:"backup,omitempty"`
+	Error       int    `json:"error,omitempty"`
+	Interface   int    `json:"interface,omitempty"`
+	ServerSide  bool   `json:"server_side,omitempty"`
+	Flags       uint16 `json:"flags,omitempty"`
+	ResetReason uint32 `json:"reset_reason,omitempty"`
+	ResetFlags  uint32 `json:"reset_flags,omitempty"`
+}
+
+// eventTypes maps the names of pm.EventType values to their values.
+var eventTypes = func() map[string]pm.EventType {
+	m := make(map[string]pm.EventType)
+	for i := 0; i < 256; i++ {
+		t := pm.EventType(i)
+		if s := t.String(); !strings.HasPrefix(s, "EventType(") {
+			m[s] = t
+		}
+	}
+
+	return m
+}()
+
+// marshalRecord creates the JSON representation of r.
+func marshalRecord(r Record) (*recordJSON, error) {
+	rj := &recordJSON{
+		Time: r.Time,
+		Kind: r.Kind.String(),
+	}
+
+	switch r.Kind {
+	case KindSnapshot:
+		rj.Entries = make([]entryJSON, 0, len(r.Entries))
+		for _, e := range r.Entries {
+			rj.Entries = append(rj.Entries, marshalEntry(e))
+		}
+	case KindEvent:
+		if r.Event == nil {
+			return nil, errors.New("record: event record has no event")
+		}
+		if r.Event.Type == pm.EventError {
+			return nil, errors.New("record: subscription errors cannot be recorded")
+		}
+
+		rj.Event = marshalEvent(*r.Event)
+	default:
+		return nil, fmt.Errorf("record: invalid record kind %d", int(r.Kind))
+	}
+
+	return rj, nil
+}
+
+// record creates a Record from its JSON representation.
+func (rj *recordJSON) record() (*Record, error) {
+	r := &Record{Time: rj.Time}
+
+	switch rj.Kind {
+	case KindSnapshot.String():
+		r.Kind = KindSnapshot
+		r.Entries = make([]mptcp.Entry, 0, len(rj.Entries))
+		for _, ej := range rj.Entries {
+			e, err := ej.entry()
+			if err != nil {
+				return nil, err
+			}
+
+			r.Entries = append(r.Entries, *e)
+		}
+	case KindEvent.String():
+		if rj.Event == nil {
+			return nil, errors.New("event record has no event")
+		}
+
+		e, err := rj.Event.event()
+		if err != nil {
+			return nil, err
+		}
+
+		r.Kind = KindEvent
+		r.Event = e
+	default:
+		return nil, fmt.Errorf("unknown record kind %q", rj.Kind)
+	}
+
+	return r, nil
+}
+
+// marshalEntry creates the JSON representation of e.
+func marshalEntry(e mptcp.Entry) entryJSON {
+	ej := entryJSON{
+		LocalToken: e.LocalToken.String(),
+		IPv6:       e.IPv6,
+		Local:      formatAddr(e.Local),
+		Remote:     formatAddr(e.Remote),
+		State:      uint8(e.State),
+		Subflows:   e.Subflows,
+		TxQueue:    e.TxQueue,
+		RxQueue:    e.RxQueue,
+		Inode:      e.Inode,
+	}
+	if e.RemoteToken != 0 {
+		ej.RemoteToken = e.RemoteToken.String()
+	}
+
+	if i := e.Info; i != nil {
+		ej.Info = &infoJSON{
+			Subflows:           i.Subflows,
+			SubflowsMax:        i.SubflowsMax,
+			AddAddrSignal:      i.AddAddrSignal,
+			AddAddrAccepted:    i.AddAddrAccepted,
+			AddAddrSignalMax:   i.AddAddrSignalMax,
+			AddAddrAcceptedMax: i.AddAddrAcceptedMax,
+			Fallback:           i.Fallback,
+			RemoteKeyReceived:  i.RemoteKeyReceived,
+			Token:              i.Token.String(),
+			WriteSeq:           i.WriteSeq,
+			SndUna:             i.SndUna,
+			RcvNxt:             i.RcvNxt,
+			LocalAddrUsed:      i.LocalAddrUsed,
+			LocalAddrMax:       i.LocalAddrMax,
+			ChecksumEnabled:    i.ChecksumEnabled,
+			Retransmits:        i.Retransmits,
+			BytesRetrans:       i.BytesRetrans,
+			BytesSent:          i.BytesSent,
+			BytesReceived:      i.BytesReceived,
+			BytesAcked:         i.BytesAcked,
+			SubflowsTotal:      i.SubflowsTotal,
+			LastDataSent:       i.LastDataSent.Milliseconds(),
+			LastDataRecv:       i.LastDataRecv.Milliseconds(),
+			LastAckRecv:        i.LastAckRecv.Milliseconds(),
+		}
+	}
+
+	return ej
+}
+
+// entry creates an mptcp.Entry from its JSON representation.
+func (ej *entryJSON) entry() (*mptcp.Entry, error) {
+	e := &mptcp.Entry{
+		IPv6:     ej.IPv6,
+		State:    mptcp.State(ej.State),
+		Subflows: ej.Subflows,
+		TxQueue:  ej.TxQueue,
+		RxQueue:  ej.RxQueue,
+		Inode:    ej.Inode,
+	}
+
+	var err error
+	if e.LocalToken, err = mptcp.ParseToken(ej.LocalToken); err != nil {
+		return nil, fmt.Errorf("invalid local token %q", ej.LocalToken)
+	}
+	if ej.RemoteToken != "" {
+		if e.RemoteToken, err = mptcp.ParseToken(ej.RemoteToken); err != nil {
+			return nil, fmt.Errorf("invalid remote token %q", ej.RemoteToken)
+		}
+	}
+	if e.Local, err = parseAddr(ej.Local); err != nil {
+		return nil, err
+	}
+	if e.Remote, err = parseAddr(ej.Remote); err != nil {
+		return nil, err
+	}
+
+	if ij := ej.Info; ij != nil {
+		tok, err := mptcp.ParseToken(ij.Token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid info token %q", ij.Token)
+		}
+
+		e.Info = &mptcp.Info{
+			Subflows:           ij.Subflows,
+			SubflowsMax:        ij.SubflowsMax,
+			AddAddrSignal:      ij.AddAddrSignal,
+			AddAddrAccepted:    ij.AddAddrAccepted,
+			AddAddrSignalMax:   ij.AddAddrSignalMax,
+			AddAddrAcceptedMax: ij.AddAddrAcceptedMax,
+			Fallback:           ij.Fallback,
+			RemoteKeyReceived:  ij.RemoteKeyReceived,
+			Token:              tok,
+			WriteSeq:           ij.WriteSeq,
+			SndUna:             ij.SndUna,
+			RcvNxt:             ij.RcvNxt,
+			LocalAddrUsed:      ij.LocalAddrUsed,
+			LocalAddrMax:       ij.LocalAddrMax,
+			ChecksumEnabled:    ij.ChecksumEnabled,
+			Retransmits:        ij.Retransmits,
+			BytesRetrans:       ij.BytesRetrans,
+			BytesSent:          ij.BytesSent,
+			BytesReceived:      ij.BytesReceived,
+			BytesAcked:         ij.BytesAcked,
+			SubflowsTotal:      ij.SubflowsTotal,
+			LastDataSent:       time.Duration(ij.LastDataSent) * time.Millisecond,
+			LastDataRecv:       time.Duration(ij.LastDataRecv) * time.Millisecond,
+			LastAckRecv:        time.Duration(ij.LastAckRecv) * time.Millisecond,
+		}
+	}
+
+	return e, nil
+}
+
+// marshalEvent creates the JSON representation of e.
+func marshalEvent(e pm.Event) *eventJSON {
+	return &eventJSON{
+		Type:        e.Type.String(),
+		Token:       e.Token.String(),
+		Local:       formatAddr(e.Local),
+		Remote:      formatAddr(e.Remote),
+		LocalID:     e.LocalID,
+		RemoteID:    e.RemoteID,
+		Backup:      e.Backup,
+		Error:       int(e.Error),
+		Interface:   e.Interface,
+		ServerSide:  e.ServerSide,
+		Flags:       e.Flags,
+		ResetReason: e.ResetReason,
+		ResetFlags:  e.ResetFlags,
+	}
+}
+
+// event creates a pm.Event from its JSON representation.
+func (ej *eventJSON) event() (*pm.Event, error) {
+	typ, ok := eventTypes[ej.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", ej.Type)
+	}
+
+	tok, err := mptcp.ParseToken(ej.Token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event token %q", ej.Token)
+	}
+
+	e := &pm.Event{
+		Type:        typ,
+		Token:       tok,
+		LocalID:     ej.LocalID,
+		RemoteID:    ej.RemoteID,
+		Backup:      ej.Backup,
+		Error:       syscall.Errno(ej.Error),
+		Interface:   ej.Interface,
+		ServerSide:  ej.ServerSide,
+		Flags:       ej.Flags,
+		ResetReason: ej.ResetReason,
+		ResetFlags:  ej.ResetFlags,
+	}
+
+	if ej.Local != "" {
+		if e.Local, err = parseAddr(ej.Local); err != nil {
+			return nil, err
+		}
+	}
+	if ej.Remote != "" {
+		if e.Remote, err = parseAddr(ej.Remote); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// formatAddr formats a TCP address, or returns the empty string if it is
+// nil.
+func formatAddr(a *net.TCPAddr) string {
+	if a == nil {
+		return ""
+	}
+
+	return a.String()
+}
+
+// parseAddr parses a TCP address formatted by formatAddr.  IPv4 addresses
+// use their 4 byte representation.
+func parseAddr(s string) (*net.TCPAddr, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+
+	return net.TCPAddrFromAddrPort(ap), nil
+}
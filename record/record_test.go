@@ -0,0 +1,320 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// testEntry creates an mptcp.Entry for tests.
+func testEntry(tok mptcp.Token, port int, subflows int) mptcp.Entry {
+	return mptcp.Entry{
+		LocalToken: tok,
+		Local:      &net.TCPAddr{IP: net.IP{192, 0, 2, 1}, Port: port},
+		Remote:     &net.TCPAddr{IP: net.IP{198, 51, 100, 1}, Port: 443},
+		State:      mptcp.StateEstablished,
+		Subflows:   subflows,
+	}
+}
+
+// testLog writes a log containing recs for tests.
+func testLog(t *testing.T, recs ...Record) *bytes.Buffer {
+	t.Helper()
+
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	for _, r := range recs {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+
+	return &b
+}
+
+// TestWriterReader verifies that records written by a Writer are read back
+// unchanged by a Reader.
+func TestWriterReader(t *testing.T) {
+	t0 := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	full := mptcp.Entry{
+		LocalToken:  0xdeadbeef,
+		RemoteToken: 0x01020304,
+		IPv6:        true,
+		Local:       &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 50000},
+		Remote:      &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443},
+		State:       mptcp.StateEstablished,
+		Subflows:    2,
+		TxQueue:     10,
+		RxQueue:     20,
+		Inode:       12345,
+		Info: &mptcp.Info{
+			Subflows:          1,
+			SubflowsMax:       2,
+			AddAddrAccepted:   1,
+			Token:             0xdeadbeef,
+			WriteSeq:          100,
+			SndUna:            90,
+			RcvNxt:            200,
+			ChecksumEnabled:   true,
+			BytesSent:         1000,
+			BytesReceived:     2000,
+			LastDataSent:      5 * time.Millisecond,
+			LastAckRecv:       time.Second,
+			RemoteKeyReceived: true,
+		},
+	}
+
+	recs := []Record{
+		{
+			Kind:    KindSnapshot,
+			Time:    t0,
+			Entries: []mptcp.Entry{full, testEntry(1, 50001, 1)},
+		},
+		{
+			Kind:    KindSnapshot,
+			Time:    t0.Add(time.Second),
+			Entries: []mptcp.Entry{},
+		},
+		{
+			Kind: KindEvent,
+			Time: t0.Add(2 * time.Second),
+			Event: &pm.Event{
+				Type:       pm.EventSubflowClosed,
+				Token:      0xdeadbeef,
+				Local:      &net.TCPAddr{IP: net.IP{192, 0, 2, 1}, Port: 50000},
+				Remote:     &net.TCPAddr{IP: net.IP{198, 51, 100, 1}, Port: 443},
+				LocalID:    1,
+				RemoteID:   2,
+				Backup:     true,
+				Error:      syscall.ECONNRESET,
+				Interface:  3,
+				ServerSide: true,
+			},
+		},
+		{
+			Kind: KindEvent,
+			Time: t0.Add(3 * time.Second),
+			Event: &pm.Event{
+				Type:  pm.EventClosed,
+				Token: 0xdeadbeef,
+			},
+		},
+	}
+
+	r := NewReader(testLog(t, recs...))
+	for i, want := range recs {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("[%02d] failed to read record: %v", i, err)
+		}
+
+		if !reflect.DeepEqual(want, *got) {
+			t.Fatalf("[%02d] unexpected record:\n- want: %+v\n-  got: %+v",
+				i, want, *got)
+		}
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, but got: %v", err)
+	}
+}
+
+// TestWriterInvalid verifies that Writer rejects records which cannot be
+// written.
+func TestWriterInvalid(t *testing.T) {
+	var tests = []struct {
+		desc string
+		r    Record
+	}{
+		{
+			desc: "no kind",
+			r:    Record{Time: time.Now()},
+		},
+		{
+			desc: "no event",
+			r:    Record{Kind: KindEvent},
+		},
+		{
+			desc: "error event",
+			r:    Record{Kind: KindEvent, Event: &pm.Event{Type: pm.EventError}},
+		},
+	}
+
+	for i, tt := range tests {
+		if err := NewWriter(io.Discard).Write(tt.r); err == nil {
+			t.Fatalf("[%02d] test %q, expected an error, but none occurred",
+				i, tt.desc)
+		}
+	}
+}
+
+// TestReaderInvalid verifies that Reader reports malformed logs.
+func TestReaderInvalid(t *testing.T) {
+	var tests = []struct {
+		desc string
+		s    string
+	}{
+		{
+			desc: "bad JSON",
+			s:    "{",
+		},
+		{
+			desc: "unknown kind",
+			s:    `{"kind":"foo"}`,
+		},
+		{
+			desc: "event without event",
+			s:    `{"kind":"event"}`,
+		},
+		{
+			desc: "unknown event type",
+			s:    `{"kind":"event","event":{"type":"FOO","token":"00000001"}}`,
+		},
+		{
+			desc: "bad event token",
+			s:    `{"kind":"event","event":{"type":"CLOSED","token":"zz"}}`,
+		},
+		{
+			desc: "bad local token",
+			s:    `{"kind":"snapshot","entries":[{"local_token":"zz","local":"192.0.2.1:1","remote":"192.0.2.2:2"}]}`,
+		},
+		{
+			desc: "bad address",
+			s:    `{"kind":"snapshot","entries":[{"local_token":"00000001","local":"192.0.2.1","remote":"192.0.2.2:2"}]}`,
+		},
+	}
+
+	for i, tt := range tests {
+		_, err := NewReader(strings.NewReader(tt.s)).Next()
+		if err == nil || err == io.EOF {
+			t.Fatalf("[%02d] test %q, expected an error, but got: %v",
+				i, tt.desc, err)
+		}
+	}
+}
+
+// TestReaderReplay verifies that a Reader replays a log to the same consumers
+// as a live host.
+func TestReaderReplay(t *testing.T) {
+	var (
+		t0 = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		t1 = t0.Add(time.Second)
+
+		a  = testEntry(1, 50000, 1)
+		a2 = testEntry(1, 50000, 2)
+		b  = testEntry(2, 50001, 1)
+
+		ev = pm.Event{Type: pm.EventSubflowEstablished, Token: 1}
+	)
+
+	log := func() *bytes.Buffer {
+		return testLog(t,
+			Record{Kind: KindSnapshot, Time: t0, Entries: []mptcp.Entry{a}},
+			Record{Kind: KindEvent, Time: t1, Event: &ev},
+			Record{Kind: KindSnapshot, Time: t1, Entries: []mptcp.Entry{a2, b}},
+		)
+	}
+
+	t.Run("entries", func(t *testing.T) {
+		r := NewReader(log())
+		for i, want := range [][]mptcp.Entry{{a}, {a2, b}} {
+			got, err := r.Entries()
+			if err != nil {
+				t.Fatalf("[%02d] failed to read entries: %v", i, err)
+			}
+
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("[%02d] unexpected entries:\n- want: %+v\n-  got: %+v",
+					i, want, got)
+			}
+		}
+
+		if _, err := r.Entries(); err != io.EOF {
+			t.Fatalf("expected io.EOF, but got: %v", err)
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		want := []mptcp.Event{
+			{Type: mptcp.EventOpened, Time: t0, Entry: a},
+			{Type: mptcp.EventSubflowCountChanged, Time: t1, Entry: a2, Previous: &a},
+			{Type: mptcp.EventOpened, Time: t1, Entry: b},
+		}
+
+		var got []mptcp.Event
+		for e := range NewReader(log()).Watch(context.Background()) {
+			got = append(got, e)
+		}
+
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("unexpected events:\n- want: %+v\n-  got: %+v", want, got)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		var got []pm.Event
+		for e := range NewReader(log()).Events(context.Background()) {
+			got = append(got, e)
+		}
+
+		if want := []pm.Event{ev}; !reflect.DeepEqual(want, got) {
+			t.Fatalf("unexpected events:\n- want: %+v\n-  got: %+v", want, got)
+		}
+	})
+
+	t.Run("events error", func(t *testing.T) {
+		b := log()
+		b.WriteString("{\n")
+
+		var last pm.Event
+		for e := range NewReader(b).Events(context.Background()) {
+			last = e
+		}
+
+		if last.Type != pm.EventError || last.Err == nil {
+			t.Fatalf("expected final error event, but got: %+v", last)
+		}
+	})
+
+	t.Run("watcher", func(t *testing.T) {
+		r := NewReader(log())
+		w := mptcp.Watcher{
+			Interval: time.Millisecond,
+			Entries:  r.Entries,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events, err := w.Watch(ctx)
+		if err != nil {
+			t.Fatalf("failed to watch: %v", err)
+		}
+
+		// The channel is closed at the end of the log, without an
+		// error.
+		var got []mptcp.EventType
+		for e := range events {
+			got = append(got, e.Type)
+		}
+
+		want := []mptcp.EventType{
+			mptcp.EventOpened,
+			mptcp.EventSubflowCountChanged,
+			mptcp.EventOpened,
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("unexpected event types:\n- want: %v\n-  got: %v", want, got)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// A Recorder records snapshots of multipath TCP connections and path manager
+// events to a log.
+type Recorder struct {
+	// Interval is the time between snapshots.
+	Interval time.Duration
+
+	// Entries takes a snapshot of connections.  If nil, mptcp.Entries is
+	// used.
+	Entries func() ([]mptcp.Entry, error)
+
+	// Events are path manager events to record, typically from
+	// pm.Client.Events.  If nil, only snapshots are recorded.
+	Events <-chan pm.Event
+}
+
+// Record takes a snapshot of connections each interval and writes it to w,
+// along with each event received from Events, until ctx is canceled.
+//
+// Record returns nil when ctx is canceled or Events is closed.  If a
+// snapshot fails, writing to w fails, or EventError is received, Record
+// returns the error.
+func (r *Recorder) Record(ctx context.Context, w *Writer) error {
+	if r.Interval <= 0 {
+		return fmt.Errorf("record: invalid record interval %v", r.Interval)
+	}
+
+	entries := r.Entries
+	if entries == nil {
+		entries = mptcp.Entries
+	}
+
+	snapshot := func(now time.Time) error {
+		es, err := entries()
+		if err != nil {
+			return err
+		}
+
+		return w.WriteSnapshot(now, es)
+	}
+
+	if err := snapshot(time.Now()); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+
+	events := r.Events
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-t.C:
+			if err := snapshot(now); err != nil {
+				return err
+			}
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if e.Type == pm.EventError {
+				return e.Err
+			}
+
+			if err := w.WriteEvent(time.Now(), e); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// TestRecorderRecord verifies that a Recorder writes snapshots and events
+// until its events channel is closed.
+func TestRecorderRecord(t *testing.T) {
+	events := make(chan pm.Event)
+	go func() {
+		defer close(events)
+		events <- pm.Event{Type: pm.EventCreated, Token: 1}
+		events <- pm.Event{Type: pm.EventClosed, Token: 1}
+	}()
+
+	rec := Recorder{
+		Interval: time.Hour,
+		Entries: func() ([]mptcp.Entry, error) {
+			return []mptcp.Entry{testEntry(1, 50000, 1)}, nil
+		},
+		Events: events,
+	}
+
+	var b bytes.Buffer
+	if err := rec.Record(context.Background(), NewWriter(&b)); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+
+	var kinds []Kind
+	r := NewReader(&b)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read record: %v", err)
+		}
+
+		kinds = append(kinds, rec.Kind)
+	}
+
+	want := []Kind{KindSnapshot, KindEvent, KindEvent}
+	if len(kinds) != len(want) {
+		t.Fatalf("unexpected record kinds: %v", kinds)
+	}
+	for i := range want {
+		if want[i] != kinds[i] {
+			t.Fatalf("[%02d] unexpected record kind: %v", i, kinds[i])
+		}
+	}
+}
+
+// TestRecorderRecordErrors verifies that a Recorder returns errors from
+// snapshots and event subscriptions.
+func TestRecorderRecordErrors(t *testing.T) {
+	errTest := errors.New("test error")
+
+	entries := func() ([]mptcp.Entry, error) { return nil, nil }
+	errEvents := make(chan pm.Event, 1)
+	errEvents <- pm.Event{Type: pm.EventError, Err: errTest}
+
+	var tests = []struct {
+		desc string
+		r    Recorder
+	}{
+		{
+			desc: "snapshot",
+			r: Recorder{
+				Interval: time.Hour,
+				Entries:  func() ([]mptcp.Entry, error) { return nil, errTest },
+			},
+		},
+		{
+			desc: "events",
+			r: Recorder{
+				Interval: time.Hour,
+				Entries:  entries,
+				Events:   errEvents,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		err := tt.r.Record(context.Background(), NewWriter(io.Discard))
+		if !errors.Is(err, errTest) {
+			t.Fatalf("[%02d] test %q, unexpected error: %v", i, tt.desc, err)
+		}
+	}
+
+	r := Recorder{Entries: entries}
+	if err := r.Record(context.Background(), NewWriter(io.Discard)); err == nil {
+		t.Fatal("expected an error for invalid interval, but none occurred")
+	}
+}
@@ -0,0 +1,115 @@
+package record
+
+import (
+	"context"
+	"io"
+
+	"github.com/mdlayher/mptcp"
+	"github.com/mdlayher/mptcp/pm"
+)
+
+// Entries returns the connections of the next snapshot in the log, skipping
+// any events.  At the end of the log, Entries returns io.EOF.
+//
+// Entries may stand in for mptcp.Entries, such as in an mptcp.Watcher, so
+// that each call returns the next recorded snapshot, one per interval.  The
+// Watcher stops and closes its channel at the end of the log.  To replay a
+// log without waiting between snapshots, use Watch.
+func (r *Reader) Entries() ([]mptcp.Entry, error) {
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if rec.Kind == KindSnapshot {
+			return rec.Entries, nil
+		}
+	}
+}
+
+// Watch replays the snapshots in the log as mptcp.Events, in the same way as
+// mptcp.Watcher.Watch, but without waiting between snapshots.  Each Event's
+// Time is the time its snapshot was recorded.
+//
+// The channel is closed at the end of the log, or when ctx is canceled.  If
+// the log cannot be read, mptcp.EventError is sent before the channel is
+// closed.  Watch consumes the Reader, so no other methods may be called
+// while the channel is open.
+func (r *Reader) Watch(ctx context.Context) <-chan mptcp.Event {
+	ch := make(chan mptcp.Event)
+	go func() {
+		defer close(ch)
+
+		send := func(e mptcp.Event) bool {
+			select {
+			case ch <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var prev []mptcp.Entry
+		for {
+			rec, err := r.Next()
+			if err != nil {
+				if err != io.EOF {
+					send(mptcp.Event{Type: mptcp.EventError, Err: err})
+				}
+				return
+			}
+			if rec.Kind != KindSnapshot {
+				continue
+			}
+
+			for _, e := range mptcp.Diff(prev, rec.Entries) {
+				e.Time = rec.Time
+				if !send(e) {
+					return
+				}
+			}
+			prev = rec.Entries
+		}
+	}()
+
+	return ch
+}
+
+// Events replays the path manager events in the log, skipping any snapshots,
+// in the same way as pm.Client.Events.
+//
+// The channel is closed at the end of the log, or when ctx is canceled.  If
+// the log cannot be read, pm.EventError is sent before the channel is
+// closed.  Events consumes the Reader, so no other methods may be called
+// while the channel is open.
+func (r *Reader) Events(ctx context.Context) <-chan pm.Event {
+	ch := make(chan pm.Event)
+	go func() {
+		defer close(ch)
+
+		for {
+			rec, err := r.Next()
+			if err != nil {
+				if err != io.EOF {
+					select {
+					case ch <- pm.Event{Type: pm.EventError, Err: err}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			if rec.Kind != KindEvent {
+				continue
+			}
+
+			select {
+			case ch <- *rec.Event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
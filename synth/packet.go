@@ -0,0 +1,174 @@
+package synth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/option"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// Header lengths and fields of the packets built by a Conn.
+const (
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	tcpHeaderLen  = 20
+	maxOptions    = 40
+	protoTCP      = 6
+	ttl           = 64
+	window        = 65535
+	ipv4DF        = 0x4000
+	optNOP        = 1
+	optMSS        = 2
+)
+
+// emit adds a segment with flags, payload, and options opts, sent on sf by
+// from, and advances the sender's sequence number.
+func (c *Conn) emit(sf *Subflow, from int, flags capture.Flags, payload []byte, opts ...option.Option) error {
+	src, dst := sf.Client, sf.Server
+	if from == serverEnd {
+		src, dst = dst, src
+	}
+
+	var ack uint32
+	if flags&capture.FlagACK != 0 {
+		ack = sf.seq[1-from]
+	}
+
+	var ob []byte
+	if flags&capture.FlagSYN != 0 {
+		ob = append(ob, optMSS, 4)
+		ob = binary.BigEndian.AppendUint16(ob, uint16(c.cfg.MSS))
+	}
+	for _, o := range opts {
+		b, err := o.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("synth: %v: %w", o.Subtype(), err)
+		}
+
+		ob = append(ob, b...)
+	}
+	for len(ob)%4 != 0 {
+		ob = append(ob, optNOP)
+	}
+	if len(ob) > maxOptions {
+		return fmt.Errorf("synth: %d bytes of TCP options exceeds %d", len(ob), maxOptions)
+	}
+
+	b := packet(c.ipID, src, dst, tcp(src, dst, sf.seq[from], ack, flags, ob, payload))
+	c.ipID++
+
+	switch {
+	case c.lastFrom == -1:
+	case c.lastFrom != from:
+		c.now = c.now.Add(sf.RTT / 2)
+	default:
+		c.now = c.now.Add(gap)
+	}
+	c.lastFrom = from
+
+	c.packets = append(c.packets, &pcap.Packet{
+		Time:     c.now,
+		LinkType: pcap.LinkTypeRaw,
+		Data:     b,
+		Length:   len(b),
+	})
+
+	n := uint32(len(payload))
+	if flags&capture.FlagSYN != 0 {
+		n++
+	}
+	if flags&capture.FlagFIN != 0 {
+		n++
+	}
+	sf.seq[from] += n
+
+	return nil
+}
+
+// tcp builds a TCP segment from src to dst, with options opts which are
+// padded to a multiple of 4 bytes.
+func tcp(src, dst netip.AddrPort, seq, ack uint32, flags capture.Flags, opts, payload []byte) []byte {
+	n := tcpHeaderLen + len(opts)
+
+	b := make([]byte, n, n+len(payload))
+	binary.BigEndian.PutUint16(b[0:2], src.Port())
+	binary.BigEndian.PutUint16(b[2:4], dst.Port())
+	binary.BigEndian.PutUint32(b[4:8], seq)
+	binary.BigEndian.PutUint32(b[8:12], ack)
+	b[12] = byte(n/4) << 4
+	b[13] = byte(flags)
+	binary.BigEndian.PutUint16(b[14:16], window)
+	copy(b[tcpHeaderLen:], opts)
+	b = append(b, payload...)
+
+	// The checksum covers a pseudo-header of the IP addresses, protocol,
+	// and length of the segment.
+	var ph []byte
+	ph = append(ph, src.Addr().AsSlice()...)
+	ph = append(ph, dst.Addr().AsSlice()...)
+	if src.Addr().Is4() {
+		ph = append(ph, 0, protoTCP)
+		ph = binary.BigEndian.AppendUint16(ph, uint16(len(b)))
+	} else {
+		ph = binary.BigEndian.AppendUint32(ph, uint32(len(b)))
+		ph = append(ph, 0, 0, 0, protoTCP)
+	}
+
+	binary.BigEndian.PutUint16(b[16:18], checksum(ph, b))
+	return b
+}
+
+// packet builds an IPv4 or IPv6 packet with identification id, which carries
+// a TCP segment from src to dst.
+func packet(id uint16, src, dst netip.AddrPort, seg []byte) []byte {
+	if !src.Addr().Is4() {
+		b := make([]byte, ipv6HeaderLen, ipv6HeaderLen+len(seg))
+		b[0] = 6 << 4
+		binary.BigEndian.PutUint16(b[4:6], uint16(len(seg)))
+		b[6] = protoTCP
+		b[7] = ttl
+		s, d := src.Addr().As16(), dst.Addr().As16()
+		copy(b[8:24], s[:])
+		copy(b[24:40], d[:])
+
+		return append(b, seg...)
+	}
+
+	b := make([]byte, ipv4HeaderLen, ipv4HeaderLen+len(seg))
+	b[0] = 4<<4 | ipv4HeaderLen/4
+	binary.BigEndian.PutUint16(b[2:4], uint16(ipv4HeaderLen+len(seg)))
+	binary.BigEndian.PutUint16(b[4:6], id)
+	binary.BigEndian.PutUint16(b[6:8], ipv4DF)
+	b[8] = ttl
+	b[9] = protoTCP
+	s, d := src.Addr().As4(), dst.Addr().As4()
+	copy(b[12:16], s[:])
+	copy(b[16:20], d[:])
+	binary.BigEndian.PutUint16(b[10:12], checksum(b))
+
+	return append(b, seg...)
+}
+
+// checksum returns the Internet checksum of the concatenation of bs, all
+// but the last of which must have an even length.
+func checksum(bs ...[]byte) uint16 {
+	var sum uint32
+	for _, b := range bs {
+		for len(b) >= 2 {
+			sum += uint32(binary.BigEndian.Uint16(b))
+			b = b[2:]
+		}
+		if len(b) == 1 {
+			sum += uint32(b[0]) << 8
+		}
+	}
+
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+
+	return ^uint16(sum)
+}
@@ -0,0 +1,212 @@
+package synth
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/crypto"
+	"github.com/mdlayher/mptcp/option"
+)
+
+// A Subflow is a subflow of a Conn.  The client of every subflow is the
+// client of its connection.
+type Subflow struct {
+	// Client and Server are the endpoints of the subflow.
+	Client, Server netip.AddrPort
+
+	// RTT is the round-trip time of the subflow's path.  It may be changed
+	// to affect the timestamps of later segments.
+	RTT time.Duration
+
+	c       *Conn
+	initial bool
+	closed  bool
+
+	// isn, seq, and unacked are indexed by sender: the initial sequence
+	// number of each endpoint, the next sequence number it will send, and
+	// the number of its data segments which the peer has not
+	// acknowledged.
+	isn     [2]uint32
+	seq     [2]uint32
+	unacked [2]int
+}
+
+// newSubflow creates a Subflow between client and server, with random
+// initial sequence numbers.
+func (c *Conn) newSubflow(client, server netip.AddrPort) *Subflow {
+	sf := &Subflow{
+		Client: client,
+		Server: server,
+		RTT:    c.cfg.RTT,
+		c:      c,
+	}
+
+	for i := range sf.isn {
+		sf.isn[i] = c.rand.Uint32()
+		sf.seq[i] = sf.isn[i]
+	}
+
+	c.subflows = append(c.subflows, sf)
+	return sf
+}
+
+// Join adds the MP_JOIN handshake of a subflow from client to server, and
+// returns the subflow.  id is the address ID of the client, and backup
+// requests that the subflow is used as a backup path.  The address ID of the
+// server is the ID it announced for its address with AddAddr, or zero.
+func (c *Conn) Join(client, server netip.AddrPort, id uint8, backup bool) (*Subflow, error) {
+	if len(c.subflows) == 0 {
+		return nil, errors.New("synth: handshake not completed")
+	}
+	if c.closed {
+		return nil, errClosed
+	}
+	if !client.IsValid() || !server.IsValid() || client.Addr().Is4() != server.Addr().Is4() {
+		return nil, fmt.Errorf("synth: invalid subflow addresses %s and %s", client, server)
+	}
+
+	sf := c.newSubflow(client, server)
+
+	var (
+		keyA, keyB     = c.keys[clientEnd], c.keys[serverEnd]
+		nonceA, nonceB = c.rand.Uint32(), c.rand.Uint32()
+	)
+
+	steps := []struct {
+		from  int
+		flags capture.Flags
+		o     option.Option
+	}{
+		{clientEnd, capture.FlagSYN, &option.MPJoin{
+			Form:      option.JoinSYN,
+			Backup:    backup,
+			AddressID: id,
+			Token:     crypto.Token(version, keyB),
+			Nonce:     nonceA,
+		}},
+		{serverEnd, capture.FlagSYN | capture.FlagACK, &option.MPJoin{
+			Form:      option.JoinSYNACK,
+			AddressID: c.announced[server.Addr()],
+			Nonce:     nonceB,
+			HMAC:      crypto.JoinSYNACKHMAC(version, keyA, keyB, nonceA, nonceB),
+		}},
+		{clientEnd, capture.FlagACK, &option.MPJoin{
+			Form: option.JoinACK,
+			HMAC: crypto.JoinACKHMAC(version, keyA, keyB, nonceA, nonceB),
+		}},
+		// The server acknowledges the third ACK, which carries options
+		// that must be delivered reliably.
+		{serverEnd, capture.FlagACK, c.dataACK(serverEnd)},
+	}
+
+	for _, s := range steps {
+		if err := c.emit(sf, s.from, s.flags, nil, s.o); err != nil {
+			return nil, err
+		}
+	}
+
+	return sf, nil
+}
+
+// Send adds data sent on sf by the client if fromClient is set, or by the
+// server otherwise.  The data is split into segments no larger than the
+// configured MSS, each mapped to the data sequence space with a DSS option,
+// and the peer acknowledges every second segment and the last.
+//
+// The first data sent by the client on the initial subflow is mapped by
+// MP_CAPABLE rather than DSS, as it would be by a client which had not yet
+// received a DSS option from the server.
+func (sf *Subflow) Send(fromClient bool, data []byte) error {
+	c := sf.c
+	if c.closed || sf.closed {
+		return errClosed
+	}
+
+	from := sender(fromClient)
+	for len(data) > 0 {
+		n := min(len(data), c.cfg.MSS)
+		b := data[:n]
+		data = data[n:]
+
+		if err := c.emit(sf, from, capture.FlagACK|capture.FlagPSH, b, sf.mapping(from, b)); err != nil {
+			return err
+		}
+
+		c.dsn[from] += uint64(n)
+		sf.unacked[from]++
+		if sf.unacked[from] < 2 && len(data) > 0 {
+			continue
+		}
+
+		sf.unacked[from] = 0
+		if err := c.emit(sf, 1-from, capture.FlagACK, nil, c.dataACK(1-from)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mapping returns the option which maps data b, sent by from as the next
+// data on sf, to the data sequence space.
+func (sf *Subflow) mapping(from int, b []byte) option.Option {
+	var (
+		c   = sf.c
+		dsn = c.dsn[from]
+		ssn = sf.seq[from] - sf.isn[from]
+		n   = uint16(len(b))
+		sum = crypto.DSSChecksum(dsn, ssn, n, b)
+	)
+
+	if sf.initial && from == clientEnd && ssn == 1 && dsn == c.start[from] {
+		return &option.MPCapable{
+			Version:        version,
+			Flags:          capableFlags(c.cfg.Checksum),
+			SenderKey:      c.keys[clientEnd],
+			HasSenderKey:   true,
+			ReceiverKey:    c.keys[serverEnd],
+			HasReceiverKey: true,
+			DataLength:     n,
+			HasDataLength:  true,
+			Checksum:       sum,
+			HasChecksum:    c.cfg.Checksum,
+		}
+	}
+
+	d := c.dataACK(from)
+	d.DSN = dsn
+	d.DSN64 = true
+	d.SubflowSeq = ssn
+	d.DataLength = n
+	d.HasMapping = true
+	if c.cfg.Checksum {
+		d.Checksum = sum
+		d.HasChecksum = true
+	}
+
+	return d
+}
+
+// dataACK returns a DSS option sent by from which acknowledges all of the
+// data sent by its peer.
+func (c *Conn) dataACK(from int) *option.DSS {
+	return &option.DSS{
+		DataACK:    c.dsn[1-from],
+		HasDataACK: true,
+		DataACK64:  true,
+	}
+}
+
+// capableFlags returns the MP_CAPABLE flags of a connection which uses DSS
+// checksums if checksum is set.
+func capableFlags(checksum bool) option.CapableFlags {
+	flags := option.CapableHMACSHA
+	if checksum {
+		flags |= option.CapableChecksum
+	}
+
+	return flags
+}
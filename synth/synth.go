@@ -0,0 +1,312 @@
+// Package synth builds synthetic multipath TCP traffic, for testing packet
+// capture analyzers and middlebox behavior without a kernel.
+//
+// A Conn produces complete IPv4 or IPv6 TCP segments with multipath TCP
+// version 1 options, as they would be sent by a client and a server: the
+// MP_CAPABLE handshake, subflows joined with correctly authenticated MP_JOIN
+// handshakes, data mapped by DSS options, address announcements, and
+// fast-close.  Keys, initial sequence numbers, and nonces which are not
+// configured are chosen using a seeded random source, so the packets of a
+// Conn are deterministic given its Config.  The packets may be written to a
+// pcap file using WritePcap.
+package synth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/netip"
+	"sort"
+	"time"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/crypto"
+	"github.com/mdlayher/mptcp/option"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// version is the multipath TCP version used by a Conn.
+const version = 1
+
+// Default values for Config.
+const (
+	defaultMSS = 1460
+	defaultRTT = 10 * time.Millisecond
+)
+
+// Indices of the endpoints of a connection, for fields indexed by sender.
+const (
+	clientEnd = 0
+	serverEnd = 1
+)
+
+// sender returns the index of the client if fromClient is set, or of the
+// server otherwise.
+func sender(fromClient bool) int {
+	if fromClient {
+		return clientEnd
+	}
+
+	return serverEnd
+}
+
+// maxMSS is the largest MSS for which a segment with full TCP options fits
+// in an IPv4 packet.
+const maxMSS = 65535 - ipv4HeaderLen - tcpHeaderLen - maxOptions
+
+// gap is the time between consecutive segments sent in the same direction.
+const gap = 10 * time.Microsecond
+
+var errClosed = errors.New("synth: connection is closed")
+
+// A Config configures a Conn.
+type Config struct {
+	// Seed seeds the random source used to choose keys, initial sequence
+	// numbers, and nonces which are not otherwise configured.
+	Seed int64
+
+	// Client and Server are the addresses of the initial subflow, which
+	// must be of the same IP version.
+	Client, Server netip.AddrPort
+
+	// ClientKey and ServerKey are the keys of each endpoint.  If zero, a
+	// key is chosen randomly.
+	ClientKey, ServerKey uint64
+
+	// Checksum enables DSS checksums.
+	Checksum bool
+
+	// MSS is the maximum number of bytes of data in each segment.  If
+	// zero, 1460 is used.
+	MSS int
+
+	// RTT is the round-trip time of the initial subflow, and the default
+	// for joined subflows.  If zero, 10ms is used.
+	RTT time.Duration
+
+	// Start is the time of the first segment.  If zero, the Unix epoch is
+	// used.
+	Start time.Time
+}
+
+// A Conn is a synthetic multipath TCP connection.  Segments are added to the
+// connection by calling its methods in the order they should occur.
+//
+// Timestamps are those observed midway along each path: each change of
+// direction on a subflow takes half of its round-trip time, and consecutive
+// segments in one direction are separated by a small gap.
+type Conn struct {
+	cfg  Config
+	rand *rand.Rand
+
+	// keys, start, and dsn are indexed by sender: the key of each
+	// endpoint, its first data sequence number, and the next data
+	// sequence number it will send.
+	keys  [2]uint64
+	start [2]uint64
+	dsn   [2]uint64
+
+	subflows  []*Subflow
+	announced map[netip.Addr]uint8
+	closed    bool
+
+	now      time.Time
+	lastFrom int
+	ipID     uint16
+	packets  []*pcap.Packet
+}
+
+// New creates a Conn using the configuration in cfg.  No segments are added
+// until Handshake is called.
+func New(cfg Config) (*Conn, error) {
+	if !cfg.Client.IsValid() || !cfg.Server.IsValid() {
+		return nil, errors.New("synth: client and server addresses are required")
+	}
+	if cfg.Client.Addr().Is4() != cfg.Server.Addr().Is4() {
+		return nil, fmt.Errorf("synth: client %s and server %s use different IP versions",
+			cfg.Client, cfg.Server)
+	}
+	if cfg.MSS < 0 || cfg.MSS > maxMSS {
+		return nil, fmt.Errorf("synth: invalid MSS %d", cfg.MSS)
+	}
+	if cfg.RTT < 0 {
+		return nil, fmt.Errorf("synth: invalid RTT %s", cfg.RTT)
+	}
+
+	if cfg.MSS == 0 {
+		cfg.MSS = defaultMSS
+	}
+	if cfg.RTT == 0 {
+		cfg.RTT = defaultRTT
+	}
+	if cfg.Start.IsZero() {
+		cfg.Start = time.Unix(0, 0).UTC()
+	}
+
+	c := &Conn{
+		cfg:       cfg,
+		rand:      rand.New(rand.NewSource(cfg.Seed)),
+		announced: make(map[netip.Addr]uint8),
+		now:       cfg.Start,
+		lastFrom:  -1,
+	}
+
+	c.keys = [2]uint64{cfg.ClientKey, cfg.ServerKey}
+	for i := range c.keys {
+		for c.keys[i] == 0 {
+			c.keys[i] = c.rand.Uint64()
+		}
+
+		c.start[i] = crypto.IDSN(version, c.keys[i]) + 1
+		c.dsn[i] = c.start[i]
+	}
+	c.ipID = uint16(c.rand.Uint32())
+
+	return c, nil
+}
+
+// Handshake adds the MP_CAPABLE handshake of the initial subflow, and returns
+// the subflow.  It must be called once, before any other method.
+func (c *Conn) Handshake() (*Subflow, error) {
+	if len(c.subflows) > 0 {
+		return nil, errors.New("synth: handshake already completed")
+	}
+
+	sf := c.newSubflow(c.cfg.Client, c.cfg.Server)
+	sf.initial = true
+
+	flags := capableFlags(c.cfg.Checksum)
+
+	steps := []struct {
+		from  int
+		flags capture.Flags
+		o     *option.MPCapable
+	}{
+		{clientEnd, capture.FlagSYN, &option.MPCapable{Version: version, Flags: flags}},
+		{serverEnd, capture.FlagSYN | capture.FlagACK, &option.MPCapable{
+			Version:      version,
+			Flags:        flags,
+			SenderKey:    c.keys[serverEnd],
+			HasSenderKey: true,
+		}},
+		{clientEnd, capture.FlagACK, &option.MPCapable{
+			Version:        version,
+			Flags:          flags,
+			SenderKey:      c.keys[clientEnd],
+			HasSenderKey:   true,
+			ReceiverKey:    c.keys[serverEnd],
+			HasReceiverKey: true,
+		}},
+	}
+
+	for _, s := range steps {
+		if err := c.emit(sf, s.from, s.flags, nil, s.o); err != nil {
+			return nil, err
+		}
+	}
+
+	return sf, nil
+}
+
+// Subflows returns the subflows of the connection, beginning with the
+// initial subflow.
+func (c *Conn) Subflows() []*Subflow {
+	return append([]*Subflow(nil), c.subflows...)
+}
+
+// AddAddr adds an ADD_ADDR option which announces address ID id with addr and
+// an optional port, sent on sf by the client if fromClient is set, or by the
+// server otherwise.  The peer echoes the announcement.  Subflows joined to an
+// address announced by the server use its address ID.
+func (c *Conn) AddAddr(sf *Subflow, fromClient bool, id uint8, addr netip.Addr, port uint16) error {
+	if c.closed || sf.closed {
+		return errClosed
+	}
+
+	from := sender(fromClient)
+	key, peer := c.keys[from], c.keys[1-from]
+
+	if from == serverEnd {
+		c.announced[addr] = id
+	}
+
+	if err := c.emit(sf, from, capture.FlagACK, nil, &option.AddAddr{
+		Version:   version,
+		AddressID: id,
+		Address:   addr,
+		Port:      port,
+		HMAC:      crypto.AddAddrHMAC(key, peer, id, addr, port),
+	}); err != nil {
+		return err
+	}
+
+	return c.emit(sf, 1-from, capture.FlagACK, nil, &option.AddAddr{
+		Version:   version,
+		Echo:      true,
+		AddressID: id,
+		Address:   addr,
+		Port:      port,
+	})
+}
+
+// Fastclose abruptly closes the connection with MP_FASTCLOSE, sent by the
+// client if fromClient is set, or by the server otherwise, on a reset of each
+// open subflow.
+func (c *Conn) Fastclose(fromClient bool) error {
+	if c.closed {
+		return errClosed
+	}
+
+	from := sender(fromClient)
+	for _, sf := range c.subflows {
+		if sf.closed {
+			continue
+		}
+
+		err := c.emit(sf, from, capture.FlagRST|capture.FlagACK, nil,
+			&option.MPFastclose{ReceiverKey: c.keys[1-from]},
+			&option.MPTCPRst{Reason: option.ResetUnspecified},
+		)
+		if err != nil {
+			return err
+		}
+
+		sf.closed = true
+	}
+
+	c.closed = true
+	return nil
+}
+
+// Packets returns the packets of the connection, in time order.  Each packet
+// is an IPv4 or IPv6 packet with link type pcap.LinkTypeRaw.
+func (c *Conn) Packets() []*pcap.Packet {
+	return append([]*pcap.Packet(nil), c.packets...)
+}
+
+// WritePcap writes the packets of each connection in cs to w as a pcap file,
+// in time order.
+func WritePcap(w io.Writer, cs ...*Conn) error {
+	var ps []*pcap.Packet
+	for _, c := range cs {
+		ps = append(ps, c.packets...)
+	}
+
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Time.Before(ps[j].Time)
+	})
+
+	pw, err := pcap.NewWriter(w, pcap.LinkTypeRaw)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range ps {
+		if err := pw.WritePacket(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package synth
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/mptcp/capture"
+	"github.com/mdlayher/mptcp/pcap"
+)
+
+// Data sent by each endpoint of a test connection.
+var (
+	request  = []byte(strings.Repeat("request ", 150))
+	response = []byte(strings.Repeat("response ", 200))
+)
+
+// testConn builds a connection using cfg, with a subflow joined to an address
+// announced by the server, data in each direction on both subflows, and a
+// fast-close by the client.
+func testConn(t *testing.T, cfg Config, addr netip.AddrPort) *Conn {
+	t.Helper()
+
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create connection: %v", err)
+	}
+
+	initial, err := c.Handshake()
+	if err != nil {
+		t.Fatalf("failed to add handshake: %v", err)
+	}
+
+	if err := c.AddAddr(initial, false, 1, addr.Addr(), addr.Port()); err != nil {
+		t.Fatalf("failed to add ADD_ADDR: %v", err)
+	}
+
+	client2 := netip.AddrPortFrom(cfg.Client.Addr(), cfg.Client.Port()+1)
+	joined, err := c.Join(client2, addr, 2, false)
+	if err != nil {
+		t.Fatalf("failed to join subflow: %v", err)
+	}
+
+	for _, s := range []struct {
+		sf         *Subflow
+		fromClient bool
+		data       []byte
+	}{
+		{initial, true, request[:600]},
+		{joined, true, request[600:]},
+		{joined, false, response[:1000]},
+		{initial, false, response[1000:]},
+	} {
+		if err := s.sf.Send(s.fromClient, s.data); err != nil {
+			t.Fatalf("failed to send data: %v", err)
+		}
+	}
+
+	if err := c.Fastclose(true); err != nil {
+		t.Fatalf("failed to add fast-close: %v", err)
+	}
+
+	return c
+}
+
+// analyze writes cs to a pcap file, and analyzes the file.
+func analyze(t *testing.T, cs ...*Conn) *capture.Capture {
+	t.Helper()
+
+	var b bytes.Buffer
+	if err := WritePcap(&b, cs...); err != nil {
+		t.Fatalf("failed to write pcap: %v", err)
+	}
+
+	r, err := pcap.NewReader(&b)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	segs, bad, err := capture.ReadSegments(r)
+	if err != nil || bad > 0 {
+		t.Fatalf("failed to read segments: %d malformed, %v", bad, err)
+	}
+
+	return capture.Analyze(segs)
+}
+
+// TestConn verifies that the packets of a Conn are analyzed as a multipath
+// TCP connection with authenticated joins and announcements, and data which
+// reassembles without issues.
+func TestConn(t *testing.T) {
+	var tests = []struct {
+		desc string
+		cfg  Config
+		addr netip.AddrPort
+	}{
+		{
+			desc: "IPv4",
+			cfg: Config{
+				Client:    netip.MustParseAddrPort("192.0.2.1:50000"),
+				Server:    netip.MustParseAddrPort("198.51.100.1:443"),
+				ClientKey: 0x62fdcce14201dfbc,
+				ServerKey: 0x6d0cb517e2512744,
+			},
+			addr: netip.MustParseAddrPort("198.51.100.2:443"),
+		},
+		{
+			desc: "IPv6 with checksums",
+			cfg: Config{
+				Seed:     1,
+				Client:   netip.MustParseAddrPort("[2001:db8::1]:50000"),
+				Server:   netip.MustParseAddrPort("[2001:db8:1::1]:443"),
+				Checksum: true,
+				MSS:      500,
+			},
+			addr: netip.MustParseAddrPort("[2001:db8:1::2]:8443"),
+		},
+	}
+
+	for i, tt := range tests {
+		c := testConn(t, tt.cfg, tt.addr)
+
+		capt := analyze(t, c)
+		if len(capt.Connections) != 1 || len(capt.Orphans) > 0 {
+			t.Fatalf("[%02d] test %q, unexpected capture: %d connections, %d orphans",
+				i, tt.desc, len(capt.Connections), len(capt.Orphans))
+		}
+
+		conn := capt.Connections[0]
+		if want, got := c.keys, [2]uint64{conn.ClientKey, conn.ServerKey}; want != got {
+			t.Fatalf("[%02d] test %q, unexpected keys:\n- want: %x\n-  got: %x",
+				i, tt.desc, want, got)
+		}
+		if want, got := tt.cfg.Checksum, conn.Checksum; want != got || conn.Fallback {
+			t.Fatalf("[%02d] test %q, unexpected checksum %v or fallback %v",
+				i, tt.desc, got, conn.Fallback)
+		}
+
+		if len(conn.Subflows) != 2 {
+			t.Fatalf("[%02d] test %q, unexpected number of subflows: %d",
+				i, tt.desc, len(conn.Subflows))
+		}
+		joined := conn.Subflows[1]
+		if joined.Auth != capture.AuthValid || joined.ClientID != 2 || joined.ServerID != 1 || joined.Server != tt.addr {
+			t.Fatalf("[%02d] test %q, unexpected joined subflow: %+v", i, tt.desc, joined)
+		}
+
+		var types []capture.EventType
+		for _, e := range conn.Events {
+			types = append(types, e.Type)
+			if e.Type == capture.EventAddAddr && e.Auth == capture.AuthInvalid {
+				t.Fatalf("[%02d] test %q, ADD_ADDR failed authentication", i, tt.desc)
+			}
+		}
+
+		want := []capture.EventType{
+			capture.EventSubflow,
+			capture.EventAddAddr,
+			capture.EventAddAddr,
+			capture.EventSubflow,
+			capture.EventFastclose,
+			capture.EventReset,
+			capture.EventFastclose,
+			capture.EventReset,
+		}
+		if !reflect.DeepEqual(want, types) {
+			t.Fatalf("[%02d] test %q, unexpected events:\n- want: %v\n-  got: %v",
+				i, tt.desc, want, types)
+		}
+
+		cs, ss := conn.Streams()
+		for _, s := range []struct {
+			want []byte
+			st   *capture.Stream
+		}{
+			{request, cs},
+			{response, ss},
+		} {
+			if !bytes.Equal(s.want, s.st.Data) || len(s.st.Issues) > 0 {
+				t.Fatalf("[%02d] test %q, unexpected stream: %d bytes, issues: %v",
+					i, tt.desc, len(s.st.Data), s.st.Issues)
+			}
+		}
+
+		st := conn.Stats(time.Second)
+		for _, ss := range st.Subflows {
+			if ss.RTT != defaultRTT || ss.HandshakeRTT != defaultRTT {
+				t.Fatalf("[%02d] test %q, unexpected RTTs: %s and %s",
+					i, tt.desc, ss.RTT, ss.HandshakeRTT)
+			}
+		}
+	}
+}
+
+// TestConnDeterministic verifies that the packets of a Conn depend only on
+// its Config.
+func TestConnDeterministic(t *testing.T) {
+	cfg := Config{
+		Seed:   1,
+		Client: netip.MustParseAddrPort("192.0.2.1:50000"),
+		Server: netip.MustParseAddrPort("198.51.100.1:443"),
+	}
+	addr := netip.MustParseAddrPort("198.51.100.2:443")
+
+	pcapBytes := func(cfg Config) []byte {
+		var b bytes.Buffer
+		if err := WritePcap(&b, testConn(t, cfg, addr)); err != nil {
+			t.Fatalf("failed to write pcap: %v", err)
+		}
+
+		return b.Bytes()
+	}
+
+	a, b := pcapBytes(cfg), pcapBytes(cfg)
+	if !bytes.Equal(a, b) {
+		t.Fatal("connections with the same seed produced different packets")
+	}
+
+	cfg.Seed = 2
+	if bytes.Equal(a, pcapBytes(cfg)) {
+		t.Fatal("connections with different seeds produced identical packets")
+	}
+}
+
+// TestNewErrors verifies that New rejects invalid configurations.
+func TestNewErrors(t *testing.T) {
+	var (
+		v4 = netip.MustParseAddrPort("192.0.2.1:50000")
+		v6 = netip.MustParseAddrPort("[2001:db8::1]:443")
+	)
+
+	var tests = []struct {
+		desc string
+		cfg  Config
+	}{
+		{
+			desc: "no addresses",
+		},
+		{
+			desc: "mixed IP versions",
+			cfg:  Config{Client: v4, Server: v6},
+		},
+		{
+			desc: "invalid MSS",
+			cfg:  Config{Client: v4, Server: v4, MSS: 65535},
+		},
+	}
+
+	for i, tt := range tests {
+		if _, err := New(tt.cfg); err == nil {
+			t.Fatalf("[%02d] test %q, expected an error", i, tt.desc)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package mptcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// procSysMPTCP is the location of the Linux-specific directory which
+	// contains multipath TCP sysctls.
+	procSysMPTCP = "/proc/sys/net/mptcp"
+)
+
+var (
+	// ErrInvalidSysctl is returned when an invalid value is passed to a
+	// Sysctl method which sets a sysctl.
+	ErrInvalidSysctl = errors.New("invalid sysctl value")
+)
+
+// A Flavor is a kind of multipath TCP implementation provided by the Linux
+// kernel.
+type Flavor int
+
+// Possible Flavor values.
+const (
+	// FlavorNone indicates that the kernel does not support multipath TCP.
+	FlavorNone Flavor = iota
+
+	// FlavorMainline indicates the multipath TCP implementation merged
+	// into Linux 5.6 and newer.
+	FlavorMainline
+
+	// FlavorOutOfTree indicates the out-of-tree multipath TCP
+	// implementation from multipath-tcp.org.
+	FlavorOutOfTree
+)
+
+// String returns the name of a Flavor.
+func (f Flavor) String() string {
+	switch f {
+	case FlavorNone:
+		return "none"
+	case FlavorMainline:
+		return "mainline"
+	case FlavorOutOfTree:
+		return "out-of-tree"
+	default:
+		return fmt.Sprintf("Flavor(%d)", int(f))
+	}
+}
+
+// A PMType is the type of path manager used by mainline multipath TCP.
+type PMType int
+
+// Possible PMType values.
+const (
+	// PMTypeKernel indicates the in-kernel path manager, which is
+	// configured using endpoints and limits.
+	PMTypeKernel PMType = 0
+
+	// PMTypeUserspace indicates the userspace path manager, which allows
+	// a userspace daemon to manage each connection.
+	PMTypeUserspace PMType = 1
+)
+
+// String returns the name of a PMType.
+func (t PMType) String() string {
+	switch t {
+	case PMTypeKernel:
+		return "kernel"
+	case PMTypeUserspace:
+		return "userspace"
+	default:
+		return fmt.Sprintf("PMType(%d)", int(t))
+	}
+}
+
+// Sysctl provides typed access to the multipath TCP sysctls in the
+// net.mptcp namespace, for both mainline and out-of-tree Linux kernels.
+//
+// Methods which apply to both flavors use whichever sysctl the current kernel
+// provides.  Methods which apply to only one flavor return an error which
+// satisfies os.IsNotExist when used with the other flavor.  Methods which set
+// sysctls typically require root privileges.
+type Sysctl struct {
+	root string
+}
+
+// NewSysctl creates a new Sysctl which accesses the sysctls of the current
+// network namespace, using the Linux /proc filesystem.
+func NewSysctl() *Sysctl {
+	return &Sysctl{
+		root: procSysMPTCP,
+	}
+}
+
+// Flavor detects which multipath TCP implementation the kernel provides.
+// If the kernel does not support multipath TCP, FlavorNone is returned.
+func (s *Sysctl) Flavor() (Flavor, error) {
+	// Each flavor is identified by its version of the enabled sysctl
+	for _, f := range []struct {
+		name   string
+		flavor Flavor
+	}{
+		{"enabled", FlavorMainline},
+		{"mptcp_enabled", FlavorOutOfTree},
+	} {
+		_, err := os.Stat(filepath.Join(s.root, f.name))
+		if err == nil {
+			return f.flavor, nil
+		}
+		if !os.IsNotExist(err) {
+			return FlavorNone, err
+		}
+	}
+
+	return FlavorNone, nil
+}
+
+// Enabled reports whether multipath TCP is enabled for new sockets, using
+// net.mptcp.enabled on mainline kernels, or net.mptcp.mptcp_enabled on
+// out-of-tree kernels.
+func (s *Sysctl) Enabled() (bool, error) {
+	name, err := s.name("enabled", "mptcp_enabled")
+	if err != nil {
+		return false, err
+	}
+
+	// Out-of-tree kernels use 2 to enable multipath TCP only for sockets
+	// which request it, which is still considered enabled
+	v, err := s.readInt(name)
+	return v != 0, err
+}
+
+// SetEnabled enables or disables multipath TCP for new sockets.
+func (s *Sysctl) SetEnabled(enabled bool) error {
+	name, err := s.name("enabled", "mptcp_enabled")
+	if err != nil {
+		return err
+	}
+
+	return s.writeBool(name, enabled)
+}
+
+// ChecksumEnabled reports whether DSS checksums are enabled, using
+// net.mptcp.checksum_enabled on mainline kernels, or net.mptcp.mptcp_checksum
+// on out-of-tree kernels.
+func (s *Sysctl) ChecksumEnabled() (bool, error) {
+	name, err := s.name("checksum_enabled", "mptcp_checksum")
+	if err != nil {
+		return false, err
+	}
+
+	return s.readBool(name)
+}
+
+// SetChecksumEnabled enables or disables DSS checksums.
+func (s *Sysctl) SetChecksumEnabled(enabled bool) error {
+	name, err := s.name("checksum_enabled", "mptcp_checksum")
+	if err != nil {
+		return err
+	}
+
+	return s.writeBool(name, enabled)
+}
+
+// Scheduler returns the name of the packet scheduler used for new
+// connections, using net.mptcp.scheduler on mainline kernels, or
+// net.mptcp.mptcp_scheduler on out-of-tree kernels.
+func (s *Sysctl) Scheduler() (string, error) {
+	name, err := s.name("scheduler", "mptcp_scheduler")
+	if err != nil {
+		return "", err
+	}
+
+	return s.read(name)
+}
+
+// SetScheduler sets the packet scheduler used for new connections.  On
+// mainline kernels, the scheduler must be one of AvailableSchedulers.
+func (s *Sysctl) SetScheduler(scheduler string) error {
+	name, err := s.name("scheduler", "mptcp_scheduler")
+	if err != nil {
+		return err
+	}
+
+	if err := validName(scheduler); err != nil {
+		return err
+	}
+
+	if name == "scheduler" {
+		available, err := s.AvailableSchedulers()
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(available, scheduler) {
+			return fmt.Errorf("%w: scheduler %q is not one of %v",
+				ErrInvalidSysctl, scheduler, available)
+		}
+	}
+
+	return s.write(name, scheduler)
+}
+
+// AvailableSchedulers returns the names of the packet schedulers available
+// on mainline kernels, from net.mptcp.available_schedulers.
+func (s *Sysctl) AvailableSchedulers() ([]string, error) {
+	v, err := s.read("available_schedulers")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(v), nil
+}
+
+// PMType returns the type of path manager used for new connections on
+// mainline kernels, from net.mptcp.pm_type.
+func (s *Sysctl) PMType() (PMType, error) {
+	v, err := s.readInt("pm_type")
+	return PMType(v), err
+}
+
+// SetPMType sets the type of path manager used for new connections on
+// mainline kernels.
+func (s *Sysctl) SetPMType(t PMType) error {
+	if t != PMTypeKernel && t != PMTypeUserspace {
+		return fmt.Errorf("%w: unknown path manager type %d", ErrInvalidSysctl, int(t))
+	}
+
+	return s.writeInt("pm_type", int(t))
+}
+
+// PathManager returns the name of the path manager used for new connections
+// on out-of-tree kernels, from net.mptcp.mptcp_path_manager.
+func (s *Sysctl) PathManager() (string, error) {
+	return s.read("mptcp_path_manager")
+}
+
+// SetPathManager sets the path manager used for new connections on
+// out-of-tree kernels, such as "default", "fullmesh" or "ndiffports".
+func (s *Sysctl) SetPathManager(pm string) error {
+	if err := validName(pm); err != nil {
+		return err
+	}
+
+	return s.write("mptcp_path_manager", pm)
+}
+
+// AllowJoinInitialAddrPort reports whether peers are allowed to join a
+// connection using the address and port of its initial subflow, on mainline
+// kernels.
+func (s *Sysctl) AllowJoinInitialAddrPort() (bool, error) {
+	return s.readBool("allow_join_initial_addr_port")
+}
+
+// SetAllowJoinInitialAddrPort sets whether peers are allowed to join a
+// connection using the address and port of its initial subflow.
+func (s *Sysctl) SetAllowJoinInitialAddrPort(allow bool) error {
+	return s.writeBool("allow_join_initial_addr_port", allow)
+}
+
+// AddAddrTimeout returns the time after which an unacknowledged ADD_ADDR
+// option is retransmitted, on mainline kernels.
+func (s *Sysctl) AddAddrTimeout() (time.Duration, error) {
+	v, err := s.readInt("add_addr_timeout")
+	return time.Duration(v) * time.Second, err
+}
+
+// SetAddAddrTimeout sets the time after which an unacknowledged ADD_ADDR
+// option is retransmitted.  The timeout must be a positive, whole number of
+// seconds.
+func (s *Sysctl) SetAddAddrTimeout(d time.Duration) error {
+	if d <= 0 || d%time.Second != 0 {
+		return fmt.Errorf("%w: timeout %v must be a positive number of seconds",
+			ErrInvalidSysctl, d)
+	}
+
+	return s.writeInt("add_addr_timeout", int(d/time.Second))
+}
+
+// StaleLossCount returns the number of retransmission intervals after which
+// an idle subflow is considered stale, on mainline kernels.
+func (s *Sysctl) StaleLossCount() (int, error) {
+	return s.readInt("stale_loss_cnt")
+}
+
+// SetStaleLossCount sets the number of retransmission intervals after which
+// an idle subflow is considered stale.  The count must be positive.
+func (s *Sysctl) SetStaleLossCount(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("%w: stale loss count %d must be positive", ErrInvalidSysctl, n)
+	}
+
+	return s.writeInt("stale_loss_cnt", n)
+}
+
+// SynRetries returns the number of SYN retransmissions carrying the
+// MP_CAPABLE option before falling back to regular TCP, on out-of-tree
+// kernels.
+func (s *Sysctl) SynRetries() (int, error) {
+	return s.readInt("mptcp_syn_retries")
+}
+
+// SetSynRetries sets the number of SYN retransmissions carrying the
+// MP_CAPABLE option before falling back to regular TCP.
+func (s *Sysctl) SetSynRetries(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%w: SYN retries %d must not be negative", ErrInvalidSysctl, n)
+	}
+
+	return s.writeInt("mptcp_syn_retries", n)
+}
+
+// name returns the name of the mainline or out-of-tree version of a sysctl,
+// depending on which flavor of multipath TCP the kernel provides.
+func (s *Sysctl) name(mainline, outOfTree string) (string, error) {
+	f, err := s.Flavor()
+	if err != nil {
+		return "", err
+	}
+
+	switch f {
+	case FlavorMainline:
+		return mainline, nil
+	case FlavorOutOfTree:
+		return outOfTree, nil
+	default:
+		return "", &os.PathError{
+			Op:   "open",
+			Path: s.root,
+			Err:  os.ErrNotExist,
+		}
+	}
+}
+
+// read reads the value of a sysctl, without trailing whitespace.
+func (s *Sysctl) read(name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(s.root, name))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readInt reads the integer value of a sysctl.
+func (s *Sysctl) readInt(name string) (int, error) {
+	v, err := s.read(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(v)
+}
+
+// readBool reads the boolean value of a sysctl, which is stored as an integer.
+func (s *Sysctl) readBool(name string) (bool, error) {
+	v, err := s.readInt(name)
+	return v != 0, err
+}
+
+// write writes the value of an existing sysctl.
+func (s *Sysctl) write(name, value string) error {
+	// Sysctls cannot be created, so do not pass os.O_CREATE
+	f, err := os.OpenFile(filepath.Join(s.root, name), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(value + "\n"); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeInt writes the integer value of a sysctl.
+func (s *Sysctl) writeInt(name string, v int) error {
+	return s.write(name, strconv.Itoa(v))
+}
+
+// writeBool writes the boolean value of a sysctl as an integer.
+func (s *Sysctl) writeBool(name string, v bool) error {
+	if v {
+		return s.writeInt(name, 1)
+	}
+
+	return s.writeInt(name, 0)
+}
+
+// validName verifies that a scheduler or path manager name is not empty and
+// contains no whitespace.
+func validName(name string) error {
+	if name == "" || strings.ContainsFunc(name, unicode.IsSpace) {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidSysctl, name)
+	}
+
+	return nil
+}
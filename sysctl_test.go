@@ -0,0 +1,233 @@
+package mptcp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Sysctl files from a mainline kernel, used for testing
+var mainlineSysctls = map[string]string{
+	"enabled":                      "1",
+	"checksum_enabled":             "0",
+	"allow_join_initial_addr_port": "1",
+	"add_addr_timeout":             "120",
+	"stale_loss_cnt":               "4",
+	"pm_type":                      "0",
+	"scheduler":                    "default",
+	"available_schedulers":         "default bpf_first",
+}
+
+// Sysctl files from an out-of-tree kernel, used for testing
+var outOfTreeSysctls = map[string]string{
+	"mptcp_enabled":      "2",
+	"mptcp_checksum":     "1",
+	"mptcp_path_manager": "fullmesh",
+	"mptcp_scheduler":    "default",
+	"mptcp_syn_retries":  "3",
+}
+
+// TestSysctlFlavor verifies that Sysctl.Flavor detects each flavor of
+// multipath TCP.
+func TestSysctlFlavor(t *testing.T) {
+	var tests = []struct {
+		files  map[string]string
+		flavor Flavor
+	}{
+		{nil, FlavorNone},
+		{mainlineSysctls, FlavorMainline},
+		{outOfTreeSysctls, FlavorOutOfTree},
+	}
+
+	for i, test := range tests {
+		f, err := testSysctl(t, test.files).Flavor()
+		if err != nil {
+			t.Fatalf("[%02d] unexpected err: %v", i, err)
+		}
+
+		if f != test.flavor {
+			t.Fatalf("[%02d] unexpected flavor: %v != %v", i, f, test.flavor)
+		}
+	}
+}
+
+// TestSysctlRead verifies that Sysctl reads typed values from the sysctls of
+// each flavor of multipath TCP.
+func TestSysctlRead(t *testing.T) {
+	s := testSysctl(t, mainlineSysctls)
+
+	var (
+		enabled   = mustBool(t, s.Enabled)
+		checksum  = mustBool(t, s.ChecksumEnabled)
+		allowJoin = mustBool(t, s.AllowJoinInitialAddrPort)
+	)
+
+	if !enabled || checksum || !allowJoin {
+		t.Fatalf("unexpected mainline booleans: enabled: %v, checksum: %v, allow join: %v",
+			enabled, checksum, allowJoin)
+	}
+
+	if d, err := s.AddAddrTimeout(); err != nil || d != 2*time.Minute {
+		t.Fatalf("unexpected ADD_ADDR timeout: (%v, %v)", d, err)
+	}
+	if n, err := s.StaleLossCount(); err != nil || n != 4 {
+		t.Fatalf("unexpected stale loss count: (%v, %v)", n, err)
+	}
+	if pm, err := s.PMType(); err != nil || pm != PMTypeKernel {
+		t.Fatalf("unexpected path manager type: (%v, %v)", pm, err)
+	}
+	if sched, err := s.Scheduler(); err != nil || sched != "default" {
+		t.Fatalf("unexpected scheduler: (%v, %v)", sched, err)
+	}
+
+	available, err := s.AvailableSchedulers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"default", "bpf_first"}; !reflect.DeepEqual(available, want) {
+		t.Fatalf("unexpected available schedulers: %v != %v", available, want)
+	}
+
+	// Out-of-tree sysctls do not exist on mainline kernels
+	if _, err := s.PathManager(); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error for path manager, but got: %v", err)
+	}
+
+	s = testSysctl(t, outOfTreeSysctls)
+
+	// mptcp_enabled=2 is still considered enabled
+	if !mustBool(t, s.Enabled) || !mustBool(t, s.ChecksumEnabled) {
+		t.Fatal("expected out-of-tree multipath TCP and checksums to be enabled")
+	}
+
+	if pm, err := s.PathManager(); err != nil || pm != "fullmesh" {
+		t.Fatalf("unexpected path manager: (%v, %v)", pm, err)
+	}
+	if sched, err := s.Scheduler(); err != nil || sched != "default" {
+		t.Fatalf("unexpected scheduler: (%v, %v)", sched, err)
+	}
+	if n, err := s.SynRetries(); err != nil || n != 3 {
+		t.Fatalf("unexpected SYN retries: (%v, %v)", n, err)
+	}
+
+	// Mainline sysctls do not exist on out-of-tree kernels
+	if _, err := s.PMType(); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error for path manager type, but got: %v", err)
+	}
+
+	// No sysctls exist without multipath TCP
+	if _, err := testSysctl(t, nil).Enabled(); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error for enabled, but got: %v", err)
+	}
+}
+
+// TestSysctlWrite verifies that Sysctl writes valid values to the sysctls of
+// each flavor of multipath TCP, and rejects invalid values.
+func TestSysctlWrite(t *testing.T) {
+	s := testSysctl(t, mainlineSysctls)
+
+	var tests = []struct {
+		desc  string
+		set   func() error
+		name  string
+		value string
+		err   error
+	}{
+		{"enabled", func() error { return s.SetEnabled(false) }, "enabled", "0", nil},
+		{"checksum", func() error { return s.SetChecksumEnabled(true) }, "checksum_enabled", "1", nil},
+		{"allow join", func() error { return s.SetAllowJoinInitialAddrPort(false) }, "allow_join_initial_addr_port", "0", nil},
+		{"timeout", func() error { return s.SetAddAddrTimeout(30 * time.Second) }, "add_addr_timeout", "30", nil},
+		{"stale loss count", func() error { return s.SetStaleLossCount(8) }, "stale_loss_cnt", "8", nil},
+		{"path manager type", func() error { return s.SetPMType(PMTypeUserspace) }, "pm_type", "1", nil},
+		{"scheduler", func() error { return s.SetScheduler("bpf_first") }, "scheduler", "bpf_first", nil},
+
+		{"bad timeout", func() error { return s.SetAddAddrTimeout(1500 * time.Millisecond) }, "", "", ErrInvalidSysctl},
+		{"zero timeout", func() error { return s.SetAddAddrTimeout(0) }, "", "", ErrInvalidSysctl},
+		{"bad stale loss count", func() error { return s.SetStaleLossCount(0) }, "", "", ErrInvalidSysctl},
+		{"bad path manager type", func() error { return s.SetPMType(2) }, "", "", ErrInvalidSysctl},
+		{"unavailable scheduler", func() error { return s.SetScheduler("redundant") }, "", "", ErrInvalidSysctl},
+		{"bad scheduler", func() error { return s.SetScheduler("foo bar") }, "", "", ErrInvalidSysctl},
+	}
+
+	for i, test := range tests {
+		if err := test.set(); !errors.Is(err, test.err) {
+			t.Fatalf("[%02d] %s: unexpected err: %v != %v", i, test.desc, err, test.err)
+		}
+		if test.err != nil {
+			continue
+		}
+
+		if v := readTestSysctl(t, s, test.name); v != test.value {
+			t.Fatalf("[%02d] %s: unexpected value: %q != %q", i, test.desc, v, test.value)
+		}
+	}
+
+	s = testSysctl(t, outOfTreeSysctls)
+
+	if err := s.SetPathManager("ndiffports"); err != nil {
+		t.Fatal(err)
+	}
+	if v := readTestSysctl(t, s, "mptcp_path_manager"); v != "ndiffports" {
+		t.Fatalf("unexpected path manager: %q", v)
+	}
+
+	// Out-of-tree schedulers are not validated against a list
+	if err := s.SetScheduler("redundant"); err != nil {
+		t.Fatal(err)
+	}
+	if v := readTestSysctl(t, s, "mptcp_scheduler"); v != "redundant" {
+		t.Fatalf("unexpected scheduler: %q", v)
+	}
+
+	if err := s.SetSynRetries(-1); !errors.Is(err, ErrInvalidSysctl) {
+		t.Fatalf("unexpected SYN retries err: %v", err)
+	}
+
+	// Sysctls cannot be created
+	if err := s.SetPMType(PMTypeKernel); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error for path manager type, but got: %v", err)
+	}
+}
+
+// testSysctl creates a Sysctl which uses a temporary directory populated with
+// the input files.
+func testSysctl(t *testing.T, files map[string]string) *Sysctl {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, v := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(v+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &Sysctl{root: root}
+}
+
+// readTestSysctl reads a sysctl written by a Sysctl created by testSysctl.
+func readTestSysctl(t *testing.T, s *Sysctl, name string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(s.root, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+// mustBool calls fn, and fails the test if it returns an error.
+func mustBool(t *testing.T, fn func() (bool, error)) bool {
+	t.Helper()
+
+	v, err := fn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
@@ -0,0 +1,106 @@
+package mptcp
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// waitPoll is the interval at which WaitSubflows polls a connection
+	// when path manager events are unavailable.
+	waitPoll = 100 * time.Millisecond
+
+	// waitPollEvents is the interval at which WaitSubflows polls a
+	// connection when path manager events are available, in case events
+	// are lost.
+	waitPollEvents = time.Second
+)
+
+var (
+	// ErrFallback is returned by WaitSubflows when the connection has
+	// fallen back to regular TCP, and will never have more than one
+	// subflow.
+	ErrFallback = errors.New("connection fell back to regular TCP")
+
+	// ErrNoSubflows is returned by WaitSubflows when a connection has no
+	// established subflows, because it has been closed by the peer.
+	ErrNoSubflows = errors.New("connection has no established subflows")
+)
+
+// WaitSubflows blocks until the connection has at least n established
+// subflows, including the initial subflow.  On Linux, additional subflows
+// are only created once the connection is fully established, which may
+// require data to be exchanged with the peer.
+//
+// WaitSubflows returns ErrFallback if the connection falls back to regular
+// TCP, ErrNoSubflows if the peer closes all of the connection's subflows, an
+// error if the connection is closed, or the context's error if ctx is
+// canceled before n subflows are established.
+//
+// On Linux, WaitSubflows is notified of new subflows by path manager events
+// when the caller has the CAP_NET_ADMIN capability, and otherwise polls the
+// connection.  If waiting for subflows is not implemented for the current
+// operating system, WaitSubflows will return ErrNotImplemented.
+func (c *Conn) WaitSubflows(ctx context.Context, n int) error {
+	info, err := c.Info()
+	if err != nil {
+		return err
+	}
+	if info.Fallback {
+		return ErrFallback
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Subscribe before the first check, so that no subflows can be
+	// established unnoticed in between.
+	events, err := subflowEvents(ctx, info.Token)
+	if err != nil {
+		events = nil
+	}
+
+	return waitSubflows(ctx, n, events, func() (int, error) {
+		return connEstablished(c.tc)
+	})
+}
+
+// waitSubflows waits until established reports at least n subflows.  It
+// checks again whenever a value is received on events, and otherwise polls
+// periodically.
+func waitSubflows(ctx context.Context, n int, events <-chan struct{}, established func() (int, error)) error {
+	poll := waitPoll
+	if events != nil {
+		poll = waitPollEvents
+	}
+
+	t := time.NewTicker(poll)
+	defer t.Stop()
+
+	for {
+		got, err := established()
+		if err != nil {
+			return err
+		}
+		if got >= n {
+			return nil
+		}
+		if got == 0 {
+			return ErrNoSubflows
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		case _, ok := <-events:
+			if !ok {
+				// Events are no longer available, so poll more
+				// frequently instead.
+				events = nil
+				t.Reset(waitPoll)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// +build linux
+
+package mptcp
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/mptcp/internal/pmevents"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// connEstablished returns the number of established subflows of the MPTCP
+// socket of c, or ErrFallback if the connection has fallen back to regular
+// TCP.
+func connEstablished(c *net.TCPConn) (int, error) {
+	ok, err := c.MultipathTCP()
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrFallback
+	}
+
+	infos, err := subflowData(c, sockoptTCPInfo, tcpInfoLen)
+	if err != nil {
+		// The connection may fall back between the two requests.
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOPROTOOPT) {
+			return 0, ErrFallback
+		}
+
+		return 0, err
+	}
+
+	// The first byte of tcp_info is the subflow's TCP state.
+	var n int
+	for _, b := range infos {
+		if State(b[0]) == StateEstablished {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// subflowEvents subscribes to path manager events, and sends a value on the
+// returned channel whenever a subflow of the connection with the specified
+// token may have been established or closed.  The channel is closed when ctx
+// is canceled or events can no longer be received.
+//
+// Joining the event multicast group requires the CAP_NET_ADMIN capability.
+func subflowEvents(ctx context.Context, token Token) (<-chan struct{}, error) {
+	c, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.GetFamily(pmevents.Family)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	stop, err := pmevents.Subscribe(ctx, c, f)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer func() {
+			stop()
+			_ = c.Close()
+			close(ch)
+		}()
+
+		for {
+			msgs, _, err := c.Receive()
+			switch {
+			case errors.Is(err, unix.ENOBUFS):
+				// Events may have been dropped, so the connection
+				// must be checked again.
+			case err != nil:
+				return
+			case !subflowEvent(msgs, token):
+				continue
+			}
+
+			// Coalesce notifications which have not been observed.
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// subflowEvent reports whether msgs contain an event which may change the
+// number of established subflows of the connection with token.
+func subflowEvent(msgs []genetlink.Message, token Token) bool {
+	for _, m := range msgs {
+		switch m.Header.Command {
+		case pmevents.Established, pmevents.Closed, pmevents.SubflowEstablished, pmevents.SubflowClosed:
+		default:
+			continue
+		}
+
+		ad, err := netlink.NewAttributeDecoder(m.Data)
+		if err != nil {
+			continue
+		}
+
+		for ad.Next() {
+			if ad.Type() == pmevents.AttrToken && Token(ad.Uint32()) == token {
+				return true
+			}
+		}
+	}
+
+	return false
+}
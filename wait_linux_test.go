@@ -0,0 +1,138 @@
+// +build linux
+
+package mptcp
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/mptcp/internal/pmevents"
+	"github.com/mdlayher/netlink"
+)
+
+// TestLinux_subflowEvent verifies that subflowEvent only matches events
+// which affect the subflows of a connection with the specified token.
+func TestLinux_subflowEvent(t *testing.T) {
+	const token Token = 0x9c290bf6
+
+	msg := func(cmd uint8, tok Token) genetlink.Message {
+		ae := netlink.NewAttributeEncoder()
+		ae.Uint32(pmevents.AttrToken, uint32(tok))
+		b, err := ae.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return genetlink.Message{
+			Header: genetlink.Header{Command: cmd},
+			Data:   b,
+		}
+	}
+
+	var tests = []struct {
+		desc string
+		msgs []genetlink.Message
+		ok   bool
+	}{
+		{
+			desc: "no messages",
+		},
+		{
+			desc: "other token",
+			msgs: []genetlink.Message{msg(pmevents.SubflowEstablished, 1)},
+		},
+		{
+			desc: "other event",
+			msgs: []genetlink.Message{msg(6, token)},
+		},
+		{
+			desc: "subflow established",
+			msgs: []genetlink.Message{msg(pmevents.SubflowEstablished, token)},
+			ok:   true,
+		},
+		{
+			desc: "subflow closed after other token",
+			msgs: []genetlink.Message{
+				msg(pmevents.SubflowClosed, 1),
+				msg(pmevents.SubflowClosed, token),
+			},
+			ok: true,
+		},
+		{
+			desc: "connection closed",
+			msgs: []genetlink.Message{msg(pmevents.Closed, token)},
+			ok:   true,
+		},
+	}
+
+	for i, test := range tests {
+		if ok := subflowEvent(test.msgs, token); ok != test.ok {
+			t.Fatalf("[%02d] test %q, unexpected result: %v != %v",
+				i, test.desc, test.ok, ok)
+		}
+	}
+}
+
+// TestLinux_ConnWaitSubflows verifies that WaitSubflows returns for an
+// established connection, and returns errors for connections which fall
+// back or are closed.
+func TestLinux_ConnWaitSubflows(t *testing.T) {
+	if ok, _ := Enabled(); !ok {
+		t.Skip("skipping, multipath TCP is not enabled")
+	}
+
+	ml, err := Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ml.Close()
+
+	go func() {
+		c, err := ml.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		_, _ = io.Copy(io.Discard, c)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial("tcp4", ml.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fallback, _ := c.IsFallback(); fallback {
+		t.Skip("skipping, connection fell back to TCP")
+	}
+
+	if err := c.WaitSubflows(ctx, 1); err != nil {
+		t.Fatalf("failed to wait for initial subflow: %v", err)
+	}
+
+	_ = c.Close()
+	if err := c.WaitSubflows(ctx, 1); err == nil {
+		t.Fatal("expected error for closed connection")
+	}
+
+	// Connect to a regular TCP listener
+	tl := listenTCP(t)
+	defer tl.Close()
+	go serveDiscard(tl)
+
+	tc, err := Dial("tcp4", tl.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Close()
+
+	if err := tc.WaitSubflows(ctx, 2); err != ErrFallback {
+		t.Fatalf("expected ErrFallback, but got: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+// +build !linux
+
+package mptcp
+
+import (
+	"context"
+	"net"
+)
+
+// connEstablished is not currently implemented on non-Linux platforms.
+func connEstablished(_ *net.TCPConn) (int, error) {
+	return 0, ErrNotImplemented
+}
+
+// subflowEvents is not currently implemented on non-Linux platforms.
+func subflowEvents(_ context.Context, _ Token) (<-chan struct{}, error) {
+	return nil, ErrNotImplemented
+}
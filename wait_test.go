@@ -0,0 +1,113 @@
+package mptcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestWaitSubflows verifies that waitSubflows returns once enough subflows
+// are established, or when the connection can no longer gain subflows.
+func TestWaitSubflows(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	// counts returns a function which reports each count in turn, and then
+	// repeats the final count.
+	counts := func(ns ...int) func() (int, error) {
+		return func() (int, error) {
+			n := ns[0]
+			if len(ns) > 1 {
+				ns = ns[1:]
+			}
+			return n, nil
+		}
+	}
+
+	var tests = []struct {
+		desc        string
+		n           int
+		established func() (int, error)
+		events      int
+		err         error
+	}{
+		{
+			desc:        "already established",
+			n:           2,
+			established: counts(2),
+		},
+		{
+			desc:        "established after events",
+			n:           3,
+			established: counts(1, 2, 3),
+			events:      2,
+		},
+		{
+			desc:        "no subflows",
+			n:           2,
+			established: counts(1, 0),
+			events:      1,
+			err:         ErrNoSubflows,
+		},
+		{
+			desc: "fallback",
+			n:    2,
+			established: func() (int, error) {
+				return 0, ErrFallback
+			},
+			err: ErrFallback,
+		},
+		{
+			desc: "error",
+			n:    2,
+			established: func() (int, error) {
+				return 0, errBoom
+			},
+			err: errBoom,
+		},
+	}
+
+	for i, test := range tests {
+		events := make(chan struct{}, test.events)
+		for j := 0; j < test.events; j++ {
+			events <- struct{}{}
+		}
+
+		// Polling must not be needed, so any wait longer than the
+		// events is a failure.
+		ctx, cancel := context.WithTimeout(context.Background(), waitPoll/2)
+		err := waitSubflows(ctx, test.n, events, test.established)
+		cancel()
+
+		if err != test.err {
+			t.Fatalf("[%02d] test %q, unexpected error: %v != %v",
+				i, test.desc, err, test.err)
+		}
+	}
+}
+
+// TestWaitSubflowsPoll verifies that waitSubflows polls when events are no
+// longer available, and returns when its context is canceled.
+func TestWaitSubflowsPoll(t *testing.T) {
+	events := make(chan struct{})
+	close(events)
+
+	var calls int
+	established := func() (int, error) {
+		calls++
+		return 1, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitPoll*5/2)
+	defer cancel()
+
+	start := time.Now()
+	if err := waitSubflows(ctx, 2, events, established); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// One check after each of the closed events channel and two polls.
+	if calls < 3 {
+		t.Fatalf("expected at least 3 checks in %v, but got %d", time.Since(start), calls)
+	}
+}
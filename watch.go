@@ -0,0 +1,233 @@
+package mptcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// An EventType is the type of an Event.
+type EventType int
+
+// Possible EventType values.
+const (
+	// EventOpened indicates a new connection.
+	EventOpened EventType = iota + 1
+
+	// EventClosed indicates a connection which no longer exists.
+	EventClosed
+
+	// EventSubflowCountChanged indicates a change in the number of
+	// subflows of a connection.
+	EventSubflowCountChanged
+
+	// EventStateChanged indicates a change in the TCP state of a
+	// connection.
+	EventStateChanged
+
+	// EventError indicates an error while listing connections.
+	EventError
+)
+
+// String returns the name of an EventType.
+func (t EventType) String() string {
+	switch t {
+	case EventOpened:
+		return "opened"
+	case EventClosed:
+		return "closed"
+	case EventSubflowCountChanged:
+		return "subflow_count_changed"
+	case EventStateChanged:
+		return "state_changed"
+	case EventError:
+		return "error"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// An Event is a change in the multipath TCP connections of a host, found by
+// comparing two snapshots of its connections.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+
+	// Time is the time at which the snapshot containing the change was
+	// taken.  Time is zero for events returned by Diff.
+	Time time.Time
+
+	// Entry is the connection after the change.  For EventClosed, it is
+	// the last known state of the connection.
+	Entry Entry
+
+	// Previous is the connection before the change, for
+	// EventSubflowCountChanged and EventStateChanged.
+	Previous *Entry
+
+	// Err is the error which occurred, for EventError.
+	Err error
+}
+
+// Diff compares two snapshots of connections, such as those returned by
+// Entries, and returns the Events which describe the changes from old to new.
+//
+// Connections are matched by their local token.  A token which identifies a
+// connection with different addresses in each snapshot has been reused, and
+// is reported as a closed and an opened connection.  EventClosed events are
+// returned first, followed by other events in the order of new.
+func Diff(old, new []Entry) []Event {
+	olds, before := indexEntries(old)
+	news, after := indexEntries(new)
+
+	var events []Event
+	for _, o := range olds {
+		if n, ok := after[o.LocalToken]; !ok || !sameConn(o, n) {
+			events = append(events, Event{Type: EventClosed, Entry: o})
+		}
+	}
+
+	for _, n := range news {
+		o, ok := before[n.LocalToken]
+		if !ok || !sameConn(o, n) {
+			events = append(events, Event{Type: EventOpened, Entry: n})
+			continue
+		}
+
+		prev := o
+		if o.State != n.State {
+			events = append(events, Event{Type: EventStateChanged, Entry: n, Previous: &prev})
+		}
+		if o.Subflows != n.Subflows {
+			events = append(events, Event{Type: EventSubflowCountChanged, Entry: n, Previous: &prev})
+		}
+	}
+
+	return events
+}
+
+// indexEntries returns es without entries whose token was already seen, and
+// a map of those entries by token.
+func indexEntries(es []Entry) ([]Entry, map[Token]Entry) {
+	var (
+		unique = make([]Entry, 0, len(es))
+		m      = make(map[Token]Entry, len(es))
+	)
+
+	for _, e := range es {
+		if _, ok := m[e.LocalToken]; ok {
+			continue
+		}
+
+		m[e.LocalToken] = e
+		unique = append(unique, e)
+	}
+
+	return unique, m
+}
+
+// sameConn reports whether a and b have the same addresses, and so are the
+// same connection.
+func sameConn(a, b Entry) bool {
+	return a.Local.String() == b.Local.String() && a.Remote.String() == b.Remote.String()
+}
+
+// A Watcher watches for changes in the multipath TCP connections of a host,
+// by periodically comparing snapshots of its connections using Diff.  This
+// works on any kernel, including those with out-of-tree multipath TCP
+// support, which do not provide path manager events.
+type Watcher struct {
+	// Interval is the time between snapshots.
+	Interval time.Duration
+
+	// Entries takes a snapshot of connections.  If nil, the package-level
+	// Entries function is used.
+	Entries func() ([]Entry, error)
+}
+
+// Watch watches for changes in the multipath TCP connections of the current
+// host, taking a snapshot each interval.  It is equivalent to using a Watcher
+// with the specified interval.
+func Watch(ctx context.Context, interval time.Duration) (<-chan Event, error) {
+	w := Watcher{Interval: interval}
+	return w.Watch(ctx)
+}
+
+// Watch takes an initial snapshot of connections, and then sends Events on
+// the returned channel as connections change, until ctx is canceled.  The
+// channel is closed when Watch stops.
+//
+// The initial snapshot is compared with an empty snapshot, so EventOpened is
+// sent for each existing connection.  If a later snapshot fails, EventError
+// is sent and Watch tries again after the next interval.  If the Entries
+// function returns io.EOF, there are no more snapshots, so Watch stops and
+// closes the channel; this allows a finite sequence of snapshots, such as a
+// recorded log, to be watched.
+func (w *Watcher) Watch(ctx context.Context) (<-chan Event, error) {
+	if w.Interval <= 0 {
+		return nil, fmt.Errorf("mptcp: invalid watch interval %v", w.Interval)
+	}
+
+	entries := w.Entries
+	if entries == nil {
+		entries = Entries
+	}
+
+	// Errors for the initial snapshot are returned immediately, as they
+	// likely indicate the snapshot cannot be taken at all.
+	cur, err := entries()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+
+		send := func(e Event) bool {
+			select {
+			case ch <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		t := time.NewTicker(w.Interval)
+		defer t.Stop()
+
+		var prev []Entry
+		now := time.Now()
+		for {
+			for _, e := range Diff(prev, cur) {
+				e.Time = now
+				if !send(e) {
+					return
+				}
+			}
+			prev = cur
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now = <-t.C:
+				}
+
+				cur, err = entries()
+				if err == nil {
+					break
+				}
+				if err == io.EOF {
+					return
+				}
+				if !send(Event{Type: EventError, Time: now, Err: err}) {
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,222 @@
+package mptcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testEntry creates an Entry for tests.
+func testEntry(tok Token, port int, state State, subflows int) Entry {
+	return Entry{
+		LocalToken: tok,
+		Local:      &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: port},
+		Remote:     &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 443},
+		State:      state,
+		Subflows:   subflows,
+	}
+}
+
+// TestDiff verifies that Diff reports the changes between two snapshots of
+// connections.
+func TestDiff(t *testing.T) {
+	var (
+		a  = testEntry(1, 50000, StateEstablished, 1)
+		b  = testEntry(2, 50001, StateEstablished, 2)
+		c  = testEntry(3, 50002, StateEstablished, 1)
+		a2 = testEntry(1, 50000, StateEstablished, 2)
+		a3 = testEntry(1, 50000, StateFinWait1, 3)
+		b2 = testEntry(2, 50003, StateEstablished, 2)
+	)
+
+	var tests = []struct {
+		desc     string
+		old, new []Entry
+		events   []Event
+	}{
+		{
+			desc: "empty",
+		},
+		{
+			desc: "unchanged",
+			old:  []Entry{a, b},
+			new:  []Entry{b, a},
+		},
+		{
+			desc:   "opened",
+			new:    []Entry{a, b},
+			events: []Event{{Type: EventOpened, Entry: a}, {Type: EventOpened, Entry: b}},
+		},
+		{
+			desc:   "closed",
+			old:    []Entry{a, b},
+			new:    []Entry{b},
+			events: []Event{{Type: EventClosed, Entry: a}},
+		},
+		{
+			desc:   "subflows",
+			old:    []Entry{a},
+			new:    []Entry{a2},
+			events: []Event{{Type: EventSubflowCountChanged, Entry: a2, Previous: &a}},
+		},
+		{
+			desc: "state and subflows",
+			old:  []Entry{a},
+			new:  []Entry{a3},
+			events: []Event{
+				{Type: EventStateChanged, Entry: a3, Previous: &a},
+				{Type: EventSubflowCountChanged, Entry: a3, Previous: &a},
+			},
+		},
+		{
+			desc: "token reused",
+			old:  []Entry{b},
+			new:  []Entry{b2},
+			events: []Event{
+				{Type: EventClosed, Entry: b},
+				{Type: EventOpened, Entry: b2},
+			},
+		},
+		{
+			desc: "closed before opened",
+			old:  []Entry{a, b},
+			new:  []Entry{c, a2},
+			events: []Event{
+				{Type: EventClosed, Entry: b},
+				{Type: EventOpened, Entry: c},
+				{Type: EventSubflowCountChanged, Entry: a2, Previous: &a},
+			},
+		},
+		{
+			desc:   "duplicate tokens",
+			new:    []Entry{a, a2},
+			events: []Event{{Type: EventOpened, Entry: a}},
+		},
+	}
+
+	for i, test := range tests {
+		events := Diff(test.old, test.new)
+		if !reflect.DeepEqual(test.events, events) {
+			t.Fatalf("[%02d] test %q, unexpected events:\n- want: %+v\n-  got: %+v",
+				i, test.desc, test.events, events)
+		}
+	}
+}
+
+// TestWatcherWatch verifies that a Watcher sends events for each change in
+// successive snapshots, and reports errors without stopping.
+func TestWatcherWatch(t *testing.T) {
+	var (
+		a       = testEntry(1, 50000, StateEstablished, 1)
+		a2      = testEntry(1, 50000, StateEstablished, 2)
+		errBoom = errors.New("boom")
+	)
+
+	snapshots := []struct {
+		entries []Entry
+		err     error
+	}{
+		{entries: []Entry{a}},
+		{entries: []Entry{a}},
+		{err: errBoom},
+		{entries: []Entry{a2}},
+		{entries: nil},
+	}
+
+	var i int
+	w := &Watcher{
+		Interval: time.Millisecond,
+		Entries: func() ([]Entry, error) {
+			if i >= len(snapshots) {
+				return nil, nil
+			}
+
+			s := snapshots[i]
+			i++
+			return s.entries, s.err
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EventType{EventOpened, EventError, EventSubflowCountChanged, EventClosed}
+	for j, typ := range want {
+		e := <-events
+		if e.Type != typ {
+			t.Fatalf("[%02d] unexpected event type: %v != %v", j, typ, e.Type)
+		}
+		if e.Time.IsZero() {
+			t.Fatalf("[%02d] event has no time", j)
+		}
+		if typ == EventError && e.Err != errBoom {
+			t.Fatalf("[%02d] unexpected error: %v", j, e.Err)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+// TestWatcherWatchEOF verifies that a Watcher stops and closes its channel
+// when there are no more snapshots.
+func TestWatcherWatchEOF(t *testing.T) {
+	a := testEntry(1, 50000, StateEstablished, 1)
+
+	var i int
+	w := &Watcher{
+		Interval: time.Millisecond,
+		Entries: func() ([]Entry, error) {
+			i++
+			if i > 2 {
+				return nil, io.EOF
+			}
+
+			return []Entry{a}, nil
+		},
+	}
+
+	events, err := w.Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []EventType
+	for e := range events {
+		got = append(got, e.Type)
+	}
+
+	if want := []EventType{EventOpened}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected event types:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+// TestWatcherWatchErrors verifies that Watch returns errors for an invalid
+// interval, or when the initial snapshot fails.
+func TestWatcherWatchErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	if _, err := Watch(context.Background(), 0); err == nil {
+		t.Fatal("expected error for zero interval")
+	}
+
+	w := &Watcher{
+		Interval: time.Second,
+		Entries: func() ([]Entry, error) {
+			return nil, errBoom
+		},
+	}
+	if _, err := w.Watch(context.Background()); err != errBoom {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}